@@ -50,7 +50,7 @@ func main() {
 		panic("no device found")
 	}
 
-	engine, engineCreationError := arp_scanner.NewEngine(targetDevice, script)
+	engine, engineCreationError := arp_scanner.NewEngine(targetDevice, "", "", script, arp_scanner.Options{})
 	if engineCreationError != nil {
 		panic(engineCreationError)
 	}
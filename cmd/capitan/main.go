@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"github.com/shoriwe/CAPitan/internal/data/memory"
 	"github.com/shoriwe/CAPitan/internal/logs"
+	"github.com/shoriwe/CAPitan/internal/oui"
 	"github.com/shoriwe/CAPitan/internal/web"
 	"log"
-	"net/http"
 	"os"
 )
 
@@ -28,18 +28,29 @@ func generalHelp() {
 
 func handleMemoryCommand() {
 	var host string = "127.0.0.1:8080"
+	var ouiFile string
 	if len(os.Args) > 2 {
 		flagSet := flag.NewFlagSet("memory", flag.ExitOnError)
 		flagSet.StringVar(&host, "host", "127.0.0.1:8080", "Host to listen on")
+		flagSet.StringVar(&ouiFile, "oui", "", "IEEE oui.csv or oui.txt with the MAC vendors, replaces the embedded ones")
 		parseError := flagSet.Parse(os.Args[2:])
 		if parseError != nil {
 			printError(parseError)
 		}
 	}
+	if len(ouiFile) > 0 {
+		_, loadError := oui.LoadFile(ouiFile)
+		if loadError != nil {
+			printError(loadError)
+		}
+	}
 	dataController := memory.NewInMemoryDB()
 	logger := logs.NewLogger(os.Stderr)
-	handler := web.NewServerMux(dataController, logger)
-	log.Fatal(http.ListenAndServe(host, handler))
+	handler, jobScheduler := web.NewServerMux(dataController, logger)
+	serveError := web.Serve(host, handler, jobScheduler)
+	if serveError != nil {
+		log.Fatal(serveError)
+	}
 }
 
 func main() {
@@ -39,7 +39,7 @@ func main() {
 	if targetDevice == "" {
 		panic("no device found")
 	}
-	engine, engineCreationError := capture.NewEngineWithInterface(targetDevice)
+	engine, engineCreationError := capture.NewEngineWithInterface(targetDevice, "")
 	if engineCreationError != nil {
 		panic(engineCreationError)
 	}
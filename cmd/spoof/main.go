@@ -26,7 +26,7 @@ func main() {
 		panic("no device found")
 	}
 
-	engine, engineCreationError := spoof.NewEngine("192.168.1.84", "192.168.1.1", targetDevice)
+	engine, _, engineCreationError := spoof.NewEngine([]net.IP{net.ParseIP("192.168.1.84")}, "192.168.1.1", targetDevice, spoof.ReplyMode, false)
 	if engineCreationError != nil {
 		panic(engineCreationError)
 	}
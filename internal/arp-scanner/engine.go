@@ -3,6 +3,7 @@ package arp_scanner
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
@@ -13,17 +14,61 @@ import (
 	"github.com/shoriwe/gplasma/pkg/std/modules/json"
 	"github.com/shoriwe/gplasma/pkg/std/modules/regex"
 	"github.com/shoriwe/gplasma/pkg/vm"
+	"math/rand"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Limits of the scans, the rate is in requests per second and the timeout is the time waited for the answer of
+// every request
+const (
+	DefaultRate    = 100
+	MaximumRate    = 10000
+	MaximumRetries = 10
+	DefaultTimeout = time.Second
+	MaximumTimeout = 10 * time.Second
+)
+
+// Options controls the pace of a scan, hosts that do not answer are asked again Retries times.
+// Method selects how the hosts are discovered, the ports are the ones probed by the TCP and UDP methods and the
+// gateway is the router the probes to other subnets are sent through
+type Options struct {
+	Rate    uint
+	Retries uint
+	Timeout time.Duration
+	Method  string
+	Ports   []uint16
+	Gateway string
+}
+
+// Progress counts the hosts requested, the ones that answered and the number of hosts of the scan.
+// Total is zero while a script generator still has hosts
+type Progress struct {
+	Sent     uint64
+	Answered uint64
+	Total    uint64
+}
+
+type probe struct {
+	attempts uint
+	lastSent time.Time
+}
+
+// Host is a discovered host, Source is the evidence that revealed it and Hostname the name it announced, when the
+// evidence carries one
 type Host struct {
-	IP  net.IP
-	MAC net.HardwareAddr
+	IP       net.IP
+	MAC      net.HardwareAddr
+	Hostname string
+	Source   string
 }
 
 type Engine struct {
 	iFaceMac       net.HardwareAddr
+	iFaceIP        net.IP
+	iFaceIPv6      net.IP
 	handle         *pcap.Handle
 	Hosts          chan Host
 	ErrorChannel   chan error
@@ -33,6 +78,136 @@ type Engine struct {
 	hostGenerator  func() (bool, string, error)
 	VirtualMachine *gplasma.VirtualMachine
 	masterContext  *vm.Context
+	options        Options
+	// Done is closed when every host answered or ran out of retries
+	Done      chan struct{}
+	mutex     *sync.Mutex
+	pending   map[string]*probe
+	answered  map[string]struct{}
+	sent      uint64
+	total     uint64
+	exhausted bool
+	// packets counts the requests and probes sent and the packets received that revealed a host
+	packets uint64
+	// State of the IP discovery methods
+	gatewayIP    net.IP
+	gatewayMAC   net.HardwareAddr
+	gatewayProbe probe
+	probeId      uint16
+	sourcePort   uint16
+	// State of the passive method
+	subnet *net.IPNet
+	filter *Targets
+}
+
+// Progress returns the current counters of the scan
+func (engine *Engine) Progress() Progress {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	result := Progress{
+		Sent:     engine.sent,
+		Answered: uint64(len(engine.answered)),
+		Total:    engine.total,
+	}
+	if result.Total == 0 && engine.exhausted {
+		result.Total = engine.sent
+	}
+	return result
+}
+
+// Packets returns the number of requests and probes sent and of packets received that revealed a host
+func (engine *Engine) Packets() uint64 {
+	return atomic.LoadUint64(&engine.packets)
+}
+
+// writePacket sends the frame and counts it
+func (engine *Engine) writePacket(data []byte) error {
+	writeError := engine.handle.WritePacketData(data)
+	if writeError == nil {
+		atomic.AddUint64(&engine.packets, 1)
+	}
+	return writeError
+}
+
+// nextRequest returns the host to ask for next, the ones waiting for a retry go first. When there is nothing left
+// to send and no host is waiting for an answer, finished is true
+func (engine *Engine) nextRequest() (host string, finished bool, generatorError error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	now := time.Now()
+	for ip, p := range engine.pending {
+		if now.Sub(p.lastSent) < engine.options.Timeout {
+			continue
+		}
+		if p.attempts > engine.options.Retries {
+			delete(engine.pending, ip)
+			continue
+		}
+		if len(host) == 0 {
+			host = ip
+		}
+	}
+	if len(host) > 0 {
+		engine.pending[host].attempts++
+		engine.pending[host].lastSent = now
+		return host, false, nil
+	}
+	for !engine.exhausted {
+		hasNext, nextHost, callError := engine.hostGenerator()
+		if callError != nil {
+			return "", false, callError
+		}
+		if !hasNext {
+			engine.exhausted = true
+			break
+		}
+		ip := net.ParseIP(nextHost)
+		if ip == nil {
+			return "", false, errors.New("invalid IP provided")
+		}
+		host = ip.String()
+		if _, found := engine.pending[host]; found {
+			continue
+		}
+		if _, found := engine.answered[host]; found {
+			continue
+		}
+		engine.sent++
+		engine.pending[host] = &probe{
+			attempts: 1,
+			lastSent: now,
+		}
+		return host, false, nil
+	}
+	return "", len(engine.pending) == 0, nil
+}
+
+// gatewayAnswer keeps the MAC of the gateway when the ARP reply comes from it
+func (engine *Engine) gatewayAnswer(arp *layers.ARP) {
+	if engine.gatewayIP == nil || arp.Operation != layers.ARPReply || !net.IP(arp.SourceProtAddress).Equal(engine.gatewayIP) {
+		return
+	}
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	engine.gatewayMAC = arp.SourceHwAddress
+}
+
+// markObserved counts the hosts seen by the passive method
+func (engine *Engine) markObserved(ip net.IP) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	engine.answered[ip.String()] = struct{}{}
+}
+
+// markAnswered removes the host from the ones waiting for an answer
+func (engine *Engine) markAnswered(ip net.IP) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	host := ip.String()
+	if _, found := engine.pending[host]; found {
+		delete(engine.pending, host)
+		engine.answered[host] = struct{}{}
+	}
 }
 
 func (engine *Engine) arpRequest(host string) error {
@@ -40,6 +215,9 @@ func (engine *Engine) arpRequest(host string) error {
 	if ip == nil {
 		return errors.New("invalid IP provided")
 	}
+	if ip.To4() == nil {
+		return engine.neighborSolicitation(ip)
+	}
 
 	buf := gopacket.NewSerializeBuffer()
 	opts := gopacket.SerializeOptions{
@@ -51,32 +229,64 @@ func (engine *Engine) arpRequest(host string) error {
 	if serializationError != nil {
 		return serializationError
 	}
-	return engine.handle.WritePacketData(buf.Bytes())
+	return engine.writePacket(buf.Bytes())
+}
+
+// neighborSolicitation asks for the MAC of an IPv6 host through its solicited node multicast group
+func (engine *Engine) neighborSolicitation(ip net.IP) error {
+	if engine.iFaceIPv6 == nil {
+		return errors.New("the interface has no IPv6 address")
+	}
+	multicastIP, multicastMAC := tools.SolicitedNodeMulticast(ip)
+	solicitation, serializationError := tools.NeighborSolicitation(engine.iFaceMac, multicastMAC, engine.iFaceIPv6, multicastIP, ip)
+	if serializationError != nil {
+		return serializationError
+	}
+	return engine.writePacket(solicitation)
 }
 
 func (engine *Engine) sendPackets() {
 	defer tools.RecoverFromChannelClosedWhenWriting()
 
-	tick := time.Tick(time.Microsecond)
+	ticker := time.NewTicker(time.Second / time.Duration(engine.options.Rate))
+	defer ticker.Stop()
 	for {
 		select {
 		case <-engine.stopChannel:
 			// The other goroutine send the stop channel, no need to send a signal to it
 			return
-		case <-tick:
-			hasNext, nextHost, callError := engine.hostGenerator()
+		case <-ticker.C:
+			ready, gatewayError := engine.gatewayReady()
+			if gatewayError != nil {
+				engine.ErrorChannel <- gatewayError
+				engine.stopChannel <- true
+				return
+			}
+			if !ready {
+				continue
+			}
+			nextHost, finished, callError := engine.nextRequest()
 			if callError != nil {
 				engine.ErrorChannel <- callError
 				engine.stopChannel <- true
 				return
 			}
-			if !hasNext {
-				// Do not send the signal since we have not check if we received response from all
+			if finished {
+				close(engine.Done)
 				return
 			}
-			arpRequestError := engine.arpRequest(nextHost)
-			if arpRequestError != nil {
-				engine.ErrorChannel <- arpRequestError
+			if len(nextHost) == 0 {
+				// Waiting for the answers of the last hosts
+				continue
+			}
+			var requestError error
+			if engine.options.Method == ARPMethod {
+				requestError = engine.arpRequest(nextHost)
+			} else {
+				requestError = engine.ipProbe(nextHost)
+			}
+			if requestError != nil {
+				engine.ErrorChannel <- requestError
 				engine.stopChannel <- true
 				return
 			}
@@ -99,24 +309,76 @@ func (engine *Engine) scanPackets() {
 				engine.stopChannel <- true
 				return
 			}
+			if engine.options.Method == PassiveMethod {
+				observed := engine.observe(packet)
+				if len(observed) > 0 {
+					atomic.AddUint64(&engine.packets, 1)
+				}
+				for _, host := range observed {
+					if !engine.filter.Contains(host.IP) {
+						continue
+					}
+					engine.Hosts <- host
+					engine.markObserved(host.IP)
+				}
+				continue
+			}
 			arpLayer := packet.Layer(layers.LayerTypeARP)
+			if engine.options.Method != ARPMethod {
+				if arpLayer != nil {
+					engine.gatewayAnswer(arpLayer.(*layers.ARP))
+					continue
+				}
+				ip, mac, answered := engine.probeAnswer(packet)
+				if !answered {
+					continue
+				}
+				atomic.AddUint64(&engine.packets, 1)
+				engine.Hosts <- Host{
+					IP:     ip,
+					MAC:    mac,
+					Source: engine.options.Method,
+				}
+				engine.markAnswered(ip)
+				continue
+			}
 			if arpLayer == nil {
+				// IPv6 hosts are collected from the advertisements answering the solicitations and from the
+				// router and neighbor discovery traffic seen on the link
+				ip, mac, isNDP := tools.ParseNeighborDiscovery(packet)
+				if !isNDP || bytes.Equal(engine.iFaceMac, mac) {
+					continue
+				}
+				atomic.AddUint64(&engine.packets, 1)
+				engine.Hosts <- Host{
+					IP:     ip,
+					MAC:    mac,
+					Source: NDPSource,
+				}
+				engine.markAnswered(ip)
 				continue
 			}
 			arp := arpLayer.(*layers.ARP)
 			if arp.Operation != layers.ARPReply || bytes.Equal(engine.iFaceMac, arp.SourceHwAddress) {
 				continue
 			}
+			atomic.AddUint64(&engine.packets, 1)
 			engine.Hosts <- Host{
-				IP:  arp.SourceProtAddress,
-				MAC: arp.SourceHwAddress,
+				IP:     arp.SourceProtAddress,
+				MAC:    arp.SourceHwAddress,
+				Source: ARPSource,
 			}
+			// Marked after the host is queued, so every answer is in Hosts when Done is closed
+			engine.markAnswered(arp.SourceProtAddress)
 		}
 	}
 }
 
 func (engine *Engine) mainLoop() {
-	go engine.sendPackets()
+	// The passive method never sends anything, so it only finishes when it is stopped
+	if engine.options.Method != PassiveMethod {
+		go engine.sendPackets()
+	}
 	go engine.scanPackets()
 }
 
@@ -207,22 +469,98 @@ func (engine *Engine) loadVMFeatures() vm.Feature {
 	}
 }
 
-func NewEngine(iFace, scriptSource string) (*Engine, error) {
+// NewEngine prepares a scan of the hosts returned by the Plasma host generator of the script, or when no script is
+// provided, of the targets list. Without script nor targets the subnet of the interface is scanned.
+// The passive method does not accept scripts, its targets only filter the hosts seen on the interface.
+// The exclusions are applied in every case
+func NewEngine(iFace, targets, exclude, scriptSource string, options Options) (*Engine, error) {
+	if options.Rate == 0 {
+		options.Rate = DefaultRate
+	}
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.Rate > MaximumRate {
+		return nil, fmt.Errorf("rate must be between 1 and %d requests per second", MaximumRate)
+	}
+	if options.Retries > MaximumRetries {
+		return nil, fmt.Errorf("retries must be between 0 and %d", MaximumRetries)
+	}
+	if options.Timeout < 0 || options.Timeout > MaximumTimeout {
+		return nil, fmt.Errorf("timeout must be between 1 and %d milliseconds", MaximumTimeout.Milliseconds())
+	}
+	methodError := checkMethod(&options)
+	if methodError != nil {
+		return nil, methodError
+	}
+	var gatewayIP net.IP
+	if len(options.Gateway) > 0 {
+		gatewayIP = net.ParseIP(options.Gateway).To4()
+		if gatewayIP == nil {
+			return nil, errors.New("invalid gateway IP provided, it must be an IPv4 address")
+		}
+	}
+	useScript := len(scriptSource) > 0 && !tools.CheckFilledWithWhiteSpace.MatchString(scriptSource)
+	useTargets := len(targets) > 0 && !tools.CheckFilledWithWhiteSpace.MatchString(targets)
+	if useScript && useTargets {
+		return nil, errors.New("provide either a targets list or a host generator script")
+	}
+	if useScript && options.Method == PassiveMethod {
+		return nil, errors.New("the passive method only listens, host generator scripts are not supported")
+	}
+	var (
+		hosts       *Targets
+		targetError error
+	)
+	if useTargets {
+		hosts, targetError = ParseTargets(targets, exclude)
+	} else if options.Method == PassiveMethod {
+		// Every host seen is kept, except the excluded ones
+		hosts, targetError = ParseExclusions(exclude)
+	} else if !useScript {
+		subnet, subnetError := tools.FindInterfaceSubnet(iFace)
+		if subnetError != nil {
+			return nil, subnetError
+		}
+		hosts, targetError = SubnetTargets(subnet, exclude)
+	} else {
+		// Only the exclusions are used, the hosts come from the script
+		hosts, targetError = ParseExclusions(exclude)
+	}
+	if targetError != nil {
+		return nil, targetError
+	}
+
 	iFaceMac, deviceAddress, findError := tools.FindInterfaceIpAndMac(iFace)
 	if findError != nil {
 		return nil, findError
 	}
+	if options.Method != ARPMethod && options.Method != PassiveMethod && deviceAddress.To4() == nil {
+		return nil, errors.New("the interface has no IPv4 address to send the probes from")
+	}
+	// Without an IPv6 address only IPv4 hosts can be scanned
+	iFaceIPv6, _ := tools.FindInterfaceIPv6(iFace, iFaceMac)
 	handle, openHandleError := pcap.OpenLive(iFace, 65536, true, 0)
 	if openHandleError != nil {
 		return nil, openHandleError
 	}
 	engine := &Engine{
 		iFaceMac:      iFaceMac,
+		iFaceIP:       deviceAddress.To4(),
+		iFaceIPv6:     iFaceIPv6,
 		handle:        handle,
 		Hosts:         make(chan Host, 1000),
 		ErrorChannel:  make(chan error, 1),
 		stopChannel:   make(chan bool, 100),
 		hostGenerator: nil,
+		options:       options,
+		Done:          make(chan struct{}),
+		mutex:         new(sync.Mutex),
+		pending:       map[string]*probe{},
+		answered:      map[string]struct{}{},
+		gatewayIP:     gatewayIP,
+		probeId:       uint16(rand.Intn(1 << 16)),
+		sourcePort:    uint16(32768 + rand.Intn(28232)),
 		ethLayer: layers.Ethernet{
 			SrcMAC:       iFaceMac,
 			DstMAC:       net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // Send to the broadcast
@@ -253,6 +591,18 @@ func NewEngine(iFace, scriptSource string) (*Engine, error) {
 	engine.VirtualMachine.LoadFeature(importer.Result(&tools.SecuredFileSystem{}, &tools.SecuredFileSystem{}))
 	engine.masterContext = engine.VirtualMachine.NewContext()
 
+	if options.Method == PassiveMethod {
+		// Ordinary IP traffic only counts when it comes from the subnet of the interface
+		engine.subnet, _ = tools.FindInterfaceSubnet(iFace)
+		engine.filter = hosts
+		return engine, nil
+	}
+	if !useScript {
+		engine.hostGenerator = hosts.Next
+		engine.total = hosts.Count()
+		return engine, nil
+	}
+
 	// Load the script
 
 	executionResult, succeed := engine.VirtualMachine.ExecuteMain(scriptSource)
@@ -271,6 +621,18 @@ func NewEngine(iFace, scriptSource string) (*Engine, error) {
 	if engine.hostGenerator == nil {
 		return nil, errors.New("host generator never specified")
 	}
+	scriptGenerator := engine.hostGenerator
+	engine.hostGenerator = func() (bool, string, error) {
+		for {
+			hasNext, host, generatorError := scriptGenerator()
+			if !hasNext || generatorError != nil {
+				return hasNext, host, generatorError
+			}
+			if ip := net.ParseIP(host); ip == nil || !hosts.Excluded(ip) {
+				return true, host, nil
+			}
+		}
+	}
 
 	return engine, nil
 }
@@ -0,0 +1,224 @@
+package arp_scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Discovery methods, ARP and neighbor discovery only reach the local segment while the others are routed through
+// the gateway of the options
+const (
+	ARPMethod           = "arp"
+	ICMPEchoMethod      = "icmp-echo"
+	ICMPTimestampMethod = "icmp-timestamp"
+	TCPSYNMethod        = "tcp-syn"
+	TCPACKMethod        = "tcp-ack"
+	UDPMethod           = "udp"
+)
+
+// Ports probed by the TCP and UDP methods when none is provided
+var (
+	DefaultTCPPorts = []uint16{80, 443}
+	DefaultUDPPorts = []uint16{53}
+)
+
+var (
+	ethernetBroadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	ipOnlyIPv4        = errors.New("only IPv4 hosts can be discovered with this method, use arp for IPv6 neighbors")
+)
+
+// Methods lists the discovery methods in the order they are shown to the user
+func Methods() []string {
+	return []string{ARPMethod, ICMPEchoMethod, ICMPTimestampMethod, TCPSYNMethod, TCPACKMethod, UDPMethod, PassiveMethod}
+}
+
+func checkMethod(options *Options) error {
+	if len(options.Method) == 0 {
+		options.Method = ARPMethod
+	}
+	switch options.Method {
+	case ARPMethod, ICMPEchoMethod, ICMPTimestampMethod, PassiveMethod:
+		return nil
+	case TCPSYNMethod, TCPACKMethod:
+		if len(options.Ports) == 0 {
+			options.Ports = DefaultTCPPorts
+		}
+	case UDPMethod:
+		if len(options.Ports) == 0 {
+			options.Ports = DefaultUDPPorts
+		}
+	default:
+		return fmt.Errorf("unknown discovery method %q", options.Method)
+	}
+	for _, port := range options.Ports {
+		if port == 0 {
+			return errors.New("ports must be between 1 and 65535")
+		}
+	}
+	return nil
+}
+
+// destinationMAC returns the MAC the IP probes are sent to, the gateway one when the scan is routed
+func (engine *Engine) destinationMAC() net.HardwareAddr {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	if engine.gatewayMAC != nil {
+		return engine.gatewayMAC
+	}
+	return ethernetBroadcast
+}
+
+// gatewayReady asks for the MAC of the gateway until it answers, the IP probes wait for it
+func (engine *Engine) gatewayReady() (bool, error) {
+	if engine.gatewayIP == nil {
+		return true, nil
+	}
+	engine.mutex.Lock()
+	resolved := engine.gatewayMAC != nil
+	engine.mutex.Unlock()
+	if resolved {
+		return true, nil
+	}
+	if time.Since(engine.gatewayProbe.lastSent) < engine.options.Timeout {
+		return false, nil
+	}
+	if engine.gatewayProbe.attempts > engine.options.Retries {
+		return false, fmt.Errorf("gateway %s did not answer", engine.gatewayIP)
+	}
+	engine.gatewayProbe.attempts++
+	engine.gatewayProbe.lastSent = time.Now()
+	return false, engine.arpRequest(engine.gatewayIP.String())
+}
+
+// ipProbe sends the probes of the method to the host, one for every port of the TCP and UDP methods
+func (engine *Engine) ipProbe(host string) error {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.New("invalid IP provided")
+	}
+	if ip.To4() == nil {
+		return ipOnlyIPv4
+	}
+	ipLayer := &layers.IPv4{
+		Version: 4,
+		TTL:     64,
+		SrcIP:   engine.iFaceIP,
+		DstIP:   ip.To4(),
+	}
+	ethernetLayer := &layers.Ethernet{
+		SrcMAC:       engine.iFaceMac,
+		DstMAC:       engine.destinationMAC(),
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	var probes [][]gopacket.SerializableLayer
+	switch engine.options.Method {
+	case ICMPEchoMethod:
+		ipLayer.Protocol = layers.IPProtocolICMPv4
+		probes = append(probes, []gopacket.SerializableLayer{
+			&layers.ICMPv4{
+				TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+				Id:       engine.probeId,
+				Seq:      1,
+			},
+		})
+	case ICMPTimestampMethod:
+		ipLayer.Protocol = layers.IPProtocolICMPv4
+		probes = append(probes, []gopacket.SerializableLayer{
+			&layers.ICMPv4{
+				TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimestampRequest, 0),
+				Id:       engine.probeId,
+				Seq:      1,
+			},
+			// Originate, receive and transmit timestamps
+			gopacket.Payload(make([]byte, 12)),
+		})
+	case TCPSYNMethod, TCPACKMethod:
+		ipLayer.Protocol = layers.IPProtocolTCP
+		for _, port := range engine.options.Ports {
+			tcpLayer := &layers.TCP{
+				SrcPort: layers.TCPPort(engine.sourcePort),
+				DstPort: layers.TCPPort(port),
+				Seq:     rand.Uint32(),
+				SYN:     engine.options.Method == TCPSYNMethod,
+				ACK:     engine.options.Method == TCPACKMethod,
+				Window:  1024,
+			}
+			if tcpLayer.ACK {
+				tcpLayer.Ack = rand.Uint32()
+			}
+			_ = tcpLayer.SetNetworkLayerForChecksum(ipLayer)
+			probes = append(probes, []gopacket.SerializableLayer{tcpLayer})
+		}
+	case UDPMethod:
+		ipLayer.Protocol = layers.IPProtocolUDP
+		for _, port := range engine.options.Ports {
+			udpLayer := &layers.UDP{
+				SrcPort: layers.UDPPort(engine.sourcePort),
+				DstPort: layers.UDPPort(port),
+			}
+			_ = udpLayer.SetNetworkLayerForChecksum(ipLayer)
+			probes = append(probes, []gopacket.SerializableLayer{udpLayer})
+		}
+	}
+	for _, probeLayers := range probes {
+		buffer := gopacket.NewSerializeBuffer()
+		serializationError := gopacket.SerializeLayers(
+			buffer,
+			gopacket.SerializeOptions{
+				FixLengths:       true,
+				ComputeChecksums: true,
+			},
+			append([]gopacket.SerializableLayer{ethernetLayer, ipLayer}, probeLayers...)...,
+		)
+		if serializationError != nil {
+			return serializationError
+		}
+		writeError := engine.writePacket(buffer.Bytes())
+		if writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+// probeAnswer returns the host that answered one of the probes of the method
+func (engine *Engine) probeAnswer(packet gopacket.Packet) (net.IP, net.HardwareAddr, bool) {
+	ethernetLayer, isEthernet := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ipLayer, isIPv4 := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !isEthernet || !isIPv4 || !ipLayer.DstIP.Equal(engine.iFaceIP) || bytes.Equal(ethernetLayer.SrcMAC, engine.iFaceMac) {
+		return nil, nil, false
+	}
+	answered := false
+	switch engine.options.Method {
+	case ICMPEchoMethod, ICMPTimestampMethod:
+		if icmpLayer, isICMP := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); isICMP {
+			expected := uint8(layers.ICMPv4TypeEchoReply)
+			if engine.options.Method == ICMPTimestampMethod {
+				expected = layers.ICMPv4TypeTimestampReply
+			}
+			answered = icmpLayer.TypeCode.Type() == expected && icmpLayer.Id == engine.probeId
+		}
+	case TCPSYNMethod, TCPACKMethod:
+		// Open ports answer a SYN with SYN ACK and closed ports with RST, an unexpected ACK always gets a RST
+		if tcpLayer, isTCP := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); isTCP {
+			answered = uint16(tcpLayer.DstPort) == engine.sourcePort
+		}
+	case UDPMethod:
+		if udpLayer, isUDP := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); isUDP {
+			answered = uint16(udpLayer.DstPort) == engine.sourcePort
+		} else if icmpLayer, isICMP := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); isICMP {
+			// Closed ports answer with port unreachable
+			answered = icmpLayer.TypeCode == layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)
+		}
+	}
+	if !answered {
+		return nil, nil, false
+	}
+	return ipLayer.SrcIP, ethernetLayer.SrcMAC, true
+}
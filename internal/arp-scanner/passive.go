@@ -0,0 +1,233 @@
+package arp_scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"net"
+	"strings"
+)
+
+// PassiveMethod only listens on the interface, the hosts are taken from the traffic they send
+const PassiveMethod = "passive"
+
+// Evidence sources of the hosts found by the passive method
+const (
+	ARPSource     = "arp"
+	NDPSource     = "ndp"
+	DHCPSource    = "dhcp"
+	MDNSSource    = "mdns"
+	LLDPSource    = "lldp"
+	CDPSource     = "cdp"
+	NetBIOSSource = "netbios"
+	IPSource      = "ip"
+)
+
+const (
+	mdnsPort        = 5353
+	netBIOSNamePort = 137
+)
+
+func isUsableAddress(ip net.IP) bool {
+	return ip != nil && !ip.IsUnspecified() && !ip.IsMulticast() && !ip.Equal(net.IPv4bcast)
+}
+
+// observeARP takes the sender of requests and replies, ARP probes have no sender IP
+func observeARP(arp *layers.ARP) []Host {
+	if !isUsableAddress(arp.SourceProtAddress) {
+		return nil
+	}
+	return []Host{{IP: arp.SourceProtAddress, MAC: arp.SourceHwAddress, Source: ARPSource}}
+}
+
+// observeDHCP takes the hostname of the clients from their requests and the address given by the server
+func observeDHCP(dhcp *layers.DHCPv4) []Host {
+	host := Host{
+		MAC:    dhcp.ClientHWAddr,
+		Source: DHCPSource,
+	}
+	var messageType layers.DHCPMsgType
+	for _, option := range dhcp.Options {
+		switch option.Type {
+		case layers.DHCPOptHostname:
+			host.Hostname = string(option.Data)
+		case layers.DHCPOptRequestIP:
+			if len(option.Data) == net.IPv4len {
+				host.IP = net.IP(option.Data)
+			}
+		case layers.DHCPOptMessageType:
+			if len(option.Data) == 1 {
+				messageType = layers.DHCPMsgType(option.Data[0])
+			}
+		}
+	}
+	if messageType == layers.DHCPMsgTypeAck {
+		host.IP = dhcp.YourClientIP
+	} else if isUsableAddress(dhcp.ClientIP) {
+		host.IP = dhcp.ClientIP
+	}
+	if !isUsableAddress(host.IP) {
+		return nil
+	}
+	return []Host{host}
+}
+
+// observeMDNS takes the addresses announced by the answers of the multicast DNS responders
+func observeMDNS(payload []byte, mac net.HardwareAddr) []Host {
+	var dns layers.DNS
+	if dns.DecodeFromBytes(payload, gopacket.NilDecodeFeedback) != nil || !dns.QR {
+		return nil
+	}
+	var result []Host
+	for _, answer := range append(dns.Answers, dns.Additionals...) {
+		if answer.Type != layers.DNSTypeA && answer.Type != layers.DNSTypeAAAA {
+			continue
+		}
+		if !isUsableAddress(answer.IP) {
+			continue
+		}
+		result = append(result, Host{
+			IP:       answer.IP,
+			MAC:      mac,
+			Hostname: trimDomain(string(answer.Name)),
+			Source:   MDNSSource,
+		})
+	}
+	return result
+}
+
+// encodeNetBIOSName applies the first level encoding to the name padded with zeros, as the wildcard name is sent
+func encodeNetBIOSName(name string) []byte {
+	var raw [16]byte
+	copy(raw[:], name)
+	result := []byte{32}
+	for _, b := range raw {
+		result = append(result, 'A'+b>>4, 'A'+b&0xf)
+	}
+	return result
+}
+
+// decodeNetBIOSName reverts the first level encoding of a NetBIOS name, it returns the name and the offset after
+// its terminator
+func decodeNetBIOSName(data []byte, offset int) (string, int, bool) {
+	if len(data) < offset+34 || data[offset] != 32 {
+		return "", 0, false
+	}
+	var name [16]byte
+	for index := range name {
+		high, low := data[offset+1+index*2]-'A', data[offset+2+index*2]-'A'
+		if high > 15 || low > 15 {
+			return "", 0, false
+		}
+		name[index] = high<<4 | low
+	}
+	// The last byte is the type of the service, the name is padded with spaces
+	return strings.TrimRight(string(name[:15]), " \x00"), offset + 34, true
+}
+
+// observeNetBIOS takes the names the hosts register and the ones answered by the positive query responses
+func observeNetBIOS(payload []byte, source net.IP, mac net.HardwareAddr) []Host {
+	if len(payload) < 12 {
+		return nil
+	}
+	flags := binary.BigEndian.Uint16(payload[2:4])
+	isResponse := flags&0x8000 != 0
+	opcode := (flags >> 11) & 0xf
+	resultCode := flags & 0xf
+	questions := binary.BigEndian.Uint16(payload[4:6])
+	answers := binary.BigEndian.Uint16(payload[6:8])
+	switch {
+	case !isResponse && (opcode == 5 || opcode == 8 || opcode == 9) && questions > 0:
+		// Registration and refresh requests
+		name, _, ok := decodeNetBIOSName(payload, 12)
+		if !ok || len(name) == 0 {
+			return nil
+		}
+		return []Host{{IP: source, MAC: mac, Hostname: name, Source: NetBIOSSource}}
+	case isResponse && opcode == 0 && resultCode == 0 && answers > 0:
+		name, end, ok := decodeNetBIOSName(payload, 12)
+		// Type, class, TTL, data length and flags of the NB record before its address
+		if !ok || len(name) == 0 || len(payload) < end+2+2+4+2+2+4 {
+			return nil
+		}
+		address := net.IP(payload[end+12 : end+16])
+		if !isUsableAddress(address) {
+			return nil
+		}
+		if !address.Equal(source) {
+			// The answer may come from a name server, the MAC is not the one of the host
+			mac = nil
+		}
+		return []Host{{IP: address, MAC: mac, Hostname: name, Source: NetBIOSSource}}
+	}
+	return nil
+}
+
+// observe returns the hosts seen in the packet, with the evidence that reveals them
+func (engine *Engine) observe(packet gopacket.Packet) []Host {
+	ethernetLayer, isEthernet := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !isEthernet || bytes.Equal(ethernetLayer.SrcMAC, engine.iFaceMac) {
+		return nil
+	}
+	if arp, isARP := packet.Layer(layers.LayerTypeARP).(*layers.ARP); isARP {
+		return observeARP(arp)
+	}
+	if ip, mac, isNDP := tools.ParseNeighborDiscovery(packet); isNDP {
+		return []Host{{IP: ip, MAC: mac, Source: NDPSource}}
+	}
+	if lldp, isLLDP := packet.Layer(layers.LayerTypeLinkLayerDiscoveryInfo).(*layers.LinkLayerDiscoveryInfo); isLLDP {
+		address := net.IP(lldp.MgmtAddress.Address)
+		if (len(address) != net.IPv4len && len(address) != net.IPv6len) || !isUsableAddress(address) {
+			return nil
+		}
+		return []Host{{IP: address, MAC: ethernetLayer.SrcMAC, Hostname: lldp.SysName, Source: LLDPSource}}
+	}
+	if cdp, isCDP := packet.Layer(layers.LayerTypeCiscoDiscoveryInfo).(*layers.CiscoDiscoveryInfo); isCDP {
+		var result []Host
+		for _, address := range append(cdp.Addresses, cdp.MgmtAddresses...) {
+			if isUsableAddress(address) {
+				result = append(result, Host{IP: address, MAC: ethernetLayer.SrcMAC, Hostname: cdp.DeviceID, Source: CDPSource})
+			}
+		}
+		return result
+	}
+	if dhcp, isDHCP := packet.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4); isDHCP {
+		// Both the client requests and the server answers carry the MAC of the client
+		return observeDHCP(dhcp)
+	}
+	var source net.IP
+	switch network := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		source = network.SrcIP
+	case *layers.IPv6:
+		source = network.SrcIP
+	default:
+		return nil
+	}
+	if udp, isUDP := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); isUDP {
+		switch {
+		case udp.SrcPort == mdnsPort:
+			if hosts := observeMDNS(udp.Payload, ethernetLayer.SrcMAC); len(hosts) > 0 {
+				return hosts
+			}
+		case udp.SrcPort == netBIOSNamePort:
+			if hosts := observeNetBIOS(udp.Payload, source, ethernetLayer.SrcMAC); len(hosts) > 0 {
+				return hosts
+			}
+		}
+	}
+	// Ordinary traffic only reveals the hosts of the local segment, routed packets carry the MAC of the router
+	if !isUsableAddress(source) || !engine.isLocal(source) {
+		return nil
+	}
+	return []Host{{IP: source, MAC: ethernetLayer.SrcMAC, Source: IPSource}}
+}
+
+func (engine *Engine) isLocal(ip net.IP) bool {
+	if ip.To4() == nil {
+		return ip.IsLinkLocalUnicast()
+	}
+	return engine.subnet != nil && engine.subnet.Contains(ip)
+}
@@ -0,0 +1,227 @@
+package arp_scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name sources of the resolver, NetBIOSSource and MDNSSource are shared with the passive method
+const (
+	LLMNRSource = "llmnr"
+	DNSSource   = "dns"
+)
+
+const (
+	llmnrPort = 5355
+	// resolverWorkers is the number of hosts resolved at the same time
+	resolverWorkers = 32
+	nbstatType      = 0x21
+	nbGroupFlag     = 0x8000
+	workstationType = 0x00
+)
+
+// Resolver looks for the names of the discovered hosts with NetBIOS node status, mDNS and LLMNR reverse lookups and
+// reverse DNS against the resolver of the system. The names found are sent to Names as hosts with the Hostname and
+// the Source of the lookup that returned it
+type Resolver struct {
+	Names     chan Host
+	timeout   time.Duration
+	semaphore chan struct{}
+	wait      *sync.WaitGroup
+}
+
+// Resolve queries the names of the host in the background
+func (resolver *Resolver) Resolve(ip net.IP) {
+	resolver.wait.Add(1)
+	go func() {
+		defer resolver.wait.Done()
+		resolver.semaphore <- struct{}{}
+		defer func() { <-resolver.semaphore }()
+
+		lookups := []struct {
+			source string
+			lookup func(net.IP, time.Duration) ([]string, error)
+		}{
+			{NetBIOSSource, netBIOSNodeStatus},
+			{MDNSSource, mdnsReverse},
+			{LLMNRSource, llmnrReverse},
+			{DNSSource, dnsReverse},
+		}
+		var lookupsWait sync.WaitGroup
+		for _, l := range lookups {
+			lookupsWait.Add(1)
+			go func(source string, lookup func(net.IP, time.Duration) ([]string, error)) {
+				defer lookupsWait.Done()
+				// Hosts without the service just do not answer, the errors carry no information
+				names, _ := lookup(ip, resolver.timeout)
+				for _, name := range names {
+					resolver.Names <- Host{
+						IP:       ip,
+						Hostname: name,
+						Source:   source,
+					}
+				}
+			}(l.source, l.lookup)
+		}
+		lookupsWait.Wait()
+	}()
+}
+
+// Finish closes Names once every pending lookup is done, the names left must be read until then
+func (resolver *Resolver) Finish() {
+	go func() {
+		resolver.wait.Wait()
+		close(resolver.Names)
+	}()
+}
+
+// NewResolver prepares a resolver that waits timeout for the answer of every lookup
+func NewResolver(timeout time.Duration) *Resolver {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Resolver{
+		Names:     make(chan Host, 1000),
+		timeout:   timeout,
+		semaphore: make(chan struct{}, resolverWorkers),
+		wait:      new(sync.WaitGroup),
+	}
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of the address
+func reverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	var name strings.Builder
+	ip6 := ip.To16()
+	for index := len(ip6) - 1; index >= 0; index-- {
+		_, _ = fmt.Fprintf(&name, "%x.%x.", ip6[index]&0xf, ip6[index]>>4)
+	}
+	name.WriteString("ip6.arpa")
+	return name.String()
+}
+
+// exchange sends the query to the UDP port of the host and returns the first answer
+func exchange(ip net.IP, port int, query []byte, timeout time.Duration) ([]byte, error) {
+	connection, dialError := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: port})
+	if dialError != nil {
+		return nil, dialError
+	}
+	defer connection.Close()
+	_ = connection.SetDeadline(time.Now().Add(timeout))
+	_, writeError := connection.Write(query)
+	if writeError != nil {
+		return nil, writeError
+	}
+	answer := make([]byte, 1500)
+	length, readError := connection.Read(answer)
+	if readError != nil {
+		return nil, readError
+	}
+	return answer[:length], nil
+}
+
+// reverseQuery asks for the PTR records of the host to the DNS speaking service listening on the port
+func reverseQuery(ip net.IP, port int, timeout time.Duration) ([]string, error) {
+	buffer := gopacket.NewSerializeBuffer()
+	serializeError := (&layers.DNS{
+		ID:      uint16(rand.Intn(1 << 16)),
+		QDCount: 1,
+		Questions: []layers.DNSQuestion{
+			{
+				Name:  []byte(reverseName(ip)),
+				Type:  layers.DNSTypePTR,
+				Class: layers.DNSClassIN,
+			},
+		},
+	}).SerializeTo(buffer, gopacket.SerializeOptions{FixLengths: true})
+	if serializeError != nil {
+		return nil, serializeError
+	}
+	answer, exchangeError := exchange(ip, port, buffer.Bytes(), timeout)
+	if exchangeError != nil {
+		return nil, exchangeError
+	}
+	var dns layers.DNS
+	decodeError := dns.DecodeFromBytes(answer, gopacket.NilDecodeFeedback)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+	var names []string
+	for _, record := range dns.Answers {
+		if record.Type == layers.DNSTypePTR && len(record.PTR) > 0 {
+			names = append(names, trimDomain(string(record.PTR)))
+		}
+	}
+	return names, nil
+}
+
+func trimDomain(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(name, "."), ".local")
+}
+
+// mdnsReverse sends a legacy unicast query to the multicast DNS responder of the host
+func mdnsReverse(ip net.IP, timeout time.Duration) ([]string, error) {
+	return reverseQuery(ip, mdnsPort, timeout)
+}
+
+func llmnrReverse(ip net.IP, timeout time.Duration) ([]string, error) {
+	return reverseQuery(ip, llmnrPort, timeout)
+}
+
+func dnsReverse(ip net.IP, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	names, lookupError := net.DefaultResolver.LookupAddr(ctx, ip.String())
+	if lookupError != nil {
+		return nil, lookupError
+	}
+	for index, name := range names {
+		names[index] = strings.TrimSuffix(name, ".")
+	}
+	return names, nil
+}
+
+// netBIOSNodeStatus asks the host for its name table and returns the unique workstation name
+func netBIOSNodeStatus(ip net.IP, timeout time.Duration) ([]string, error) {
+	if ip.To4() == nil {
+		return nil, errors.New("NetBIOS only runs over IPv4")
+	}
+	query := make([]byte, 12, 50)
+	binary.BigEndian.PutUint16(query[0:2], uint16(rand.Intn(1<<16)))
+	// One question
+	binary.BigEndian.PutUint16(query[4:6], 1)
+	// The wildcard name "*" padded with zeros
+	query = append(query, encodeNetBIOSName("*")...)
+	// Name terminator, NBSTAT type and IN class
+	query = append(query, 0x00, 0x00, nbstatType, 0x00, 0x01)
+	answer, exchangeError := exchange(ip, netBIOSNamePort, query, timeout)
+	if exchangeError != nil {
+		return nil, exchangeError
+	}
+	_, end, ok := decodeNetBIOSName(answer, 12)
+	// Type, class, TTL, data length and the number of names
+	if !ok || len(answer) < end+11 {
+		return nil, errors.New("invalid node status answer")
+	}
+	count := int(answer[end+10])
+	entries := answer[end+11:]
+	for index := 0; index < count && len(entries) >= (index+1)*18; index++ {
+		entry := entries[index*18 : (index+1)*18]
+		flags := binary.BigEndian.Uint16(entry[16:18])
+		if entry[15] == workstationType && flags&nbGroupFlag == 0 {
+			return []string{strings.TrimRight(string(entry[:15]), " \x00")}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,249 @@
+package arp_scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaximumTargets is the number of hosts a list of targets can expand to
+const MaximumTargets = 1 << 16
+
+type addressRange struct {
+	first net.IP
+	last  net.IP
+}
+
+func (r addressRange) contains(ip net.IP) bool {
+	return bytes.Compare(ip, r.first) >= 0 && bytes.Compare(ip, r.last) <= 0
+}
+
+func (r addressRange) size() *big.Int {
+	size := new(big.Int).Sub(new(big.Int).SetBytes(r.last), new(big.Int).SetBytes(r.first))
+	return size.Add(size, big.NewInt(1))
+}
+
+func nextIP(ip net.IP) net.IP {
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	for index := len(result) - 1; index >= 0; index-- {
+		result[index]++
+		if result[index] != 0 {
+			break
+		}
+	}
+	return result
+}
+
+func previousIP(ip net.IP) net.IP {
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	for index := len(result) - 1; index >= 0; index-- {
+		result[index]--
+		if result[index] != 0xff {
+			break
+		}
+	}
+	return result
+}
+
+// subnetRange returns every address of the network
+func subnetRange(network *net.IPNet) addressRange {
+	first := network.IP.To16()
+	last := make(net.IP, net.IPv6len)
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+	for index := range last {
+		last[index] = first[index] | ^mask[index]
+	}
+	return addressRange{
+		first: first,
+		last:  last,
+	}
+}
+
+func parseRange(entry string) (addressRange, error) {
+	if strings.Contains(entry, "/") {
+		_, network, parseError := net.ParseCIDR(entry)
+		if parseError != nil {
+			return addressRange{}, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return subnetRange(network), nil
+	}
+	if separator := strings.Index(entry, "-"); separator != -1 {
+		first := net.ParseIP(entry[:separator])
+		if first == nil {
+			return addressRange{}, fmt.Errorf("invalid range %q", entry)
+		}
+		last := net.ParseIP(entry[separator+1:])
+		// 192.168.1.10-50 only sets the last octet of the end of the range
+		if lastOctet, atoiError := strconv.Atoi(entry[separator+1:]); last == nil && atoiError == nil && first.To4() != nil && lastOctet >= 0 && lastOctet <= 255 {
+			last = net.IPv4(first.To4()[0], first.To4()[1], first.To4()[2], byte(lastOctet))
+		}
+		if last == nil || (first.To4() == nil) != (last.To4() == nil) || bytes.Compare(first.To16(), last.To16()) > 0 {
+			return addressRange{}, fmt.Errorf("invalid range %q", entry)
+		}
+		return addressRange{
+			first: first.To16(),
+			last:  last.To16(),
+		}, nil
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return addressRange{}, fmt.Errorf("invalid host %q", entry)
+	}
+	return addressRange{
+		first: ip.To16(),
+		last:  ip.To16(),
+	}, nil
+}
+
+// parseRanges reads the CIDR blocks, dash ranges and hosts separated by commas, spaces or new lines
+func parseRanges(targets string) ([]addressRange, error) {
+	var result []addressRange
+	for _, entry := range strings.FieldsFunc(targets, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		r, parseError := parseRange(entry)
+		if parseError != nil {
+			return nil, parseError
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Targets iterates the hosts of a list of ranges, skipping the excluded ones
+type Targets struct {
+	ranges   []addressRange
+	excluded []addressRange
+	index    int
+	next     net.IP
+}
+
+// Excluded reports if the host must not be scanned
+func (targets *Targets) Excluded(ip net.IP) bool {
+	ip = ip.To16()
+	for _, r := range targets.excluded {
+		if r.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports if the host is one of the targets and it is not excluded, without ranges every host that is not
+// excluded is a target
+func (targets *Targets) Contains(ip net.IP) bool {
+	ip = ip.To16()
+	if targets.Excluded(ip) {
+		return false
+	}
+	if len(targets.ranges) == 0 {
+		return true
+	}
+	for _, r := range targets.ranges {
+		if r.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the following host to scan, with the signature expected by the engine host generators
+func (targets *Targets) Next() (bool, string, error) {
+	for targets.index < len(targets.ranges) {
+		current := targets.ranges[targets.index]
+		if targets.next == nil {
+			targets.next = current.first
+		}
+		ip := targets.next
+		if bytes.Equal(ip, current.last) {
+			targets.index++
+			targets.next = nil
+		} else {
+			targets.next = nextIP(ip)
+		}
+		if targets.Excluded(ip) {
+			continue
+		}
+		return true, ip.String(), nil
+	}
+	return false, "", nil
+}
+
+// Count returns the number of hosts left to scan
+func (targets *Targets) Count() uint64 {
+	counter := *targets
+	var result uint64
+	for {
+		hasNext, _, _ := counter.Next()
+		if !hasNext {
+			return result
+		}
+		result++
+	}
+}
+
+// ParseTargets reads the hosts to scan and the ones to exclude, both lists accept CIDR blocks like 192.168.1.0/24,
+// dash ranges like 192.168.1.10-192.168.1.50 or 192.168.1.10-50 and single hosts
+func ParseTargets(targets, exclude string) (*Targets, error) {
+	ranges, parseError := parseRanges(targets)
+	if parseError != nil {
+		return nil, parseError
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("no targets provided")
+	}
+	total := new(big.Int)
+	for _, r := range ranges {
+		total.Add(total, r.size())
+	}
+	if total.Cmp(big.NewInt(MaximumTargets)) > 0 {
+		return nil, fmt.Errorf("targets expand to more than %d hosts", MaximumTargets)
+	}
+	excluded, parseError := parseRanges(exclude)
+	if parseError != nil {
+		return nil, errors.New("exclusions: " + parseError.Error())
+	}
+	return &Targets{
+		ranges:   ranges,
+		excluded: excluded,
+	}, nil
+}
+
+// SubnetTargets returns the hosts of the subnet, skipping the excluded ones and the network and broadcast
+// addresses of IPv4 subnets
+func SubnetTargets(network *net.IPNet, exclude string) (*Targets, error) {
+	r := subnetRange(network)
+	if ones, bits := network.Mask.Size(); network.IP.To4() != nil && bits-ones > 1 {
+		r.first, r.last = nextIP(r.first), previousIP(r.last)
+	}
+	if r.size().Cmp(big.NewInt(MaximumTargets)) > 0 {
+		return nil, fmt.Errorf("the subnet %s has more than %d hosts, provide the targets to scan", network, MaximumTargets)
+	}
+	excluded, parseError := parseRanges(exclude)
+	if parseError != nil {
+		return nil, errors.New("exclusions: " + parseError.Error())
+	}
+	return &Targets{
+		ranges:   []addressRange{r},
+		excluded: excluded,
+	}, nil
+}
+
+// ParseExclusions returns an empty list of targets, used to filter the hosts of other generators
+func ParseExclusions(exclude string) (*Targets, error) {
+	excluded, parseError := parseRanges(exclude)
+	if parseError != nil {
+		return nil, errors.New("exclusions: " + parseError.Error())
+	}
+	return &Targets{
+		excluded: excluded,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package arp_scanner
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func targetsString(t *testing.T, targets *Targets) string {
+	var hosts []string
+	for {
+		hasNext, host, nextError := targets.Next()
+		if nextError != nil {
+			t.Fatal(nextError)
+		}
+		if !hasNext {
+			return strings.Join(hosts, " ")
+		}
+		hosts = append(hosts, host)
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	for _, test := range []struct {
+		targets string
+		exclude string
+		hosts   string
+	}{
+		{"192.168.1.0/30", "", "192.168.1.0 192.168.1.1 192.168.1.2 192.168.1.3"},
+		{"192.168.1.10-192.168.1.12", "", "192.168.1.10 192.168.1.11 192.168.1.12"},
+		{"192.168.1.254-255", "", "192.168.1.254 192.168.1.255"},
+		{"192.168.1.1, 10.0.0.1\n10.0.0.2", "", "192.168.1.1 10.0.0.1 10.0.0.2"},
+		{"192.168.1.0/29", "192.168.1.1 192.168.1.4-6", "192.168.1.0 192.168.1.2 192.168.1.3 192.168.1.7"},
+		{"2001:db8::/127", "", "2001:db8:: 2001:db8::1"},
+	} {
+		targets, parseError := ParseTargets(test.targets, test.exclude)
+		if parseError != nil {
+			t.Fatal(test.targets, parseError)
+		}
+		if hosts := targetsString(t, targets); hosts != test.hosts {
+			t.Fatal(test.targets, hosts)
+		}
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	targets, parseError := ParseTargets("192.168.1.0/24", "192.168.1.10-20")
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	if !targets.Excluded(net.ParseIP("192.168.1.15")) || targets.Excluded(net.ParseIP("192.168.1.21")) {
+		t.Fatal("wrong exclusions")
+	}
+}
+
+func TestInvalidTargets(t *testing.T) {
+	for _, test := range []struct {
+		targets string
+		exclude string
+	}{
+		{"", ""},
+		{"192.168.1", ""},
+		{"192.168.1.0/33", ""},
+		{"192.168.1.50-10", ""},
+		{"192.168.1.10-256", ""},
+		{"192.168.1.10-2001:db8::1", ""},
+		{"10.0.0.0/15", ""},
+		{"192.168.1.0/24", "host"},
+	} {
+		if _, parseError := ParseTargets(test.targets, test.exclude); parseError == nil {
+			t.Fatal(test.targets, test.exclude)
+		}
+	}
+}
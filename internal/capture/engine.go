@@ -8,6 +8,7 @@ import (
 	"github.com/google/gopacket/pcap"
 	"github.com/google/gopacket/pcapgo"
 	"github.com/google/gopacket/tcpassembly"
+	"github.com/shoriwe/CAPitan/internal/fingerprint"
 	"github.com/shoriwe/CAPitan/internal/tools"
 	"github.com/shoriwe/gplasma"
 	"github.com/shoriwe/gplasma/pkg/std/features/importlib"
@@ -18,9 +19,15 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	pcapFileHeaderLength   = 24
+	pcapRecordHeaderLength = 16
+)
+
 var (
 	FailedToConvertErrorToString = errors.New("failed to convert to string the error received")
 )
@@ -72,10 +79,15 @@ type Engine struct {
 	Promiscuous      bool
 	NetworkInterface string
 	PcapFile         *os.File
-	handle           *pcap.Handle
+	// BPFFilter is applied by the kernel to the handle, the packets it drops never reach the filter script
+	BPFFilter string
+	handle    *pcap.Handle
 
 	pcapContents []byte
 	pcapDumpFile *os.File
+	dumpedBytes  uint64
+	// Every packet is fingerprinted before the filters, the SYNs carry no payload to filter
+	fingerprinter *fingerprint.Fingerprinter
 }
 
 func (engine *Engine) loadVMFeatures() vm.Feature {
@@ -207,6 +219,7 @@ func (engine *Engine) mainLoop() {
 		engine.ErrorChannel <- writeError
 		return
 	}
+	atomic.StoreUint64(&engine.dumpedBytes, pcapFileHeaderLength)
 
 	tick := time.Tick(time.Second)
 
@@ -217,7 +230,9 @@ func (engine *Engine) mainLoop() {
 		case packet, isOpen := <-packets:
 			if isOpen {
 				if packet != nil {
+					atomic.AddUint64(&engine.dumpedBytes, pcapRecordHeaderLength+uint64(len(packet.Data())))
 					go engine.dump(pcapDump, packet)
+					engine.fingerprinter.Observe(packet)
 					if packet.NetworkLayer() != nil && packet.TransportLayer() != nil && packet.ApplicationLayer() != nil {
 						if packet.TransportLayer().LayerType() == layers.LayerTypeTCP {
 							assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), packet.TransportLayer().(*layers.TCP), packet.Metadata().Timestamp)
@@ -373,6 +388,18 @@ func (engine *Engine) Start() error {
 	return nil
 }
 
+// Fingerprints returns the guesses of the systems of the hosts that changed since the last call
+func (engine *Engine) Fingerprints() map[string][]fingerprint.Guess {
+	return engine.fingerprinter.Changes()
+}
+
+/*
+	DumpedBytes: Size in bytes of the pcap file written until now
+*/
+func (engine *Engine) DumpedBytes() uint64 {
+	return atomic.LoadUint64(&engine.dumpedBytes)
+}
+
 /*
 	DumpPcap: This function should always be called before engine.Close()
 */
@@ -390,6 +417,41 @@ func (engine *Engine) DumpPcap() []byte {
 	return contents
 }
 
+/*
+	SetBPFFilter: Restrict the packets read from the handle on top of the BPF filter of the engine, should be called before engine.Start()
+*/
+func (engine *Engine) SetBPFFilter(filter string) error {
+	return engine.handle.SetBPFFilter(CombineBPFFilters(engine.BPFFilter, filter))
+}
+
+/*
+	CompileBPFFilter: Check the expression compiles to a BPF program for packets of the link type
+*/
+func CompileBPFFilter(linkType layers.LinkType, filter string) error {
+	_, compileError := pcap.CompileBPFFilter(linkType, 65536, filter)
+	if compileError != nil {
+		return errors.New("invalid BPF filter: " + compileError.Error())
+	}
+	return nil
+}
+
+/*
+	CombineBPFFilters: Join the non empty expressions so the packets need to match all of them
+*/
+func CombineBPFFilters(filters ...string) string {
+	var result string
+	for _, filter := range filters {
+		if len(filter) == 0 {
+			continue
+		}
+		if len(result) > 0 {
+			result += " and "
+		}
+		result += "(" + filter + ")"
+	}
+	return result
+}
+
 func (engine *Engine) Close() {
 	defer tools.RecoverFromChannelClosedWhenWriting()
 
@@ -585,6 +647,7 @@ func newEngine() (*Engine, error) {
 		Promiscuous:     false,
 		PcapFile:        nil,
 		handle:          nil,
+		fingerprinter:   fingerprint.NewFingerprinter(),
 	}
 	engine.packetsToFilter = engine.Packets
 	engine.tcpStreamsToFilter = engine.TCPStreams
@@ -608,7 +671,29 @@ func newEngine() (*Engine, error) {
 	return engine, nil
 }
 
-func NewEngineWithInterface(netInterface string) (*Engine, error) {
+/*
+	setHandle: Use the handle for the engine, the BPF filter is compiled for the link type of the handle and applied before any packet gets read
+*/
+func (engine *Engine) setHandle(handle *pcap.Handle, bpfFilter string) error {
+	engine.handle = handle
+	engine.BPFFilter = bpfFilter
+	if len(bpfFilter) == 0 {
+		return nil
+	}
+	filterError := CompileBPFFilter(handle.LinkType(), bpfFilter)
+	if filterError == nil {
+		filterError = handle.SetBPFFilter(bpfFilter)
+	}
+	if filterError != nil {
+		handle.Close()
+		_ = engine.pcapDumpFile.Close()
+		_ = os.Remove(engine.pcapDumpFile.Name())
+		return filterError
+	}
+	return nil
+}
+
+func NewEngineWithInterface(netInterface, bpfFilter string) (*Engine, error) {
 	engine, creationError := newEngine()
 	if creationError != nil {
 		return nil, creationError
@@ -618,11 +703,14 @@ func NewEngineWithInterface(netInterface string) (*Engine, error) {
 	if openError != nil {
 		return nil, openError
 	}
-	engine.handle = handle
+	filterError := engine.setHandle(handle, bpfFilter)
+	if filterError != nil {
+		return nil, filterError
+	}
 	return engine, nil
 }
 
-func NewEngineWithFile(file *os.File) (*Engine, error) {
+func NewEngineWithFile(file *os.File, bpfFilter string) (*Engine, error) {
 	engine, creationError := newEngine()
 	if creationError != nil {
 		return nil, creationError
@@ -632,6 +720,9 @@ func NewEngineWithFile(file *os.File) (*Engine, error) {
 	if openError != nil {
 		return nil, openError
 	}
-	engine.handle = handle
+	filterError := engine.setHandle(handle, bpfFilter)
+	if filterError != nil {
+		return nil, filterError
+	}
 	return engine, nil
 }
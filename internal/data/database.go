@@ -9,7 +9,7 @@ import (
 
 type DatabaseAdminFeatures interface {
 	UpdatePasswordAndSetExpiration(username, newPassword string, duration time.Duration) (bool, error)
-	GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, err error)
+	GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, injectInterfaces map[string]*objects.InjectPermission, replayInterfaces map[string]*objects.ReplayPermission, portScanInterfaces map[string]*objects.PortScanPermission, err error)
 	ListUsers(username string) ([]*objects.User, error)
 	CreateUser(username string) (bool, error)
 	GetUserByUsername(username string) (bool, *objects.User, error)
@@ -20,18 +20,51 @@ type DatabaseAdminFeatures interface {
 	AddCaptureInterfacePrivilege(username, i string) (bool, error)
 	AddARPScanInterfacePrivilege(username, i string) (bool, error)
 	AddARPSpoofInterfacePrivilege(username, i string) (bool, error)
+	DeleteInjectInterfacePrivilege(username, i string) (bool, error)
+	AddInjectInterfacePrivilege(username, i string) (bool, error)
+	DeleteReplayInterfacePrivilege(username, i string) (bool, error)
+	AddReplayInterfacePrivilege(username, i string) (bool, error)
+	DeletePortScanInterfacePrivilege(username, i string) (bool, error)
+	AddPortScanInterfacePrivilege(username, i string) (bool, error)
 	ListAllARPScans() (bool, []*objects.ARPScanSessionAdminView, error)
 	ListAllCaptures() (bool, []*objects.CaptureSessionAdminView, error)
+	ListAllJobs() (bool, []*objects.JobAdminView, error)
+	ListAllARPSpoofs() (bool, []*objects.ARPSpoofSessionAdminView, error)
+	ListAllInjections() (bool, []*objects.InjectionSessionAdminView, error)
+	ListStorageUsage() (bool, []*objects.StorageUsage, error)
+	ListRoleStorageQuotas() (bool, []*objects.StorageQuota, error)
+	SetRoleStorageQuota(role string, maxBytes uint64, maxCaptures uint) (bool, error)
+	SetUserStorageQuota(username string, maxBytes uint64, maxCaptures uint) (bool, error)
+	DeleteUserStorageQuota(username string) (bool, error)
 }
 
 type DatabaseUserFeatures interface {
 	ListUserCaptures(username string) (bool, []*objects.CaptureSession, error)
-	SaveInterfaceCapture(username, captureName, interfaceName, description, script string, promiscuous bool, topologyOptions, hostPacketCountOptions, layer4CountOptions, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error)
-	SaveImportCapture(username string, name string, description string, script string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error)
+	SaveInterfaceCapture(username, captureName, interfaceName, description, script, bpfFilter string, promiscuous bool, topologyOptions, hostPacketCountOptions, layer4CountOptions, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error)
+	SaveImportCapture(username string, name string, description string, script string, bpfFilter string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error)
 	QueryCapture(username, captureName string) (succeed bool, captureSession *objects.CaptureSession, packets []map[string]interface{}, streams []capture.Data, queryError error)
 	ListUserARPScans(username string) (bool, []*objects.ARPScanSession, error)
-	SaveARPScan(username string, scanName string, interfaceName string, script string, hosts interface{}, start time.Time, finish time.Time) (bool, error)
+	SaveARPScan(username string, scanName string, interfaceName string, script string, targets string, exclude string, method string, hosts interface{}, start time.Time, finish time.Time) (bool, error)
 	QueryARPScan(username string, scanName string) (bool, *objects.ARPScanSession, error)
+	ListUserPortScans(username string) (bool, []*objects.PortScanSession, error)
+	SavePortScan(username, scanName, interfaceName, targets, exclude, ports, mode string, results interface{}, start, finish time.Time) (bool, error)
+	QueryPortScan(username, scanName string) (bool, *objects.PortScanSession, error)
+	UpdateInventory(username string, sighting *objects.InventorySighting, observations []*objects.InventoryObservation) (bool, error)
+	ListUserInventory(username, search string) (bool, []*objects.InventoryHost, error)
+	QueryInventoryHost(username string, hostId uint) (bool, *objects.InventoryHost, error)
+	SaveAlerts(username string, alerts []*objects.Alert) (bool, error)
+	ListUserAlerts(username string) (bool, []*objects.Alert, error)
+	SaveJob(username string, job *objects.Job) (bool, error)
+	ListUserJobs(username string) (bool, []*objects.Job, error)
+	QueryJob(username string, jobId uint) (bool, *objects.Job, error)
+	DeleteJob(username string, jobId uint) (bool, error)
+	SaveJobRun(username string, run *objects.JobRun) (bool, error)
+	ListJobRuns(username string, jobId uint) (bool, []*objects.JobRun, error)
+	ListUserARPSpoofs(username string) (bool, []*objects.ARPSpoofSession, error)
+	SaveARPSpoof(username, interfaceName, targetIP, gateway, captureName string, packetsSent uint64, stopReason string, dnsAnswers []*objects.DNSSpoofedAnswer, start, finish time.Time) (bool, error)
+	ListUserInjections(username string) (bool, []*objects.InjectionSession, error)
+	SaveInjection(username, interfaceName, script string, framesSent uint64, stopReason string, frames []*objects.InjectedFrame, start, finish time.Time) (bool, error)
+	GetUserStorageUsage(username string) (bool, *objects.StorageUsage, error)
 }
 
 type DatabaseGlobalFeatures interface {
@@ -9,6 +9,7 @@ import (
 	"github.com/shoriwe/CAPitan/internal/data"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
 	"golang.org/x/crypto/bcrypt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -23,12 +24,39 @@ type Memory struct {
 	arpScanInterfacePermissions       map[uint]*objects.ARPScanPermission
 	arpSpoofInterfacePermissionsMutex *sync.Mutex
 	arpSpoofInterfacePermissions      map[uint]*objects.ARPSpoofPermission
+	injectInterfacePermissionsMutex   *sync.Mutex
+	injectInterfacePermissions        map[uint]*objects.InjectPermission
+	replayInterfacePermissionsMutex   *sync.Mutex
+	replayInterfacePermissions        map[uint]*objects.ReplayPermission
+	portScanInterfacePermissionsMutex *sync.Mutex
+	portScanInterfacePermissions      map[uint]*objects.PortScanPermission
 	nextCaptureSessionId              uint
 	captureSessions                   map[uint]*objects.CaptureSession
 	captureSessionsMutex              *sync.Mutex
 	nextARPScanSessionId              uint
 	arpScanSessions                   map[uint]*objects.ARPScanSession
 	arpScanSessionsMutex              *sync.Mutex
+	nextARPSpoofSessionId             uint
+	arpSpoofSessions                  map[uint]*objects.ARPSpoofSession
+	arpSpoofSessionsMutex             *sync.Mutex
+	nextInjectionSessionId            uint
+	injectionSessions                 map[uint]*objects.InjectionSession
+	injectionSessionsMutex            *sync.Mutex
+	nextPortScanSessionId             uint
+	portScanSessions                  map[uint]*objects.PortScanSession
+	portScanSessionsMutex             *sync.Mutex
+	nextInventoryHostId               uint
+	inventoryHosts                    map[uint]*objects.InventoryHost
+	inventoryHostsMutex               *sync.Mutex
+	nextAlertId                       uint
+	alerts                            map[uint]*objects.Alert
+	alertsMutex                       *sync.Mutex
+	nextJobId                         uint
+	jobs                              map[uint]*objects.Job
+	jobsMutex                         *sync.Mutex
+	nextJobRunId                      uint
+	jobRuns                           map[uint]*objects.JobRun
+	jobRunsMutex                      *sync.Mutex
 	nextCapturePacketId               uint
 	capturedPackets                   map[uint]*objects.Packet
 	capturedPacketsMutex              *sync.Mutex
@@ -39,6 +67,159 @@ type Memory struct {
 	nextCapturePermissionId           uint
 	nextARPScanPermissionId           uint
 	nextARPSpoofPermissionId          uint
+	nextInjectPermissionId            uint
+	nextReplayPermissionId            uint
+	nextPortScanPermissionId          uint
+	nextStorageQuotaId                uint
+	storageQuotas                     map[uint]*objects.StorageQuota
+	storageQuotasMutex                *sync.Mutex
+	storageUsage                      map[uint]*storedCaptures
+	storageUsageMutex                 *sync.Mutex
+}
+
+func (memory *Memory) effectiveStorageQuota(user *objects.User) *objects.StorageQuota {
+	var roleQuota *objects.StorageQuota
+	for _, quota := range memory.storageQuotas {
+		if quota.UsersId == user.Id {
+			return quota
+		}
+		if quota.UsersId == 0 && quota.Role == objects.RoleOf(user) {
+			roleQuota = quota
+		}
+	}
+	return roleQuota
+}
+
+// storedCaptures counts the captures of a user and the bytes of their pcaps, packets and streams
+type storedCaptures struct {
+	bytes    uint64
+	captures uint
+}
+
+// addStoredCapture updates the counters of the user after one of its captures is saved
+func (memory *Memory) addStoredCapture(userId uint, bytes uint64) {
+	memory.storageUsageMutex.Lock()
+	defer memory.storageUsageMutex.Unlock()
+	counters, found := memory.storageUsage[userId]
+	if !found {
+		counters = &storedCaptures{}
+		memory.storageUsage[userId] = counters
+	}
+	counters.bytes += bytes
+	counters.captures++
+}
+
+func (memory *Memory) computeStorageUsage(user *objects.User) *objects.StorageUsage {
+	memory.storageQuotasMutex.Lock()
+	quota := memory.effectiveStorageQuota(user)
+	memory.storageQuotasMutex.Unlock()
+	usage := &objects.StorageUsage{
+		User:     user,
+		Quota:    quota,
+		Bytes:    0,
+		Captures: 0,
+	}
+	memory.storageUsageMutex.Lock()
+	defer memory.storageUsageMutex.Unlock()
+	if counters, found := memory.storageUsage[user.Id]; found {
+		usage.Bytes = counters.bytes
+		usage.Captures = counters.captures
+	}
+	return usage
+}
+
+func (memory *Memory) GetUserStorageUsage(username string) (bool, *objects.StorageUsage, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	return true, memory.computeStorageUsage(user), nil
+}
+
+func (memory *Memory) ListStorageUsage() (bool, []*objects.StorageUsage, error) {
+	memory.usersMutex.Lock()
+	var users []*objects.User
+	for _, user := range memory.users {
+		users = append(users, user)
+	}
+	memory.usersMutex.Unlock()
+	var result []*objects.StorageUsage
+	for _, user := range users {
+		result = append(result, memory.computeStorageUsage(user))
+	}
+	return true, result, nil
+}
+
+func (memory *Memory) ListRoleStorageQuotas() (bool, []*objects.StorageQuota, error) {
+	memory.storageQuotasMutex.Lock()
+	defer memory.storageQuotasMutex.Unlock()
+	var result []*objects.StorageQuota
+	for _, quota := range memory.storageQuotas {
+		if quota.UsersId == 0 {
+			result = append(result, quota)
+		}
+	}
+	return true, result, nil
+}
+
+func (memory *Memory) SetRoleStorageQuota(role string, maxBytes uint64, maxCaptures uint) (bool, error) {
+	memory.storageQuotasMutex.Lock()
+	defer memory.storageQuotasMutex.Unlock()
+	for _, quota := range memory.storageQuotas {
+		if quota.UsersId == 0 && quota.Role == role {
+			quota.MaxBytes = maxBytes
+			quota.MaxCaptures = maxCaptures
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (memory *Memory) SetUserStorageQuota(username string, maxBytes uint64, maxCaptures uint) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.storageQuotasMutex.Lock()
+	defer memory.storageQuotasMutex.Unlock()
+	for _, quota := range memory.storageQuotas {
+		if quota.UsersId == user.Id {
+			quota.MaxBytes = maxBytes
+			quota.MaxCaptures = maxCaptures
+			return true, nil
+		}
+	}
+	memory.storageQuotas[memory.nextStorageQuotaId] = &objects.StorageQuota{
+		Id:          memory.nextStorageQuotaId,
+		UsersId:     user.Id,
+		Role:        "",
+		MaxBytes:    maxBytes,
+		MaxCaptures: maxCaptures,
+	}
+	memory.nextStorageQuotaId++
+	return true, nil
+}
+
+func (memory *Memory) DeleteUserStorageQuota(username string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.storageQuotasMutex.Lock()
+	defer memory.storageQuotasMutex.Unlock()
+	for id, quota := range memory.storageQuotas {
+		if quota.UsersId == user.Id {
+			delete(memory.storageQuotas, id)
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (memory *Memory) ListAllCaptures() (bool, []*objects.CaptureSessionAdminView, error) {
@@ -93,6 +274,35 @@ func (memory *Memory) ListAllARPScans() (bool, []*objects.ARPScanSessionAdminVie
 	return true, result, nil
 }
 
+func (memory *Memory) ListAllJobs() (bool, []*objects.JobAdminView, error) {
+	memory.jobsMutex.Lock()
+	defer memory.jobsMutex.Unlock()
+	memory.usersMutex.Lock()
+	defer memory.usersMutex.Unlock()
+	var result []*objects.JobAdminView
+	idOrderedUsers := map[uint]*objects.User{}
+	for _, job := range memory.jobs {
+		user, found := idOrderedUsers[job.UserId]
+		if !found {
+			for _, u := range memory.users {
+				if u.Id == job.UserId {
+					idOrderedUsers[u.Id] = u
+					break
+				}
+			}
+			user = idOrderedUsers[job.UserId]
+		}
+		result = append(result, &objects.JobAdminView{
+			User: user,
+			Job:  job,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Job.Id < result[j].Job.Id
+	})
+	return true, result, nil
+}
+
 func (memory *Memory) QueryARPScan(username, scanName string) (bool, *objects.ARPScanSession, error) {
 	memory.usersMutex.Lock()
 	user, found := memory.users[username]
@@ -110,7 +320,7 @@ func (memory *Memory) QueryARPScan(username, scanName string) (bool, *objects.AR
 	return false, nil, nil
 }
 
-func (memory *Memory) SaveARPScan(username string, scanName string, interfaceName string, script string, hosts interface{}, start time.Time, finish time.Time) (bool, error) {
+func (memory *Memory) SaveARPScan(username string, scanName string, interfaceName string, script string, targets string, exclude string, method string, hosts interface{}, start time.Time, finish time.Time) (bool, error) {
 	memory.usersMutex.Lock()
 	user, found := memory.users[username]
 	memory.usersMutex.Unlock()
@@ -132,6 +342,9 @@ func (memory *Memory) SaveARPScan(username string, scanName string, interfaceNam
 		Started:   start,
 		Ended:     finish,
 		Script:    []byte(script),
+		Targets:   targets,
+		Exclude:   exclude,
+		Method:    method,
 		Hosts:     marshalHosts,
 	}
 
@@ -159,7 +372,452 @@ func (memory *Memory) ListUserARPScans(username string) (bool, []*objects.ARPSca
 	return true, result, nil
 }
 
-func (memory *Memory) SaveImportCapture(username string, name string, description string, script string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error) {
+func (memory *Memory) ListUserPortScans(username string) (bool, []*objects.PortScanSession, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.portScanSessionsMutex.Lock()
+	defer memory.portScanSessionsMutex.Unlock()
+	var result []*objects.PortScanSession
+	for _, session := range memory.portScanSessions {
+		if session.UserId == user.Id {
+			result = append(result, session)
+		}
+	}
+	return true, result, nil
+}
+
+func (memory *Memory) QueryPortScan(username, scanName string) (bool, *objects.PortScanSession, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.portScanSessionsMutex.Lock()
+	defer memory.portScanSessionsMutex.Unlock()
+	for _, session := range memory.portScanSessions {
+		if session.UserId == user.Id && session.Name == scanName {
+			return true, session, nil
+		}
+	}
+	return false, nil, nil
+}
+
+func (memory *Memory) SavePortScan(username, scanName, interfaceName, targets, exclude, ports, mode string, results interface{}, start, finish time.Time) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	marshalResults, marshalError := json.Marshal(results)
+	if marshalError != nil {
+		return false, marshalError
+	}
+	memory.portScanSessionsMutex.Lock()
+	defer memory.portScanSessionsMutex.Unlock()
+	session := &objects.PortScanSession{
+		Id:        memory.nextPortScanSessionId,
+		UserId:    user.Id,
+		Interface: interfaceName,
+		Name:      scanName,
+		Started:   start,
+		Ended:     finish,
+		Targets:   targets,
+		Exclude:   exclude,
+		Ports:     ports,
+		Mode:      mode,
+		Results:   marshalResults,
+	}
+	memory.nextPortScanSessionId++
+	memory.portScanSessions[session.Id] = session
+	return true, nil
+}
+
+func (memory *Memory) UpdateInventory(username string, sighting *objects.InventorySighting, observations []*objects.InventoryObservation) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.inventoryHostsMutex.Lock()
+	defer memory.inventoryHostsMutex.Unlock()
+	for _, observation := range observations {
+		// Hosts with the same MAC are preferred to the ones that only share the IP
+		var host *objects.InventoryHost
+		for _, known := range memory.inventoryHosts {
+			if known.UserId != user.Id || !known.Identifies(observation) {
+				continue
+			}
+			if host == nil || (len(observation.MAC) > 0 && known.MAC == observation.MAC) {
+				host = known
+			}
+		}
+		if host == nil {
+			host = &objects.InventoryHost{
+				Id:     memory.nextInventoryHostId,
+				UserId: user.Id,
+			}
+			memory.nextInventoryHostId++
+			memory.inventoryHosts[host.Id] = host
+		}
+		host.Merge(observation, sighting)
+	}
+	return true, nil
+}
+
+func (memory *Memory) ListUserInventory(username, search string) (bool, []*objects.InventoryHost, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.inventoryHostsMutex.Lock()
+	defer memory.inventoryHostsMutex.Unlock()
+	var result []*objects.InventoryHost
+	for _, host := range memory.inventoryHosts {
+		if host.UserId == user.Id && host.Search(search) {
+			result = append(result, host)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) QueryInventoryHost(username string, hostId uint) (bool, *objects.InventoryHost, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.inventoryHostsMutex.Lock()
+	defer memory.inventoryHostsMutex.Unlock()
+	host, found := memory.inventoryHosts[hostId]
+	if !found || host.UserId != user.Id {
+		return false, nil, nil
+	}
+	return true, host, nil
+}
+
+func (memory *Memory) SaveAlerts(username string, alerts []*objects.Alert) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.alertsMutex.Lock()
+	defer memory.alertsMutex.Unlock()
+	for _, alert := range alerts {
+		alert.Id = memory.nextAlertId
+		alert.UserId = user.Id
+		memory.nextAlertId++
+		memory.alerts[alert.Id] = alert
+	}
+	return true, nil
+}
+
+func (memory *Memory) ListUserAlerts(username string) (bool, []*objects.Alert, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.alertsMutex.Lock()
+	defer memory.alertsMutex.Unlock()
+	var result []*objects.Alert
+	for _, alert := range memory.alerts {
+		if alert.UserId == user.Id {
+			result = append(result, alert)
+		}
+	}
+	// Newest first
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) SaveJob(username string, job *objects.Job) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.jobsMutex.Lock()
+	defer memory.jobsMutex.Unlock()
+	for _, known := range memory.jobs {
+		if known.UserId == user.Id && known.Name == job.Name {
+			return false, nil
+		}
+	}
+	job.Id = memory.nextJobId
+	job.UserId = user.Id
+	memory.nextJobId++
+	memory.jobs[job.Id] = job
+	return true, nil
+}
+
+func (memory *Memory) ListUserJobs(username string) (bool, []*objects.Job, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.jobsMutex.Lock()
+	defer memory.jobsMutex.Unlock()
+	var result []*objects.Job
+	for _, job := range memory.jobs {
+		if job.UserId == user.Id {
+			result = append(result, job)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) QueryJob(username string, jobId uint) (bool, *objects.Job, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.jobsMutex.Lock()
+	defer memory.jobsMutex.Unlock()
+	job, found := memory.jobs[jobId]
+	if !found || job.UserId != user.Id {
+		return false, nil, nil
+	}
+	return true, job, nil
+}
+
+// DeleteJob removes the job with its history, the sessions it stored are kept
+func (memory *Memory) DeleteJob(username string, jobId uint) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.jobsMutex.Lock()
+	job, found := memory.jobs[jobId]
+	if !found || job.UserId != user.Id {
+		memory.jobsMutex.Unlock()
+		return false, nil
+	}
+	delete(memory.jobs, jobId)
+	memory.jobsMutex.Unlock()
+	memory.jobRunsMutex.Lock()
+	defer memory.jobRunsMutex.Unlock()
+	for runId, run := range memory.jobRuns {
+		if run.JobId == jobId {
+			delete(memory.jobRuns, runId)
+		}
+	}
+	return true, nil
+}
+
+func (memory *Memory) SaveJobRun(username string, run *objects.JobRun) (bool, error) {
+	succeed, _, queryError := memory.QueryJob(username, run.JobId)
+	if !succeed || queryError != nil {
+		return succeed, queryError
+	}
+	memory.jobRunsMutex.Lock()
+	defer memory.jobRunsMutex.Unlock()
+	run.Id = memory.nextJobRunId
+	memory.nextJobRunId++
+	memory.jobRuns[run.Id] = run
+	return true, nil
+}
+
+func (memory *Memory) ListJobRuns(username string, jobId uint) (bool, []*objects.JobRun, error) {
+	succeed, _, queryError := memory.QueryJob(username, jobId)
+	if !succeed || queryError != nil {
+		return succeed, nil, queryError
+	}
+	memory.jobRunsMutex.Lock()
+	defer memory.jobRunsMutex.Unlock()
+	var result []*objects.JobRun
+	for _, run := range memory.jobRuns {
+		if run.JobId == jobId {
+			result = append(result, run)
+		}
+	}
+	// Newest first
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) ListAllARPSpoofs() (bool, []*objects.ARPSpoofSessionAdminView, error) {
+	memory.arpSpoofSessionsMutex.Lock()
+	defer memory.arpSpoofSessionsMutex.Unlock()
+	memory.usersMutex.Lock()
+	defer memory.usersMutex.Unlock()
+	var result []*objects.ARPSpoofSessionAdminView
+	idOrderedUsers := map[uint]*objects.User{}
+	for _, session := range memory.arpSpoofSessions {
+		user, found := idOrderedUsers[session.UserId]
+		if !found {
+			for _, u := range memory.users {
+				if u.Id == session.UserId {
+					idOrderedUsers[u.Id] = u
+					break
+				}
+			}
+			user = idOrderedUsers[session.UserId]
+		}
+		result = append(result, &objects.ARPSpoofSessionAdminView{
+			User:    user,
+			Session: session,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Session.Id > result[j].Session.Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) SaveARPSpoof(username, interfaceName, targetIP, gateway, captureName string, packetsSent uint64, stopReason string, dnsAnswers []*objects.DNSSpoofedAnswer, start, finish time.Time) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.arpSpoofSessionsMutex.Lock()
+	defer memory.arpSpoofSessionsMutex.Unlock()
+	session := &objects.ARPSpoofSession{
+		Id:          memory.nextARPSpoofSessionId,
+		UserId:      user.Id,
+		Interface:   interfaceName,
+		TargetIP:    targetIP,
+		Gateway:     gateway,
+		Started:     start,
+		Ended:       finish,
+		CaptureName: captureName,
+		PacketsSent: packetsSent,
+		StopReason:  stopReason,
+		DNSAnswers:  dnsAnswers,
+	}
+	memory.nextARPSpoofSessionId++
+	memory.arpSpoofSessions[session.Id] = session
+	return true, nil
+}
+
+func (memory *Memory) ListUserARPSpoofs(username string) (bool, []*objects.ARPSpoofSession, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.arpSpoofSessionsMutex.Lock()
+	defer memory.arpSpoofSessionsMutex.Unlock()
+	var result []*objects.ARPSpoofSession
+	for _, session := range memory.arpSpoofSessions {
+		if session.UserId == user.Id {
+			result = append(result, session)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) ListAllInjections() (bool, []*objects.InjectionSessionAdminView, error) {
+	memory.injectionSessionsMutex.Lock()
+	defer memory.injectionSessionsMutex.Unlock()
+	memory.usersMutex.Lock()
+	defer memory.usersMutex.Unlock()
+	var result []*objects.InjectionSessionAdminView
+	idOrderedUsers := map[uint]*objects.User{}
+	for _, session := range memory.injectionSessions {
+		user, found := idOrderedUsers[session.UserId]
+		if !found {
+			for _, u := range memory.users {
+				if u.Id == session.UserId {
+					idOrderedUsers[u.Id] = u
+					break
+				}
+			}
+			user = idOrderedUsers[session.UserId]
+		}
+		result = append(result, &objects.InjectionSessionAdminView{
+			User:    user,
+			Session: session,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Session.Id > result[j].Session.Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) SaveInjection(username, interfaceName, script string, framesSent uint64, stopReason string, frames []*objects.InjectedFrame, start, finish time.Time) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.injectionSessionsMutex.Lock()
+	defer memory.injectionSessionsMutex.Unlock()
+	session := &objects.InjectionSession{
+		Id:         memory.nextInjectionSessionId,
+		UserId:     user.Id,
+		Interface:  interfaceName,
+		Script:     []byte(script),
+		Started:    start,
+		Ended:      finish,
+		FramesSent: framesSent,
+		StopReason: stopReason,
+		Frames:     frames,
+	}
+	memory.nextInjectionSessionId++
+	memory.injectionSessions[session.Id] = session
+	return true, nil
+}
+
+func (memory *Memory) ListUserInjections(username string) (bool, []*objects.InjectionSession, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil, nil
+	}
+	memory.injectionSessionsMutex.Lock()
+	defer memory.injectionSessionsMutex.Unlock()
+	var result []*objects.InjectionSession
+	for _, session := range memory.injectionSessions {
+		if session.UserId == user.Id {
+			result = append(result, session)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+	return true, result, nil
+}
+
+func (memory *Memory) SaveImportCapture(username string, name string, description string, script string, bpfFilter string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error) {
 	memory.usersMutex.Lock()
 	user, found := memory.users[username]
 	memory.usersMutex.Unlock()
@@ -208,6 +866,7 @@ func (memory *Memory) SaveImportCapture(username string, name string, descriptio
 		Ended:               time.Time{},
 		Pcap:                pcap,
 		FilterScript:        []byte(script),
+		BPFFilter:           bpfFilter,
 		TopologyJson:        topologyEncoded,
 		HostCountJson:       hostCountEncoded,
 		LayerCountJson:      layerCountEncoded,
@@ -215,6 +874,7 @@ func (memory *Memory) SaveImportCapture(username string, name string, descriptio
 	}
 
 	memory.nextCapturePacketId++
+	storedBytes := uint64(len(pcap))
 
 	for _, stream := range streams {
 		memory.capturedTCPStreams[memory.nextCapturedTCPStreamId] = &objects.TCPStream{
@@ -223,6 +883,7 @@ func (memory *Memory) SaveImportCapture(username string, name string, descriptio
 			TCPStreamType:    stream.Type,
 			Contents:         stream.Content,
 		}
+		storedBytes += uint64(len(stream.Content))
 		memory.nextCapturedTCPStreamId++
 	}
 
@@ -252,9 +913,11 @@ func (memory *Memory) SaveImportCapture(username string, name string, descriptio
 			DestinationPort:    uint(dstPort),
 			Contents:           encodedPacket,
 		}
+		storedBytes += uint64(len(encodedPacket))
 		memory.nextCapturePacketId++
 	}
 	memory.captureSessions[session.Id] = session
+	memory.addStoredCapture(user.Id, storedBytes)
 	return true, nil
 }
 
@@ -308,7 +971,7 @@ func (memory *Memory) QueryCapture(username, captureName string) (succeed bool,
 	return true, targetSession, packets, streams, nil
 }
 
-func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName, description, script string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error) {
+func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName, description, script, bpfFilter string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error) {
 	memory.usersMutex.Lock()
 	user, found := memory.users[username]
 	memory.usersMutex.Unlock()
@@ -357,6 +1020,7 @@ func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName,
 		Ended:               finish,
 		Pcap:                pcapContents,
 		FilterScript:        []byte(script),
+		BPFFilter:           bpfFilter,
 		TopologyJson:        topologyEncoded,
 		HostCountJson:       hostCountEncoded,
 		LayerCountJson:      layerCountEncoded,
@@ -364,6 +1028,7 @@ func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName,
 	}
 
 	memory.nextCapturePacketId++
+	storedBytes := uint64(len(pcapContents))
 
 	for _, stream := range streams {
 		memory.capturedTCPStreams[memory.nextCapturedTCPStreamId] = &objects.TCPStream{
@@ -372,6 +1037,7 @@ func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName,
 			TCPStreamType:    stream.Type,
 			Contents:         stream.Content,
 		}
+		storedBytes += uint64(len(stream.Content))
 		memory.nextCapturedTCPStreamId++
 	}
 
@@ -401,9 +1067,11 @@ func (memory *Memory) SaveInterfaceCapture(username, captureName, interfaceName,
 			DestinationPort:    uint(dstPort),
 			Contents:           encodedPacket,
 		}
+		storedBytes += uint64(len(encodedPacket))
 		memory.nextCapturePacketId++
 	}
 	memory.captureSessions[session.Id] = session
+	memory.addStoredCapture(user.Id, storedBytes)
 	return true, nil
 }
 
@@ -564,6 +1232,129 @@ func (memory *Memory) AddARPSpoofInterfacePrivilege(username string, i string) (
 	return true, nil
 }
 
+func (memory *Memory) DeleteInjectInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.injectInterfacePermissionsMutex.Lock()
+	defer memory.injectInterfacePermissionsMutex.Unlock()
+	for id, injectPermission := range memory.injectInterfacePermissions {
+		if injectPermission.UsersId == user.Id && injectPermission.Interface == i {
+			delete(memory.injectInterfacePermissions, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (memory *Memory) AddInjectInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.injectInterfacePermissionsMutex.Lock()
+	defer memory.injectInterfacePermissionsMutex.Unlock()
+	for _, injectPermission := range memory.injectInterfacePermissions {
+		if injectPermission.UsersId == user.Id && injectPermission.Interface == i {
+			return false, nil
+		}
+	}
+	memory.injectInterfacePermissions[memory.nextInjectPermissionId] = &objects.InjectPermission{
+		Id:        memory.nextInjectPermissionId,
+		UsersId:   user.Id,
+		Interface: i,
+	}
+	memory.nextInjectPermissionId++
+	return true, nil
+}
+
+func (memory *Memory) DeleteReplayInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.replayInterfacePermissionsMutex.Lock()
+	defer memory.replayInterfacePermissionsMutex.Unlock()
+	for id, replayPermission := range memory.replayInterfacePermissions {
+		if replayPermission.UsersId == user.Id && replayPermission.Interface == i {
+			delete(memory.replayInterfacePermissions, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (memory *Memory) AddReplayInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.replayInterfacePermissionsMutex.Lock()
+	defer memory.replayInterfacePermissionsMutex.Unlock()
+	for _, replayPermission := range memory.replayInterfacePermissions {
+		if replayPermission.UsersId == user.Id && replayPermission.Interface == i {
+			return false, nil
+		}
+	}
+	memory.replayInterfacePermissions[memory.nextReplayPermissionId] = &objects.ReplayPermission{
+		Id:        memory.nextReplayPermissionId,
+		UsersId:   user.Id,
+		Interface: i,
+	}
+	memory.nextReplayPermissionId++
+	return true, nil
+}
+
+func (memory *Memory) DeletePortScanInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.portScanInterfacePermissionsMutex.Lock()
+	defer memory.portScanInterfacePermissionsMutex.Unlock()
+	for id, portScanPermission := range memory.portScanInterfacePermissions {
+		if portScanPermission.UsersId == user.Id && portScanPermission.Interface == i {
+			delete(memory.portScanInterfacePermissions, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (memory *Memory) AddPortScanInterfacePrivilege(username string, i string) (bool, error) {
+	memory.usersMutex.Lock()
+	user, found := memory.users[username]
+	memory.usersMutex.Unlock()
+	if !found {
+		return false, nil
+	}
+	memory.portScanInterfacePermissionsMutex.Lock()
+	defer memory.portScanInterfacePermissionsMutex.Unlock()
+	for _, portScanPermission := range memory.portScanInterfacePermissions {
+		if portScanPermission.UsersId == user.Id && portScanPermission.Interface == i {
+			return false, nil
+		}
+	}
+	memory.portScanInterfacePermissions[memory.nextPortScanPermissionId] = &objects.PortScanPermission{
+		Id:        memory.nextPortScanPermissionId,
+		UsersId:   user.Id,
+		Interface: i,
+	}
+	memory.nextPortScanPermissionId++
+	return true, nil
+}
+
 func (memory *Memory) UpdateUserStatus(username string, isAdmin, isEnabled bool) (succeed bool, updateError error) {
 	memory.usersMutex.Lock()
 	defer memory.usersMutex.Unlock()
@@ -576,17 +1367,20 @@ func (memory *Memory) UpdateUserStatus(username string, isAdmin, isEnabled bool)
 	return true, nil
 }
 
-func (memory *Memory) GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, err error) {
+func (memory *Memory) GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, injectInterfaces map[string]*objects.InjectPermission, replayInterfaces map[string]*objects.ReplayPermission, portScanInterfaces map[string]*objects.PortScanPermission, err error) {
 	memory.usersMutex.Lock()
 	user, succeed = memory.users[username]
 	memory.usersMutex.Unlock()
 	if !succeed {
-		return false, nil, nil, nil, nil, nil
+		return false, nil, nil, nil, nil, nil, nil, nil, nil
 	}
 
 	captureInterfaces = map[string]*objects.CapturePermission{}
 	arpScanInterfaces = map[string]*objects.ARPScanPermission{}
 	arpSpoofInterfaces = map[string]*objects.ARPSpoofPermission{}
+	injectInterfaces = map[string]*objects.InjectPermission{}
+	replayInterfaces = map[string]*objects.ReplayPermission{}
+	portScanInterfaces = map[string]*objects.PortScanPermission{}
 
 	// Capture
 	memory.captureInterfacePermissionsMutex.Lock()
@@ -615,7 +1409,34 @@ func (memory *Memory) GetUserInterfacePermissions(username string) (succeed bool
 	}
 	memory.arpSpoofInterfacePermissionsMutex.Unlock()
 
-	return true, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, nil
+	// Inject
+	memory.injectInterfacePermissionsMutex.Lock()
+	for _, permission := range memory.injectInterfacePermissions {
+		if permission.UsersId == user.Id {
+			injectInterfaces[permission.Interface] = permission
+		}
+	}
+	memory.injectInterfacePermissionsMutex.Unlock()
+
+	// Replay
+	memory.replayInterfacePermissionsMutex.Lock()
+	for _, permission := range memory.replayInterfacePermissions {
+		if permission.UsersId == user.Id {
+			replayInterfaces[permission.Interface] = permission
+		}
+	}
+	memory.replayInterfacePermissionsMutex.Unlock()
+
+	// Port scan
+	memory.portScanInterfacePermissionsMutex.Lock()
+	for _, permission := range memory.portScanInterfacePermissions {
+		if permission.UsersId == user.Id {
+			portScanInterfaces[permission.Interface] = permission
+		}
+	}
+	memory.portScanInterfacePermissionsMutex.Unlock()
+
+	return true, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, injectInterfaces, replayInterfaces, portScanInterfaces, nil
 }
 
 func (memory *Memory) CreateUser(username string) (bool, error) {
@@ -747,26 +1568,76 @@ func NewInMemoryDB() data.Database {
 		captureInterfacePermissionsMutex:  new(sync.Mutex),
 		arpScanInterfacePermissionsMutex:  new(sync.Mutex),
 		arpSpoofInterfacePermissionsMutex: new(sync.Mutex),
+		injectInterfacePermissionsMutex:   new(sync.Mutex),
+		replayInterfacePermissionsMutex:   new(sync.Mutex),
+		portScanInterfacePermissionsMutex: new(sync.Mutex),
 		captureSessionsMutex:              new(sync.Mutex),
 		capturedPacketsMutex:              new(sync.Mutex),
 		capturedTCPStreamsMutex:           new(sync.Mutex),
 		arpScanSessionsMutex:              new(sync.Mutex),
+		arpSpoofSessionsMutex:             new(sync.Mutex),
+		injectionSessionsMutex:            new(sync.Mutex),
+		portScanSessionsMutex:             new(sync.Mutex),
+		inventoryHostsMutex:               new(sync.Mutex),
+		alertsMutex:                       new(sync.Mutex),
+		jobsMutex:                         new(sync.Mutex),
+		jobRunsMutex:                      new(sync.Mutex),
+		storageQuotasMutex:                new(sync.Mutex),
+		storageUsageMutex:                 new(sync.Mutex),
 		users:                             map[string]*objects.User{},
 		captureInterfacePermissions:       map[uint]*objects.CapturePermission{},
 		arpScanInterfacePermissions:       map[uint]*objects.ARPScanPermission{},
 		arpSpoofInterfacePermissions:      map[uint]*objects.ARPSpoofPermission{},
+		injectInterfacePermissions:        map[uint]*objects.InjectPermission{},
+		replayInterfacePermissions:        map[uint]*objects.ReplayPermission{},
+		portScanInterfacePermissions:      map[uint]*objects.PortScanPermission{},
 		captureSessions:                   map[uint]*objects.CaptureSession{},
 		capturedPackets:                   map[uint]*objects.Packet{},
 		capturedTCPStreams:                map[uint]*objects.TCPStream{},
 		arpScanSessions:                   map[uint]*objects.ARPScanSession{},
+		arpSpoofSessions:                  map[uint]*objects.ARPSpoofSession{},
+		injectionSessions:                 map[uint]*objects.InjectionSession{},
+		portScanSessions:                  map[uint]*objects.PortScanSession{},
+		inventoryHosts:                    map[uint]*objects.InventoryHost{},
+		alerts:                            map[uint]*objects.Alert{},
+		jobs:                              map[uint]*objects.Job{},
+		jobRuns:                           map[uint]*objects.JobRun{},
+		storageQuotas:                     map[uint]*objects.StorageQuota{},
+		storageUsage:                      map[uint]*storedCaptures{},
 		nextUserId:                        2,
 		nextCapturePermissionId:           1,
 		nextARPScanPermissionId:           1,
 		nextARPSpoofPermissionId:          1,
+		nextInjectPermissionId:            1,
+		nextReplayPermissionId:            1,
+		nextPortScanPermissionId:          1,
 		nextCaptureSessionId:              1,
 		nextCapturePacketId:               1,
 		nextCapturedTCPStreamId:           1,
 		nextARPScanSessionId:              0,
+		nextARPSpoofSessionId:             1,
+		nextInjectionSessionId:            1,
+		nextPortScanSessionId:             1,
+		nextInventoryHostId:               1,
+		nextAlertId:                       1,
+		nextJobId:                         1,
+		nextJobRunId:                      1,
+		nextStorageQuotaId:                3,
+	}
+	// Default quotas per role, zero means unlimited
+	result.storageQuotas[1] = &objects.StorageQuota{
+		Id:          1,
+		UsersId:     0,
+		Role:        objects.AdminRole,
+		MaxBytes:    0,
+		MaxCaptures: 0,
+	}
+	result.storageQuotas[2] = &objects.StorageQuota{
+		Id:          2,
+		UsersId:     0,
+		Role:        objects.UserRole,
+		MaxBytes:    0,
+		MaxCaptures: 0,
 	}
 	result.users["admin"] = &objects.User{
 		Id:                     1,
@@ -2,7 +2,6 @@ package noauth
 
 import (
 	"github.com/google/gopacket"
-	arp_scanner "github.com/shoriwe/CAPitan/internal/arp-scanner"
 	"github.com/shoriwe/CAPitan/internal/capture"
 	"github.com/shoriwe/CAPitan/internal/data"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
@@ -23,8 +22,111 @@ var admin = &objects.User{
 type NoAuth struct {
 }
 
-func (noAuth *NoAuth) SaveARPScan(username string, scanName string, interfaceName string, script string, hosts map[string]arp_scanner.Host, start time.Time, finish time.Time) (bool, error) {
-	//TODO implement me
+func (noAuth *NoAuth) GetUserStorageUsage(username string) (bool, *objects.StorageUsage, error) {
+	return true, &objects.StorageUsage{
+		User: admin,
+		Quota: &objects.StorageQuota{
+			Id:          1,
+			UsersId:     0,
+			Role:        objects.AdminRole,
+			MaxBytes:    1024 * 1024 * 1024,
+			MaxCaptures: 10,
+		},
+		Bytes:    350 * 1024 * 1024,
+		Captures: 3,
+	}, nil
+}
+
+func (noAuth *NoAuth) ListStorageUsage() (bool, []*objects.StorageUsage, error) {
+	_, usage, _ := noAuth.GetUserStorageUsage("admin")
+	return true, []*objects.StorageUsage{usage}, nil
+}
+
+func (noAuth *NoAuth) ListRoleStorageQuotas() (bool, []*objects.StorageQuota, error) {
+	return true, []*objects.StorageQuota{
+		{
+			Id:          1,
+			UsersId:     0,
+			Role:        objects.AdminRole,
+			MaxBytes:    0,
+			MaxCaptures: 0,
+		},
+		{
+			Id:          2,
+			UsersId:     0,
+			Role:        objects.UserRole,
+			MaxBytes:    1024 * 1024 * 1024,
+			MaxCaptures: 10,
+		},
+	}, nil
+}
+
+func (noAuth *NoAuth) SetRoleStorageQuota(role string, maxBytes uint64, maxCaptures uint) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) SetUserStorageQuota(username string, maxBytes uint64, maxCaptures uint) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) DeleteUserStorageQuota(username string) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListAllARPScans() (bool, []*objects.ARPScanSessionAdminView, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) ListAllJobs() (bool, []*objects.JobAdminView, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) ListAllCaptures() (bool, []*objects.CaptureSessionAdminView, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) ListAllARPSpoofs() (bool, []*objects.ARPSpoofSessionAdminView, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) SaveARPSpoof(username, interfaceName, targetIP, gateway, captureName string, packetsSent uint64, stopReason string, dnsAnswers []*objects.DNSSpoofedAnswer, start, finish time.Time) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListAllInjections() (bool, []*objects.InjectionSessionAdminView, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) SaveInjection(username, interfaceName, script string, framesSent uint64, stopReason string, frames []*objects.InjectedFrame, start, finish time.Time) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListUserInjections(username string) (bool, []*objects.InjectionSession, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) ListUserARPSpoofs(username string) (bool, []*objects.ARPSpoofSession, error) {
+	var spoofs []*objects.ARPSpoofSession
+	spoofs = append(spoofs,
+		&objects.ARPSpoofSession{
+			Id:          1,
+			UserId:      1,
+			Interface:   "eth0",
+			TargetIP:    "192.168.1.10",
+			Gateway:     "192.168.1.1",
+			Started:     time.Now(),
+			Ended:       time.Now().Add(time.Minute),
+			PacketsSent: 120,
+			StopReason:  "Stopped by user",
+		})
+	return true, spoofs, nil
+}
+
+func (noAuth *NoAuth) QueryARPScan(username string, scanName string) (bool, *objects.ARPScanSession, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) SaveARPScan(username string, scanName string, interfaceName string, script string, targets string, exclude string, method string, hosts interface{}, start time.Time, finish time.Time) (bool, error) {
 	panic("implement me")
 }
 
@@ -64,7 +166,63 @@ func (noAuth *NoAuth) ListUserARPScans(username string) (bool, []*objects.ARPSca
 	return true, scans, nil
 }
 
-func (noAuth *NoAuth) SaveImportCapture(username string, name string, description string, script string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error) {
+func (noAuth *NoAuth) ListUserPortScans(username string) (bool, []*objects.PortScanSession, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) SavePortScan(username, scanName, interfaceName, targets, exclude, ports, mode string, results interface{}, start, finish time.Time) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) QueryPortScan(username, scanName string) (bool, *objects.PortScanSession, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) UpdateInventory(username string, sighting *objects.InventorySighting, observations []*objects.InventoryObservation) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListUserInventory(username, search string) (bool, []*objects.InventoryHost, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) QueryInventoryHost(username string, hostId uint) (bool, *objects.InventoryHost, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) SaveAlerts(username string, alerts []*objects.Alert) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListUserAlerts(username string) (bool, []*objects.Alert, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) SaveJob(username string, job *objects.Job) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListUserJobs(username string) (bool, []*objects.Job, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) QueryJob(username string, jobId uint) (bool, *objects.Job, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) DeleteJob(username string, jobId uint) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) SaveJobRun(username string, run *objects.JobRun) (bool, error) {
+	return true, nil
+}
+
+func (noAuth *NoAuth) ListJobRuns(username string, jobId uint) (bool, []*objects.JobRun, error) {
+	return true, nil, nil
+}
+
+func (noAuth *NoAuth) SaveImportCapture(username string, name string, description string, script string, bpfFilter string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, pcap []byte) (bool, error) {
 	panic("implement me")
 }
 
@@ -72,7 +230,7 @@ func (noAuth *NoAuth) QueryCapture(username, captureName string) (succeed bool,
 	panic("implement me")
 }
 
-func (noAuth *NoAuth) SaveInterfaceCapture(username, captureName, interfaceName, description, script string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error) {
+func (noAuth *NoAuth) SaveInterfaceCapture(username, captureName, interfaceName, description, script, bpfFilter string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) (bool, error) {
 	panic("implement me")
 }
 
@@ -142,11 +300,35 @@ func (noAuth *NoAuth) AddARPSpoofInterfacePrivilege(username string, i string) (
 	panic("implement me")
 }
 
+func (noAuth *NoAuth) DeleteInjectInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) AddInjectInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) DeleteReplayInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) AddReplayInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) DeletePortScanInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
+func (noAuth *NoAuth) AddPortScanInterfacePrivilege(username string, i string) (bool, error) {
+	panic("implement me")
+}
+
 func (noAuth *NoAuth) UpdateUserStatus(username string, isAdmin, isEnabled bool) (succeed bool, updateError error) {
 	return true, nil
 }
 
-func (noAuth *NoAuth) GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, err error) {
+func (noAuth *NoAuth) GetUserInterfacePermissions(username string) (succeed bool, user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, injectInterfaces map[string]*objects.InjectPermission, replayInterfaces map[string]*objects.ReplayPermission, portScanInterfaces map[string]*objects.PortScanPermission, err error) {
 	user = &objects.User{
 		Id:                     1,
 		Username:               "admin",
@@ -176,7 +358,22 @@ func (noAuth *NoAuth) GetUserInterfacePermissions(username string) (succeed bool
 		UsersId:   1,
 		Interface: "eth2",
 	}}
-	return true, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, nil
+	injectInterfaces = map[string]*objects.InjectPermission{"eth0": {
+		Id:        1,
+		UsersId:   1,
+		Interface: "eth0",
+	}}
+	replayInterfaces = map[string]*objects.ReplayPermission{"eth0": {
+		Id:        1,
+		UsersId:   1,
+		Interface: "eth0",
+	}}
+	portScanInterfaces = map[string]*objects.PortScanPermission{"eth0": {
+		Id:        1,
+		UsersId:   1,
+		Interface: "eth0",
+	}}
+	return true, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, injectInterfaces, replayInterfaces, portScanInterfaces, nil
 }
 
 func (noAuth *NoAuth) CreateUser(username string) (bool, error) {
@@ -32,6 +32,21 @@ type (
 		UsersId   uint
 		Interface string
 	}
+	InjectPermission struct {
+		Id        uint
+		UsersId   uint
+		Interface string
+	}
+	ReplayPermission struct {
+		Id        uint
+		UsersId   uint
+		Interface string
+	}
+	PortScanPermission struct {
+		Id        uint
+		UsersId   uint
+		Interface string
+	}
 	ARPScanSession struct {
 		Id        uint
 		Interface string
@@ -40,12 +55,91 @@ type (
 		Started   time.Time
 		Ended     time.Time
 		Script    []byte
+		Targets   string
+		Exclude   string
+		Method    string
 		Hosts     []byte
 	}
+	// ScannedHost is an entry of the hosts of an ARPScanSession, Sources lists the evidence that revealed it
+	ScannedHost struct {
+		IP                  string
+		MAC                 string
+		Vendor              string
+		LocallyAdministered bool
+		Hostnames           []string
+		Sources             []string
+		FirstSeen           time.Time
+		LastSeen            time.Time
+	}
+	PortScanSession struct {
+		Id        uint
+		UserId    uint
+		Interface string
+		Name      string
+		Started   time.Time
+		Ended     time.Time
+		Targets   string
+		Exclude   string
+		Ports     string
+		Mode      string
+		Results   []byte
+	}
+	// ScannedPort is an entry of the results of a PortScanSession
+	ScannedPort struct {
+		Host     string
+		Port     uint16
+		Protocol string
+		State    string
+	}
 	ARPScanSessionAdminView struct {
 		User    *User
 		Session *ARPScanSession
 	}
+	ARPSpoofSession struct {
+		Id          uint
+		UserId      uint
+		Interface   string
+		TargetIP    string
+		Gateway     string
+		Started     time.Time
+		Ended       time.Time
+		CaptureName string
+		PacketsSent uint64
+		StopReason  string
+		DNSAnswers  []*DNSSpoofedAnswer
+	}
+	DNSSpoofedAnswer struct {
+		Time    time.Time
+		Client  string
+		Name    string
+		Type    string
+		Address string
+	}
+	ARPSpoofSessionAdminView struct {
+		User    *User
+		Session *ARPSpoofSession
+	}
+	InjectionSession struct {
+		Id         uint
+		UserId     uint
+		Interface  string
+		Script     []byte
+		Started    time.Time
+		Ended      time.Time
+		FramesSent uint64
+		StopReason string
+		Frames     []*InjectedFrame
+	}
+	InjectedFrame struct {
+		Time     time.Time
+		Length   int
+		Summary  string
+		Contents []byte
+	}
+	InjectionSessionAdminView struct {
+		User    *User
+		Session *InjectionSession
+	}
 	CaptureSession struct {
 		Id                  uint
 		UserId              uint
@@ -57,6 +151,7 @@ type (
 		Ended               time.Time
 		Pcap                []byte
 		FilterScript        []byte
+		BPFFilter           string
 		TopologyJson        []byte
 		HostCountJson       []byte
 		LayerCountJson      []byte
@@ -100,4 +195,17 @@ type (
 		Id   uint
 		Name string
 	}
+	StorageQuota struct {
+		Id          uint
+		UsersId     uint
+		Role        string
+		MaxBytes    uint64
+		MaxCaptures uint
+	}
+	StorageUsage struct {
+		User     *User
+		Quota    *StorageQuota
+		Bytes    uint64
+		Captures uint
+	}
 )
@@ -0,0 +1,120 @@
+package objects
+
+import (
+	"sort"
+	"time"
+)
+
+// Kinds of the alerts raised by the scans
+const (
+	NewDeviceAlert  = "new-device"
+	MACChangedAlert = "mac-changed"
+)
+
+type (
+	// ScanDiff is what changed in the network between two ARP scans
+	ScanDiff struct {
+		New      []ScannedHost
+		Vanished []ScannedHost
+		Changes  []HostChange
+	}
+	// HostChange is an IP answered by a different MAC or a MAC found with a different IP
+	HostChange struct {
+		OldIP  string
+		NewIP  string
+		OldMAC string
+		NewMAC string
+	}
+	// Alert is a change in the network noticed by a scan against the inventory of its user
+	Alert struct {
+		Id          uint
+		UserId      uint
+		Kind        string
+		ScanName    string
+		IP          string
+		MAC         string
+		PreviousMAC string
+		Time        time.Time
+	}
+)
+
+// CompareScans lists the hosts of the new scan missing in the old one, the hosts of the old scan missing in the new one
+// and the hosts that changed their IP or MAC. A host is the same in both scans when it shares its IP or its MAC
+func CompareScans(oldHosts, newHosts []ScannedHost) *ScanDiff {
+	index := func(hosts []ScannedHost) (map[string]ScannedHost, map[string]ScannedHost) {
+		byIP, byMAC := map[string]ScannedHost{}, map[string]ScannedHost{}
+		for _, host := range hosts {
+			byIP[host.IP] = host
+			if len(host.MAC) > 0 {
+				byMAC[host.MAC] = host
+			}
+		}
+		return byIP, byMAC
+	}
+	oldByIP, oldByMAC := index(oldHosts)
+	newByIP, newByMAC := index(newHosts)
+	diff := &ScanDiff{}
+	for _, host := range newHosts {
+		oldHost, sameIP := oldByIP[host.IP]
+		_, sameMAC := oldByMAC[host.MAC]
+		sameMAC = sameMAC && len(host.MAC) > 0
+		// A host gets one change at most, the MAC answering its IP goes first and covers the MAC that moved to it
+		switch {
+		case !sameIP && !sameMAC:
+			diff.New = append(diff.New, host)
+		case sameIP && len(oldHost.MAC) > 0 && len(host.MAC) > 0 && oldHost.MAC != host.MAC:
+			diff.Changes = append(diff.Changes, HostChange{OldIP: host.IP, NewIP: host.IP, OldMAC: oldHost.MAC, NewMAC: host.MAC})
+		case sameMAC && oldByMAC[host.MAC].IP != host.IP:
+			diff.Changes = append(diff.Changes, HostChange{OldIP: oldByMAC[host.MAC].IP, NewIP: host.IP, OldMAC: host.MAC, NewMAC: host.MAC})
+		}
+	}
+	for _, host := range oldHosts {
+		_, sameIP := newByIP[host.IP]
+		_, sameMAC := newByMAC[host.MAC]
+		if !sameIP && !(sameMAC && len(host.MAC) > 0) {
+			diff.Vanished = append(diff.Vanished, host)
+		}
+	}
+	sort.Slice(diff.New, func(i, j int) bool {
+		return diff.New[i].IP < diff.New[j].IP
+	})
+	sort.Slice(diff.Vanished, func(i, j int) bool {
+		return diff.Vanished[i].IP < diff.Vanished[j].IP
+	})
+	return diff
+}
+
+// InventoryAlerts compares the hosts of a scan with the inventory of the user before the scan is merged. A MAC never
+// seen raises a new device alert and a known IP answered by another MAC a MAC changed alert. An inventory without
+// MACs is only a baseline in the making, so nothing is raised
+func InventoryAlerts(inventory []*InventoryHost, hosts interface{}, scanName string, finish time.Time) ([]*Alert, error) {
+	var scannedHosts []ScannedHost
+	convertError := convertJSON(hosts, &scannedHosts)
+	if convertError != nil {
+		return nil, convertError
+	}
+	knownMACs := map[string]struct{}{}
+	macByIP := map[string]string{}
+	for _, host := range inventory {
+		if len(host.MAC) == 0 {
+			continue
+		}
+		knownMACs[host.MAC] = struct{}{}
+		macByIP[host.IP] = host.MAC
+	}
+	if len(knownMACs) == 0 {
+		return nil, nil
+	}
+	var alerts []*Alert
+	for _, host := range scannedHosts {
+		if len(host.MAC) == 0 {
+			continue
+		}
+		if previous, found := macByIP[host.IP]; found && previous != host.MAC {
+			alerts = append(alerts, &Alert{Kind: MACChangedAlert, ScanName: scanName, IP: host.IP, MAC: host.MAC, PreviousMAC: previous, Time: finish})
+		} else if _, known := knownMACs[host.MAC]; !known {
+			alerts = append(alerts, &Alert{Kind: NewDeviceAlert, ScanName: scanName, IP: host.IP, MAC: host.MAC, Time: finish})
+		}
+	}
+	return alerts, nil
+}
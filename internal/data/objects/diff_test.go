@@ -0,0 +1,63 @@
+package objects
+
+import (
+	"testing"
+)
+
+func TestCompareScans(t *testing.T) {
+	oldHosts := []ScannedHost{
+		{IP: "192.0.2.10", MAC: "00:1b:21:00:00:0a"},
+		{IP: "192.0.2.11", MAC: "00:1b:21:00:00:0b"},
+		{IP: "192.0.2.12", MAC: "00:1b:21:00:00:0c"},
+	}
+	// The first host is kept, the second one vanishes, the third one moves to another IP and a new one appears
+	newHosts := []ScannedHost{
+		{IP: "192.0.2.10", MAC: "00:1b:21:00:00:0a"},
+		{IP: "192.0.2.20", MAC: "00:1b:21:00:00:0c"},
+		{IP: "192.0.2.13", MAC: "00:1b:21:00:00:0d"},
+	}
+	diff := CompareScans(oldHosts, newHosts)
+	if len(diff.New) != 1 || diff.New[0].IP != "192.0.2.13" {
+		t.Fatal(diff.New)
+	}
+	if len(diff.Vanished) != 1 || diff.Vanished[0].IP != "192.0.2.11" {
+		t.Fatal(diff.Vanished)
+	}
+	if len(diff.Changes) != 1 {
+		t.Fatal(diff.Changes)
+	}
+	change := diff.Changes[0]
+	if change.OldIP != "192.0.2.12" || change.NewIP != "192.0.2.20" || change.OldMAC != change.NewMAC {
+		t.Fatal(change)
+	}
+}
+
+func TestCompareScansOneChangePerHost(t *testing.T) {
+	oldHosts := []ScannedHost{
+		{IP: "192.0.2.10", MAC: "00:1b:21:00:00:0a"},
+		{IP: "192.0.2.11", MAC: "00:1b:21:00:00:0b"},
+	}
+	// The IP of the first host is answered by the MAC of the second one
+	newHosts := []ScannedHost{
+		{IP: "192.0.2.10", MAC: "00:1b:21:00:00:0b"},
+		{IP: "192.0.2.11", MAC: "00:1b:21:00:00:0b"},
+	}
+	diff := CompareScans(oldHosts, newHosts)
+	if len(diff.Changes) != 1 || len(diff.New) != 0 || len(diff.Vanished) != 0 {
+		t.Fatal(diff)
+	}
+	change := diff.Changes[0]
+	if change.OldIP != "192.0.2.10" || change.NewIP != "192.0.2.10" || change.OldMAC != "00:1b:21:00:00:0a" || change.NewMAC != "00:1b:21:00:00:0b" {
+		t.Fatal(change)
+	}
+}
+
+func TestCompareScansWithoutMACs(t *testing.T) {
+	diff := CompareScans(
+		[]ScannedHost{{IP: "192.0.2.10"}, {IP: "192.0.2.11"}},
+		[]ScannedHost{{IP: "192.0.2.11"}, {IP: "192.0.2.12"}},
+	)
+	if len(diff.New) != 1 || diff.New[0].IP != "192.0.2.12" || len(diff.Vanished) != 1 || diff.Vanished[0].IP != "192.0.2.10" || len(diff.Changes) != 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,237 @@
+package objects
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of the sessions that observe the hosts of the inventory
+const (
+	ARPScanSighting  = "arp-scan"
+	PortScanSighting = "port-scan"
+	CaptureSighting  = "capture"
+)
+
+type (
+	// InventoryHost is a host of the inventory of a user, merged from every scan and capture that observed it. Hosts
+	// are identified by their MAC and by their IP when the MAC is unknown
+	InventoryHost struct {
+		Id        uint
+		UserId    uint
+		IP        string
+		MAC       string
+		Vendor    string
+		OS        string
+		Addresses []string
+		Hostnames []string
+		Ports     []*InventoryPort
+		Sightings []*InventorySighting
+		FirstSeen time.Time
+		LastSeen  time.Time
+	}
+	InventoryPort struct {
+		Port     uint16
+		Protocol string
+		State    string
+		LastSeen time.Time
+	}
+	// InventorySighting is a session that observed the host
+	InventorySighting struct {
+		Kind string
+		Name string
+		Time time.Time
+	}
+	// InventoryObservation is what a session learned of a host
+	InventoryObservation struct {
+		IP        string
+		MAC       string
+		Vendor    string
+		OS        string
+		Hostnames []string
+		Ports     []*InventoryPort
+		FirstSeen time.Time
+		LastSeen  time.Time
+	}
+)
+
+// Identifies reports if the observation is of the host, a different MAC is a different host even with the same IP
+func (host *InventoryHost) Identifies(observation *InventoryObservation) bool {
+	if len(host.MAC) > 0 && len(observation.MAC) > 0 {
+		return host.MAC == observation.MAC
+	}
+	return len(observation.IP) > 0 && host.IP == observation.IP
+}
+
+func appendMissing(values []string, value string) []string {
+	if len(value) == 0 {
+		return values
+	}
+	for _, known := range values {
+		if known == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// Merge updates the host with the observation of the session, the newest labels replace the old ones
+func (host *InventoryHost) Merge(observation *InventoryObservation, sighting *InventorySighting) {
+	if len(host.MAC) == 0 {
+		host.MAC = observation.MAC
+	}
+	if len(observation.IP) > 0 {
+		host.IP = observation.IP
+		host.Addresses = appendMissing(host.Addresses, observation.IP)
+	}
+	if len(observation.Vendor) > 0 {
+		host.Vendor = observation.Vendor
+	}
+	if len(observation.OS) > 0 {
+		host.OS = observation.OS
+	}
+	for _, hostname := range observation.Hostnames {
+		host.Hostnames = appendMissing(host.Hostnames, hostname)
+	}
+	for _, port := range observation.Ports {
+		merged := false
+		for _, known := range host.Ports {
+			if known.Port == port.Port && known.Protocol == port.Protocol {
+				known.State, known.LastSeen = port.State, port.LastSeen
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			host.Ports = append(host.Ports, &InventoryPort{Port: port.Port, Protocol: port.Protocol, State: port.State, LastSeen: port.LastSeen})
+		}
+	}
+	sort.Slice(host.Ports, func(i, j int) bool {
+		if host.Ports[i].Protocol != host.Ports[j].Protocol {
+			return host.Ports[i].Protocol < host.Ports[j].Protocol
+		}
+		return host.Ports[i].Port < host.Ports[j].Port
+	})
+	seen := false
+	for _, known := range host.Sightings {
+		if known.Kind == sighting.Kind && known.Name == sighting.Name {
+			known.Time = sighting.Time
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		host.Sightings = append(host.Sightings, &InventorySighting{Kind: sighting.Kind, Name: sighting.Name, Time: sighting.Time})
+	}
+	if host.FirstSeen.IsZero() || observation.FirstSeen.Before(host.FirstSeen) {
+		host.FirstSeen = observation.FirstSeen
+	}
+	if observation.LastSeen.After(host.LastSeen) {
+		host.LastSeen = observation.LastSeen
+	}
+}
+
+// Search reports if any of the addresses, names, labels or ports of the host contain the query, case insensitive
+func (host *InventoryHost) Search(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if len(query) == 0 {
+		return true
+	}
+	values := append([]string{host.IP, host.MAC, host.Vendor, host.OS}, host.Addresses...)
+	values = append(values, host.Hostnames...)
+	for _, port := range host.Ports {
+		values = append(values, strconv.Itoa(int(port.Port))+"/"+port.Protocol)
+	}
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// ARPScanObservations turns the hosts of an ARP scan into observations
+func ARPScanObservations(hosts interface{}, finish time.Time) ([]*InventoryObservation, error) {
+	var scannedHosts []ScannedHost
+	convertError := convertJSON(hosts, &scannedHosts)
+	if convertError != nil {
+		return nil, convertError
+	}
+	observations := make([]*InventoryObservation, 0, len(scannedHosts))
+	for _, host := range scannedHosts {
+		observation := &InventoryObservation{
+			IP:        host.IP,
+			MAC:       host.MAC,
+			Vendor:    host.Vendor,
+			Hostnames: host.Hostnames,
+			FirstSeen: host.FirstSeen,
+			LastSeen:  host.LastSeen,
+		}
+		if observation.FirstSeen.IsZero() {
+			observation.FirstSeen, observation.LastSeen = finish, finish
+		}
+		observations = append(observations, observation)
+	}
+	return observations, nil
+}
+
+// PortScanObservations groups the ports of a port scan by host
+func PortScanObservations(results interface{}, finish time.Time) ([]*InventoryObservation, error) {
+	var ports []ScannedPort
+	convertError := convertJSON(results, &ports)
+	if convertError != nil {
+		return nil, convertError
+	}
+	var observations []*InventoryObservation
+	byHost := map[string]*InventoryObservation{}
+	for _, port := range ports {
+		observation, found := byHost[port.Host]
+		if !found {
+			observation = &InventoryObservation{IP: port.Host, FirstSeen: finish, LastSeen: finish}
+			byHost[port.Host] = observation
+			observations = append(observations, observation)
+		}
+		observation.Ports = append(observation.Ports, &InventoryPort{Port: port.Port, Protocol: port.Protocol, State: port.State, LastSeen: finish})
+	}
+	return observations, nil
+}
+
+// CaptureObservations turns the hosts of the local network and the fingerprinted hosts of a capture topology into
+// observations, the other remote hosts the capture talked to are not part of the inventory
+func CaptureObservations(topology interface{}, finish time.Time) ([]*InventoryObservation, error) {
+	topologyJson, marshalError := json.Marshal(topology)
+	if marshalError != nil {
+		return nil, marshalError
+	}
+	hosts, unmarshalError := TopologyHosts(topologyJson)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	var observations []*InventoryObservation
+	for _, host := range hosts {
+		ip := net.ParseIP(host.Host)
+		if ip == nil || !(ip.IsPrivate() || ip.IsLinkLocalUnicast() || len(host.OS) > 0) {
+			continue
+		}
+		observations = append(observations, &InventoryObservation{
+			IP:        host.Host,
+			Vendor:    host.Vendor,
+			OS:        host.OS,
+			FirstSeen: finish,
+			LastSeen:  finish,
+		})
+	}
+	return observations, nil
+}
+
+// convertJSON decodes the value as the target, sessions hand their results as the values they store as JSON
+func convertJSON(value, target interface{}) error {
+	contents, marshalError := json.Marshal(value)
+	if marshalError != nil {
+		return marshalError
+	}
+	return json.Unmarshal(contents, target)
+}
@@ -0,0 +1,50 @@
+package objects
+
+import "time"
+
+// Kinds of the sessions the scheduler runs
+const (
+	ARPScanJob = "arp-scan"
+	CaptureJob = "capture"
+)
+
+type (
+	// Job is a saved ARP scan or capture definition the scheduler runs on behalf of its user, at the times of its
+	// cron Schedule or every Interval seconds when there is no schedule
+	Job struct {
+		Id        uint
+		UserId    uint
+		Name      string
+		Kind      string
+		Interface string
+		Schedule  string
+		Interval  uint
+		// Duration in seconds the session runs at most, zero lets ARP scans finish on their own
+		Duration uint
+		// MaxPackets stops captures after that number of packets, zero means no limit
+		MaxPackets  uint
+		Script      string
+		Description string
+		Promiscuous bool
+		Targets     string
+		Exclude     string
+		Method      string
+		Alerts      bool
+		Created     time.Time
+	}
+	// JobRun is an execution of a Job, Session is the name of the ARP scan or capture it stored
+	JobRun struct {
+		Id       uint
+		JobId    uint
+		Session  string
+		Started  time.Time
+		Finished time.Time
+		Succeed  bool
+		Message  string
+		Packets  uint64
+	}
+	JobAdminView struct {
+		User *User
+		Job  *Job
+	}
+)
@@ -1,6 +1,11 @@
 package objects
 
-import "strconv"
+import (
+	"encoding/json"
+	"github.com/shoriwe/CAPitan/internal/fingerprint"
+	"sort"
+	"strconv"
+)
 
 type (
 	Topology struct {
@@ -15,6 +20,10 @@ type (
 			Name     string
 			Category int
 		}
+		// Vendors of the MACs seen with the vertices of the local network
+		Vendors map[string]string
+		// Fingerprints are the guesses of the systems of the vertices made from their traffic
+		Fingerprints map[string][]fingerprint.Guess
 	}
 	Counter struct {
 		numberOfHosts int
@@ -31,6 +40,8 @@ func NewTopology() *Topology {
 		nextVertexId:       1,
 		Categories:         nil,
 		Edges:              map[string]map[string]struct{}{},
+		Vendors:            map[string]string{},
+		Fingerprints:       map[string][]fingerprint.Guess{},
 		Vertices: map[string]struct {
 			Id       string
 			Name     string
@@ -74,6 +85,26 @@ func (topology *Topology) addEdge(from, to string) bool {
 	return false
 }
 
+// SetVendor labels the vertex with the vendor of its MAC, true is returned when the label changes
+func (topology *Topology) SetVendor(name, vendor string) bool {
+	if _, found := topology.Vertices[name]; !found || len(vendor) == 0 || topology.Vendors[name] == vendor {
+		return false
+	}
+	topology.Vendors[name] = vendor
+	return true
+}
+
+// SetFingerprints replaces the guesses of the system of the host, the vertex is added when the host was not seen
+// yet. True is returned when the guesses change
+func (topology *Topology) SetFingerprints(name string, guesses []fingerprint.Guess) bool {
+	if len(guesses) == len(topology.Fingerprints[name]) {
+		return false
+	}
+	topology.AddVertex(name)
+	topology.Fingerprints[name] = append([]fingerprint.Guess(nil), guesses...)
+	return true
+}
+
 func (topology *Topology) AddEdge(from, to string) bool {
 	topology.AddVertex(from)
 	topology.AddVertex(to)
@@ -82,23 +113,32 @@ func (topology *Topology) AddEdge(from, to string) bool {
 
 func (topology *Topology) Options() interface{} {
 	var vertices = make([]struct {
-		Id       string  `json:"id"`
-		Name     string  `json:"name"`
-		Category int     `json:"category"`
-		Value    float64 `json:"value"`
+		Id           string              `json:"id"`
+		Name         string              `json:"name"`
+		Category     int                 `json:"category"`
+		Value        float64             `json:"value"`
+		Vendor       string              `json:"vendor,omitempty"`
+		OS           string              `json:"os,omitempty"`
+		Fingerprints []fingerprint.Guess `json:"fingerprints,omitempty"`
 	}, topology.numberOfVertices)
 	dataIndex := 0
 	for _, vertex := range topology.Vertices {
 		vertices[dataIndex] = struct {
-			Id       string  `json:"id"`
-			Name     string  `json:"name"`
-			Category int     `json:"category"`
-			Value    float64 `json:"value"`
+			Id           string              `json:"id"`
+			Name         string              `json:"name"`
+			Category     int                 `json:"category"`
+			Value        float64             `json:"value"`
+			Vendor       string              `json:"vendor,omitempty"`
+			OS           string              `json:"os,omitempty"`
+			Fingerprints []fingerprint.Guess `json:"fingerprints,omitempty"`
 		}{
-			Id:       vertex.Id,
-			Name:     vertex.Name,
-			Category: vertex.Category,
-			Value:    1,
+			Id:           vertex.Id,
+			Name:         vertex.Name,
+			Category:     vertex.Category,
+			Value:        1,
+			Vendor:       topology.Vendors[vertex.Name],
+			OS:           fingerprint.Best(topology.Fingerprints[vertex.Name]),
+			Fingerprints: topology.Fingerprints[vertex.Name],
 		}
 		dataIndex++
 	}
@@ -131,10 +171,13 @@ func (topology *Topology) Options() interface{} {
 	}
 	return struct {
 		Vertices []struct {
-			Id       string  `json:"id"`
-			Name     string  `json:"name"`
-			Category int     `json:"category"`
-			Value    float64 `json:"value"`
+			Id           string              `json:"id"`
+			Name         string              `json:"name"`
+			Category     int                 `json:"category"`
+			Value        float64             `json:"value"`
+			Vendor       string              `json:"vendor,omitempty"`
+			OS           string              `json:"os,omitempty"`
+			Fingerprints []fingerprint.Guess `json:"fingerprints,omitempty"`
 		}
 		Edges []struct {
 			Source string `json:"source"`
@@ -150,6 +193,43 @@ func (topology *Topology) Options() interface{} {
 	}
 }
 
+// TopologyHost is a vertex of a stored topology with the labels of its host
+type TopologyHost struct {
+	Host         string
+	Vendor       string              `json:",omitempty"`
+	OS           string              `json:",omitempty"`
+	Fingerprints []fingerprint.Guess `json:",omitempty"`
+}
+
+// TopologyHosts lists the hosts of the topology stored with a capture
+func TopologyHosts(topologyJson []byte) ([]TopologyHost, error) {
+	var topology struct {
+		Vertices []struct {
+			Name         string              `json:"name"`
+			Vendor       string              `json:"vendor"`
+			OS           string              `json:"os"`
+			Fingerprints []fingerprint.Guess `json:"fingerprints"`
+		}
+	}
+	unmarshalError := json.Unmarshal(topologyJson, &topology)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	hosts := make([]TopologyHost, 0, len(topology.Vertices))
+	for _, vertex := range topology.Vertices {
+		hosts = append(hosts, TopologyHost{
+			Host:         vertex.Name,
+			Vendor:       vertex.Vendor,
+			OS:           vertex.OS,
+			Fingerprints: vertex.Fingerprints,
+		})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts, nil
+}
+
 func NewCounter() *Counter {
 	return &Counter{
 		numberOfHosts: 0,
@@ -0,0 +1,85 @@
+package objects
+
+import "fmt"
+
+const (
+	AdminRole = "admin"
+	UserRole  = "user"
+	// QuotaWarningRatio is the fraction of the byte quota after which the user is warned
+	QuotaWarningRatio = 0.8
+)
+
+func FormatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func RoleOf(user *User) string {
+	if user.IsAdmin {
+		return AdminRole
+	}
+	return UserRole
+}
+
+// CanCreateCapture checks if the user can store one more capture without exceeding any of its limits
+func (usage *StorageUsage) CanCreateCapture() bool {
+	if usage.Quota.MaxCaptures > 0 && usage.Captures >= usage.Quota.MaxCaptures {
+		return false
+	}
+	return !usage.Exceeded(0)
+}
+
+func (usage *StorageUsage) Exceeded(extraBytes uint64) bool {
+	if usage.Quota.MaxBytes == 0 {
+		return false
+	}
+	return usage.Bytes+extraBytes >= usage.Quota.MaxBytes
+}
+
+func (usage *StorageUsage) NearLimit(extraBytes uint64) bool {
+	if usage.Quota.MaxBytes == 0 {
+		return false
+	}
+	return float64(usage.Bytes+extraBytes) >= float64(usage.Quota.MaxBytes)*QuotaWarningRatio
+}
+
+func (usage *StorageUsage) UsedString() string {
+	return FormatBytes(usage.Bytes)
+}
+
+func (usage *StorageUsage) LimitString() string {
+	if usage.Quota.MaxBytes == 0 {
+		return "Unlimited"
+	}
+	return FormatBytes(usage.Quota.MaxBytes)
+}
+
+func (usage *StorageUsage) CapturesLimitString() string {
+	if usage.Quota.MaxCaptures == 0 {
+		return "Unlimited"
+	}
+	return fmt.Sprint(usage.Quota.MaxCaptures)
+}
+
+func (usage *StorageUsage) Percentage() int {
+	if usage.Quota.MaxBytes == 0 {
+		return 0
+	}
+	return int(usage.Bytes * 100 / usage.Quota.MaxBytes)
+}
+
+func (usage *StorageUsage) IsCustom() bool {
+	return usage.Quota.UsersId != 0
+}
+
+func (quota *StorageQuota) MaxMegabytes() uint64 {
+	return quota.MaxBytes / (1024 * 1024)
+}
@@ -0,0 +1,67 @@
+package fingerprint
+
+import (
+	"github.com/google/gopacket/layers"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dhcpSignatures are the parameter request lists (option 55) the DHCP clients of the systems send
+var dhcpSignatures = map[string]string{
+	"1,3,6,15,31,33,43,44,46,47,119,121,249,252":         "Windows 10 or 11",
+	"1,15,3,6,44,46,47,31,33,121,249,43,252":             "Windows Vista",
+	"1,15,3,6,44,46,47,31,33,121,249,43":                 "Windows 7 or 8",
+	"1,15,3,6,44,46,47,31,33,249,43":                     "Windows XP",
+	"1,121,3,6,15,119,252,95,44,46":                      "macOS",
+	"1,121,3,6,15,108,114,119,252,95,44,46":              "macOS",
+	"1,121,3,6,15,119,252":                               "iOS",
+	"1,121,3,6,15,108,114,119,252":                       "iOS",
+	"1,3,6,15,26,28,51,58,59,43":                         "Android",
+	"1,3,6,15,26,28,51,58,59,43,114":                     "Android",
+	"1,3,6,15,26,28,51,58,59,43,114,108":                 "Android",
+	"1,28,2,3,15,6,119,12,44,47,26,121,42":               "Linux (dhclient)",
+	"1,28,2,3,15,6,119,12,44,47,26,121,42,249,33,252,17": "Linux (NetworkManager)",
+	"1,3,6,12,15,28,42":                                  "Embedded Linux (udhcpc)",
+}
+
+// parameterRequestList joins the options requested by the client in the order they were sent
+func parameterRequestList(dhcp *layers.DHCPv4) string {
+	for _, option := range dhcp.Options {
+		if option.Type != layers.DHCPOptParamsRequest {
+			continue
+		}
+		parameters := make([]string, len(option.Data))
+		for index, parameter := range option.Data {
+			parameters[index] = strconv.Itoa(int(parameter))
+		}
+		return strings.Join(parameters, ",")
+	}
+	return ""
+}
+
+// dhcpClientAddress is the address the client has or asks for, nil is returned before it is known
+func dhcpClientAddress(dhcp *layers.DHCPv4) net.IP {
+	if !dhcp.ClientIP.IsUnspecified() {
+		return dhcp.ClientIP
+	}
+	for _, option := range dhcp.Options {
+		if option.Type == layers.DHCPOptRequestIP && len(option.Data) == net.IPv4len {
+			return net.IP(option.Data)
+		}
+	}
+	return nil
+}
+
+// dhcpGuess fingerprints the requests of the DHCP clients
+func dhcpGuess(dhcp *layers.DHCPv4) (Guess, bool) {
+	if dhcp.Operation != layers.DHCPOpRequest {
+		return Guess{}, false
+	}
+	parameters := parameterRequestList(dhcp)
+	label, found := dhcpSignatures[parameters]
+	if !found {
+		return Guess{}, false
+	}
+	return Guess{Method: DHCPMethod, Label: label, Evidence: "option 55 " + parameters}, true
+}
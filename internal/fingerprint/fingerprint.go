@@ -0,0 +1,161 @@
+package fingerprint
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"net"
+	"sync"
+)
+
+// Methods of the guesses, in the order of their reliability. TTL guesses are made from the initial TTL of the SYNs
+// whose options match no known system
+const (
+	HTTPMethod = "http"
+	DHCPMethod = "dhcp"
+	TCPMethod  = "tcp"
+	TTLMethod  = "ttl"
+)
+
+// maximumGuesses limits the different guesses kept for every host and maximumPending the DHCP clients waiting for
+// their address, the clients that never get one are not kept forever
+const (
+	maximumGuesses = 16
+	maximumPending = 1024
+)
+
+var methodRank = map[string]int{
+	HTTPMethod: 0,
+	DHCPMethod: 1,
+	TCPMethod:  2,
+	TTLMethod:  3,
+}
+
+// Guess is a system or device a host is believed to be, with the traffic it was derived from
+type Guess struct {
+	Method   string
+	Label    string
+	Evidence string
+}
+
+// Best returns the label of the most reliable guess
+func Best(guesses []Guess) string {
+	var (
+		label string
+		rank  = len(methodRank)
+	)
+	for _, guess := range guesses {
+		if methodRank[guess.Method] < rank {
+			label, rank = guess.Label, methodRank[guess.Method]
+		}
+	}
+	return label
+}
+
+// Fingerprinter guesses the systems of the hosts from the traffic they send, without sending anything
+type Fingerprinter struct {
+	mutex *sync.Mutex
+	hosts map[string][]Guess
+	// pending keeps the guesses of the DHCP clients by MAC until their address is known
+	pending map[string][]Guess
+	// changed are the hosts with new guesses since the last call to Changes
+	changed map[string]struct{}
+}
+
+func NewFingerprinter() *Fingerprinter {
+	return &Fingerprinter{
+		mutex:   new(sync.Mutex),
+		hosts:   map[string][]Guess{},
+		pending: map[string][]Guess{},
+		changed: map[string]struct{}{},
+	}
+}
+
+func addGuess(guesses []Guess, guess Guess) ([]Guess, bool) {
+	if len(guesses) >= maximumGuesses {
+		return guesses, false
+	}
+	for _, known := range guesses {
+		if known == guess {
+			return guesses, false
+		}
+	}
+	return append(guesses, guess), true
+}
+
+// attach adds the guess to the host
+func (fingerprinter *Fingerprinter) attach(host string, guess Guess) {
+	var added bool
+	fingerprinter.hosts[host], added = addGuess(fingerprinter.hosts[host], guess)
+	if added {
+		fingerprinter.changed[host] = struct{}{}
+	}
+}
+
+// resolve moves the pending guesses of the MAC to the host
+func (fingerprinter *Fingerprinter) resolve(mac net.HardwareAddr, host net.IP) {
+	guesses, found := fingerprinter.pending[mac.String()]
+	if !found || !host.IsGlobalUnicast() {
+		return
+	}
+	delete(fingerprinter.pending, mac.String())
+	for _, guess := range guesses {
+		fingerprinter.attach(host.String(), guess)
+	}
+}
+
+// Observe fingerprints the packet
+func (fingerprinter *Fingerprinter) Observe(packet gopacket.Packet) {
+	networkLayer := packet.NetworkLayer()
+	if networkLayer == nil {
+		return
+	}
+	fingerprinter.mutex.Lock()
+	defer fingerprinter.mutex.Unlock()
+	source := net.IP(networkLayer.NetworkFlow().Src().Raw())
+	if ethernetLayer, isEthernet := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); isEthernet {
+		fingerprinter.resolve(ethernetLayer.SrcMAC, source)
+	}
+	if dhcp, isDHCP := packet.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4); isDHCP {
+		if dhcp.Operation == layers.DHCPOpReply {
+			fingerprinter.resolve(dhcp.ClientHWAddr, dhcp.YourClientIP)
+		} else if guess, found := dhcpGuess(dhcp); found {
+			if client := dhcpClientAddress(dhcp); client != nil {
+				fingerprinter.attach(client.String(), guess)
+			} else {
+				mac := dhcp.ClientHWAddr.String()
+				if _, found := fingerprinter.pending[mac]; found || len(fingerprinter.pending) < maximumPending {
+					fingerprinter.pending[mac], _ = addGuess(fingerprinter.pending[mac], guess)
+				}
+			}
+		}
+	}
+	tcp, isTCP := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !isTCP {
+		return
+	}
+	var ttl uint8
+	switch network := networkLayer.(type) {
+	case *layers.IPv4:
+		ttl = network.TTL
+	case *layers.IPv6:
+		ttl = network.HopLimit
+	}
+	if guess, found := tcpGuess(ttl, tcp); found {
+		fingerprinter.attach(source.String(), guess)
+	}
+	if guess, found := httpGuess(tcp.Payload); found {
+		fingerprinter.attach(source.String(), guess)
+	}
+}
+
+// Changes returns the guesses of the hosts that changed since the last call
+func (fingerprinter *Fingerprinter) Changes() map[string][]Guess {
+	fingerprinter.mutex.Lock()
+	defer fingerprinter.mutex.Unlock()
+	changes := make(map[string][]Guess, len(fingerprinter.changed))
+	for host := range fingerprinter.changed {
+		changes[host] = append([]Guess(nil), fingerprinter.hosts[host]...)
+	}
+	fingerprinter.changed = map[string]struct{}{}
+	return changes
+}
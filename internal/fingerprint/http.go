@@ -0,0 +1,100 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// maximumUserAgent limits the length of the User-Agents kept as evidence
+const maximumUserAgent = 256
+
+var (
+	httpMethods = [][]byte{
+		[]byte("GET "), []byte("POST "), []byte("HEAD "), []byte("PUT "), []byte("DELETE "),
+		[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "),
+	}
+	androidVersion = regexp.MustCompile(`Android ([0-9.]+)`)
+)
+
+// userAgentRule labels the User-Agents that contain any of the tokens, devices come first since their
+// User-Agents also name the system they run
+type userAgentRule struct {
+	tokens []string
+	label  string
+}
+
+var userAgentRules = []userAgentRule{
+	{tokens: []string{"Windows Phone"}, label: "Windows Phone"},
+	{tokens: []string{"Xbox"}, label: "Xbox"},
+	{tokens: []string{"PlayStation"}, label: "PlayStation"},
+	{tokens: []string{"Nintendo"}, label: "Nintendo console"},
+	{tokens: []string{"SMART-TV", "SmartTV", "Tizen", "Web0S"}, label: "Smart TV"},
+	{tokens: []string{"iPhone"}, label: "iOS (iPhone)"},
+	{tokens: []string{"iPad"}, label: "iPadOS"},
+	{tokens: []string{"Android"}, label: "Android"},
+	{tokens: []string{"CrOS"}, label: "ChromeOS"},
+	{tokens: []string{"Windows NT 10.0"}, label: "Windows 10 or 11"},
+	{tokens: []string{"Windows NT 6.3"}, label: "Windows 8.1"},
+	{tokens: []string{"Windows NT 6.2"}, label: "Windows 8"},
+	{tokens: []string{"Windows NT 6.1"}, label: "Windows 7"},
+	{tokens: []string{"Windows NT 6.0"}, label: "Windows Vista"},
+	{tokens: []string{"Windows NT 5.1"}, label: "Windows XP"},
+	{tokens: []string{"Macintosh", "Mac OS X"}, label: "macOS"},
+	{tokens: []string{"Linux"}, label: "Linux"},
+}
+
+// userAgent returns the User-Agent header of the HTTP request in the payload
+func userAgent(payload []byte) string {
+	isRequest := false
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(payload, method) {
+			isRequest = true
+			break
+		}
+	}
+	if !isRequest {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	// Skip the request line
+	scanner.Scan()
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			break
+		}
+		header := strings.SplitN(line, ":", 2)
+		if len(header) == 2 && strings.EqualFold(strings.TrimSpace(header[0]), "User-Agent") {
+			return strings.TrimSpace(header[1])
+		}
+	}
+	return ""
+}
+
+// httpGuess fingerprints the clients by the User-Agents of their HTTP requests
+func httpGuess(payload []byte) (Guess, bool) {
+	agent := userAgent(payload)
+	if len(agent) == 0 {
+		return Guess{}, false
+	}
+	for _, rule := range userAgentRules {
+		for _, token := range rule.tokens {
+			if !strings.Contains(agent, token) {
+				continue
+			}
+			label := rule.label
+			if label == "Android" {
+				if version := androidVersion.FindStringSubmatch(agent); version != nil {
+					label += " " + version[1]
+				}
+			}
+			if len(agent) > maximumUserAgent {
+				agent = agent[:maximumUserAgent]
+			}
+			return Guess{Method: HTTPMethod, Label: label, Evidence: agent}, true
+		}
+	}
+	return Guess{}, false
+}
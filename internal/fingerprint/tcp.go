@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"fmt"
+	"github.com/google/gopacket/layers"
+	"strings"
+)
+
+// tcpSignature matches the SYN of the network stack of an operating system by its initial TTL and the order of its
+// TCP options, window and mss are only compared when they are not zero
+type tcpSignature struct {
+	label   string
+	ttl     uint8
+	options string
+	window  uint16
+	mss     uint16
+}
+
+var tcpSignatures = []tcpSignature{
+	{label: "Linux or Android", ttl: 64, options: "M,S,T,N,W"},
+	{label: "macOS or iOS", ttl: 64, options: "M,N,W,N,N,T,S,E"},
+	{label: "FreeBSD", ttl: 64, options: "M,N,W,S,T"},
+	{label: "OpenBSD", ttl: 64, options: "M,N,N,S,N,W,N,N,T"},
+	{label: "Windows", ttl: 128, options: "M,N,W,N,N,S"},
+	{label: "Windows XP", ttl: 128, options: "M,N,N,S", window: 65535},
+	{label: "Windows XP", ttl: 128, options: "M,N,N,S", window: 64240, mss: 1460},
+	{label: "Cisco IOS", ttl: 255, options: "M", window: 4128, mss: 536},
+}
+
+// ttlLabels guess the family of the system when the options of the SYN are unknown
+var ttlLabels = map[uint8]string{
+	64:  "Unix-like",
+	128: "Windows",
+	255: "Network device",
+}
+
+// initialTTL rounds the TTL up to the default of the systems that sent it, every router on the way decrements it
+func initialTTL(ttl uint8) uint8 {
+	switch {
+	case ttl <= 32:
+		return 32
+	case ttl <= 64:
+		return 64
+	case ttl <= 128:
+		return 128
+	}
+	return 255
+}
+
+// optionsOrder abbreviates the kinds of the options in the order they were sent
+func optionsOrder(options []layers.TCPOption) (string, uint16) {
+	var (
+		kinds []string
+		mss   uint16
+	)
+	for _, option := range options {
+		switch option.OptionType {
+		case layers.TCPOptionKindMSS:
+			kinds = append(kinds, "M")
+			if len(option.OptionData) == 2 {
+				mss = uint16(option.OptionData[0])<<8 | uint16(option.OptionData[1])
+			}
+		case layers.TCPOptionKindNop:
+			kinds = append(kinds, "N")
+		case layers.TCPOptionKindWindowScale:
+			kinds = append(kinds, "W")
+		case layers.TCPOptionKindSACKPermitted:
+			kinds = append(kinds, "S")
+		case layers.TCPOptionKindTimestamps:
+			kinds = append(kinds, "T")
+		case layers.TCPOptionKindEndList:
+			kinds = append(kinds, "E")
+		default:
+			kinds = append(kinds, fmt.Sprint(uint8(option.OptionType)))
+		}
+	}
+	return strings.Join(kinds, ","), mss
+}
+
+// tcpGuess fingerprints the SYNs without ACK that start the connections, the answers and the established traffic
+// depend on the peer
+func tcpGuess(ttl uint8, tcp *layers.TCP) (Guess, bool) {
+	if !tcp.SYN || tcp.ACK {
+		return Guess{}, false
+	}
+	initial := initialTTL(ttl)
+	options, mss := optionsOrder(tcp.Options)
+	evidence := fmt.Sprintf("ttl %d, window %d, mss %d, options %s", initial, tcp.Window, mss, options)
+	for _, signature := range tcpSignatures {
+		if signature.ttl == initial && signature.options == options &&
+			(signature.window == 0 || signature.window == tcp.Window) && (signature.mss == 0 || signature.mss == mss) {
+			return Guess{Method: TCPMethod, Label: signature.label, Evidence: evidence}, true
+		}
+	}
+	if label, found := ttlLabels[initial]; found {
+		return Guess{Method: TTLMethod, Label: label, Evidence: evidence}, true
+	}
+	return Guess{}, false
+}
@@ -0,0 +1,214 @@
+package inject
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket/pcap"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/gplasma"
+	"github.com/shoriwe/gplasma/pkg/std/features/importlib"
+	"github.com/shoriwe/gplasma/pkg/std/modules/base64"
+	"github.com/shoriwe/gplasma/pkg/std/modules/json"
+	"github.com/shoriwe/gplasma/pkg/std/modules/regex"
+	"github.com/shoriwe/gplasma/pkg/vm"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of the injection sessions, the rate is in frames per second
+const (
+	DefaultRate      = 10
+	MaximumRate      = 1000
+	DefaultMaxFrames = 1000
+	MaximumFrames    = 100000
+)
+
+var (
+	injectionStopped             = errors.New("injection stopped")
+	failedToConvertErrorToString = errors.New("unknown error happen when executing script")
+)
+
+// Engine runs the scripts that build and send frames on an interface, every frame sent is reported through Frames
+type Engine struct {
+	interfaceMAC   net.HardwareAddr
+	interfaceIP    net.IP
+	interfaceIPv6  net.IP
+	handle         *pcap.Handle
+	interval       time.Duration
+	maxFrames      uint64
+	lastSent       time.Time
+	framesSent     uint64
+	stopped        int32
+	Frames         chan objects.InjectedFrame
+	VirtualMachine *gplasma.VirtualMachine
+	machineContext *vm.Context
+}
+
+// FramesSent returns the number of frames written to the interface
+func (engine *Engine) FramesSent() uint64 {
+	return atomic.LoadUint64(&engine.framesSent)
+}
+
+// Stop makes the next call to Inject fail, so the running script finishes
+func (engine *Engine) Stop() {
+	atomic.StoreInt32(&engine.stopped, 1)
+}
+
+func (engine *Engine) Close() {
+	engine.Stop()
+	engine.handle.Close()
+}
+
+func (engine *Engine) inject(values []*vm.Value) (int, error) {
+	if atomic.LoadInt32(&engine.stopped) == 1 {
+		return 0, injectionStopped
+	}
+	if engine.FramesSent() >= engine.maxFrames {
+		return 0, fmt.Errorf("limit of %d frames reached", engine.maxFrames)
+	}
+	frame, summary, buildError := engine.buildFrame(values)
+	if buildError != nil {
+		return 0, buildError
+	}
+	if wait := engine.interval - time.Since(engine.lastSent); wait > 0 {
+		time.Sleep(wait)
+		if atomic.LoadInt32(&engine.stopped) == 1 {
+			return 0, injectionStopped
+		}
+	}
+	writeError := engine.handle.WritePacketData(frame)
+	if writeError != nil {
+		return 0, writeError
+	}
+	engine.lastSent = time.Now()
+	atomic.AddUint64(&engine.framesSent, 1)
+	engine.Frames <- objects.InjectedFrame{
+		Time:     engine.lastSent,
+		Length:   len(frame),
+		Summary:  summary,
+		Contents: frame,
+	}
+	return len(frame), nil
+}
+
+func (engine *Engine) scriptError(result *vm.Value) error {
+	toString, getError := result.Get(engine.VirtualMachine.Plasma, engine.machineContext, vm.ToString)
+	if getError != nil {
+		return failedToConvertErrorToString
+	}
+	errorAsString, succeed := engine.VirtualMachine.CallFunction(engine.machineContext, toString)
+	if !succeed {
+		return failedToConvertErrorToString
+	}
+	return errors.New(errorAsString.String)
+}
+
+// layerConstructor returns a function that creates the hash table of the layer, with the arguments stored in the
+// named fields
+func layerConstructor(name string, fieldNames ...string) func(context *vm.Context, plasma *vm.Plasma) *vm.Value {
+	return func(context *vm.Context, plasma *vm.Plasma) *vm.Value {
+		return plasma.NewFunction(context, true, plasma.BuiltInSymbols(),
+			vm.NewBuiltInFunction(len(fieldNames),
+				func(self *vm.Value, arguments ...*vm.Value) (*vm.Value, bool) {
+					layer := plasma.NewHashTable(context, false)
+					result, succeed := plasma.HashIndexAssign(context, layer, plasma.NewString(context, false, "Layer"), plasma.NewString(context, false, name))
+					if !succeed {
+						return result, false
+					}
+					for index, fieldName := range fieldNames {
+						result, succeed = plasma.HashIndexAssign(context, layer, plasma.NewString(context, false, fieldName), arguments[index])
+						if !succeed {
+							return result, false
+						}
+					}
+					return layer, true
+				},
+			),
+		)
+	}
+}
+
+func (engine *Engine) loadVMFeatures() vm.Feature {
+	return vm.Feature{
+		EthernetLayer: layerConstructor(EthernetLayer, "Source", "Destination"),
+		ARPLayer:      layerConstructor(ARPLayer, "Operation", "SenderMAC", "SenderIP", "TargetMAC", "TargetIP"),
+		IPv4Layer:     layerConstructor(IPv4Layer, "Source", "Destination"),
+		IPv6Layer:     layerConstructor(IPv6Layer, "Source", "Destination"),
+		TCPLayer:      layerConstructor(TCPLayer, "SourcePort", "DestinationPort", "Flags", "Sequence", "Acknowledgment"),
+		UDPLayer:      layerConstructor(UDPLayer, "SourcePort", "DestinationPort"),
+		ICMPLayer:     layerConstructor(ICMPLayer, "Type", "Code"),
+		DNSLayer:      layerConstructor(DNSLayer, "Id", "Name", "Type"),
+		PayloadLayer:  layerConstructor(PayloadLayer, "Content"),
+		"Inject": func(context *vm.Context, plasma *vm.Plasma) *vm.Value {
+			return plasma.NewFunction(context, true, plasma.BuiltInSymbols(),
+				vm.NewBuiltInFunction(1,
+					func(self *vm.Value, arguments ...*vm.Value) (*vm.Value, bool) {
+						if !arguments[0].IsTypeById(vm.ArrayId) && !arguments[0].IsTypeById(vm.TupleId) {
+							return plasma.NewInvalidTypeError(context, arguments[0].TypeName(), vm.ArrayName, vm.TupleName), false
+						}
+						length, injectError := engine.inject(arguments[0].Content)
+						if injectError != nil {
+							return plasma.NewGoRuntimeError(context, injectError), false
+						}
+						return plasma.NewInteger(context, false, int64(length)), true
+					},
+				),
+			)
+		},
+	}
+}
+
+// Run executes the script until it finishes, fails or the engine is stopped. Frames are built by passing to
+// Inject an array of layers created with Ethernet, ARP, IPv4, IPv6, TCP, UDP, ICMP, DNS and Payload, None
+// in an address field means the address of the interface
+func (engine *Engine) Run(script string) error {
+	result, succeed := engine.VirtualMachine.ExecuteMain(script)
+	if !succeed {
+		return engine.scriptError(result)
+	}
+	return nil
+}
+
+// NewEngine opens the interface for an injection sending at most rate frames per second and maxFrames in total
+func NewEngine(interfaceName string, rate, maxFrames uint) (*Engine, error) {
+	if rate == 0 || rate > MaximumRate {
+		return nil, fmt.Errorf("rate must be between 1 and %d frames per second", MaximumRate)
+	}
+	if maxFrames == 0 || maxFrames > MaximumFrames {
+		return nil, fmt.Errorf("frame limit must be between 1 and %d", MaximumFrames)
+	}
+	interfaceMAC, interfaceIP, findError := tools.FindInterfaceIpAndMac(interfaceName)
+	if findError != nil {
+		return nil, findError
+	}
+	// Without an IPv6 address the scripts must set the source of their IPv6 layers
+	interfaceIPv6, _ := tools.FindInterfaceIPv6(interfaceName, interfaceMAC)
+	handle, openError := pcap.OpenLive(interfaceName, 65536, false, pcap.BlockForever)
+	if openError != nil {
+		return nil, openError
+	}
+	engine := &Engine{
+		interfaceMAC:  interfaceMAC,
+		interfaceIP:   interfaceIP.To4(),
+		interfaceIPv6: interfaceIPv6,
+		handle:        handle,
+		interval:      time.Second / time.Duration(rate),
+		maxFrames:     uint64(maxFrames),
+		Frames:        make(chan objects.InjectedFrame, 1000),
+	}
+	engine.VirtualMachine = gplasma.NewVirtualMachine()
+	engine.VirtualMachine.Stdin = &bytes.Buffer{}
+	engine.VirtualMachine.Stdout = &bytes.Buffer{}
+	engine.VirtualMachine.Stderr = &bytes.Buffer{}
+	engine.VirtualMachine.LoadFeature(engine.loadVMFeatures())
+	importer := importlib.NewImporter()
+	importer.LoadModule(json.JSON)
+	importer.LoadModule(base64.Base64)
+	importer.LoadModule(regex.Regex)
+	engine.VirtualMachine.LoadFeature(importer.Result(&tools.SecuredFileSystem{}, &tools.SecuredFileSystem{}))
+	engine.machineContext = engine.VirtualMachine.NewContext()
+	return engine, nil
+}
@@ -0,0 +1,455 @@
+package inject
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/shoriwe/gplasma/pkg/vm"
+	"net"
+	"strings"
+)
+
+// Names of the layers the scripts can build, stored in the Layer key of their hash tables
+const (
+	EthernetLayer = "Ethernet"
+	ARPLayer      = "ARP"
+	IPv4Layer     = "IPv4"
+	IPv6Layer     = "IPv6"
+	TCPLayer      = "TCP"
+	UDPLayer      = "UDP"
+	ICMPLayer     = "ICMP"
+	DNSLayer      = "DNS"
+	PayloadLayer  = "Payload"
+)
+
+const defaultHopLimit = 64
+
+var dnsTypes = map[string]layers.DNSType{
+	"A":     layers.DNSTypeA,
+	"AAAA":  layers.DNSTypeAAAA,
+	"ANY":   layers.DNSType(255), // gopacket has no name for the QTYPE of every record
+	"CNAME": layers.DNSTypeCNAME,
+	"MX":    layers.DNSTypeMX,
+	"NS":    layers.DNSTypeNS,
+	"PTR":   layers.DNSTypePTR,
+	"SOA":   layers.DNSTypeSOA,
+	"SRV":   layers.DNSTypeSRV,
+	"TXT":   layers.DNSTypeTXT,
+}
+
+// fields is the decoded content of a layer hash table
+type fields map[string]*vm.Value
+
+func decodeLayer(value *vm.Value) (fields, error) {
+	if !value.IsTypeById(vm.HashTableId) {
+		return nil, fmt.Errorf("layers must be hash tables, received %s", value.TypeName())
+	}
+	result := fields{}
+	for _, keyValues := range value.KeyValues {
+		for _, keyValue := range keyValues {
+			if keyValue.Key.IsTypeById(vm.StringId) {
+				result[keyValue.Key.String] = keyValue.Value
+			}
+		}
+	}
+	return result, nil
+}
+
+func (f fields) isNone(name string) bool {
+	value, found := f[name]
+	return !found || value.IsTypeById(vm.NoneId)
+}
+
+func (f fields) string(name string) (string, error) {
+	value, found := f[name]
+	if !found || !value.IsTypeById(vm.StringId) {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	return value.String, nil
+}
+
+func (f fields) integer(name string, maximum int64) (int64, error) {
+	value, found := f[name]
+	if !found || !value.IsTypeById(vm.IntegerId) {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	if value.Integer < 0 || value.Integer > maximum {
+		return 0, fmt.Errorf("%s must be between 0 and %d", name, maximum)
+	}
+	return value.Integer, nil
+}
+
+// mac returns the fallback when the field is None
+func (f fields) mac(name string, fallback net.HardwareAddr) (net.HardwareAddr, error) {
+	if f.isNone(name) {
+		return fallback, nil
+	}
+	raw, getError := f.string(name)
+	if getError != nil {
+		return nil, getError
+	}
+	mac, parseError := net.ParseMAC(raw)
+	if parseError != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %s for %s", raw, name)
+	}
+	return mac, nil
+}
+
+// ip returns the fallback when the field is None
+func (f fields) ip(name string, fallback net.IP) (net.IP, error) {
+	if f.isNone(name) {
+		if fallback == nil {
+			return nil, fmt.Errorf("%s must be specified, the interface has no address of that family", name)
+		}
+		return fallback, nil
+	}
+	raw, getError := f.string(name)
+	if getError != nil {
+		return nil, getError
+	}
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %s for %s", raw, name)
+	}
+	return ip, nil
+}
+
+func (f fields) content(name string) ([]byte, error) {
+	value, found := f[name]
+	if found && value.IsTypeById(vm.BytesId) {
+		return value.Bytes, nil
+	}
+	if found && value.IsTypeById(vm.StringId) {
+		return []byte(value.String), nil
+	}
+	return nil, fmt.Errorf("%s must be a string or bytes", name)
+}
+
+// builtLayer remembers the decoded layer, so the following ones can be linked to it
+type builtLayer struct {
+	name  string
+	layer gopacket.SerializableLayer
+}
+
+// buildFrame serializes the layers of the script, the type fields of each layer are deduced from the next one and
+// the lengths and checksums computed
+func (engine *Engine) buildFrame(values []*vm.Value) ([]byte, string, error) {
+	if len(values) == 0 {
+		return nil, "", errors.New("a frame needs at least one layer")
+	}
+	var built []builtLayer
+	for index, value := range values {
+		f, decodeError := decodeLayer(value)
+		if decodeError != nil {
+			return nil, "", decodeError
+		}
+		name, nameError := f.string("Layer")
+		if nameError != nil {
+			return nil, "", nameError
+		}
+		var previous *builtLayer
+		if index > 0 {
+			previous = &built[index-1]
+		}
+		layer, buildError := engine.buildLayer(name, f, previous)
+		if buildError != nil {
+			return nil, "", fmt.Errorf("layer %d (%s): %s", index, name, buildError)
+		}
+		built = append(built, builtLayer{name: name, layer: layer})
+	}
+	if built[0].name != EthernetLayer {
+		return nil, "", errors.New("frames must start with an Ethernet layer")
+	}
+	var serializable []gopacket.SerializableLayer
+	for index, b := range built {
+		if index+1 < len(built) {
+			linkError := link(b, built[index+1])
+			if linkError != nil {
+				return nil, "", linkError
+			}
+		}
+		serializable = append(serializable, b.layer)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	serializationError := gopacket.SerializeLayers(buf, opts, serializable...)
+	if serializationError != nil {
+		return nil, "", serializationError
+	}
+	return buf.Bytes(), summarize(built), nil
+}
+
+func (engine *Engine) buildLayer(name string, f fields, previous *builtLayer) (gopacket.SerializableLayer, error) {
+	switch name {
+	case EthernetLayer:
+		source, sourceError := f.mac("Source", engine.interfaceMAC)
+		if sourceError != nil {
+			return nil, sourceError
+		}
+		destination, destinationError := f.mac("Destination", layers.EthernetBroadcast)
+		if destinationError != nil {
+			return nil, destinationError
+		}
+		return &layers.Ethernet{SrcMAC: source, DstMAC: destination}, nil
+	case ARPLayer:
+		operation, operationError := f.integer("Operation", 2)
+		if operationError != nil || operation == 0 {
+			return nil, errors.New("Operation must be 1 (request) or 2 (reply)")
+		}
+		senderMAC, senderMACError := f.mac("SenderMAC", engine.interfaceMAC)
+		if senderMACError != nil {
+			return nil, senderMACError
+		}
+		senderIP, senderIPError := f.ip("SenderIP", engine.interfaceIP)
+		if senderIPError != nil {
+			return nil, senderIPError
+		}
+		targetMAC, targetMACError := f.mac("TargetMAC", net.HardwareAddr{0, 0, 0, 0, 0, 0})
+		if targetMACError != nil {
+			return nil, targetMACError
+		}
+		targetIP, targetIPError := f.ip("TargetIP", nil)
+		if targetIPError != nil {
+			return nil, targetIPError
+		}
+		if senderIP.To4() == nil || targetIP.To4() == nil {
+			return nil, errors.New("ARP only carries IPv4 addresses")
+		}
+		return &layers.ARP{
+			AddrType:          layers.LinkTypeEthernet,
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         uint16(operation),
+			SourceHwAddress:   senderMAC,
+			SourceProtAddress: senderIP.To4(),
+			DstHwAddress:      targetMAC,
+			DstProtAddress:    targetIP.To4(),
+		}, nil
+	case IPv4Layer:
+		source, sourceError := f.ip("Source", engine.interfaceIP)
+		if sourceError != nil {
+			return nil, sourceError
+		}
+		destination, destinationError := f.ip("Destination", nil)
+		if destinationError != nil {
+			return nil, destinationError
+		}
+		if source.To4() == nil || destination.To4() == nil {
+			return nil, errors.New("IPv4 addresses expected")
+		}
+		return &layers.IPv4{Version: 4, TTL: defaultHopLimit, SrcIP: source.To4(), DstIP: destination.To4()}, nil
+	case IPv6Layer:
+		source, sourceError := f.ip("Source", engine.interfaceIPv6)
+		if sourceError != nil {
+			return nil, sourceError
+		}
+		destination, destinationError := f.ip("Destination", nil)
+		if destinationError != nil {
+			return nil, destinationError
+		}
+		if source.To4() != nil || destination.To4() != nil {
+			return nil, errors.New("IPv6 addresses expected")
+		}
+		return &layers.IPv6{Version: 6, HopLimit: defaultHopLimit, SrcIP: source, DstIP: destination}, nil
+	case TCPLayer:
+		source, sourceError := f.integer("SourcePort", 65535)
+		if sourceError != nil {
+			return nil, sourceError
+		}
+		destination, destinationError := f.integer("DestinationPort", 65535)
+		if destinationError != nil {
+			return nil, destinationError
+		}
+		flags, flagsError := f.string("Flags")
+		if flagsError != nil {
+			return nil, flagsError
+		}
+		sequence, sequenceError := f.integer("Sequence", 0xffffffff)
+		if sequenceError != nil {
+			return nil, sequenceError
+		}
+		acknowledgment, acknowledgmentError := f.integer("Acknowledgment", 0xffffffff)
+		if acknowledgmentError != nil {
+			return nil, acknowledgmentError
+		}
+		tcp := &layers.TCP{
+			SrcPort: layers.TCPPort(source),
+			DstPort: layers.TCPPort(destination),
+			Seq:     uint32(sequence),
+			Ack:     uint32(acknowledgment),
+			Window:  65535,
+		}
+		for _, flag := range strings.ToUpper(flags) {
+			switch flag {
+			case 'S':
+				tcp.SYN = true
+			case 'A':
+				tcp.ACK = true
+			case 'F':
+				tcp.FIN = true
+			case 'R':
+				tcp.RST = true
+			case 'P':
+				tcp.PSH = true
+			case 'U':
+				tcp.URG = true
+			default:
+				return nil, fmt.Errorf("unknown TCP flag %c", flag)
+			}
+		}
+		return tcp, nil
+	case UDPLayer:
+		source, sourceError := f.integer("SourcePort", 65535)
+		if sourceError != nil {
+			return nil, sourceError
+		}
+		destination, destinationError := f.integer("DestinationPort", 65535)
+		if destinationError != nil {
+			return nil, destinationError
+		}
+		return &layers.UDP{SrcPort: layers.UDPPort(source), DstPort: layers.UDPPort(destination)}, nil
+	case ICMPLayer:
+		icmpType, typeError := f.integer("Type", 255)
+		if typeError != nil {
+			return nil, typeError
+		}
+		code, codeError := f.integer("Code", 255)
+		if codeError != nil {
+			return nil, codeError
+		}
+		// The version follows the network layer carrying the message
+		if previous != nil && previous.name == IPv6Layer {
+			return &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(uint8(icmpType), uint8(code))}, nil
+		}
+		return &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(uint8(icmpType), uint8(code))}, nil
+	case DNSLayer:
+		queryName, nameError := f.string("Name")
+		if nameError != nil {
+			return nil, nameError
+		}
+		rawType, typeError := f.string("Type")
+		if typeError != nil {
+			return nil, typeError
+		}
+		dnsType, found := dnsTypes[strings.ToUpper(rawType)]
+		if !found {
+			return nil, fmt.Errorf("unsupported DNS record type %s", rawType)
+		}
+		id, idError := f.integer("Id", 65535)
+		if idError != nil {
+			return nil, idError
+		}
+		return &layers.DNS{
+			ID:      uint16(id),
+			RD:      true,
+			QDCount: 1,
+			Questions: []layers.DNSQuestion{
+				{Name: []byte(queryName), Type: dnsType, Class: layers.DNSClassIN},
+			},
+		}, nil
+	case PayloadLayer:
+		content, contentError := f.content("Content")
+		if contentError != nil {
+			return nil, contentError
+		}
+		payload := gopacket.Payload(content)
+		return &payload, nil
+	}
+	return nil, errors.New("unknown layer")
+}
+
+// link fills the fields of the layer that announce the type of the next one
+func link(current, next builtLayer) error {
+	switch layer := current.layer.(type) {
+	case *layers.Ethernet:
+		switch next.name {
+		case ARPLayer:
+			layer.EthernetType = layers.EthernetTypeARP
+		case IPv4Layer:
+			layer.EthernetType = layers.EthernetTypeIPv4
+		case IPv6Layer:
+			layer.EthernetType = layers.EthernetTypeIPv6
+		case PayloadLayer:
+			return nil
+		default:
+			return fmt.Errorf("%s can not follow Ethernet", next.name)
+		}
+	case *layers.IPv4:
+		switch next.name {
+		case TCPLayer:
+			layer.Protocol = layers.IPProtocolTCP
+		case UDPLayer:
+			layer.Protocol = layers.IPProtocolUDP
+		case ICMPLayer:
+			layer.Protocol = layers.IPProtocolICMPv4
+		case PayloadLayer:
+			return nil
+		default:
+			return fmt.Errorf("%s can not follow IPv4", next.name)
+		}
+		return setChecksumLayer(next.layer, layer)
+	case *layers.IPv6:
+		switch next.name {
+		case TCPLayer:
+			layer.NextHeader = layers.IPProtocolTCP
+		case UDPLayer:
+			layer.NextHeader = layers.IPProtocolUDP
+		case ICMPLayer:
+			layer.NextHeader = layers.IPProtocolICMPv6
+		case PayloadLayer:
+			layer.NextHeader = layers.IPProtocolNoNextHeader
+			return nil
+		default:
+			return fmt.Errorf("%s can not follow IPv6", next.name)
+		}
+		return setChecksumLayer(next.layer, layer)
+	case *layers.TCP, *layers.UDP:
+		if next.name != DNSLayer && next.name != PayloadLayer {
+			return fmt.Errorf("%s can not follow %s", next.name, current.name)
+		}
+	case *layers.ICMPv4, *layers.ICMPv6:
+		if next.name != PayloadLayer {
+			return fmt.Errorf("%s can not follow ICMP", next.name)
+		}
+	default:
+		return fmt.Errorf("no layer can follow %s", current.name)
+	}
+	return nil
+}
+
+func setChecksumLayer(layer gopacket.SerializableLayer, network gopacket.NetworkLayer) error {
+	switch l := layer.(type) {
+	case *layers.TCP:
+		return l.SetNetworkLayerForChecksum(network)
+	case *layers.UDP:
+		return l.SetNetworkLayerForChecksum(network)
+	case *layers.ICMPv6:
+		return l.SetNetworkLayerForChecksum(network)
+	}
+	return nil
+}
+
+// summarize describes the frame for the audit record, like Ethernet/IPv4/UDP/DNS 192.0.2.2 -> 192.0.2.1
+func summarize(built []builtLayer) string {
+	var names []string
+	var endpoints string
+	for _, b := range built {
+		names = append(names, b.name)
+		switch layer := b.layer.(type) {
+		case *layers.Ethernet:
+			endpoints = layer.SrcMAC.String() + " -> " + layer.DstMAC.String()
+		case *layers.ARP:
+			endpoints = net.IP(layer.SourceProtAddress).String() + " -> " + net.IP(layer.DstProtAddress).String()
+		case *layers.IPv4:
+			endpoints = layer.SrcIP.String() + " -> " + layer.DstIP.String()
+		case *layers.IPv6:
+			endpoints = layer.SrcIP.String() + " -> " + layer.DstIP.String()
+		}
+	}
+	return strings.Join(names, "/") + " " + endpoints
+}
@@ -0,0 +1,265 @@
+package live
+
+import (
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ViewerBuffer is the number of events a viewer can fall behind before it gets detached
+const ViewerBuffer = 4096
+
+// BacklogSize is the number of recent events kept for the clients attaching later, older ones are dropped
+const BacklogSize = 1000
+
+// Viewer is a client attached to a running capture by a user, Events is closed when the viewer is detached or the
+// capture finishes
+type Viewer struct {
+	Username string
+	Events   chan tools.ServerWSResponse
+}
+
+// Capture is a capture running on the server independently of its clients. The clients attaching later receive the
+// latest state of every graph and target list followed by the most recent events before the live ones
+type Capture struct {
+	Owner       string
+	Name        string
+	Interface   string
+	Started     time.Time
+	mutex       *sync.Mutex
+	states      map[string]tools.ServerWSResponse
+	stateOrder  []string
+	recent      []tools.ServerWSResponse
+	recentStart int
+	viewers     map[*Viewer]struct{}
+	finished    bool
+	stop        chan string
+	// Done is closed once the capture finished and its viewers were detached
+	Done chan struct{}
+}
+
+func NewCapture(owner, name, interfaceName string) *Capture {
+	return &Capture{
+		Owner:     owner,
+		Name:      name,
+		Interface: interfaceName,
+		Started:   time.Now(),
+		mutex:     new(sync.Mutex),
+		states:    map[string]tools.ServerWSResponse{},
+		recent:    make([]tools.ServerWSResponse, 0, BacklogSize),
+		viewers:   map[*Viewer]struct{}{},
+		stop:      make(chan string, 1),
+		Done:      make(chan struct{}),
+	}
+}
+
+// broadcast sends the event to every viewer, the ones too slow to keep up are detached so they can attach again
+func (capture *Capture) broadcast(event tools.ServerWSResponse) {
+	for viewer := range capture.viewers {
+		select {
+		case viewer.Events <- event:
+		default:
+			delete(capture.viewers, viewer)
+			close(viewer.Events)
+		}
+	}
+}
+
+// Publish stores the event in the ring of recent events, replacing the oldest one when full, and sends it to the
+// attached viewers
+func (capture *Capture) Publish(event tools.ServerWSResponse) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if len(capture.recent) < BacklogSize {
+		capture.recent = append(capture.recent, event)
+	} else {
+		capture.recent[capture.recentStart] = event
+		capture.recentStart = (capture.recentStart + 1) % BacklogSize
+	}
+	capture.broadcast(event)
+}
+
+// PublishState replaces the previous state stored with the key, like the options of a graph, and sends the event to
+// the viewers
+func (capture *Capture) PublishState(key string, event tools.ServerWSResponse) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if _, found := capture.states[key]; !found {
+		capture.stateOrder = append(capture.stateOrder, key)
+	}
+	capture.states[key] = event
+	capture.broadcast(event)
+}
+
+// Notify sends the event to the viewers of the user without storing it in the backlog
+func (capture *Capture) Notify(username string, event tools.ServerWSResponse) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	for viewer := range capture.viewers {
+		if viewer.Username != username {
+			continue
+		}
+		select {
+		case viewer.Events <- event:
+		default:
+			delete(capture.viewers, viewer)
+			close(viewer.Events)
+		}
+	}
+}
+
+// Watchers returns the users besides the owner attached to the capture
+func (capture *Capture) Watchers() []string {
+	capture.mutex.Lock()
+	usernames := map[string]struct{}{}
+	for viewer := range capture.viewers {
+		if viewer.Username != capture.Owner {
+			usernames[viewer.Username] = struct{}{}
+		}
+	}
+	capture.mutex.Unlock()
+	result := make([]string, 0, len(usernames))
+	for username := range usernames {
+		result = append(result, username)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Attach returns a new viewer of the user with the latest states and the recent events, false when the capture
+// already finished
+func (capture *Capture) Attach(username string) (*Viewer, []tools.ServerWSResponse, bool) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if capture.finished {
+		return nil, nil, false
+	}
+	backlog := make([]tools.ServerWSResponse, 0, len(capture.stateOrder)+len(capture.recent))
+	for _, key := range capture.stateOrder {
+		backlog = append(backlog, capture.states[key])
+	}
+	backlog = append(backlog, capture.recent[capture.recentStart:]...)
+	backlog = append(backlog, capture.recent[:capture.recentStart]...)
+	viewer := &Viewer{
+		Username: username,
+		Events:   make(chan tools.ServerWSResponse, ViewerBuffer),
+	}
+	capture.viewers[viewer] = struct{}{}
+	return viewer, backlog, true
+}
+
+// Detach stops sending events to the viewer
+func (capture *Capture) Detach(viewer *Viewer) {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if _, found := capture.viewers[viewer]; found {
+		delete(capture.viewers, viewer)
+		close(viewer.Events)
+	}
+}
+
+// Stop asks the capture to finish, false when it was already asked
+func (capture *Capture) Stop(reason string) bool {
+	select {
+	case capture.stop <- reason:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stopped receives the reason when somebody asks the capture to finish
+func (capture *Capture) Stopped() <-chan string {
+	return capture.stop
+}
+
+// Finish detaches every viewer and closes Done, no more events are published after it
+func (capture *Capture) Finish() {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if capture.finished {
+		return
+	}
+	capture.finished = true
+	for viewer := range capture.viewers {
+		delete(capture.viewers, viewer)
+		close(viewer.Events)
+	}
+	close(capture.Done)
+}
+
+// Captures are the captures running on the server indexed by their owner and name
+type Captures struct {
+	mutex    *sync.Mutex
+	captures map[string]map[string]*Capture
+}
+
+func NewCaptures() *Captures {
+	return &Captures{
+		mutex:    new(sync.Mutex),
+		captures: map[string]map[string]*Capture{},
+	}
+}
+
+func (captures *Captures) Add(capture *Capture) {
+	captures.mutex.Lock()
+	defer captures.mutex.Unlock()
+	if _, found := captures.captures[capture.Owner]; !found {
+		captures.captures[capture.Owner] = map[string]*Capture{}
+	}
+	captures.captures[capture.Owner][capture.Name] = capture
+}
+
+func (captures *Captures) Remove(capture *Capture) {
+	captures.mutex.Lock()
+	defer captures.mutex.Unlock()
+	if userCaptures, found := captures.captures[capture.Owner]; found && userCaptures[capture.Name] == capture {
+		delete(userCaptures, capture.Name)
+		if len(userCaptures) == 0 {
+			delete(captures.captures, capture.Owner)
+		}
+	}
+}
+
+func (captures *Captures) Get(owner, name string) (*Capture, bool) {
+	captures.mutex.Lock()
+	defer captures.mutex.Unlock()
+	capture, found := captures.captures[owner][name]
+	return capture, found
+}
+
+// List returns the running captures of the user, the oldest first
+func (captures *Captures) List(owner string) []*Capture {
+	captures.mutex.Lock()
+	result := make([]*Capture, 0, len(captures.captures[owner]))
+	for _, capture := range captures.captures[owner] {
+		result = append(result, capture)
+	}
+	captures.mutex.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+// ListShared returns the running captures of the other users on the interfaces, the oldest first
+func (captures *Captures) ListShared(username string, interfaces map[string]struct{}) []*Capture {
+	captures.mutex.Lock()
+	var result []*Capture
+	for owner, userCaptures := range captures.captures {
+		if owner == username {
+			continue
+		}
+		for _, capture := range userCaptures {
+			if _, found := interfaces[capture.Interface]; found {
+				result = append(result, capture)
+			}
+		}
+	}
+	captures.mutex.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
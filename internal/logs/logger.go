@@ -155,6 +155,54 @@ func (logger *Logger) LogAdminDeleteARPSpoofPrivilege(request *http.Request, use
 	}
 }
 
+func (logger *Logger) LogAdminAddInjectPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully added inject privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to add inject privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminDeleteInjectPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully removed inject privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to remove inject privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminAddReplayPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully added replay privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to add replay privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminDeleteReplayPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully removed replay privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to remove replay privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminAddPortScanPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully added port scan privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to add port scan privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminDeletePortScanPrivilege(request *http.Request, username string, i string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully removed port scan privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to remove port scan privilege for interface %s and user %s by %s", i, username, request.RemoteAddr)
+	}
+}
+
 func (logger *Logger) LogListUserCaptures(request *http.Request, username string, succeed bool) {
 	if succeed {
 		logger.debugLogger.Printf("Successfully listed captures for user %s by %s", username, request.RemoteAddr)
@@ -215,6 +263,70 @@ func (logger *Logger) LogARPSpoofStopped(request *http.Request, username, ip, ga
 	logger.debugLogger.Printf("Successfully stopped ARP spoof by %s to IP %s and gateway %s at %s", username, ip, gateway, request.RemoteAddr)
 }
 
+func (logger *Logger) LogARPSpoofRestored(request *http.Request, username, ip, gateway string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully restored ARP caches after spoof by %s to IP %s and gateway %s at %s", username, ip, gateway, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to restore ARP caches after spoof by %s to IP %s and gateway %s at %s", username, ip, gateway, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogARPSpoofTargetUpdate(request *http.Request, username, action, target string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully applied %s of target %s to ARP spoof by %s at %s", action, target, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to apply %s of target %s to ARP spoof by %s at %s", action, target, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogInterceptStarted(request *http.Request, username, captureName, targets, gateway string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully started intercept %s by %s of targets %s and gateway %s at %s", captureName, username, targets, gateway, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to start intercept %s by %s of targets %s and gateway %s at %s", captureName, username, targets, gateway, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogInterceptStopped(request *http.Request, username, captureName string, forwardedPackets uint64) {
+	logger.debugLogger.Printf("Successfully stopped intercept %s by %s after forwarding %d packets at %s", captureName, username, forwardedPackets, request.RemoteAddr)
+}
+
+func (logger *Logger) LogDNSAnswerSpoofed(request *http.Request, username, client, name, address string) {
+	logger.debugLogger.Printf("Successfully spoofed DNS answer %s -> %s to %s in session of %s at %s", name, address, client, username, request.RemoteAddr)
+}
+
+func (logger *Logger) LogDNSResolverFailed(request *http.Request, username string, resolverError error) {
+	logger.debugLogger.Printf("Failed to resolve with the DNS script in session of %s at %s: %s", username, request.RemoteAddr, resolverError)
+}
+
+func (logger *Logger) LogInjectionStarted(request *http.Request, username, interfaceName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully started injection by %s on interface %s at %s", username, interfaceName, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to start injection by %s on interface %s at %s", username, interfaceName, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogInjectionStopped(request *http.Request, username, interfaceName string, framesSent uint64) {
+	logger.debugLogger.Printf("Successfully stopped injection by %s on interface %s after %d frames at %s", username, interfaceName, framesSent, request.RemoteAddr)
+}
+
+func (logger *Logger) LogFrameInjected(request *http.Request, username, interfaceName, summary string) {
+	logger.debugLogger.Printf("Successfully injected frame %s on interface %s by %s at %s", summary, interfaceName, username, request.RemoteAddr)
+}
+
+func (logger *Logger) LogReplayStarted(request *http.Request, username, captureName, interfaceName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully started replay of capture %s by %s on interface %s at %s", captureName, username, interfaceName, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to start replay of capture %s by %s on interface %s at %s", captureName, username, interfaceName, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogReplayStopped(request *http.Request, username, captureName, interfaceName string, packetsSent uint64) {
+	logger.debugLogger.Printf("Successfully stopped replay of capture %s by %s on interface %s after %d packets at %s", captureName, username, interfaceName, packetsSent, request.RemoteAddr)
+}
+
 func (logger *Logger) LogReserveARPScanNameForUser(request *http.Request, username, captureName string, succeed bool) {
 	if succeed {
 		logger.debugLogger.Printf("Successfully reserved arp scan name \"%s\" for user %s by %s", captureName, username, request.RemoteAddr)
@@ -263,6 +375,190 @@ func (logger *Logger) LogListAllARPScans(request *http.Request, username string,
 	}
 }
 
+func (logger *Logger) LogReservePortScanNameForUser(request *http.Request, username, scanName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully reserved port scan name \"%s\" for user %s by %s", scanName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to reserve port scan name \"%s\" for user %s by %s", scanName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogRemoveReservedPortScanNameForUser(request *http.Request, username, scanName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully removed reserved port scan name \"%s\" for user %s by %s", scanName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to remove reserved port scan name \"%s\" for user %s by %s", scanName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserPortScans(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed port scans for user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list port scans for user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSavePortScan(request *http.Request, username, scanName, interfaceName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved port scan with name \"%s\" targeting the interface %s started by user %s in %s", scanName, interfaceName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save port scan with name \"%s\" targeting the interface %s started by user %s in %s", scanName, interfaceName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogQueryUserPortScan(request *http.Request, username, scanName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully queried port scan %s for user %s at %s", scanName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to query port scan %s for user %s at %s", scanName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogUpdateInventory(request *http.Request, username, kind, name string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully updated the inventory of user %s with the %s \"%s\" at %s", username, kind, name, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to update the inventory of user %s with the %s \"%s\" at %s", username, kind, name, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserInventory(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed the inventory of user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list the inventory of user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogQueryUserInventoryHost(request *http.Request, username string, hostId uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully queried inventory host %d for user %s at %s", hostId, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to query inventory host %d for user %s at %s", hostId, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSaveAlerts(request *http.Request, username, scanName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved the alerts of scan %s for user %s at %s", scanName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save the alerts of scan %s for user %s at %s", scanName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserAlerts(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed the alerts of user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list the alerts of user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSaveJob(request *http.Request, username, jobName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved job %s for user %s at %s", jobName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save job %s for user %s at %s", jobName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserJobs(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed the jobs of user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list the jobs of user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogQueryUserJob(request *http.Request, username string, jobId uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully queried job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to query job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogDeleteJob(request *http.Request, username string, jobId uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully deleted job %d of user %s at %s", jobId, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to delete job %d of user %s at %s", jobId, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogJobFinished(request *http.Request, username, jobName, session, message string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Job %s of user %s stored %s at %s", jobName, username, session, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Job %s of user %s failed: %s at %s", jobName, username, message, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSaveJobRun(request *http.Request, username string, jobId uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved a run of job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save a run of job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListJobRuns(request *http.Request, username string, jobId uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed the runs of job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list the runs of job %d for user %s at %s", jobId, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSaveARPSpoof(request *http.Request, username, ip, gateway string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved ARP spoof session by %s to IP %s and gateway %s at %s", username, ip, gateway, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save ARP spoof session by %s to IP %s and gateway %s at %s", username, ip, gateway, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserARPSpoofs(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed arp spoofs for user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list arp spoofs for user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListAllARPSpoofs(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed all arp spoofs by user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list all arp spoofs by user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogSaveInjection(request *http.Request, username, interfaceName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully saved injection session by %s on interface %s at %s", username, interfaceName, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to save injection session by %s on interface %s at %s", username, interfaceName, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListUserInjections(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed injections for user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list injections for user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListAllInjections(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed all injections by user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list all injections by user %s at %s", username, request.RemoteAddr)
+	}
+}
+
 func (logger *Logger) LogListAllCaptures(request *http.Request, username string, succeed bool) {
 	if succeed {
 		logger.debugLogger.Printf("Successfully listed all captures by user %s at %s", username, request.RemoteAddr)
@@ -271,6 +567,86 @@ func (logger *Logger) LogListAllCaptures(request *http.Request, username string,
 	}
 }
 
+func (logger *Logger) LogQueryUserStorageUsage(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully queried storage usage of user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to query storage usage of user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogListStorageUsage(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully listed storage usage by user %s at %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to list storage usage by user %s at %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminSetUserStorageQuota(request *http.Request, username string, maxBytes uint64, maxCaptures uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully set storage quota of user %s to %d bytes and %d captures by %s", username, maxBytes, maxCaptures, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to set storage quota of user %s to %d bytes and %d captures by %s", username, maxBytes, maxCaptures, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminDeleteUserStorageQuota(request *http.Request, username string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully deleted storage quota of user %s by %s", username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to delete storage quota of user %s by %s", username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminSetRoleStorageQuota(request *http.Request, role string, maxBytes uint64, maxCaptures uint, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully set storage quota of role %s to %d bytes and %d captures by %s", role, maxBytes, maxCaptures, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to set storage quota of role %s to %d bytes and %d captures by %s", role, maxBytes, maxCaptures, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogStorageQuotaExceeded(request *http.Request, username, captureName string) {
+	logger.debugLogger.Printf("Storage quota exceeded by user %s on capture %s at %s", username, captureName, request.RemoteAddr)
+}
+
+func (logger *Logger) LogRegisterTask(request *http.Request, kind, username, name string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully registered active %s %s of user %s at %s", kind, name, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to register active %s %s of user %s at %s", kind, name, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogRemoveTask(request *http.Request, kind, username, name string) {
+	logger.debugLogger.Printf("Active %s %s of user %s at %s finished", kind, name, username, request.RemoteAddr)
+}
+
+func (logger *Logger) LogAttachCapture(request *http.Request, username, owner, captureName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully attached user %s to running capture %s of user %s at %s", username, captureName, owner, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to attach user %s to running capture %s of user %s at %s", username, captureName, owner, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogStopCapture(request *http.Request, username, captureName string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully stopped running capture %s of user %s at %s", captureName, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to stop running capture %s of user %s at %s", captureName, username, request.RemoteAddr)
+	}
+}
+
+func (logger *Logger) LogAdminKillTask(request *http.Request, username, id string, succeed bool) {
+	if succeed {
+		logger.debugLogger.Printf("Successfully killed active task %s by %s at %s", id, username, request.RemoteAddr)
+	} else {
+		logger.debugLogger.Printf("Failed to kill active task %s by %s at %s", id, username, request.RemoteAddr)
+	}
+}
+
 func NewLogger(logWriter io.Writer) *Logger {
 	return &Logger{
 		errorLogger: log.New(logWriter, "ERROR: ", log.Ldate|log.Ltime),
@@ -0,0 +1,157 @@
+package oui
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"github.com/google/gopacket/macs"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LocallyAdministeredLabel is shown instead of a vendor for the MACs that are not assigned by the IEEE, like the
+// randomized MACs of phones and laptops
+const LocallyAdministeredLabel = "Locally administered"
+
+var (
+	mutex = new(sync.RWMutex)
+	// loaded takes precedence over the prefixes embedded from the IEEE registry
+	loaded = map[[3]byte]string{}
+)
+
+func parsePrefix(raw string) ([3]byte, bool) {
+	var prefix [3]byte
+	raw = strings.NewReplacer("-", "", ":", "", ".", "").Replace(strings.TrimSpace(raw))
+	if len(raw) != 6 {
+		return prefix, false
+	}
+	decoded, decodeError := hex.DecodeString(raw)
+	if decodeError != nil {
+		return prefix, false
+	}
+	copy(prefix[:], decoded)
+	return prefix, true
+}
+
+// parseCSV reads the oui.csv of the IEEE, only the MA-L assignments are 24 bit prefixes
+func parseCSV(reader io.Reader) (map[[3]byte]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	result := map[[3]byte]string{}
+	for {
+		record, readError := csvReader.Read()
+		if readError == io.EOF {
+			return result, nil
+		}
+		if readError != nil {
+			return nil, readError
+		}
+		if len(record) < 3 || record[0] != "MA-L" {
+			continue
+		}
+		if prefix, ok := parsePrefix(record[1]); ok {
+			result[prefix] = strings.TrimSpace(record[2])
+		}
+	}
+}
+
+// parseText reads the oui.txt of the IEEE, the lines with the "(hex)" marker have the prefix and the vendor
+func parseText(reader io.Reader) (map[[3]byte]string, error) {
+	scanner := bufio.NewScanner(reader)
+	result := map[[3]byte]string{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		marker := strings.Index(line, "(hex)")
+		if marker == -1 {
+			continue
+		}
+		if prefix, ok := parsePrefix(line[:marker]); ok {
+			result[prefix] = strings.TrimSpace(line[marker+len("(hex)"):])
+		}
+	}
+	return result, scanner.Err()
+}
+
+// Load reads an oui.csv or oui.txt downloaded from the IEEE, its vendors replace the embedded ones. The number of
+// prefixes read is returned
+func Load(reader io.Reader) (int, error) {
+	buffered := bufio.NewReader(reader)
+	header, _ := buffered.Peek(len("Registry,"))
+	var (
+		vendors    map[[3]byte]string
+		parseError error
+	)
+	if string(header) == "Registry," {
+		vendors, parseError = parseCSV(buffered)
+	} else {
+		vendors, parseError = parseText(buffered)
+	}
+	if parseError != nil {
+		return 0, parseError
+	}
+	if len(vendors) == 0 {
+		return 0, errors.New("no OUI prefixes found")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for prefix, vendor := range vendors {
+		loaded[prefix] = vendor
+	}
+	return len(vendors), nil
+}
+
+// LoadFile loads the IEEE registry file in the path
+func LoadFile(path string) (int, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		return 0, openError
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// IsLocallyAdministered reports if the MAC was not assigned by the IEEE, randomized MACs always have this bit set
+func IsLocallyAdministered(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x02 != 0
+}
+
+// Vendor returns the organization the prefix of the MAC is assigned to, empty when it is unknown
+func Vendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 || IsLocallyAdministered(mac) {
+		return ""
+	}
+	var prefix [3]byte
+	copy(prefix[:], mac)
+	mutex.RLock()
+	vendor, found := loaded[prefix]
+	mutex.RUnlock()
+	if found {
+		return vendor
+	}
+	return macs.ValidMACPrefixMap[prefix]
+}
+
+// Lookup returns the vendor of the MAC written as text and if it is locally administered
+func Lookup(mac string) (string, bool) {
+	hardwareAddress, parseError := net.ParseMAC(mac)
+	if parseError != nil {
+		return "", false
+	}
+	return Vendor(hardwareAddress), IsLocallyAdministered(hardwareAddress)
+}
+
+// Label returns the vendor of the MAC or LocallyAdministeredLabel, empty when the vendor is unknown or the MAC is
+// a broadcast or multicast group
+func Label(mac net.HardwareAddr) string {
+	if len(mac) == 0 || mac[0]&0x01 != 0 {
+		return ""
+	}
+	if IsLocallyAdministered(mac) {
+		return LocallyAdministeredLabel
+	}
+	return Vendor(mac)
+}
@@ -0,0 +1,369 @@
+package port_scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	arp_scanner "github.com/shoriwe/CAPitan/internal/arp-scanner"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Scan modes, SYN crafts half open connections on the interface while connect and UDP use the sockets of the
+// system bound to the address of the interface
+const (
+	SYNMode     = "syn"
+	ConnectMode = "connect"
+	UDPMode     = "udp"
+)
+
+// Transport protocols of the results
+const (
+	TCP = "tcp"
+	UDP = "udp"
+)
+
+// States of the ports, UDP ports that never answer may be open with a service that ignored the probe or filtered
+const (
+	OpenState         = "open"
+	ClosedState       = "closed"
+	FilteredState     = "filtered"
+	OpenFilteredState = "open|filtered"
+)
+
+// Limits of the scans, the rate is in probes per second and the timeout is the time waited for the answer of
+// every probe
+const (
+	DefaultRate    = 100
+	MaximumRate    = 10000
+	MaximumRetries = 10
+	DefaultTimeout = time.Second
+	MaximumTimeout = 10 * time.Second
+	// MaximumProbes limits the number of hosts times the number of ports of a scan
+	MaximumProbes = 1 << 20
+	// maximumWorkers is the number of connect and UDP probes waiting for their answer at the same time
+	maximumWorkers = 512
+)
+
+// Options controls the pace of a scan, ports that do not answer are probed again Retries times. The gateway is the
+// router the SYN probes to hosts outside the subnet of the interface are sent through, the other modes use the
+// routes of the system
+type Options struct {
+	Mode    string
+	Rate    uint
+	Retries uint
+	Timeout time.Duration
+	Gateway string
+}
+
+// Result is the state of a port of a host
+type Result struct {
+	Host     string
+	Port     uint16
+	Protocol string
+	State    string
+}
+
+// Progress counts the ports with a state, the open ones and the number of ports of the scan
+type Progress struct {
+	Probed uint64
+	Open   uint64
+	Total  uint64
+}
+
+// Modes lists the scan modes in the order they are shown to the user
+func Modes() []string {
+	return []string{SYNMode, ConnectMode, UDPMode}
+}
+
+type Engine struct {
+	// Results receives the state of every port scanned, it is never closed
+	Results      chan Result
+	ErrorChannel chan error
+	// Done is closed when every port of every host has a state
+	Done      chan struct{}
+	options   Options
+	protocol  string
+	iFaceIP   net.IP
+	hosts     []net.IP
+	ports     []uint16
+	total     uint64
+	probed    uint64
+	open      uint64
+	context   context.Context
+	cancel    context.CancelFunc
+	workers   chan struct{}
+	wait      *sync.WaitGroup
+	closeOnce *sync.Once
+	// State of the SYN mode
+	syn *synScanner
+}
+
+// Progress returns the current counters of the scan
+func (engine *Engine) Progress() Progress {
+	return Progress{
+		Probed: atomic.LoadUint64(&engine.probed),
+		Open:   atomic.LoadUint64(&engine.open),
+		Total:  engine.total,
+	}
+}
+
+// report queues the state of the port, nothing is queued once the engine is closed
+func (engine *Engine) report(host net.IP, port uint16, state string) {
+	atomic.AddUint64(&engine.probed, 1)
+	if state == OpenState {
+		atomic.AddUint64(&engine.open, 1)
+	}
+	select {
+	case engine.Results <- Result{
+		Host:     host.String(),
+		Port:     port,
+		Protocol: engine.protocol,
+		State:    state,
+	}:
+	case <-engine.context.Done():
+	}
+}
+
+// fail reports the error and stops the scan
+func (engine *Engine) fail(scanError error) {
+	select {
+	case engine.ErrorChannel <- scanError:
+	default:
+	}
+	engine.cancel()
+}
+
+// localAddress binds the IPv4 probes to the interface, IPv6 hosts are reached with the address chosen by the system
+func (engine *Engine) localAddress(host net.IP) net.IP {
+	if host.To4() == nil {
+		return nil
+	}
+	return engine.iFaceIP
+}
+
+// connectProbe completes the TCP handshake with the port, refused connections are closed ports and the ones that
+// never answer are filtered
+func (engine *Engine) connectProbe(host net.IP, port uint16) string {
+	dialer := net.Dialer{Timeout: engine.options.Timeout}
+	if local := engine.localAddress(host); local != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: local}
+	}
+	address := net.JoinHostPort(host.String(), strconv.Itoa(int(port)))
+	for attempt := uint(0); attempt <= engine.options.Retries; attempt++ {
+		connection, dialError := dialer.DialContext(engine.context, "tcp", address)
+		if dialError == nil {
+			_ = connection.Close()
+			return OpenState
+		}
+		if errors.Is(dialError, syscall.ECONNREFUSED) {
+			return ClosedState
+		}
+		if engine.context.Err() != nil {
+			return ""
+		}
+	}
+	return FilteredState
+}
+
+// udpProbe sends the probe of the service to the port, any answer means it is open and the port unreachable
+// errors reported by the system mean it is closed
+func (engine *Engine) udpProbe(host net.IP, port uint16) string {
+	var local *net.UDPAddr
+	if ip := engine.localAddress(host); ip != nil {
+		local = &net.UDPAddr{IP: ip}
+	}
+	payload := udpProbe(port)
+	answer := make([]byte, 1500)
+	for attempt := uint(0); attempt <= engine.options.Retries; attempt++ {
+		connection, dialError := net.DialUDP("udp", local, &net.UDPAddr{IP: host, Port: int(port)})
+		if dialError != nil {
+			return FilteredState
+		}
+		_ = connection.SetDeadline(time.Now().Add(engine.options.Timeout))
+		_, probeError := connection.Write(payload)
+		if probeError == nil {
+			_, probeError = connection.Read(answer)
+		}
+		_ = connection.Close()
+		if probeError == nil {
+			return OpenState
+		}
+		if errors.Is(probeError, syscall.ECONNREFUSED) {
+			return ClosedState
+		}
+		if engine.context.Err() != nil {
+			return ""
+		}
+	}
+	return OpenFilteredState
+}
+
+// socketScan starts the probes of the connect and UDP modes at the rate of the options
+func (engine *Engine) socketScan() {
+	ticker := time.NewTicker(time.Second / time.Duration(engine.options.Rate))
+	defer ticker.Stop()
+	for _, host := range engine.hosts {
+		for _, port := range engine.ports {
+			select {
+			case <-engine.context.Done():
+				return
+			case <-ticker.C:
+			}
+			select {
+			case <-engine.context.Done():
+				return
+			case engine.workers <- struct{}{}:
+			}
+			engine.wait.Add(1)
+			go func(host net.IP, port uint16) {
+				defer engine.wait.Done()
+				defer func() { <-engine.workers }()
+				var state string
+				if engine.options.Mode == ConnectMode {
+					state = engine.connectProbe(host, port)
+				} else {
+					state = engine.udpProbe(host, port)
+				}
+				if len(state) > 0 {
+					engine.report(host, port, state)
+				}
+			}(host, port)
+		}
+	}
+	engine.wait.Wait()
+	if engine.context.Err() == nil {
+		close(engine.Done)
+	}
+}
+
+func (engine *Engine) Start() {
+	if engine.options.Mode == SYNMode {
+		go engine.syn.receive(engine)
+		go engine.syn.scan(engine)
+		return
+	}
+	go engine.socketScan()
+}
+
+// Close stops the scan, the probes waiting for an answer are abandoned
+func (engine *Engine) Close() {
+	engine.closeOnce.Do(func() {
+		engine.cancel()
+		if engine.syn != nil {
+			engine.syn.handle.Close()
+		}
+	})
+}
+
+func checkOptions(options *Options) error {
+	if options.Rate == 0 {
+		options.Rate = DefaultRate
+	}
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if len(options.Mode) == 0 {
+		options.Mode = ConnectMode
+	}
+	if options.Rate > MaximumRate {
+		return fmt.Errorf("rate must be between 1 and %d probes per second", MaximumRate)
+	}
+	if options.Retries > MaximumRetries {
+		return fmt.Errorf("retries must be between 0 and %d", MaximumRetries)
+	}
+	if options.Timeout < 0 || options.Timeout > MaximumTimeout {
+		return fmt.Errorf("timeout must be between 1 and %d milliseconds", MaximumTimeout.Milliseconds())
+	}
+	switch options.Mode {
+	case SYNMode, ConnectMode, UDPMode:
+		return nil
+	}
+	return fmt.Errorf("unknown scan mode %q", options.Mode)
+}
+
+// NewEngine prepares a scan of the ports of the targets list, without targets the subnet of the interface is
+// scanned. The targets and exclusions accept the same CIDR blocks, ranges and hosts of the ARP scanner and the
+// ports the lists parsed by ParsePorts, without ports the most common ones of the protocol of the mode are scanned
+func NewEngine(iFace, targets, exclude, ports string, options Options) (*Engine, error) {
+	optionsError := checkOptions(&options)
+	if optionsError != nil {
+		return nil, optionsError
+	}
+	protocol := TCP
+	if options.Mode == UDPMode {
+		protocol = UDP
+	}
+	scanPorts, portsError := ParsePorts(ports)
+	if portsError != nil {
+		return nil, portsError
+	}
+	if len(scanPorts) == 0 {
+		scanPorts = DefaultTCPPorts
+		if protocol == UDP {
+			scanPorts = DefaultUDPPorts
+		}
+	}
+
+	var (
+		hosts       *arp_scanner.Targets
+		targetError error
+	)
+	if len(targets) > 0 && !tools.CheckFilledWithWhiteSpace.MatchString(targets) {
+		hosts, targetError = arp_scanner.ParseTargets(targets, exclude)
+	} else {
+		subnet, subnetError := tools.FindInterfaceSubnet(iFace)
+		if subnetError != nil {
+			return nil, subnetError
+		}
+		hosts, targetError = arp_scanner.SubnetTargets(subnet, exclude)
+	}
+	if targetError != nil {
+		return nil, targetError
+	}
+	engine := &Engine{
+		Results:      make(chan Result, 1000),
+		ErrorChannel: make(chan error, 1),
+		Done:         make(chan struct{}),
+		options:      options,
+		protocol:     protocol,
+		ports:        scanPorts,
+		workers:      make(chan struct{}, maximumWorkers),
+		wait:         new(sync.WaitGroup),
+		closeOnce:    new(sync.Once),
+	}
+	for {
+		hasNext, host, _ := hosts.Next()
+		if !hasNext {
+			break
+		}
+		engine.hosts = append(engine.hosts, net.ParseIP(host))
+	}
+	if len(engine.hosts) == 0 {
+		return nil, errors.New("every target is excluded")
+	}
+	engine.total = uint64(len(engine.hosts)) * uint64(len(engine.ports))
+	if engine.total > MaximumProbes {
+		return nil, fmt.Errorf("the targets and ports expand to more than %d probes", MaximumProbes)
+	}
+
+	iFaceMac, deviceAddress, findError := tools.FindInterfaceIpAndMac(iFace)
+	if findError != nil {
+		return nil, findError
+	}
+	engine.iFaceIP = deviceAddress.To4()
+	if options.Mode == SYNMode {
+		var synError error
+		engine.syn, synError = newSYNScanner(iFace, iFaceMac, engine.iFaceIP, engine.hosts, options.Gateway)
+		if synError != nil {
+			return nil, synError
+		}
+	}
+	engine.context, engine.cancel = context.WithCancel(context.Background())
+	return engine, nil
+}
@@ -0,0 +1,54 @@
+package port_scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Ports scanned when none is provided, the most common services of each protocol
+var (
+	DefaultTCPPorts = []uint16{21, 22, 23, 25, 53, 80, 110, 111, 135, 139, 143, 443, 445, 993, 995, 1723, 3306, 3389, 5900, 8080}
+	DefaultUDPPorts = []uint16{53, 67, 69, 123, 137, 161, 500, 514, 1900, 5353}
+)
+
+func parsePort(raw string) (uint16, error) {
+	port, parseError := strconv.ParseUint(raw, 10, 16)
+	if parseError != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port %q, ports must be between 1 and 65535", raw)
+	}
+	return uint16(port), nil
+}
+
+// ParsePorts reads the ports and the dash ranges like 1-1024 separated by commas, spaces or new lines. The
+// repeated ports are only returned once, in the order they first appear
+func ParsePorts(ports string) ([]uint16, error) {
+	var result []uint16
+	seen := map[uint16]struct{}{}
+	for _, entry := range strings.FieldsFunc(ports, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		first, last := entry, entry
+		if separator := strings.Index(entry, "-"); separator != -1 {
+			first, last = entry[:separator], entry[separator+1:]
+		}
+		firstPort, parseError := parsePort(first)
+		if parseError != nil {
+			return nil, parseError
+		}
+		lastPort, parseError := parsePort(last)
+		if parseError != nil {
+			return nil, parseError
+		}
+		if firstPort > lastPort {
+			return nil, fmt.Errorf("invalid port range %q", entry)
+		}
+		for port := uint32(firstPort); port <= uint32(lastPort); port++ {
+			if _, found := seen[uint16(port)]; found {
+				continue
+			}
+			seen[uint16(port)] = struct{}{}
+			result = append(result, uint16(port))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package port_scanner
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	for _, test := range []struct {
+		ports  string
+		result string
+	}{
+		{"", "[]"},
+		{"80", "[80]"},
+		{"443, 80\n22", "[443 80 22]"},
+		{"20-23", "[20 21 22 23]"},
+		{"22 20-23 21", "[22 20 21 23]"},
+		{"65534-65535", "[65534 65535]"},
+	} {
+		ports, parseError := ParsePorts(test.ports)
+		if parseError != nil {
+			t.Fatal(test.ports, parseError)
+		}
+		if result := fmt.Sprint(ports); result != test.result {
+			t.Fatal(test.ports, result)
+		}
+	}
+}
+
+func TestInvalidPorts(t *testing.T) {
+	for _, ports := range []string{"0", "65536", "http", "100-10", "1-", "-80", "1-65536"} {
+		if _, parseError := ParsePorts(ports); parseError == nil {
+			t.Fatal(ports)
+		}
+	}
+}
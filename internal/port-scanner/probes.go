@@ -0,0 +1,89 @@
+package port_scanner
+
+import (
+	"encoding/binary"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"math/rand"
+)
+
+// Well known UDP services, most of them ignore empty datagrams so they are sent a request of their own protocol
+const (
+	dnsPort     = 53
+	ntpPort     = 123
+	netBIOSPort = 137
+	snmpPort    = 161
+	ssdpPort    = 1900
+	mdnsPort    = 5353
+)
+
+var (
+	// ntpRequest is a version 4 client request with the rest of the fields zeroed
+	ntpRequest = append([]byte{0xe3}, make([]byte, 47)...)
+	// snmpRequest is a SNMPv1 get-request of sysDescr.0 with the public community
+	snmpRequest = []byte{
+		0x30, 0x29, // Message
+		0x02, 0x01, 0x00, // Version 1
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c', // Community
+		0xa0, 0x1c, // GetRequest PDU
+		0x02, 0x04, 0x00, 0x00, 0x00, 0x01, // Request ID
+		0x02, 0x01, 0x00, // Error status
+		0x02, 0x01, 0x00, // Error index
+		0x30, 0x0e, // Variable bindings
+		0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // 1.3.6.1.2.1.1.1.0
+		0x05, 0x00, // NULL value
+	}
+	ssdpRequest = []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n")
+)
+
+// dnsQuery serializes a query with a single question
+func dnsQuery(name string, queryType layers.DNSType, class layers.DNSClass) []byte {
+	buffer := gopacket.NewSerializeBuffer()
+	_ = (&layers.DNS{
+		ID:      uint16(rand.Intn(1 << 16)),
+		RD:      true,
+		QDCount: 1,
+		Questions: []layers.DNSQuestion{
+			{
+				Name:  []byte(name),
+				Type:  queryType,
+				Class: class,
+			},
+		},
+	}).SerializeTo(buffer, gopacket.SerializeOptions{FixLengths: true})
+	return buffer.Bytes()
+}
+
+// netBIOSStatusQuery asks for the name table of the host with the wildcard name
+func netBIOSStatusQuery() []byte {
+	query := make([]byte, 12, 50)
+	binary.BigEndian.PutUint16(query[0:2], uint16(rand.Intn(1<<16)))
+	binary.BigEndian.PutUint16(query[4:6], 1)
+	// "*" padded with zeros in the first level encoding
+	query = append(query, 32, 'C', 'K')
+	for index := 0; index < 15; index++ {
+		query = append(query, 'A', 'A')
+	}
+	// Name terminator, NBSTAT type and IN class
+	return append(query, 0x00, 0x00, 0x21, 0x00, 0x01)
+}
+
+// udpProbe returns the payload sent to the port, the ones without a known protocol get an empty datagram
+func udpProbe(port uint16) []byte {
+	switch port {
+	case dnsPort:
+		return dnsQuery("version.bind", layers.DNSTypeTXT, layers.DNSClassCH)
+	case ntpPort:
+		return ntpRequest
+	case netBIOSPort:
+		return netBIOSStatusQuery()
+	case snmpPort:
+		return snmpRequest
+	case ssdpPort:
+		return ssdpRequest
+	case mdnsPort:
+		return dnsQuery("_services._dns-sd._udp.local", layers.DNSTypePTR, layers.DNSClassIN)
+	}
+	return nil
+}
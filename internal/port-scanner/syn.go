@@ -0,0 +1,411 @@
+package port_scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ethernetBroadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+type synProbe struct {
+	host     net.IP
+	port     uint16
+	attempts uint
+	lastSent time.Time
+}
+
+// synScanner sends the SYN probes straight to the MAC of the hosts of the subnet, or of the gateway for the
+// other hosts, the MACs are resolved with ARP before the first probe
+type synScanner struct {
+	handle     *pcap.Handle
+	iFaceMac   net.HardwareAddr
+	iFaceIP    net.IP
+	subnet     *net.IPNet
+	gatewayIP  net.IP
+	sourcePort uint16
+	mutex      *sync.Mutex
+	neighbors  map[string]net.HardwareAddr
+	pending    map[string]*synProbe
+}
+
+func probeKey(host net.IP, port uint16) string {
+	return net.JoinHostPort(host.String(), strconv.Itoa(int(port)))
+}
+
+func (syn *synScanner) isLocal(host net.IP) bool {
+	return syn.subnet != nil && syn.subnet.Contains(host)
+}
+
+// nextHop returns the MAC the probes to the host are sent to, nil when it did not answer to ARP
+func (syn *synScanner) nextHop(host net.IP) net.HardwareAddr {
+	if !syn.isLocal(host) {
+		host = syn.gatewayIP
+	}
+	syn.mutex.Lock()
+	defer syn.mutex.Unlock()
+	return syn.neighbors[host.String()]
+}
+
+func (syn *synScanner) write(frame ...gopacket.SerializableLayer) error {
+	buffer := gopacket.NewSerializeBuffer()
+	serializationError := gopacket.SerializeLayers(
+		buffer,
+		gopacket.SerializeOptions{
+			FixLengths:       true,
+			ComputeChecksums: true,
+		},
+		frame...,
+	)
+	if serializationError != nil {
+		return serializationError
+	}
+	return syn.handle.WritePacketData(buffer.Bytes())
+}
+
+func (syn *synScanner) arpRequest(ip net.IP) error {
+	return syn.write(
+		&layers.Ethernet{
+			SrcMAC:       syn.iFaceMac,
+			DstMAC:       ethernetBroadcast,
+			EthernetType: layers.EthernetTypeARP,
+		},
+		&layers.ARP{
+			AddrType:          syn.handle.LinkType(),
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         layers.ARPRequest,
+			SourceHwAddress:   syn.iFaceMac,
+			SourceProtAddress: syn.iFaceIP,
+			DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+			DstProtAddress:    ip.To4(),
+		},
+	)
+}
+
+func (syn *synScanner) sendSYN(host net.IP, port uint16, mac net.HardwareAddr) error {
+	ipLayer := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Id:       uint16(rand.Intn(1 << 16)),
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    syn.iFaceIP,
+		DstIP:    host.To4(),
+	}
+	tcpLayer := &layers.TCP{
+		SrcPort: layers.TCPPort(syn.sourcePort),
+		DstPort: layers.TCPPort(port),
+		Seq:     rand.Uint32(),
+		SYN:     true,
+		Window:  1024,
+		Options: []layers.TCPOption{
+			{
+				OptionType:   layers.TCPOptionKindMSS,
+				OptionLength: 4,
+				OptionData:   []byte{0x05, 0xb4},
+			},
+		},
+	}
+	_ = tcpLayer.SetNetworkLayerForChecksum(ipLayer)
+	return syn.write(
+		&layers.Ethernet{
+			SrcMAC:       syn.iFaceMac,
+			DstMAC:       mac,
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		ipLayer,
+		tcpLayer,
+	)
+}
+
+// resolve asks with ARP for the MACs of the hosts of the subnet and of the gateway, the hosts that never answer
+// are down and their ports are not probed
+func (syn *synScanner) resolve(engine *Engine, ticker *time.Ticker) bool {
+	var targets []net.IP
+	needsGateway := false
+	for _, host := range engine.hosts {
+		if syn.isLocal(host) {
+			targets = append(targets, host)
+		} else {
+			needsGateway = true
+		}
+	}
+	if needsGateway {
+		targets = append(targets, syn.gatewayIP)
+	}
+	for attempt := uint(0); attempt <= engine.options.Retries; attempt++ {
+		missing := false
+		for _, ip := range targets {
+			syn.mutex.Lock()
+			_, found := syn.neighbors[ip.String()]
+			syn.mutex.Unlock()
+			if found {
+				continue
+			}
+			missing = true
+			select {
+			case <-engine.context.Done():
+				return false
+			case <-ticker.C:
+			}
+			requestError := syn.arpRequest(ip)
+			if requestError != nil {
+				engine.fail(requestError)
+				return false
+			}
+		}
+		if !missing {
+			break
+		}
+		// The last requests get the full timeout to be answered
+		select {
+		case <-engine.context.Done():
+			return false
+		case <-time.After(engine.options.Timeout):
+		}
+	}
+	if !needsGateway {
+		return true
+	}
+	syn.mutex.Lock()
+	_, found := syn.neighbors[syn.gatewayIP.String()]
+	syn.mutex.Unlock()
+	if !found {
+		engine.fail(fmt.Errorf("gateway %s did not answer", syn.gatewayIP))
+		return false
+	}
+	return true
+}
+
+// nextRetry returns the probe to send again, the ones that ran out of retries are removed and returned as expired
+func (syn *synScanner) nextRetry(options Options) (retry *synProbe, expired []*synProbe) {
+	syn.mutex.Lock()
+	defer syn.mutex.Unlock()
+	now := time.Now()
+	for key, p := range syn.pending {
+		if now.Sub(p.lastSent) < options.Timeout {
+			continue
+		}
+		if p.attempts > options.Retries {
+			delete(syn.pending, key)
+			expired = append(expired, p)
+			continue
+		}
+		if retry == nil {
+			retry = p
+		}
+	}
+	if retry != nil {
+		retry.attempts++
+		retry.lastSent = now
+	}
+	return retry, expired
+}
+
+func (syn *synScanner) track(host net.IP, port uint16) {
+	syn.mutex.Lock()
+	defer syn.mutex.Unlock()
+	syn.pending[probeKey(host, port)] = &synProbe{
+		host:     host,
+		port:     port,
+		attempts: 1,
+		lastSent: time.Now(),
+	}
+}
+
+func (syn *synScanner) idle() bool {
+	syn.mutex.Lock()
+	defer syn.mutex.Unlock()
+	return len(syn.pending) == 0
+}
+
+// answer removes the probe of the port from the pending ones, false means it was not waiting for an answer
+func (syn *synScanner) answer(host net.IP, port uint16) bool {
+	syn.mutex.Lock()
+	defer syn.mutex.Unlock()
+	key := probeKey(host, port)
+	if _, found := syn.pending[key]; !found {
+		return false
+	}
+	delete(syn.pending, key)
+	return true
+}
+
+// scan resolves the MACs of the hosts and sends the probes at the rate of the options, retrying the ports without
+// answer before starting new ones
+func (syn *synScanner) scan(engine *Engine) {
+	ticker := time.NewTicker(time.Second / time.Duration(engine.options.Rate))
+	defer ticker.Stop()
+	if !syn.resolve(engine, ticker) {
+		return
+	}
+	hostIndex, portIndex := 0, 0
+	for {
+		select {
+		case <-engine.context.Done():
+			return
+		case <-ticker.C:
+		}
+		retry, expired := syn.nextRetry(engine.options)
+		for _, p := range expired {
+			engine.report(p.host, p.port, FilteredState)
+		}
+		if retry != nil {
+			sendError := syn.sendSYN(retry.host, retry.port, syn.nextHop(retry.host))
+			if sendError != nil {
+				engine.fail(sendError)
+				return
+			}
+			continue
+		}
+		var mac net.HardwareAddr
+		for hostIndex < len(engine.hosts) {
+			mac = syn.nextHop(engine.hosts[hostIndex])
+			if mac != nil {
+				break
+			}
+			// The ports of the hosts that are down are counted without a result
+			atomic.AddUint64(&engine.probed, uint64(len(engine.ports)-portIndex))
+			hostIndex, portIndex = hostIndex+1, 0
+		}
+		if hostIndex == len(engine.hosts) {
+			if syn.idle() {
+				close(engine.Done)
+				return
+			}
+			// Waiting for the answers of the last ports
+			continue
+		}
+		host, port := engine.hosts[hostIndex], engine.ports[portIndex]
+		portIndex++
+		if portIndex == len(engine.ports) {
+			hostIndex, portIndex = hostIndex+1, 0
+		}
+		// Tracked before it is sent, so the answer always finds it
+		syn.track(host, port)
+		sendError := syn.sendSYN(host, port, mac)
+		if sendError != nil {
+			engine.fail(sendError)
+			return
+		}
+	}
+}
+
+// unreachable returns the port of the probe quoted by an ICMP destination unreachable message
+func (syn *synScanner) unreachable(icmp *layers.ICMPv4) (net.IP, uint16, bool) {
+	quoted := icmp.Payload
+	if icmp.TypeCode.Type() != layers.ICMPv4TypeDestinationUnreachable || len(quoted) < 20 {
+		return nil, 0, false
+	}
+	headerLength := int(quoted[0]&0x0f) * 4
+	if layers.IPProtocol(quoted[9]) != layers.IPProtocolTCP || len(quoted) < headerLength+4 {
+		return nil, 0, false
+	}
+	if binary.BigEndian.Uint16(quoted[headerLength:headerLength+2]) != syn.sourcePort {
+		return nil, 0, false
+	}
+	return net.IP(quoted[16:20]), binary.BigEndian.Uint16(quoted[headerLength+2 : headerLength+4]), true
+}
+
+func (syn *synScanner) handlePacket(engine *Engine, packet gopacket.Packet) {
+	if arp, isARP := packet.Layer(layers.LayerTypeARP).(*layers.ARP); isARP {
+		if arp.Operation != layers.ARPReply || bytes.Equal(arp.SourceHwAddress, syn.iFaceMac) {
+			return
+		}
+		syn.mutex.Lock()
+		syn.neighbors[net.IP(arp.SourceProtAddress).String()] = append(net.HardwareAddr(nil), arp.SourceHwAddress...)
+		syn.mutex.Unlock()
+		return
+	}
+	ipLayer, isIPv4 := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !isIPv4 || !ipLayer.DstIP.Equal(syn.iFaceIP) {
+		return
+	}
+	if tcpLayer, isTCP := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); isTCP {
+		if uint16(tcpLayer.DstPort) != syn.sourcePort {
+			return
+		}
+		// The system resets the half open connections on its own, it has no socket for them
+		state := ClosedState
+		if tcpLayer.SYN && tcpLayer.ACK {
+			state = OpenState
+		} else if !tcpLayer.RST {
+			return
+		}
+		if syn.answer(ipLayer.SrcIP, uint16(tcpLayer.SrcPort)) {
+			engine.report(ipLayer.SrcIP, uint16(tcpLayer.SrcPort), state)
+		}
+		return
+	}
+	if icmpLayer, isICMP := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); isICMP {
+		host, port, isUnreachable := syn.unreachable(icmpLayer)
+		if isUnreachable && syn.answer(host, port) {
+			engine.report(host, port, FilteredState)
+		}
+	}
+}
+
+func (syn *synScanner) receive(engine *Engine) {
+	packets := gopacket.NewPacketSource(syn.handle, layers.LayerTypeEthernet).Packets()
+	for {
+		select {
+		case <-engine.context.Done():
+			return
+		case packet, isOpen := <-packets:
+			if !isOpen {
+				return
+			}
+			syn.handlePacket(engine, packet)
+		}
+	}
+}
+
+func newSYNScanner(iFace string, iFaceMac net.HardwareAddr, iFaceIP net.IP, hosts []net.IP, gateway string) (*synScanner, error) {
+	if len(iFaceMac) == 0 {
+		return nil, errors.New("the interface has no MAC address to send the SYN probes from")
+	}
+	if iFaceIP == nil {
+		return nil, errors.New("the interface has no IPv4 address to send the SYN probes from")
+	}
+	syn := &synScanner{
+		iFaceMac:   iFaceMac,
+		iFaceIP:    iFaceIP,
+		sourcePort: uint16(32768 + rand.Intn(28232)),
+		mutex:      new(sync.Mutex),
+		neighbors:  map[string]net.HardwareAddr{},
+		pending:    map[string]*synProbe{},
+	}
+	syn.subnet, _ = tools.FindInterfaceSubnet(iFace)
+	if len(gateway) > 0 {
+		syn.gatewayIP = net.ParseIP(gateway).To4()
+		if syn.gatewayIP == nil {
+			return nil, errors.New("invalid gateway IP provided, it must be an IPv4 address")
+		}
+	}
+	for _, host := range hosts {
+		if host.To4() == nil {
+			return nil, errors.New("only IPv4 hosts can be scanned in SYN mode, use connect for IPv6 hosts")
+		}
+		if !syn.isLocal(host) && syn.gatewayIP == nil {
+			return nil, fmt.Errorf("%s is outside the subnet of the interface, provide the gateway to reach it", host)
+		}
+	}
+	var openHandleError error
+	syn.handle, openHandleError = pcap.OpenLive(iFace, 65536, true, 0)
+	if openHandleError != nil {
+		return nil, openHandleError
+	}
+	return syn, nil
+}
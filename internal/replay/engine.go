@@ -0,0 +1,323 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of the replay sessions, the rate is in packets per second
+const (
+	DefaultSpeed = 1
+	MaximumSpeed = 100
+	MaximumRate  = 10000
+	DefaultLoops = 1
+	MaximumLoops = 100
+)
+
+var replayStopped = errors.New("replay stopped")
+
+// Rewrite replaces every occurrence of the From address with To, in the headers of the replayed packets
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// Options controls the timing and the modifications of a replay.
+// When Rate is set the packets are sent at that fixed rate, otherwise the original timing of the capture is kept,
+// divided by Speed
+type Options struct {
+	Speed       float64
+	Rate        uint
+	Loops       uint
+	MACRewrites []Rewrite
+	IPRewrites  []Rewrite
+}
+
+type packet struct {
+	data      []byte
+	timestamp time.Time
+}
+
+// Engine writes the packets of a saved capture to an interface
+type Engine struct {
+	handle      *pcap.Handle
+	packets     []packet
+	options     Options
+	packetsSent uint64
+	loop        uint64
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// PacketsSent returns the number of packets written to the interface, counting every loop
+func (engine *Engine) PacketsSent() uint64 {
+	return atomic.LoadUint64(&engine.packetsSent)
+}
+
+// Total returns the number of packets the replay sends when it is not stopped
+func (engine *Engine) Total() uint64 {
+	return uint64(len(engine.packets)) * uint64(engine.options.Loops)
+}
+
+// Loop returns the number of the loop being replayed, starting from 1
+func (engine *Engine) Loop() uint64 {
+	return atomic.LoadUint64(&engine.loop)
+}
+
+// Stop interrupts the running replay, even when it is waiting for the next packet
+func (engine *Engine) Stop() {
+	engine.stopOnce.Do(func() {
+		close(engine.stop)
+	})
+}
+
+func (engine *Engine) Close() {
+	engine.Stop()
+	engine.handle.Close()
+}
+
+func (engine *Engine) wait(duration time.Duration) error {
+	if duration <= 0 {
+		select {
+		case <-engine.stop:
+			return replayStopped
+		default:
+			return nil
+		}
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-engine.stop:
+		return replayStopped
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay returns the time to wait before sending the packet at index
+func (engine *Engine) delay(index int) time.Duration {
+	if engine.options.Rate > 0 {
+		return time.Second / time.Duration(engine.options.Rate)
+	}
+	if index == 0 {
+		return 0
+	}
+	return time.Duration(float64(engine.packets[index].timestamp.Sub(engine.packets[index-1].timestamp)) / engine.options.Speed)
+}
+
+// Run sends the packets until every loop finishes or the engine is stopped, a stopped replay returns nil
+func (engine *Engine) Run() error {
+	for loop := uint(1); loop <= engine.options.Loops; loop++ {
+		atomic.StoreUint64(&engine.loop, uint64(loop))
+		for index, p := range engine.packets {
+			if waitError := engine.wait(engine.delay(index)); waitError != nil {
+				return nil
+			}
+			writeError := engine.handle.WritePacketData(p.data)
+			if writeError != nil {
+				return writeError
+			}
+			atomic.AddUint64(&engine.packetsSent, 1)
+		}
+	}
+	return nil
+}
+
+type rewriter struct {
+	macs map[string]net.HardwareAddr
+	ips  map[string]net.IP
+}
+
+func newRewriter(options Options) (*rewriter, error) {
+	result := &rewriter{
+		macs: map[string]net.HardwareAddr{},
+		ips:  map[string]net.IP{},
+	}
+	for _, rewrite := range options.MACRewrites {
+		from, parseError := net.ParseMAC(rewrite.From)
+		if parseError != nil {
+			return nil, fmt.Errorf("invalid MAC %q", rewrite.From)
+		}
+		to, parseError := net.ParseMAC(rewrite.To)
+		if parseError != nil {
+			return nil, fmt.Errorf("invalid MAC %q", rewrite.To)
+		}
+		result.macs[from.String()] = to
+	}
+	for _, rewrite := range options.IPRewrites {
+		from := net.ParseIP(rewrite.From)
+		if from == nil {
+			return nil, fmt.Errorf("invalid IP %q", rewrite.From)
+		}
+		to := net.ParseIP(rewrite.To)
+		if to == nil {
+			return nil, fmt.Errorf("invalid IP %q", rewrite.To)
+		}
+		if (from.To4() == nil) != (to.To4() == nil) {
+			return nil, fmt.Errorf("cannot rewrite %s to %s, both must be of the same IP version", rewrite.From, rewrite.To)
+		}
+		if from.To4() != nil {
+			from, to = from.To4(), to.To4()
+		}
+		result.ips[from.String()] = to
+	}
+	return result, nil
+}
+
+func (r *rewriter) mac(address *net.HardwareAddr) bool {
+	if to, found := r.macs[address.String()]; found {
+		*address = to
+		return true
+	}
+	return false
+}
+
+func (r *rewriter) ip(address *net.IP) bool {
+	if to, found := r.ips[address.String()]; found {
+		*address = to
+		return true
+	}
+	return false
+}
+
+// rewrite returns the frame with its addresses replaced, the checksums are computed again.
+// Layers that can not be serialized are kept as raw payload
+func (r *rewriter) rewrite(data []byte) []byte {
+	if len(r.macs) == 0 && len(r.ips) == 0 {
+		return data
+	}
+	decoded := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	changed := false
+	var networkLayer gopacket.NetworkLayer
+	for _, layer := range decoded.Layers() {
+		switch l := layer.(type) {
+		case *layers.Ethernet:
+			changed = r.mac(&l.SrcMAC) || changed
+			changed = r.mac(&l.DstMAC) || changed
+		case *layers.ARP:
+			sourceMAC, targetMAC := net.HardwareAddr(l.SourceHwAddress), net.HardwareAddr(l.DstHwAddress)
+			sourceIP, targetIP := net.IP(l.SourceProtAddress), net.IP(l.DstProtAddress)
+			changed = r.mac(&sourceMAC) || changed
+			changed = r.mac(&targetMAC) || changed
+			changed = r.ip(&sourceIP) || changed
+			changed = r.ip(&targetIP) || changed
+			l.SourceHwAddress, l.DstHwAddress = sourceMAC, targetMAC
+			l.SourceProtAddress, l.DstProtAddress = sourceIP, targetIP
+		case *layers.IPv4:
+			changed = r.ip(&l.SrcIP) || changed
+			changed = r.ip(&l.DstIP) || changed
+			networkLayer = l
+		case *layers.IPv6:
+			changed = r.ip(&l.SrcIP) || changed
+			changed = r.ip(&l.DstIP) || changed
+			networkLayer = l
+		case *layers.TCP:
+			if networkLayer != nil {
+				_ = l.SetNetworkLayerForChecksum(networkLayer)
+			}
+		case *layers.UDP:
+			if networkLayer != nil {
+				_ = l.SetNetworkLayerForChecksum(networkLayer)
+			}
+		case *layers.ICMPv6:
+			if networkLayer != nil {
+				_ = l.SetNetworkLayerForChecksum(networkLayer)
+			}
+		}
+	}
+	if !changed {
+		return data
+	}
+	var serializable []gopacket.SerializableLayer
+	for _, layer := range decoded.Layers() {
+		s, ok := layer.(gopacket.SerializableLayer)
+		if !ok {
+			serializable = append(serializable, gopacket.Payload(append(layer.LayerContents(), layer.LayerPayload()...)))
+			break
+		}
+		serializable = append(serializable, s)
+	}
+	buffer := gopacket.NewSerializeBuffer()
+	serializeError := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, serializable...)
+	if serializeError != nil {
+		return data
+	}
+	return buffer.Bytes()
+}
+
+func checkOptions(options *Options) error {
+	if options.Loops == 0 {
+		options.Loops = DefaultLoops
+	}
+	if options.Speed == 0 {
+		options.Speed = DefaultSpeed
+	}
+	if options.Loops > MaximumLoops {
+		return fmt.Errorf("loops must be between 1 and %d", MaximumLoops)
+	}
+	if options.Rate > MaximumRate {
+		return fmt.Errorf("rate must be between 1 and %d packets per second", MaximumRate)
+	}
+	if options.Speed < 0 || options.Speed > MaximumSpeed {
+		return fmt.Errorf("speed must be greater than 0 and at most %d", MaximumSpeed)
+	}
+	return nil
+}
+
+// NewEngine loads the packets of the pcap and opens the interface they are going to be replayed on
+func NewEngine(interfaceName string, pcapContents []byte, options Options) (*Engine, error) {
+	checkError := checkOptions(&options)
+	if checkError != nil {
+		return nil, checkError
+	}
+	r, rewriterError := newRewriter(options)
+	if rewriterError != nil {
+		return nil, rewriterError
+	}
+	reader, readerError := pcapgo.NewReader(bytes.NewReader(pcapContents))
+	if readerError != nil {
+		return nil, readerError
+	}
+	if reader.LinkType() != layers.LinkTypeEthernet {
+		return nil, fmt.Errorf("only ethernet captures can be replayed, found %s", reader.LinkType())
+	}
+	var packets []packet
+	for {
+		data, captureInfo, readError := reader.ReadPacketData()
+		if readError == io.EOF {
+			break
+		} else if readError != nil {
+			return nil, readError
+		}
+		packets = append(packets,
+			packet{
+				data:      r.rewrite(data),
+				timestamp: captureInfo.Timestamp,
+			},
+		)
+	}
+	if len(packets) == 0 {
+		return nil, errors.New("the capture has no packets")
+	}
+	handle, openError := pcap.OpenLive(interfaceName, 65536, false, pcap.BlockForever)
+	if openError != nil {
+		return nil, openError
+	}
+	return &Engine{
+		handle:  handle,
+		packets: packets,
+		options: options,
+		stop:    make(chan struct{}),
+	}, nil
+}
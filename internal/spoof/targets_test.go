@@ -0,0 +1,60 @@
+package spoof
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func targetsString(targets []net.IP) string {
+	var result []string
+	for _, target := range targets {
+		result = append(result, target.String())
+	}
+	return strings.Join(result, " ")
+}
+
+func TestSplitTargets(t *testing.T) {
+	entries := SplitTargets("192.168.1.10, 192.168.1.11\n192.168.1.0/30\t192.168.1.12")
+	if strings.Join(entries, " ") != "192.168.1.10 192.168.1.11 192.168.1.0/30 192.168.1.12" {
+		t.Fatal(entries)
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	// Network and broadcast addresses are skipped and the repeated hosts only appear once
+	targets, parseError := ParseTargets([]string{"192.168.1.8/30", "192.168.1.9", "192.168.1.20"})
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	if result := targetsString(targets); result != "192.168.1.9 192.168.1.10 192.168.1.20" {
+		t.Fatal(result)
+	}
+	// Point to point networks have no network nor broadcast addresses
+	targets, parseError = ParseTargets([]string{"192.168.1.8/31"})
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	if result := targetsString(targets); result != "192.168.1.8 192.168.1.9" {
+		t.Fatal(result)
+	}
+	for _, invalid := range []string{"192.168.1", "192.168.1.0/33", "host"} {
+		if _, parseError = ParseTargets([]string{invalid}); parseError == nil {
+			t.Fatal(invalid)
+		}
+	}
+	if _, parseError = ParseTargets([]string{"10.0.0.0/16"}); parseError == nil {
+		t.Fatal("more than the maximum targets accepted")
+	}
+}
+
+func TestParseTargetsIPv6(t *testing.T) {
+	// Only the subnet router anycast address of IPv6 networks is skipped, they have no broadcast address
+	targets, parseError := ParseTargets([]string{"2001:db8::/126", "2001:DB8::1"})
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	if result := targetsString(targets); result != "2001:db8::1 2001:db8::2 2001:db8::3" {
+		t.Fatal(result)
+	}
+}
@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	Capture  = "capture"
+	ARPScan  = "arp-scan"
+	ARPSpoof = "arp-spoof"
+	// Intercept is a capture running on top of an ARP spoof of its targets
+	Intercept = "intercept"
+	Inject    = "inject"
+	Replay    = "replay"
+	PortScan  = "port-scan"
+)
+
+type Task struct {
+	Id        string
+	Kind      string
+	Username  string
+	Interface string
+	Name      string
+	Targets   string
+	Started   time.Time
+	packets   uint64
+	// Killed receives the reason when an administrator stops the task
+	Killed chan string
+}
+
+func (task *Task) AddPackets(n uint64) {
+	atomic.AddUint64(&task.packets, n)
+}
+
+func (task *Task) Packets() uint64 {
+	return atomic.LoadUint64(&task.packets)
+}
+
+func (task *Task) Duration() time.Duration {
+	return time.Since(task.Started).Truncate(time.Second)
+}
+
+type Tasks struct {
+	*sync.Mutex
+	tasks map[string]*Task
+}
+
+func (tasks *Tasks) Register(kind, username, interfaceName, name, targets string) (*Task, error) {
+	rawId := make([]byte, 16)
+	_, readError := rand.Read(rawId)
+	if readError != nil {
+		return nil, readError
+	}
+	task := &Task{
+		Id:        hex.EncodeToString(rawId),
+		Kind:      kind,
+		Username:  username,
+		Interface: interfaceName,
+		Name:      name,
+		Targets:   targets,
+		Started:   time.Now(),
+		packets:   0,
+		Killed:    make(chan string, 1),
+	}
+	tasks.Lock()
+	tasks.tasks[task.Id] = task
+	tasks.Unlock()
+	return task, nil
+}
+
+func (tasks *Tasks) Remove(id string) {
+	tasks.Lock()
+	delete(tasks.tasks, id)
+	tasks.Unlock()
+}
+
+func (tasks *Tasks) List() []*Task {
+	tasks.Lock()
+	result := make([]*Task, 0, len(tasks.tasks))
+	for _, task := range tasks.tasks {
+		result = append(result, task)
+	}
+	tasks.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+// Kill notifies the owner of the task that it should stop, the task is removed by its owner when it finishes
+func (tasks *Tasks) Kill(id, reason string) (*Task, bool) {
+	tasks.Lock()
+	task, found := tasks.tasks[id]
+	tasks.Unlock()
+	if !found {
+		return nil, false
+	}
+	select {
+	case task.Killed <- reason:
+		return task, true
+	default:
+		// Already killed
+		return task, false
+	}
+}
+
+func NewTasks() *Tasks {
+	return &Tasks{
+		Mutex: new(sync.Mutex),
+		tasks: map[string]*Task{},
+	}
+}
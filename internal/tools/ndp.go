@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"net"
+)
+
+// Flags of neighbor advertisements
+const (
+	NDPRouterFlag    uint8 = 0x80
+	NDPSolicitedFlag uint8 = 0x40
+	NDPOverrideFlag  uint8 = 0x20
+)
+
+func serializeNDP(sourceMAC, destinationMAC net.HardwareAddr, sourceIP, destinationIP net.IP, typeCode layers.ICMPv6TypeCode, message gopacket.SerializableLayer) ([]byte, error) {
+	ethernetPacket := layers.Ethernet{
+		SrcMAC:       sourceMAC,
+		DstMAC:       destinationMAC,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ipPacket := layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolICMPv6,
+		HopLimit:   255, // Receivers drop neighbor discovery messages that were routed
+		SrcIP:      sourceIP.To16(),
+		DstIP:      destinationIP.To16(),
+	}
+	icmpPacket := layers.ICMPv6{
+		TypeCode: typeCode,
+	}
+	checksumError := icmpPacket.SetNetworkLayerForChecksum(&ipPacket)
+	if checksumError != nil {
+		return nil, checksumError
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	serializationError := gopacket.SerializeLayers(buf, opts, &ethernetPacket, &ipPacket, &icmpPacket, message)
+	if serializationError != nil {
+		return nil, serializationError
+	}
+	return buf.Bytes(), nil
+}
+
+// NeighborSolicitation asks for the MAC address of the target, receivers also learn that the source IP is at the
+// source MAC
+func NeighborSolicitation(sourceMAC, destinationMAC net.HardwareAddr, sourceIP, destinationIP, targetIP net.IP) ([]byte, error) {
+	return serializeNDP(sourceMAC, destinationMAC, sourceIP, destinationIP,
+		layers.CreateICMPv6TypeCode(layers.ICMPv6TypeNeighborSolicitation, 0),
+		&layers.ICMPv6NeighborSolicitation{
+			TargetAddress: targetIP.To16(),
+			Options: layers.ICMPv6Options{
+				{Type: layers.ICMPv6OptSourceAddress, Data: sourceMAC},
+			},
+		},
+	)
+}
+
+// NeighborAdvertisement tells the receiver that the target IP is at the target MAC
+func NeighborAdvertisement(sourceMAC, destinationMAC net.HardwareAddr, sourceIP, destinationIP, targetIP net.IP, targetMAC net.HardwareAddr, flags uint8) ([]byte, error) {
+	return serializeNDP(sourceMAC, destinationMAC, sourceIP, destinationIP,
+		layers.CreateICMPv6TypeCode(layers.ICMPv6TypeNeighborAdvertisement, 0),
+		&layers.ICMPv6NeighborAdvertisement{
+			Flags:         flags,
+			TargetAddress: targetIP.To16(),
+			Options: layers.ICMPv6Options{
+				{Type: layers.ICMPv6OptTargetAddress, Data: targetMAC},
+			},
+		},
+	)
+}
+
+func linkLayerOption(options layers.ICMPv6Options, optionType layers.ICMPv6Opt, fallback net.HardwareAddr) net.HardwareAddr {
+	for _, option := range options {
+		if option.Type == optionType && len(option.Data) >= 6 {
+			return net.HardwareAddr(option.Data[:6])
+		}
+	}
+	return fallback
+}
+
+// ParseNeighborDiscovery returns the IP and MAC announced by neighbor advertisements, router advertisements and the
+// solicitations of hosts already holding an address. The boolean is false for any other packet
+func ParseNeighborDiscovery(packet gopacket.Packet) (net.IP, net.HardwareAddr, bool) {
+	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
+	ipLayer := packet.Layer(layers.LayerTypeIPv6)
+	if ethernetLayer == nil || ipLayer == nil {
+		return nil, nil, false
+	}
+	sourceMAC := ethernetLayer.(*layers.Ethernet).SrcMAC
+	sourceIP := ipLayer.(*layers.IPv6).SrcIP
+	if advertisement, ok := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement).(*layers.ICMPv6NeighborAdvertisement); ok {
+		return advertisement.TargetAddress, linkLayerOption(advertisement.Options, layers.ICMPv6OptTargetAddress, sourceMAC), true
+	}
+	if advertisement, ok := packet.Layer(layers.LayerTypeICMPv6RouterAdvertisement).(*layers.ICMPv6RouterAdvertisement); ok {
+		return sourceIP, linkLayerOption(advertisement.Options, layers.ICMPv6OptSourceAddress, sourceMAC), true
+	}
+	if solicitation, ok := packet.Layer(layers.LayerTypeICMPv6NeighborSolicitation).(*layers.ICMPv6NeighborSolicitation); ok {
+		// Duplicate address detection probes come from the unspecified address
+		if sourceIP.IsUnspecified() {
+			return nil, nil, false
+		}
+		return sourceIP, linkLayerOption(solicitation.Options, layers.ICMPv6OptSourceAddress, sourceMAC), true
+	}
+	return nil, nil, false
+}
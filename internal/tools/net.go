@@ -85,7 +85,10 @@ func FindInterfaceIpAndMac(iFace string) (net.HardwareAddr, net.IP, error) {
 
 	var deviceAddress net.IP = nil
 	for _, address := range selectedDevice.Addresses {
-		deviceAddress = address.IP
+		// On dual stack interfaces the IPv4 address is the one ARP needs
+		if deviceAddress == nil || deviceAddress.To4() == nil || address.IP.To4() != nil {
+			deviceAddress = address.IP
+		}
 	}
 	if deviceAddress == nil {
 		return nil, nil, errors.New("not available addresses")
@@ -118,3 +121,67 @@ macLoop:
 
 	return deviceMac, deviceAddress, nil
 }
+
+// FindInterfaceIPv6 returns the IPv6 address used as source of neighbor discovery messages, the link local one is
+// preferred. Interfaces without IPv6 addresses get the EUI-64 link local address derived from their MAC
+func FindInterfaceIPv6(iFace string, mac net.HardwareAddr) (net.IP, error) {
+	devices, findError := pcap.FindAllDevs()
+	if findError != nil {
+		return nil, findError
+	}
+	var result net.IP
+	for _, device := range devices {
+		if device.Name != iFace {
+			continue
+		}
+		for _, address := range device.Addresses {
+			if address.IP.To4() != nil || address.IP.To16() == nil {
+				continue
+			}
+			if result == nil || address.IP.IsLinkLocalUnicast() {
+				result = address.IP
+			}
+		}
+	}
+	if result != nil {
+		return result, nil
+	}
+	if len(mac) != 6 {
+		return nil, errors.New("could not find an IPv6 address")
+	}
+	result = net.IP{0xfe, 0x80, 0, 0, 0, 0, 0, 0, mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}
+	return result, nil
+}
+
+// SolicitedNodeMulticast returns the multicast IPv6 and MAC addresses neighbor solicitations for the IP are sent to
+func SolicitedNodeMulticast(ip net.IP) (net.IP, net.HardwareAddr) {
+	ip = ip.To16()
+	multicastIP := net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, ip[13], ip[14], ip[15]}
+	return multicastIP, net.HardwareAddr{0x33, 0x33, 0xff, ip[13], ip[14], ip[15]}
+}
+
+// FindInterfaceSubnet returns the network of the IPv4 address FindInterfaceIpAndMac selects for the interface
+func FindInterfaceSubnet(iFace string) (*net.IPNet, error) {
+	_, deviceAddress, findError := FindInterfaceIpAndMac(iFace)
+	if findError != nil {
+		return nil, findError
+	}
+	devices, findError := pcap.FindAllDevs()
+	if findError != nil {
+		return nil, findError
+	}
+	for _, device := range devices {
+		if device.Name != iFace {
+			continue
+		}
+		for _, address := range device.Addresses {
+			if address.IP.Equal(deviceAddress) && address.Netmask != nil {
+				return &net.IPNet{
+					IP:   deviceAddress.Mask(address.Netmask),
+					Mask: address.Netmask,
+				}, nil
+			}
+		}
+	}
+	return nil, errors.New("could not find the network of the interface")
+}
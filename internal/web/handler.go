@@ -6,15 +6,21 @@ import (
 	"github.com/shoriwe/CAPitan/internal/data"
 	"github.com/shoriwe/CAPitan/internal/data/memory"
 	"github.com/shoriwe/CAPitan/internal/logs"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/routes/admin"
 	"github.com/shoriwe/CAPitan/internal/web/routes/dashboard"
 	login2 "github.com/shoriwe/CAPitan/internal/web/routes/login"
 	settings2 "github.com/shoriwe/CAPitan/internal/web/routes/settings"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/alerts"
 	"github.com/shoriwe/CAPitan/internal/web/routes/user/arp"
 	"github.com/shoriwe/CAPitan/internal/web/routes/user/arp/scan"
 	"github.com/shoriwe/CAPitan/internal/web/routes/user/arp/spoof"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/inject"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/inventory"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/jobs"
 	"github.com/shoriwe/CAPitan/internal/web/routes/user/packet"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/port"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"html"
 	"html/template"
@@ -104,8 +110,11 @@ func redirectToLogin(mw *middleware.Middleware, context *middleware.Context) boo
 	return false
 }
 
-func NewServerMux(database data.Database, logger *logs.Logger) http.Handler {
+// NewServerMux returns the handler of the web UI with the scheduler of its jobs, the scheduler is not started so the
+// caller decides how long it runs
+func NewServerMux(database data.Database, logger *logs.Logger) (http.Handler, *scheduler.Scheduler) {
 	mw := middleware.New(database, logger, templatesFS)
+	mw.Scheduler = jobs.NewScheduler(mw)
 	handler := http.NewServeMux()
 	handler.HandleFunc(symbols.Favicon,
 		mw.Handle(
@@ -132,12 +141,21 @@ func NewServerMux(database data.Database, logger *logs.Logger) http.Handler {
 	handler.HandleFunc(symbols.AdminPanel, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.Panel))
 	handler.HandleFunc(symbols.AdminEditUsers, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.EditUsers))
 	handler.HandleFunc(symbols.AdminARPScans, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.ListUserARPScans))
+	handler.HandleFunc(symbols.AdminARPSpoofs, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.ListUserARPSpoofs))
+	handler.HandleFunc(symbols.AdminInjections, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.ListUserInjections))
 	handler.HandleFunc(symbols.AdminPacketCaptures, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.PacketCaptures))
+	handler.HandleFunc(symbols.AdminActiveTasks, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.ActiveTasks))
+	handler.HandleFunc(symbols.AdminStorageQuotas, mw.Handle(logVisit, loadCredentials, requiresLogin, requiresAdminPrivilege, setNavigationBar, admin.StorageQuotas))
 	// User
 	handler.HandleFunc(symbols.UserPacketCaptures, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, packet.Captures))
 	handler.HandleFunc(symbols.UserARP, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, arp.ARP))
 	handler.HandleFunc(symbols.UserARPSpoof, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, spoof.ARPSpoof))
 	handler.HandleFunc(symbols.UserARPScan, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, scan.ARPScan))
+	handler.HandleFunc(symbols.UserInject, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, inject.Inject))
+	handler.HandleFunc(symbols.UserPortScan, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, port.PortScan))
+	handler.HandleFunc(symbols.UserInventory, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, inventory.Inventory))
+	handler.HandleFunc(symbols.UserAlerts, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, alerts.Alerts))
+	handler.HandleFunc(symbols.UserJobs, mw.Handle(logVisit, loadCredentials, requiresLogin, setNavigationBar, jobs.Jobs))
 
 	if _, ok := database.(*memory.Memory); ok {
 		request, _ := http.NewRequest(http.MethodGet, "/", nil)
@@ -145,7 +163,10 @@ func NewServerMux(database data.Database, logger *logs.Logger) http.Handler {
 			mw.AdminAddCaptureInterfacePrivilege(request, "admin", netInterface)
 			mw.AdminAddARPScanInterfacePrivilege(request, "admin", netInterface)
 			mw.AdminAddARPSpoofInterfacePrivilege(request, "admin", netInterface)
+			mw.AdminAddInjectInterfacePrivilege(request, "admin", netInterface)
+			mw.AdminAddReplayInterfacePrivilege(request, "admin", netInterface)
+			mw.AdminAddPortScanInterfacePrivilege(request, "admin", netInterface)
 		}
 	}
-	return handler
+	return handler, mw.Scheduler
 }
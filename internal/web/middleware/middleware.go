@@ -4,14 +4,21 @@ import (
 	"crypto/rand"
 	"embed"
 	"encoding/hex"
+	"encoding/json"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/pcap"
 	"github.com/shoriwe/CAPitan/internal/capture"
 	"github.com/shoriwe/CAPitan/internal/data"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
 	"github.com/shoriwe/CAPitan/internal/limit"
+	"github.com/shoriwe/CAPitan/internal/live"
 	"github.com/shoriwe/CAPitan/internal/logs"
+	"github.com/shoriwe/CAPitan/internal/oui"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
 	"github.com/shoriwe/CAPitan/internal/sessions"
+	"github.com/shoriwe/CAPitan/internal/spoof"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/tools"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/sha3"
@@ -41,14 +48,21 @@ type (
 		data.Database
 		*logs.Logger
 		*limit.Limiter
-		devices               map[string]pcap.Interface
-		reservedCaptures      map[string]map[string]struct{}
-		reservedCapturesMutex *sync.Mutex
-		reservedARPScans      map[string]map[string]struct{}
-		reservedARPScansMutex *sync.Mutex
-		Templates             embed.FS
-		LoginSessions         *sessions.Sessions
-		ResetSessions         *sessions.Sessions
+		devices                map[string]pcap.Interface
+		reservedCaptures       map[string]map[string]struct{}
+		reservedCapturesMutex  *sync.Mutex
+		reservedARPScans       map[string]map[string]struct{}
+		reservedARPScansMutex  *sync.Mutex
+		reservedPortScans      map[string]map[string]struct{}
+		reservedPortScansMutex *sync.Mutex
+		Templates              embed.FS
+		LoginSessions          *sessions.Sessions
+		ResetSessions          *sessions.Sessions
+		ActiveTasks            *tasks.Tasks
+		// LiveCaptures are the captures running independently of the clients watching them
+		LiveCaptures *live.Captures
+		// Scheduler runs the jobs of the users, it is set once the runners of the jobs are known
+		Scheduler *scheduler.Scheduler
 	}
 )
 
@@ -77,17 +91,21 @@ func NewContext(responseWriter http.ResponseWriter, request *http.Request) *Cont
 
 func New(c data.Database, l *logs.Logger, t embed.FS) *Middleware {
 	return &Middleware{
-		Database:              c,
-		Logger:                l,
-		Templates:             t,
-		reservedCaptures:      map[string]map[string]struct{}{},
-		reservedCapturesMutex: new(sync.Mutex),
-		reservedARPScans:      map[string]map[string]struct{}{},
-		reservedARPScansMutex: new(sync.Mutex),
-		Limiter:               limit.NewLimiter(),
-		LoginSessions:         sessions.NewSessions(),
-		ResetSessions:         sessions.NewSessions(),
-		devices:               nil,
+		Database:               c,
+		Logger:                 l,
+		Templates:              t,
+		reservedCaptures:       map[string]map[string]struct{}{},
+		reservedCapturesMutex:  new(sync.Mutex),
+		reservedARPScans:       map[string]map[string]struct{}{},
+		reservedARPScansMutex:  new(sync.Mutex),
+		reservedPortScans:      map[string]map[string]struct{}{},
+		reservedPortScansMutex: new(sync.Mutex),
+		Limiter:                limit.NewLimiter(),
+		LoginSessions:          sessions.NewSessions(),
+		ResetSessions:          sessions.NewSessions(),
+		ActiveTasks:            tasks.NewTasks(),
+		LiveCaptures:           live.NewCaptures(),
+		devices:                nil,
 	}
 }
 
@@ -312,16 +330,97 @@ func (middleware *Middleware) ListNetInterfaces(request *http.Request) map[strin
 	return middleware.devices
 }
 
-func (middleware *Middleware) QueryUserPermissions(request *http.Request, username string) (user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, succeed bool) {
+func (middleware *Middleware) QueryUserPermissions(request *http.Request, username string) (user *objects.User, captureInterfaces map[string]*objects.CapturePermission, arpScanInterfaces map[string]*objects.ARPScanPermission, arpSpoofInterfaces map[string]*objects.ARPSpoofPermission, injectInterfaces map[string]*objects.InjectPermission, replayInterfaces map[string]*objects.ReplayPermission, portScanInterfaces map[string]*objects.PortScanPermission, succeed bool) {
 	var getError error
-	succeed, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, getError = middleware.Database.GetUserInterfacePermissions(username)
+	succeed, user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, injectInterfaces, replayInterfaces, portScanInterfaces, getError = middleware.Database.GetUserInterfacePermissions(username)
 	if getError != nil {
 		go middleware.LogError(request, getError)
 		go middleware.LogQueryUserPermissions(request, username, false)
-		return nil, nil, nil, nil, false
+		return nil, nil, nil, nil, nil, nil, nil, false
 	}
 	go middleware.LogQueryUserPermissions(request, username, succeed)
-	return user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, succeed
+	return user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, injectInterfaces, replayInterfaces, portScanInterfaces, succeed
+}
+
+func (middleware *Middleware) AdminDeleteInjectInterfacePrivilege(request *http.Request, username string, i string) bool {
+	succeed, grantError := middleware.Database.DeleteInjectInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminDeleteInjectPrivilege(request, username, i, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminAddInjectInterfacePrivilege(request *http.Request, username string, i string) bool {
+	interfaces := middleware.ListNetInterfaces(request)
+	if interfaces == nil {
+		go middleware.LogAdminAddInjectPrivilege(request, username, i, false)
+		return false
+	}
+	if _, found := interfaces[i]; !found {
+		go middleware.LogAdminAddInjectPrivilege(request, username, i, false)
+		return false
+	}
+	succeed, grantError := middleware.Database.AddInjectInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminAddInjectPrivilege(request, username, i, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminDeleteReplayInterfacePrivilege(request *http.Request, username string, i string) bool {
+	succeed, grantError := middleware.Database.DeleteReplayInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminDeleteReplayPrivilege(request, username, i, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminAddReplayInterfacePrivilege(request *http.Request, username string, i string) bool {
+	interfaces := middleware.ListNetInterfaces(request)
+	if interfaces == nil {
+		go middleware.LogAdminAddReplayPrivilege(request, username, i, false)
+		return false
+	}
+	if _, found := interfaces[i]; !found {
+		go middleware.LogAdminAddReplayPrivilege(request, username, i, false)
+		return false
+	}
+	succeed, grantError := middleware.Database.AddReplayInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminAddReplayPrivilege(request, username, i, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminDeletePortScanInterfacePrivilege(request *http.Request, username string, i string) bool {
+	succeed, grantError := middleware.Database.DeletePortScanInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminDeletePortScanPrivilege(request, username, i, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminAddPortScanInterfacePrivilege(request *http.Request, username string, i string) bool {
+	interfaces := middleware.ListNetInterfaces(request)
+	if interfaces == nil {
+		go middleware.LogAdminAddPortScanPrivilege(request, username, i, false)
+		return false
+	}
+	if _, found := interfaces[i]; !found {
+		go middleware.LogAdminAddPortScanPrivilege(request, username, i, false)
+		return false
+	}
+	succeed, grantError := middleware.Database.AddPortScanInterfacePrivilege(username, i)
+	if grantError != nil {
+		go middleware.LogError(request, grantError)
+	}
+	go middleware.LogAdminAddPortScanPrivilege(request, username, i, succeed)
+	return succeed
 }
 
 func (middleware *Middleware) AdminDeleteARPSpoofInterfacePrivilege(request *http.Request, username string, i string) bool {
@@ -461,14 +560,18 @@ func (middleware *Middleware) RemoveReservedCaptureName(request *http.Request, u
 	return false
 }
 
-func (middleware *Middleware) SaveInterfaceCapture(request *http.Request, username, captureName, interfaceName, description, script string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) bool {
-	succeed, saveError := middleware.Database.SaveInterfaceCapture(username, captureName, interfaceName, description, script, promiscuous, topology, hostPacketCount, layer4Count, streamTypeCount, packets, streams, pcapContents, start, finish)
+func (middleware *Middleware) SaveInterfaceCapture(request *http.Request, username, captureName, interfaceName, description, script, bpfFilter string, promiscuous bool, topology, hostPacketCount, layer4Count, streamTypeCount interface{}, packets []gopacket.Packet, streams []capture.Data, pcapContents []byte, start, finish time.Time) bool {
+	succeed, saveError := middleware.Database.SaveInterfaceCapture(username, captureName, interfaceName, description, script, bpfFilter, promiscuous, topology, hostPacketCount, layer4Count, streamTypeCount, packets, streams, pcapContents, start, finish)
 	if saveError != nil {
 		go middleware.LogError(request, saveError)
 		go middleware.LogSaveInterfaceCapture(request, username, captureName, interfaceName, false)
 		return false
 	}
 	go middleware.LogSaveInterfaceCapture(request, username, captureName, interfaceName, succeed)
+	if succeed {
+		observations, convertError := objects.CaptureObservations(topology, finish)
+		middleware.updateInventory(request, username, &objects.InventorySighting{Kind: objects.CaptureSighting, Name: captureName, Time: finish}, observations, convertError)
+	}
 	return succeed
 }
 
@@ -488,25 +591,39 @@ func (middleware *Middleware) UserGetCapture(request *http.Request, username, ca
 	return false, nil, nil, nil
 }
 
-func (middleware *Middleware) SaveImportCapture(request *http.Request, username string, captureName string, description string, script string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, dumpPcap []byte) bool {
-	succeed, saveError := middleware.Database.SaveImportCapture(username, captureName, description, script, topologyOptions, hostCountOptions, layer4Options, streamTypeCountOptions, packets, streams, dumpPcap)
+func (middleware *Middleware) SaveImportCapture(request *http.Request, username string, captureName string, description string, script string, bpfFilter string, topologyOptions interface{}, hostCountOptions interface{}, layer4Options interface{}, streamTypeCountOptions interface{}, packets []gopacket.Packet, streams []capture.Data, dumpPcap []byte) bool {
+	succeed, saveError := middleware.Database.SaveImportCapture(username, captureName, description, script, bpfFilter, topologyOptions, hostCountOptions, layer4Options, streamTypeCountOptions, packets, streams, dumpPcap)
 	if saveError != nil {
 		go middleware.LogError(request, saveError)
 		go middleware.LogSaveImportCapture(request, username, captureName, false)
 		return false
 	}
 	go middleware.LogSaveImportCapture(request, username, captureName, succeed)
+	if succeed {
+		imported := time.Now()
+		observations, convertError := objects.CaptureObservations(topologyOptions, imported)
+		middleware.updateInventory(request, username, &objects.InventorySighting{Kind: objects.CaptureSighting, Name: captureName, Time: imported}, observations, convertError)
+	}
 	return succeed
 }
 
-func (middleware *Middleware) SaveARPScan(request *http.Request, username string, scanName string, interfaceName string, script string, hosts interface{}, start time.Time, finish time.Time) bool {
-	succeed, saveError := middleware.Database.SaveARPScan(username, scanName, interfaceName, script, hosts, start, finish)
+// SaveARPScan stores the scan and merges its hosts into the inventory, when alerts is set the hosts are first compared
+// with the inventory to raise the new devices and changed MACs
+func (middleware *Middleware) SaveARPScan(request *http.Request, username string, scanName string, interfaceName string, script string, targets string, exclude string, method string, hosts interface{}, start time.Time, finish time.Time, alerts bool) bool {
+	succeed, saveError := middleware.Database.SaveARPScan(username, scanName, interfaceName, script, targets, exclude, method, hosts, start, finish)
 	if saveError != nil {
 		go middleware.LogError(request, saveError)
 		go middleware.LogSaveARPScan(request, username, scanName, interfaceName, false)
 		return false
 	}
 	go middleware.LogSaveARPScan(request, username, scanName, interfaceName, succeed)
+	if succeed {
+		if alerts {
+			middleware.raiseAlerts(request, username, scanName, hosts, finish)
+		}
+		observations, convertError := objects.ARPScanObservations(hosts, finish)
+		middleware.updateInventory(request, username, &objects.InventorySighting{Kind: objects.ARPScanSighting, Name: scanName, Time: finish}, observations, convertError)
+	}
 	return succeed
 }
 
@@ -578,6 +695,221 @@ func (middleware *Middleware) UserGetARPScan(request *http.Request, username str
 	return succeed, scanSession
 }
 
+func (middleware *Middleware) SavePortScan(request *http.Request, username, scanName, interfaceName, targets, exclude, ports, mode string, results interface{}, start, finish time.Time) bool {
+	succeed, saveError := middleware.Database.SavePortScan(username, scanName, interfaceName, targets, exclude, ports, mode, results, start, finish)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSavePortScan(request, username, scanName, interfaceName, false)
+		return false
+	}
+	go middleware.LogSavePortScan(request, username, scanName, interfaceName, succeed)
+	if succeed {
+		observations, convertError := objects.PortScanObservations(results, finish)
+		middleware.updateInventory(request, username, &objects.InventorySighting{Kind: objects.PortScanSighting, Name: scanName, Time: finish}, observations, convertError)
+	}
+	return succeed
+}
+
+func (middleware *Middleware) ReserveUserPortScanName(request *http.Request, username string, scanName string) bool {
+	succeed, _ := middleware.UserGetPortScan(request, username, scanName)
+	if succeed {
+		return false
+	}
+	if middleware.isPortScanAlreadyTaken(username, scanName) {
+		go middleware.LogReservePortScanNameForUser(request, username, scanName, false)
+		return false
+	}
+	middleware.reservedPortScansMutex.Lock()
+	defer middleware.reservedPortScansMutex.Unlock()
+	_, found := middleware.reservedPortScans[username]
+	if !found {
+		go middleware.LogReservePortScanNameForUser(request, username, scanName, true)
+		middleware.reservedPortScans[username] = map[string]struct{}{scanName: {}}
+		return true
+	}
+	go middleware.LogReservePortScanNameForUser(request, username, scanName, true)
+	middleware.reservedPortScans[username][scanName] = struct{}{}
+	return true
+}
+
+func (middleware *Middleware) RemoveReservedPortScanName(request *http.Request, username string, scanName string) bool {
+	if middleware.isPortScanAlreadyTaken(username, scanName) {
+		middleware.reservedPortScansMutex.Lock()
+		defer middleware.reservedPortScansMutex.Unlock()
+		delete(middleware.reservedPortScans[username], scanName)
+		go middleware.LogRemoveReservedPortScanNameForUser(request, username, scanName, true)
+		return true
+	}
+	go middleware.LogRemoveReservedPortScanNameForUser(request, username, scanName, false)
+	return false
+}
+
+func (middleware *Middleware) isPortScanAlreadyTaken(username string, scanName string) bool {
+	// Check in the ones reserved
+	middleware.reservedPortScansMutex.Lock()
+	defer middleware.reservedPortScansMutex.Unlock()
+	user, found := middleware.reservedPortScans[username]
+	if found {
+		_, found = user[scanName]
+		return found
+	}
+	return false
+}
+
+func (middleware *Middleware) ListUserPortScans(request *http.Request, username string) (bool, []*objects.PortScanSession) {
+	succeed, scans, listError := middleware.Database.ListUserPortScans(username)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserPortScans(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserPortScans(request, username, succeed)
+	return succeed, scans
+}
+
+func (middleware *Middleware) UserGetPortScan(request *http.Request, username string, scanName string) (bool, *objects.PortScanSession) {
+	succeed, scanSession, queryError := middleware.Database.QueryPortScan(username, scanName)
+	if queryError != nil {
+		go middleware.LogError(request, queryError)
+		go middleware.LogQueryUserPortScan(request, username, scanName, false)
+		return false, nil
+	}
+	go middleware.LogQueryUserPortScan(request, username, scanName, succeed)
+	return succeed, scanSession
+}
+
+// ScanHosts returns the hosts stored with the scan, labeled with the vendors of the OUI database currently loaded
+func (middleware *Middleware) ScanHosts(request *http.Request, scanSession *objects.ARPScanSession) ([]objects.ScannedHost, bool) {
+	var hosts []objects.ScannedHost
+	unmarshalError := json.Unmarshal(scanSession.Hosts, &hosts)
+	if unmarshalError != nil {
+		go middleware.LogError(request, unmarshalError)
+		return nil, false
+	}
+	for index := range hosts {
+		hosts[index].Vendor, hosts[index].LocallyAdministered = oui.Lookup(hosts[index].MAC)
+	}
+	return hosts, true
+}
+
+// updateInventory merges the hosts observed by a saved session into the inventory of the user
+func (middleware *Middleware) updateInventory(request *http.Request, username string, sighting *objects.InventorySighting, observations []*objects.InventoryObservation, convertError error) {
+	if convertError != nil {
+		go middleware.LogError(request, convertError)
+		go middleware.LogUpdateInventory(request, username, sighting.Kind, sighting.Name, false)
+		return
+	}
+	succeed, updateError := middleware.Database.UpdateInventory(username, sighting, observations)
+	if updateError != nil {
+		go middleware.LogError(request, updateError)
+		go middleware.LogUpdateInventory(request, username, sighting.Kind, sighting.Name, false)
+		return
+	}
+	go middleware.LogUpdateInventory(request, username, sighting.Kind, sighting.Name, succeed)
+}
+
+// raiseAlerts stores the alerts of the scan hosts against the inventory the user had before the scan
+func (middleware *Middleware) raiseAlerts(request *http.Request, username, scanName string, hosts interface{}, finish time.Time) {
+	succeed, inventory := middleware.ListUserInventory(request, username, "")
+	if !succeed {
+		return
+	}
+	alerts, convertError := objects.InventoryAlerts(inventory, hosts, scanName, finish)
+	if convertError != nil {
+		go middleware.LogError(request, convertError)
+		go middleware.LogSaveAlerts(request, username, scanName, false)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	succeed, saveError := middleware.Database.SaveAlerts(username, alerts)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSaveAlerts(request, username, scanName, false)
+		return
+	}
+	go middleware.LogSaveAlerts(request, username, scanName, succeed)
+}
+
+func (middleware *Middleware) ListUserAlerts(request *http.Request, username string) (bool, []*objects.Alert) {
+	succeed, alerts, listError := middleware.Database.ListUserAlerts(username)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserAlerts(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserAlerts(request, username, succeed)
+	return succeed, alerts
+}
+
+func (middleware *Middleware) ListUserInventory(request *http.Request, username, search string) (bool, []*objects.InventoryHost) {
+	succeed, hosts, listError := middleware.Database.ListUserInventory(username, search)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserInventory(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserInventory(request, username, succeed)
+	return succeed, hosts
+}
+
+func (middleware *Middleware) UserGetInventoryHost(request *http.Request, username string, hostId uint) (bool, *objects.InventoryHost) {
+	succeed, host, queryError := middleware.Database.QueryInventoryHost(username, hostId)
+	if queryError != nil {
+		go middleware.LogError(request, queryError)
+		go middleware.LogQueryUserInventoryHost(request, username, hostId, false)
+		return false, nil
+	}
+	go middleware.LogQueryUserInventoryHost(request, username, hostId, succeed)
+	return succeed, host
+}
+
+// CaptureHosts returns the hosts of the topology stored with the capture, with their vendors and fingerprints
+func (middleware *Middleware) CaptureHosts(request *http.Request, captureSession *objects.CaptureSession) ([]objects.TopologyHost, bool) {
+	hosts, unmarshalError := objects.TopologyHosts(captureSession.TopologyJson)
+	if unmarshalError != nil {
+		go middleware.LogError(request, unmarshalError)
+		return nil, false
+	}
+	return hosts, true
+}
+
+// PortScanResults returns the ports stored with the scan
+func (middleware *Middleware) PortScanResults(request *http.Request, scanSession *objects.PortScanSession) ([]objects.ScannedPort, bool) {
+	var results []objects.ScannedPort
+	unmarshalError := json.Unmarshal(scanSession.Results, &results)
+	if unmarshalError != nil {
+		go middleware.LogError(request, unmarshalError)
+		return nil, false
+	}
+	return results, true
+}
+
+// CollectSpoofTargets expands the targets written by the user and the hosts of the selected ARP scan,
+// on failure the message to show the user is returned
+func (middleware *Middleware) CollectSpoofTargets(request *http.Request, username, rawTargets, scanName string) ([]net.IP, string) {
+	entries := spoof.SplitTargets(rawTargets)
+	if len(scanName) > 0 {
+		succeed, scanSession := middleware.UserGetARPScan(request, username, scanName)
+		if !succeed {
+			return nil, "ARP scan not found"
+		}
+		hosts, loaded := middleware.ScanHosts(request, scanSession)
+		if !loaded {
+			return nil, "Failed to load the hosts of the ARP scan"
+		}
+		for _, host := range hosts {
+			entries = append(entries, host.IP)
+		}
+	}
+	targets, parseError := spoof.ParseTargets(entries)
+	if parseError != nil || len(targets) == 0 {
+		return nil, "Invalid IP provided"
+	}
+	return targets, ""
+}
+
 func (middleware *Middleware) AdminListAllARPScans(request *http.Request, username string) (bool, []*objects.ARPScanSessionAdminView) {
 	succeed, scanSessions, listError := middleware.Database.ListAllARPScans()
 	if listError != nil {
@@ -589,6 +921,148 @@ func (middleware *Middleware) AdminListAllARPScans(request *http.Request, userna
 	return succeed, scanSessions
 }
 
+func (middleware *Middleware) UserSaveJob(request *http.Request, username string, job *objects.Job) bool {
+	succeed, saveError := middleware.Database.SaveJob(username, job)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSaveJob(request, username, job.Name, false)
+		return false
+	}
+	go middleware.LogSaveJob(request, username, job.Name, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) ListUserJobs(request *http.Request, username string) (bool, []*objects.Job) {
+	succeed, jobs, listError := middleware.Database.ListUserJobs(username)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserJobs(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserJobs(request, username, succeed)
+	return succeed, jobs
+}
+
+func (middleware *Middleware) UserGetJob(request *http.Request, username string, jobId uint) (bool, *objects.Job) {
+	succeed, job, queryError := middleware.Database.QueryJob(username, jobId)
+	if queryError != nil {
+		go middleware.LogError(request, queryError)
+		go middleware.LogQueryUserJob(request, username, jobId, false)
+		return false, nil
+	}
+	go middleware.LogQueryUserJob(request, username, jobId, succeed)
+	return succeed, job
+}
+
+func (middleware *Middleware) UserDeleteJob(request *http.Request, username string, jobId uint) bool {
+	succeed, deleteError := middleware.Database.DeleteJob(username, jobId)
+	if deleteError != nil {
+		go middleware.LogError(request, deleteError)
+		go middleware.LogDeleteJob(request, username, jobId, false)
+		return false
+	}
+	go middleware.LogDeleteJob(request, username, jobId, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) SaveJobRun(request *http.Request, username string, run *objects.JobRun) bool {
+	succeed, saveError := middleware.Database.SaveJobRun(username, run)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSaveJobRun(request, username, run.JobId, false)
+		return false
+	}
+	go middleware.LogSaveJobRun(request, username, run.JobId, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) ListJobRuns(request *http.Request, username string, jobId uint) (bool, []*objects.JobRun) {
+	succeed, runs, listError := middleware.Database.ListJobRuns(username, jobId)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListJobRuns(request, username, jobId, false)
+		return false, nil
+	}
+	go middleware.LogListJobRuns(request, username, jobId, succeed)
+	return succeed, runs
+}
+
+// ScheduledJobs lists the jobs of every user for the scheduler, it runs every second so only the errors are logged
+func (middleware *Middleware) ScheduledJobs(request *http.Request) []*objects.JobAdminView {
+	_, jobs, listError := middleware.Database.ListAllJobs()
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		return nil
+	}
+	return jobs
+}
+
+func (middleware *Middleware) SaveARPSpoof(request *http.Request, username, interfaceName, targetIP, gateway, captureName string, packetsSent uint64, stopReason string, dnsAnswers []*objects.DNSSpoofedAnswer, start, finish time.Time) bool {
+	succeed, saveError := middleware.Database.SaveARPSpoof(username, interfaceName, targetIP, gateway, captureName, packetsSent, stopReason, dnsAnswers, start, finish)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSaveARPSpoof(request, username, targetIP, gateway, false)
+		return false
+	}
+	go middleware.LogSaveARPSpoof(request, username, targetIP, gateway, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) ListUserARPSpoofs(request *http.Request, username string) (bool, []*objects.ARPSpoofSession) {
+	succeed, spoofs, listError := middleware.Database.ListUserARPSpoofs(username)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserARPSpoofs(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserARPSpoofs(request, username, succeed)
+	return succeed, spoofs
+}
+
+func (middleware *Middleware) AdminListAllARPSpoofs(request *http.Request, username string) (bool, []*objects.ARPSpoofSessionAdminView) {
+	succeed, spoofSessions, listError := middleware.Database.ListAllARPSpoofs()
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListAllARPSpoofs(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListAllARPSpoofs(request, username, succeed)
+	return succeed, spoofSessions
+}
+
+func (middleware *Middleware) SaveInjection(request *http.Request, username, interfaceName, script string, framesSent uint64, stopReason string, frames []*objects.InjectedFrame, start, finish time.Time) bool {
+	succeed, saveError := middleware.Database.SaveInjection(username, interfaceName, script, framesSent, stopReason, frames, start, finish)
+	if saveError != nil {
+		go middleware.LogError(request, saveError)
+		go middleware.LogSaveInjection(request, username, interfaceName, false)
+		return false
+	}
+	go middleware.LogSaveInjection(request, username, interfaceName, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) ListUserInjections(request *http.Request, username string) (bool, []*objects.InjectionSession) {
+	succeed, injections, listError := middleware.Database.ListUserInjections(username)
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListUserInjections(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListUserInjections(request, username, succeed)
+	return succeed, injections
+}
+
+func (middleware *Middleware) AdminListAllInjections(request *http.Request, username string) (bool, []*objects.InjectionSessionAdminView) {
+	succeed, injections, listError := middleware.Database.ListAllInjections()
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListAllInjections(request, username, false)
+		return false, nil
+	}
+	go middleware.LogListAllInjections(request, username, succeed)
+	return succeed, injections
+}
+
 func (middleware *Middleware) AdminListAllCaptures(request *http.Request, username string) (bool, []*objects.CaptureSessionAdminView) {
 	succeed, scanSessions, listError := middleware.Database.ListAllCaptures()
 	if listError != nil {
@@ -599,3 +1073,138 @@ func (middleware *Middleware) AdminListAllCaptures(request *http.Request, userna
 	go middleware.LogListAllCaptures(request, username, succeed)
 	return succeed, scanSessions
 }
+
+func (middleware *Middleware) UserGetStorageUsage(request *http.Request, username string) (bool, *objects.StorageUsage) {
+	succeed, usage, queryError := middleware.Database.GetUserStorageUsage(username)
+	if queryError != nil {
+		go middleware.LogError(request, queryError)
+		go middleware.LogQueryUserStorageUsage(request, username, false)
+		return false, nil
+	}
+	go middleware.LogQueryUserStorageUsage(request, username, succeed)
+	return succeed, usage
+}
+
+func (middleware *Middleware) AdminListStorageUsage(request *http.Request, username string) (bool, []*objects.StorageUsage, []*objects.StorageQuota) {
+	succeed, usage, listError := middleware.Database.ListStorageUsage()
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListStorageUsage(request, username, false)
+		return false, nil, nil
+	}
+	if !succeed {
+		go middleware.LogListStorageUsage(request, username, false)
+		return false, nil, nil
+	}
+	var roleQuotas []*objects.StorageQuota
+	succeed, roleQuotas, listError = middleware.Database.ListRoleStorageQuotas()
+	if listError != nil {
+		go middleware.LogError(request, listError)
+		go middleware.LogListStorageUsage(request, username, false)
+		return false, nil, nil
+	}
+	go middleware.LogListStorageUsage(request, username, succeed)
+	return succeed, usage, roleQuotas
+}
+
+func (middleware *Middleware) AdminSetUserStorageQuota(request *http.Request, username string, maxBytes uint64, maxCaptures uint) bool {
+	succeed, setError := middleware.Database.SetUserStorageQuota(username, maxBytes, maxCaptures)
+	if setError != nil {
+		go middleware.LogError(request, setError)
+	}
+	go middleware.LogAdminSetUserStorageQuota(request, username, maxBytes, maxCaptures, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminDeleteUserStorageQuota(request *http.Request, username string) bool {
+	succeed, deleteError := middleware.Database.DeleteUserStorageQuota(username)
+	if deleteError != nil {
+		go middleware.LogError(request, deleteError)
+	}
+	go middleware.LogAdminDeleteUserStorageQuota(request, username, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminSetRoleStorageQuota(request *http.Request, role string, maxBytes uint64, maxCaptures uint) bool {
+	if role != objects.AdminRole && role != objects.UserRole {
+		go middleware.LogAdminSetRoleStorageQuota(request, role, maxBytes, maxCaptures, false)
+		return false
+	}
+	succeed, setError := middleware.Database.SetRoleStorageQuota(role, maxBytes, maxCaptures)
+	if setError != nil {
+		go middleware.LogError(request, setError)
+	}
+	go middleware.LogAdminSetRoleStorageQuota(request, role, maxBytes, maxCaptures, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) RegisterTask(request *http.Request, kind, username, interfaceName, name, targets string) (*tasks.Task, bool) {
+	task, registerError := middleware.ActiveTasks.Register(kind, username, interfaceName, name, targets)
+	if registerError != nil {
+		go middleware.LogError(request, registerError)
+		go middleware.LogRegisterTask(request, kind, username, name, false)
+		return nil, false
+	}
+	go middleware.LogRegisterTask(request, kind, username, name, true)
+	return task, true
+}
+
+func (middleware *Middleware) RemoveTask(request *http.Request, task *tasks.Task) {
+	middleware.ActiveTasks.Remove(task.Id)
+	go middleware.LogRemoveTask(request, task.Kind, task.Username, task.Name)
+}
+
+// captureInterfaces returns the interfaces the user can capture on
+func (middleware *Middleware) captureInterfaces(request *http.Request, username string) map[string]struct{} {
+	succeed, _, captureInterfaces, _, _, _, _, _, getError := middleware.GetUserInterfacePermissions(username)
+	if getError != nil {
+		go middleware.LogError(request, getError)
+	}
+	interfaces := map[string]struct{}{}
+	if !succeed {
+		return interfaces
+	}
+	for interfaceName := range captureInterfaces {
+		interfaces[interfaceName] = struct{}{}
+	}
+	return interfaces
+}
+
+// UserAttachCapture attaches a new viewer of the user to a running capture of the owner, users besides the owner
+// need the capture permission of its interface
+func (middleware *Middleware) UserAttachCapture(request *http.Request, username, owner, captureName string) (*live.Capture, *live.Viewer, []tools.ServerWSResponse, bool) {
+	liveCapture, found := middleware.LiveCaptures.Get(owner, captureName)
+	if found && owner != username {
+		_, found = middleware.captureInterfaces(request, username)[liveCapture.Interface]
+	}
+	if !found {
+		go middleware.LogAttachCapture(request, username, owner, captureName, false)
+		return nil, nil, nil, false
+	}
+	viewer, backlog, attached := liveCapture.Attach(username)
+	go middleware.LogAttachCapture(request, username, owner, captureName, attached)
+	return liveCapture, viewer, backlog, attached
+}
+
+// ListSharedCaptures returns the captures other users are running on the interfaces the user can capture on
+func (middleware *Middleware) ListSharedCaptures(request *http.Request, username string) []*live.Capture {
+	return middleware.LiveCaptures.ListShared(username, middleware.captureInterfaces(request, username))
+}
+
+// UserStopCapture asks a running capture of the user to finish and be stored
+func (middleware *Middleware) UserStopCapture(request *http.Request, username, captureName, reason string) bool {
+	liveCapture, found := middleware.LiveCaptures.Get(username, captureName)
+	if !found {
+		go middleware.LogStopCapture(request, username, captureName, false)
+		return false
+	}
+	succeed := liveCapture.Stop(reason)
+	go middleware.LogStopCapture(request, username, captureName, succeed)
+	return succeed
+}
+
+func (middleware *Middleware) AdminKillTask(request *http.Request, username, id, reason string) bool {
+	_, succeed := middleware.ActiveTasks.Kill(id, reason)
+	go middleware.LogAdminKillTask(request, username, id, succeed)
+	return succeed
+}
@@ -23,13 +23,8 @@ func viewARPScan(mw *middleware.Middleware, context *middleware.Context) bool {
 		return false
 	}
 
-	var hosts []struct {
-		IP  string
-		MAC string
-	}
-	unmarshalError := json.Unmarshal(scanSession.Hosts, &hosts)
-	if unmarshalError != nil {
-		go mw.LogError(context.Request, unmarshalError)
+	hosts, loaded := mw.ScanHosts(context.Request, scanSession)
+	if !loaded {
 		context.Redirect = symbols.AdminARPScans
 		return false
 	}
@@ -43,15 +38,18 @@ func viewARPScan(mw *middleware.Middleware, context *middleware.Context) bool {
 			ScanName      string
 			InterfaceName string
 			Script        string
-			Hosts         []struct {
-				IP  string
-				MAC string
-			}
+			Targets       string
+			Exclude       string
+			Method        string
+			Hosts         []objects.ScannedHost
 		}{
 			Username:      username,
 			ScanName:      html.EscapeString(scanSession.Name),
 			InterfaceName: html.EscapeString(scanSession.Interface),
 			Script:        html.EscapeString(string(scanSession.Script)),
+			Targets:       scanSession.Targets,
+			Exclude:       scanSession.Exclude,
+			Method:        scanSession.Method,
 			Hosts:         hosts,
 		},
 	)
@@ -71,8 +69,19 @@ func downloadUserARPScan(mw *middleware.Middleware, context *middleware.Context)
 		context.Redirect = symbols.Dashboard
 		return false
 	}
+	hosts, loaded := mw.ScanHosts(context.Request, scanSession)
+	if !loaded {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	export, marshalError := json.Marshal(hosts)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
 	context.ResponseWriter.Header().Add("Content-Disposition", "attachment; filename=\"arp-scan.json\"")
-	_, writeError := context.ResponseWriter.Write(scanSession.Hosts)
+	_, writeError := context.ResponseWriter.Write(export)
 	if writeError != nil {
 		go mw.LogError(context.Request, writeError)
 		return false
@@ -126,3 +135,36 @@ func ListUserARPScans(mw *middleware.Middleware, context *middleware.Context) bo
 	}
 	return http405.MethodNotAllowed(mw, context)
 }
+
+func listUserARPSpoofs(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, spoofs := mw.AdminListAllARPSpoofs(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.AdminPanel
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/admin/arp-spoof-list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("ARP spoof list").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Spoofs []*objects.ARPSpoofSessionAdminView
+		}{
+			Spoofs: spoofs,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("ARP spoofs", context.NavigationBar, body.String())
+	return false
+}
+
+func ListUserARPSpoofs(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.Method {
+	case http.MethodGet:
+		return listUserARPSpoofs(mw, context)
+	}
+	return http405.MethodNotAllowed(mw, context)
+}
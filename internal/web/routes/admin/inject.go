@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/http405"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"html/template"
+	"net/http"
+)
+
+func listUserInjections(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, injections := mw.AdminListAllInjections(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.AdminPanel
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/admin/inject-list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Injection list").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Injections []*objects.InjectionSessionAdminView
+		}{
+			Injections: injections,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Injections", context.NavigationBar, body.String())
+	return false
+}
+
+func ListUserInjections(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.Method {
+	case http.MethodGet:
+		return listUserInjections(mw, context)
+	}
+	return http405.MethodNotAllowed(mw, context)
+}
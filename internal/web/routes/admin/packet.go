@@ -41,12 +41,14 @@ func renderOldCapture(mw *middleware.Middleware, context *middleware.Context) bo
 			CaptureName    string
 			Description    string
 			Script         string
+			BPFFilter      string
 		}{
 			RawUsername:    username,
 			RawCaptureName: captureName,
 			CaptureName:    html.EscapeString(captureName),
 			Description:    html.EscapeString(captureSession.Description),
 			Script:         html.EscapeString(string(captureSession.FilterScript)),
+			BPFFilter:      captureSession.BPFFilter,
 		},
 	)
 	if executeError != nil {
@@ -249,6 +251,36 @@ func downloadCapture(mw *middleware.Middleware, context *middleware.Context) boo
 	return false
 }
 
+// downloadCaptureHosts exports the hosts of the capture with the vendors of their MACs and the guesses of their systems
+func downloadCaptureHosts(mw *middleware.Middleware, context *middleware.Context) bool {
+	captureName := context.Request.PostFormValue(symbols.CaptureName)
+	username := context.Request.PostFormValue(symbols.Username)
+	succeed, captureSession, _, _ := mw.UserGetCapture(context.Request, username, captureName)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	hosts, loaded := mw.CaptureHosts(context.Request, captureSession)
+	if !loaded {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	export, marshalError := json.Marshal(hosts)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	context.ResponseWriter.Header().Add("Content-Disposition", "attachment; filename=\"capture-hosts.json\"")
+	_, writeError := context.ResponseWriter.Write(export)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	context.WriteBody = false
+	return false
+}
+
 func listCaptures(mw *middleware.Middleware, context *middleware.Context) bool {
 	succeed, userCaptures := mw.AdminListAllCaptures(context.Request, context.User.Username)
 	if !succeed {
@@ -280,6 +312,8 @@ func PacketCaptures(mw *middleware.Middleware, context *middleware.Context) bool
 		return handleCaptureView(mw, context)
 	case actions.Download:
 		return downloadCapture(mw, context)
+	case actions.DownloadHosts:
+		return downloadCaptureHosts(mw, context)
 	}
 	return listCaptures(mw, context)
 }
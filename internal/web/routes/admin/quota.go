@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+func parseQuotaForm(context *middleware.Context) (maxBytes uint64, maxCaptures uint, succeed bool) {
+	maxMegabytes, parseError := strconv.ParseUint(context.Request.PostFormValue(symbols.MaxMegabytes), 10, 64)
+	if parseError != nil {
+		return 0, 0, false
+	}
+	var captures uint64
+	captures, parseError = strconv.ParseUint(context.Request.PostFormValue(symbols.MaxCaptures), 10, 32)
+	if parseError != nil {
+		return 0, 0, false
+	}
+	return maxMegabytes * 1024 * 1024, uint(captures), true
+}
+
+func setUserQuota(mw *middleware.Middleware, context *middleware.Context) bool {
+	context.Redirect = symbols.AdminStorageQuotas
+	maxBytes, maxCaptures, succeed := parseQuotaForm(context)
+	if !succeed {
+		return false
+	}
+	mw.AdminSetUserStorageQuota(context.Request, context.Request.PostFormValue(symbols.Username), maxBytes, maxCaptures)
+	return false
+}
+
+func deleteUserQuota(mw *middleware.Middleware, context *middleware.Context) bool {
+	context.Redirect = symbols.AdminStorageQuotas
+	mw.AdminDeleteUserStorageQuota(context.Request, context.Request.PostFormValue(symbols.Username))
+	return false
+}
+
+func setRoleQuota(mw *middleware.Middleware, context *middleware.Context) bool {
+	context.Redirect = symbols.AdminStorageQuotas
+	maxBytes, maxCaptures, succeed := parseQuotaForm(context)
+	if !succeed {
+		return false
+	}
+	mw.AdminSetRoleStorageQuota(context.Request, context.Request.PostFormValue(symbols.Role), maxBytes, maxCaptures)
+	return false
+}
+
+func listQuotas(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, usage, roleQuotas := mw.AdminListStorageUsage(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/admin/quotas.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Admin Quotas").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Usage      []*objects.StorageUsage
+			RoleQuotas []*objects.StorageQuota
+		}{
+			Usage:      usage,
+			RoleQuotas: roleQuotas,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Admin Quotas", context.NavigationBar, body.String())
+	return false
+}
+
+func StorageQuotas(mw *middleware.Middleware, context *middleware.Context) bool {
+	if context.Request.Method == http.MethodPost {
+		switch context.Request.URL.Query().Get(actions.Action) {
+		case actions.SetUserQuota:
+			return setUserQuota(mw, context)
+		case actions.DeleteUserQuota:
+			return deleteUserQuota(mw, context)
+		case actions.SetRoleQuota:
+			return setRoleQuota(mw, context)
+		}
+	}
+	return listQuotas(mw, context)
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"net/http"
+	"time"
+)
+
+type activeTask struct {
+	Id        string
+	Kind      string
+	Username  string
+	Interface string
+	Name      string
+	Targets   string
+	Started   time.Time
+	Duration  string
+	Packets   uint64
+}
+
+func listActiveTasks(mw *middleware.Middleware, context *middleware.Context) bool {
+	var result []activeTask
+	for _, task := range mw.ActiveTasks.List() {
+		result = append(result,
+			activeTask{
+				Id:        task.Id,
+				Kind:      task.Kind,
+				Username:  task.Username,
+				Interface: task.Interface,
+				Name:      task.Name,
+				Targets:   task.Targets,
+				Started:   task.Started,
+				Duration:  task.Duration().String(),
+				Packets:   task.Packets(),
+			},
+		)
+	}
+	responseBody, marshalError := json.Marshal(result)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.StatusCode = http.StatusInternalServerError
+		return false
+	}
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func killTask(mw *middleware.Middleware, context *middleware.Context) bool {
+	id := context.Request.PostFormValue(symbols.Task)
+	reason := context.Request.PostFormValue(symbols.Reason)
+	if len(reason) == 0 || tools.CheckFilledWithWhiteSpace.MatchString(reason) {
+		reason = "Stopped by an administrator"
+	}
+	succeed := mw.AdminKillTask(context.Request, context.User.Username, id, reason)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func ActiveTasks(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.FormValue(actions.Action) {
+	case actions.List:
+		return listActiveTasks(mw, context)
+	case actions.Kill:
+		if context.Request.Method == http.MethodPost {
+			return killTask(mw, context)
+		}
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/admin/tasks.html")
+	context.Body = base.NewPage("Admin Tasks", context.NavigationBar, string(templateContents))
+	return false
+}
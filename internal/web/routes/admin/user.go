@@ -85,7 +85,7 @@ func editUser(mw *middleware.Middleware, context *middleware.Context) bool {
 		context.Redirect = symbols.AdminEditUsers
 		return false
 	}
-	user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, succeed := mw.QueryUserPermissions(context.Request, username)
+	user, captureInterfaces, arpScanInterfaces, arpSpoofInterfaces, injectInterfaces, replayInterfaces, portScanInterfaces, succeed := mw.QueryUserPermissions(context.Request, username)
 	if !succeed {
 		context.Redirect = symbols.AdminEditUsers
 		return false
@@ -98,6 +98,12 @@ func editUser(mw *middleware.Middleware, context *middleware.Context) bool {
 		ARPScanUnsetInterfaces  []objects.InterfaceInformation
 		ARPSpoofInterfaces      []objects.InterfaceInformation
 		ARPSpoofUnsetInterfaces []objects.InterfaceInformation
+		InjectInterfaces        []objects.InterfaceInformation
+		InjectUnsetInterfaces   []objects.InterfaceInformation
+		ReplayInterfaces        []objects.InterfaceInformation
+		ReplayUnsetInterfaces   []objects.InterfaceInformation
+		PortScanInterfaces      []objects.InterfaceInformation
+		PortScanUnsetInterfaces []objects.InterfaceInformation
 	}
 
 	data.User = user
@@ -132,6 +138,21 @@ func editUser(mw *middleware.Middleware, context *middleware.Context) bool {
 		} else {
 			data.ARPSpoofInterfaces = append(data.ARPSpoofInterfaces, information)
 		}
+		if _, found := injectInterfaces[interfaceName]; !found {
+			data.InjectUnsetInterfaces = append(data.InjectUnsetInterfaces, information)
+		} else {
+			data.InjectInterfaces = append(data.InjectInterfaces, information)
+		}
+		if _, found := replayInterfaces[interfaceName]; !found {
+			data.ReplayUnsetInterfaces = append(data.ReplayUnsetInterfaces, information)
+		} else {
+			data.ReplayInterfaces = append(data.ReplayInterfaces, information)
+		}
+		if _, found := portScanInterfaces[interfaceName]; !found {
+			data.PortScanUnsetInterfaces = append(data.PortScanUnsetInterfaces, information)
+		} else {
+			data.PortScanInterfaces = append(data.PortScanInterfaces, information)
+		}
 	}
 	rawTemplate, _ := mw.Templates.ReadFile("templates/admin/user-edit.html")
 	var output bytes.Buffer
@@ -161,6 +182,66 @@ func updateStatus(mw *middleware.Middleware, context *middleware.Context) bool {
 	return false
 }
 
+func deleteInjectInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminDeleteInjectInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func addInjectInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminAddInjectInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func deleteReplayInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminDeleteReplayInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func addReplayInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminAddReplayInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func deletePortScanInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminDeletePortScanInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
+func addPortScanInterface(mw *middleware.Middleware, context *middleware.Context) bool {
+	username := context.Request.PostFormValue(symbols.Username)
+	i := context.Request.PostFormValue(symbols.Interface)
+	succeed := mw.AdminAddPortScanInterfacePrivilege(context.Request, username, i)
+	responseBody, _ := json.Marshal(succeedResponse{succeed})
+	context.Headers["Content-Type"] = "application/json"
+	context.Body = string(responseBody)
+	return false
+}
+
 func deleteARPSpoofInterface(mw *middleware.Middleware, context *middleware.Context) bool {
 	username := context.Request.PostFormValue(symbols.Username)
 	i := context.Request.PostFormValue(symbols.Interface)
@@ -246,6 +327,18 @@ func EditUsers(mw *middleware.Middleware, context *middleware.Context) bool {
 			return addARPSpoofInterface(mw, context)
 		case actions.DeleteARPSpoofInterface:
 			return deleteARPSpoofInterface(mw, context)
+		case actions.AddInjectInterface:
+			return addInjectInterface(mw, context)
+		case actions.DeleteInjectInterface:
+			return deleteInjectInterface(mw, context)
+		case actions.AddReplayInterface:
+			return addReplayInterface(mw, context)
+		case actions.DeleteReplayInterface:
+			return deleteReplayInterface(mw, context)
+		case actions.AddPortScanInterface:
+			return addPortScanInterface(mw, context)
+		case actions.DeletePortScanInterface:
+			return deletePortScanInterface(mw, context)
 		}
 	}
 	return listUsers(mw, context)
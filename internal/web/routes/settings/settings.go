@@ -1,12 +1,35 @@
 package settings
 
 import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
 	"github.com/shoriwe/CAPitan/internal/web/base"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"html/template"
 )
 
 func Settings(mw *middleware.Middleware, context *middleware.Context) bool {
-	settingsBody, _ := mw.Templates.ReadFile("templates/settings/settings.html")
-	context.Body = base.NewPage("Settings", context.NavigationBar, string(settingsBody))
+	succeed, usage := mw.UserGetStorageUsage(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	settingsTemplate, _ := mw.Templates.ReadFile("templates/settings/settings.html")
+	var settingsBody bytes.Buffer
+	executeError := template.Must(template.New("Settings").Parse(string(settingsTemplate))).Execute(
+		&settingsBody,
+		struct {
+			Usage *objects.StorageUsage
+		}{
+			Usage: usage,
+		},
+	)
+	if executeError != nil {
+		go mw.LogError(context.Request, executeError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	context.Body = base.NewPage("Settings", context.NavigationBar, settingsBody.String())
 	return false
 }
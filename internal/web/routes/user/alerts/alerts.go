@@ -0,0 +1,35 @@
+package alerts
+
+import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"html/template"
+)
+
+func Alerts(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, alerts := mw.ListUserAlerts(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/alerts/list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Alerts").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Alerts []*objects.Alert
+		}{
+			Alerts: alerts,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Alerts", context.NavigationBar, body.String())
+	return false
+}
@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"errors"
+	arp_scanner "github.com/shoriwe/CAPitan/internal/arp-scanner"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"net/http"
+	"time"
+)
+
+// RunScanJob runs the ARP scan of a job without a client until every host answered, the duration of the job passes
+// or an administrator kills the task, then stores the hosts found as the scan. It returns the hosts found and why
+// the scan stopped
+func RunScanJob(mw *middleware.Middleware, request *http.Request, username string, job *objects.Job, scanName string, task *tasks.Task) (uint64, string, error) {
+	engine, engineCreationError := arp_scanner.NewEngine(job.Interface, job.Targets, job.Exclude, job.Script, arp_scanner.Options{Method: job.Method})
+	if engineCreationError != nil {
+		return 0, "", engineCreationError
+	}
+	defer engine.Close()
+
+	var deadline <-chan time.Time
+	if job.Duration > 0 {
+		deadline = time.After(time.Duration(job.Duration) * time.Second)
+	}
+
+	hostsSet := map[string]int{}
+	var hosts []objects.ScannedHost
+	var countedPackets uint64
+	addHost := func(host arp_scanner.Host) {
+		hosts, _, _, _ = mergeHost(hosts, hostsSet, host)
+		packets := engine.Packets()
+		task.AddPackets(packets - countedPackets)
+		countedPackets = packets
+	}
+
+	engine.Start()
+
+	start := time.Now()
+	stopReason := "Scan finished"
+mainLoop:
+	for {
+		select {
+		case host, isOpen := <-engine.Hosts:
+			if !isOpen {
+				break mainLoop
+			}
+			addHost(host)
+		case engineError, isOpen := <-engine.ErrorChannel:
+			if isOpen {
+				return uint64(len(hosts)), "", engineError
+			}
+			break mainLoop
+		case <-engine.Done:
+			// Every answer is already queued
+			for {
+				select {
+				case host, isOpen := <-engine.Hosts:
+					if !isOpen {
+						break mainLoop
+					}
+					addHost(host)
+				default:
+					break mainLoop
+				}
+			}
+		case <-deadline:
+			stopReason = "Duration reached"
+			break mainLoop
+		case reason := <-task.Killed:
+			stopReason = "Killed by an administrator: " + reason
+			break mainLoop
+		}
+	}
+
+	task.AddPackets(engine.Packets() - countedPackets)
+
+	if !mw.SaveARPScan(request, username, scanName, job.Interface, job.Script, job.Targets, job.Exclude, job.Method, hosts, start, time.Now(), job.Alerts) {
+		return uint64(len(hosts)), "", errors.New("failed to store the scan")
+	}
+	return uint64(len(hosts)), stopReason, nil
+}
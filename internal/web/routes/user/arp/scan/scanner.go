@@ -6,6 +6,8 @@ import (
 	"github.com/gorilla/websocket"
 	arp_scanner "github.com/shoriwe/CAPitan/internal/arp-scanner"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/oui"
+	"github.com/shoriwe/CAPitan/internal/tasks"
 	"github.com/shoriwe/CAPitan/internal/tools"
 	"github.com/shoriwe/CAPitan/internal/web/base"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
@@ -25,22 +27,63 @@ var (
 	}
 )
 
-func checkScanArguments(scanName, script string) (bool, string) {
+// checkScanArguments only validates the scan name, an empty script or targets list means the interface subnet
+// is scanned
+func checkScanArguments(scanName string) (bool, string) {
 	if len(scanName) == 0 {
 		return false, "no scan name provided"
 	}
-	if len(script) == 0 {
-		return false, "no host generator script provided"
-	}
 	if tools.CheckFilledWithWhiteSpace.MatchString(scanName) {
 		return false, "no scan name provided"
 	}
-	if tools.CheckFilledWithWhiteSpace.MatchString(script) {
-		return false, "no host generator script provided"
-	}
 	return true, ""
 }
 
+// appendMissing adds the value to the list when it is not empty and not already there
+func appendMissing(list []string, value string) ([]string, bool) {
+	if len(value) == 0 {
+		return list, false
+	}
+	for _, entry := range list {
+		if entry == value {
+			return list, false
+		}
+	}
+	return append(list, value), true
+}
+
+// mergeHost adds the answer to the hosts of the scan, indexed by IP in hostsSet. It returns the stored host, if it
+// revealed a new MAC, hostname or evidence source and if the host was not found before
+func mergeHost(hosts []objects.ScannedHost, hostsSet map[string]int, host arp_scanner.Host) ([]objects.ScannedHost, *objects.ScannedHost, bool, bool) {
+	now := time.Now()
+	index, found := hostsSet[host.IP.String()]
+	if !found {
+		index = len(hosts)
+		hostsSet[host.IP.String()] = index
+		hosts = append(
+			hosts,
+			objects.ScannedHost{
+				IP:        host.IP.String(),
+				FirstSeen: now,
+			},
+		)
+	}
+	stored := &hosts[index]
+	stored.LastSeen = now
+	changed := !found
+	if len(host.MAC) > 0 && stored.MAC != host.MAC.String() {
+		stored.MAC = host.MAC.String()
+		stored.Vendor, stored.LocallyAdministered = oui.Vendor(host.MAC), oui.IsLocallyAdministered(host.MAC)
+		changed = true
+	}
+	var added bool
+	stored.Hostnames, added = appendMissing(stored.Hostnames, host.Hostname)
+	changed = changed || added
+	stored.Sources, added = appendMissing(stored.Sources, host.Source)
+	changed = changed || added
+	return hosts, stored, changed, !found
+}
+
 func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool {
 	connection, upgradeError := upgradeARPScanSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
 	if upgradeError != nil {
@@ -60,6 +103,19 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 		ScanName      string
 		InterfaceName string
 		Script        string
+		Targets       string
+		Exclude       string
+		Rate          uint
+		Retries       uint
+		// Milliseconds waited for every answer
+		Timeout uint
+		Method  string
+		Ports   []uint
+		Gateway string
+		// Look for the names of the hosts found
+		ResolveNames bool
+		// Raise alerts for the MACs missing in the inventory and the IPs answered by a different MAC
+		Alerts bool
 	}
 	readError := connection.ReadJSON(&configuration)
 	if readError != nil {
@@ -67,7 +123,15 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 		return false
 	}
 	// Check configuration
-	isValid, errorMessage := checkScanArguments(configuration.ScanName, configuration.Script)
+	isValid, errorMessage := checkScanArguments(configuration.ScanName)
+	var ports []uint16
+	for _, port := range configuration.Ports {
+		if port > 65535 {
+			isValid, errorMessage = false, "ports must be between 1 and 65535"
+			break
+		}
+		ports = append(ports, uint16(port))
+	}
 	if !isValid {
 		writeError := connection.WriteJSON(struct {
 			Succeed bool
@@ -102,7 +166,20 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 	}
 	defer mw.RemoveReservedARPScanName(context.Request, context.User.Username, configuration.ScanName)
 
-	engine, engineCreationError := arp_scanner.NewEngine(configuration.InterfaceName, configuration.Script)
+	engine, engineCreationError := arp_scanner.NewEngine(
+		configuration.InterfaceName,
+		configuration.Targets,
+		configuration.Exclude,
+		configuration.Script,
+		arp_scanner.Options{
+			Rate:    configuration.Rate,
+			Retries: configuration.Retries,
+			Timeout: time.Duration(configuration.Timeout) * time.Millisecond,
+			Method:  configuration.Method,
+			Ports:   ports,
+			Gateway: configuration.Gateway,
+		},
+	)
 	if engineCreationError != nil {
 		go mw.LogError(context.Request, engineCreationError)
 		writeError := connection.WriteJSON(
@@ -123,6 +200,25 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 	}
 	defer engine.Close()
 
+	task, registered := mw.RegisterTask(context.Request, tasks.ARPScan, context.User.Username, configuration.InterfaceName, configuration.ScanName, "")
+	if !registered {
+		writeError := connection.WriteJSON(
+			struct {
+				Succeed bool
+				Message string
+			}{
+				Succeed: false,
+				Message: "Something goes wrong",
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			return false
+		}
+		return false
+	}
+	defer mw.RemoveTask(context.Request, task)
+
 	writeError := connection.WriteJSON(struct {
 		Succeed bool
 		Message string
@@ -136,6 +232,7 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 		return false
 	}
 
+	// Only the goroutines of the engine send to its channels, since the engine closes them when the scan finishes
 	stopChannel := make(chan bool, 1)
 	go func() {
 		var action struct {
@@ -144,7 +241,6 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 		err := connection.ReadJSON(&action)
 		if err != nil {
 			go mw.LogError(context.Request, err)
-			engine.ErrorChannel <- err
 			stopChannel <- true
 			return
 		}
@@ -156,11 +252,121 @@ func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool
 
 	tick := time.Tick(500 * time.Millisecond)
 
-	hostsSet := map[string]struct{}{}
+	hostsSet := map[string]int{}
+
+	// Packets sent and received already added to the task
+	var countedPackets uint64
+
+	var hosts []objects.ScannedHost
 
-	var hosts []struct {
-		IP  string
-		MAC string
+	var resolver *arp_scanner.Resolver
+	if configuration.ResolveNames {
+		resolver = arp_scanner.NewResolver(time.Duration(configuration.Timeout) * time.Millisecond)
+	}
+
+	sendHost := func(stored *objects.ScannedHost) bool {
+		writeError = connection.WriteJSON(tools.ServerWSResponse{
+			Type:    symbols.HostResponse,
+			Payload: *stored,
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			return false
+		}
+		return true
+	}
+
+	// flushHosts sends to the client the hosts found since the last call, false means the connection is lost.
+	// Hosts seen again are only sent when they reveal a new MAC, hostname or evidence source
+	flushHosts := func() (bool, bool) {
+		packets := engine.Packets()
+		task.AddPackets(packets - countedPackets)
+		countedPackets = packets
+		for i := 0; i < 1000; i++ {
+			select {
+			case host, isOpen := <-engine.Hosts:
+				if !isOpen {
+					return true, false
+				}
+				var (
+					stored  *objects.ScannedHost
+					changed bool
+					isNew   bool
+				)
+				hosts, stored, changed, isNew = mergeHost(hosts, hostsSet, host)
+				if isNew && resolver != nil {
+					resolver.Resolve(host.IP)
+				}
+				if changed && !sendHost(stored) {
+					return false, false
+				}
+			default:
+				return true, true
+			}
+		}
+		return true, true
+	}
+
+	// attachName adds a resolved name to its host, the lookups are not evidence of the host so its sources are kept
+	attachName := func(name arp_scanner.Host) bool {
+		index, found := hostsSet[name.IP.String()]
+		if !found {
+			return true
+		}
+		var added bool
+		hosts[index].Hostnames, added = appendMissing(hosts[index].Hostnames, name.Hostname)
+		if !added {
+			return true
+		}
+		return sendHost(&hosts[index])
+	}
+
+	// flushNames sends the names resolved since the last call
+	flushNames := func() bool {
+		if resolver == nil {
+			return true
+		}
+		for {
+			select {
+			case name := <-resolver.Names:
+				if !attachName(name) {
+					return false
+				}
+			default:
+				return true
+			}
+		}
+	}
+
+	// waitNames waits for the lookups of the hosts found, once it returns no other host is resolved
+	waitNames := func() bool {
+		if resolver == nil {
+			return true
+		}
+		resolver.Finish()
+		for name := range resolver.Names {
+			if !attachName(name) {
+				return false
+			}
+		}
+		resolver = nil
+		return true
+	}
+
+	sendProgress := func() bool {
+		if configuration.Method == arp_scanner.PassiveMethod {
+			// Nothing is requested, the hosts are the only progress
+			return true
+		}
+		writeError = connection.WriteJSON(tools.ServerWSResponse{
+			Type:    symbols.ProgressResponse,
+			Payload: engine.Progress(),
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			return false
+		}
+		return true
 	}
 
 	engine.Start()
@@ -171,6 +377,18 @@ mainLoop:
 		select {
 		case <-stopChannel:
 			break mainLoop
+		case reason := <-task.Killed:
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+				return false
+			}
+			break mainLoop
 		case engineError, isOpen := <-engine.ErrorChannel:
 			if isOpen {
 				go mw.LogError(context.Request, engineError)
@@ -187,49 +405,42 @@ mainLoop:
 			} else {
 				break mainLoop
 			}
+		case <-engine.Done:
+			// Every answer is already queued, send the remaining hosts and the final counters
+			online, _ := flushHosts()
+			if !online || !waitNames() || !sendProgress() {
+				return false
+			}
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.FinishedResponse,
+					Payload: engine.Progress(),
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+				return false
+			}
+			break mainLoop
 		case <-tick:
-		scanLoop:
-			for i := 0; i < 1000; i++ {
-				select {
-				case host, isOpen := <-engine.Hosts:
-					if isOpen {
-						if _, found := hostsSet[host.IP.To4().String()]; !found {
-							hostsSet[host.IP.To4().String()] = struct{}{}
-							hosts = append(
-								hosts,
-								struct {
-									IP  string
-									MAC string
-								}{
-									IP:  host.IP.To4().String(),
-									MAC: host.MAC.String(),
-								},
-							)
-							writeError = connection.WriteJSON(tools.ServerWSResponse{
-								Type: symbols.HostResponse,
-								Payload: struct {
-									IP  string
-									MAC string
-								}{
-									IP:  host.IP.To4().String(),
-									MAC: host.MAC.String(),
-								},
-							})
-							if writeError != nil {
-								go mw.LogError(context.Request, writeError)
-								return false
-							}
-						}
-					} else {
-						break mainLoop
-					}
-				default:
-					break scanLoop
-				}
+			online, isOpen := flushHosts()
+			if !online {
+				return false
+			}
+			if !isOpen {
+				break mainLoop
+			}
+			if !flushNames() || !sendProgress() {
+				return false
 			}
 		}
 	}
 
+	// Hosts found before the scan was stopped are still resolved
+	if online, _ := flushHosts(); !online || !waitNames() {
+		return false
+	}
+
 	finish := time.Now()
 
 	// Send to the client that it is safe to close the connection
@@ -252,14 +463,18 @@ mainLoop:
 		configuration.ScanName,
 		configuration.InterfaceName,
 		configuration.Script,
+		configuration.Targets,
+		configuration.Exclude,
+		configuration.Method,
 		hosts,
 		start, finish,
+		configuration.Alerts,
 	)
 	return false
 }
 
 func renderController(mw *middleware.Middleware, context *middleware.Context) bool {
-	succeed, _, _, arpScanPermissions, _, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
+	succeed, _, _, arpScanPermissions, _, _, _, _, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
 	if getPermissionsError != nil {
 		go mw.LogError(context.Request, getPermissionsError)
 		context.Redirect = symbols.UserARP
@@ -301,8 +516,16 @@ func renderController(mw *middleware.Middleware, context *middleware.Context) bo
 				Name    string
 				Address string
 			}
+			MaximumRate    int
+			MaximumRetries int
+			MaximumTimeout int64
+			Methods        []string
 		}{
 			ARPScanInterfaces: targetInterfaces,
+			MaximumRate:       arp_scanner.MaximumRate,
+			MaximumRetries:    arp_scanner.MaximumRetries,
+			MaximumTimeout:    arp_scanner.MaximumTimeout.Milliseconds(),
+			Methods:           arp_scanner.Methods(),
 		},
 	)
 	if templateExecutionError != nil {
@@ -321,13 +544,8 @@ func viewScan(mw *middleware.Middleware, context *middleware.Context) bool {
 		return false
 	}
 
-	var hosts []struct {
-		IP  string
-		MAC string
-	}
-	unmarshalError := json.Unmarshal(scanSession.Hosts, &hosts)
-	if unmarshalError != nil {
-		go mw.LogError(context.Request, unmarshalError)
+	hosts, loaded := mw.ScanHosts(context.Request, scanSession)
+	if !loaded {
 		context.Redirect = symbols.UserARPScan
 		return false
 	}
@@ -340,14 +558,17 @@ func viewScan(mw *middleware.Middleware, context *middleware.Context) bool {
 			ScanName      string
 			InterfaceName string
 			Script        string
-			Hosts         []struct {
-				IP  string
-				MAC string
-			}
+			Targets       string
+			Exclude       string
+			Method        string
+			Hosts         []objects.ScannedHost
 		}{
 			ScanName:      html.EscapeString(scanSession.Name),
 			InterfaceName: html.EscapeString(scanSession.Interface),
 			Script:        html.EscapeString(string(scanSession.Script)),
+			Targets:       scanSession.Targets,
+			Exclude:       scanSession.Exclude,
+			Method:        scanSession.Method,
 			Hosts:         hosts,
 		},
 	)
@@ -384,6 +605,52 @@ func listScans(mw *middleware.Middleware, context *middleware.Context) bool {
 	return false
 }
 
+func compareScans(mw *middleware.Middleware, context *middleware.Context) bool {
+	oldName, newName := context.Request.PostFormValue(symbols.Old), context.Request.PostFormValue(symbols.New)
+	succeed, oldSession := mw.UserGetARPScan(context.Request, context.User.Username, oldName)
+	if !succeed {
+		context.Redirect = symbols.UserARPScan + "?action=" + actions.List
+		return false
+	}
+	succeed, newSession := mw.UserGetARPScan(context.Request, context.User.Username, newName)
+	if !succeed {
+		context.Redirect = symbols.UserARPScan + "?action=" + actions.List
+		return false
+	}
+	oldHosts, loaded := mw.ScanHosts(context.Request, oldSession)
+	if !loaded {
+		context.Redirect = symbols.UserARPScan + "?action=" + actions.List
+		return false
+	}
+	newHosts, loaded := mw.ScanHosts(context.Request, newSession)
+	if !loaded {
+		context.Redirect = symbols.UserARPScan + "?action=" + actions.List
+		return false
+	}
+
+	templateContents, _ := mw.Templates.ReadFile("templates/user/arp/compare.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("ARP scan compare").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Old  *objects.ARPScanSession
+			New  *objects.ARPScanSession
+			Diff *objects.ScanDiff
+		}{
+			Old:  oldSession,
+			New:  newSession,
+			Diff: objects.CompareScans(oldHosts, newHosts),
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Compare ARP scans", context.NavigationBar, body.String())
+	return false
+}
+
 func downloadScan(mw *middleware.Middleware, context *middleware.Context) bool {
 	scanName := context.Request.PostFormValue(symbols.ScanName)
 	succeed, scanSession := mw.UserGetARPScan(context.Request, context.User.Username, scanName)
@@ -391,8 +658,19 @@ func downloadScan(mw *middleware.Middleware, context *middleware.Context) bool {
 		context.Redirect = symbols.Dashboard
 		return false
 	}
+	hosts, loaded := mw.ScanHosts(context.Request, scanSession)
+	if !loaded {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	export, marshalError := json.Marshal(hosts)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
 	context.ResponseWriter.Header().Add("Content-Disposition", "attachment; filename=\"arp-scan.json\"")
-	_, writeError := context.ResponseWriter.Write(scanSession.Hosts)
+	_, writeError := context.ResponseWriter.Write(export)
 	if writeError != nil {
 		go mw.LogError(context.Request, writeError)
 		return false
@@ -411,6 +689,8 @@ func ARPScan(mw *middleware.Middleware, context *middleware.Context) bool {
 		return listScans(mw, context)
 	case actions.Download:
 		return downloadScan(mw, context)
+	case actions.Compare:
+		return compareScans(mw, context)
 	}
 	return renderController(mw, context)
 }
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
 	"github.com/shoriwe/CAPitan/internal/spoof"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/tools"
 	"github.com/shoriwe/CAPitan/internal/web/base"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
 	"html/template"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,21 +29,51 @@ var (
 	}
 )
 
+const (
+	stoppedByUser  = "Stopped by user"
+	connectionLost = "Connection lost"
+)
+
 type succeedResponse struct {
 	Succeed bool
 	Message string
 }
 
-func testArguments(mw *middleware.Middleware, context *middleware.Context, ip, gateway, arpInterface string) succeedResponse {
-	var responseObject succeedResponse
+type targetsResponse struct {
+	Succeed bool
+	Message string
+	Targets []string
+}
+
+type targetAction struct {
+	Action string
+	Target string
+}
+
+// withDefaults fills the poisoning settings the client left empty
+func withDefaults(mode string, interval uint) (string, uint) {
+	if len(mode) == 0 {
+		mode = spoof.ReplyMode
+	}
+	if interval == 0 {
+		interval = spoof.DefaultInterval
+	}
+	return mode, interval
+}
+
+func testArguments(mw *middleware.Middleware, context *middleware.Context, rawTargets, scanName, gateway, arpInterface, mode string, interval uint) (succeedResponse, []net.IP) {
+	var (
+		responseObject succeedResponse
+		targets        []net.IP
+	)
 
 	responseObject.Succeed = false
 
-	succeed, _, _, _, arpSpoofInterfaces, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	succeed, _, _, _, arpSpoofInterfaces, _, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
 	if getError != nil {
 		go mw.LogError(context.Request, getError)
 		responseObject.Message = "Something goes wrong"
-		return responseObject
+		return responseObject, nil
 	}
 
 	func() {
@@ -46,14 +82,24 @@ func testArguments(mw *middleware.Middleware, context *middleware.Context, ip, g
 			return
 		}
 
-		if net.ParseIP(ip) == nil {
-			responseObject.Message = "Invalid IP provided"
+		var message string
+		targets, message = mw.CollectSpoofTargets(context.Request, context.User.Username, rawTargets, scanName)
+		if targets == nil {
+			responseObject.Message = message
 			return
 		}
 		if net.ParseIP(gateway) == nil {
 			responseObject.Message = "Invalid Gateway IP provided"
 			return
 		}
+		if mode != spoof.ReplyMode && mode != spoof.RequestMode {
+			responseObject.Message = "Invalid poisoning mode provided"
+			return
+		}
+		if interval < spoof.MinimumInterval || interval > spoof.MaximumInterval {
+			responseObject.Message = "Interval must be between " + strconv.Itoa(spoof.MinimumInterval) + " and " + strconv.Itoa(spoof.MaximumInterval) + " milliseconds"
+			return
+		}
 
 		if _, found := arpSpoofInterfaces[arpInterface]; !found {
 			responseObject.Message = "No permissions for selected interface"
@@ -72,15 +118,26 @@ func testArguments(mw *middleware.Middleware, context *middleware.Context, ip, g
 		responseObject.Succeed = true
 	}()
 
-	return responseObject
+	return responseObject, targets
 }
 
 func testARPSpoofArguments(mw *middleware.Middleware, context *middleware.Context) bool {
 	arpInterface := context.Request.PostFormValue(symbols.Interface)
 	ip := context.Request.PostFormValue(symbols.IP)
 	gateway := context.Request.PostFormValue(symbols.Gateway)
+	var interval uint
+	if rawInterval := context.Request.PostFormValue(symbols.Interval); len(rawInterval) > 0 {
+		parsedInterval, parseError := strconv.ParseUint(rawInterval, 10, 32)
+		if parseError != nil {
+			context.Body = "{\"Succeed\": false, \"Message\": \"Invalid interval provided\"}"
+			return false
+		}
+		interval = uint(parsedInterval)
+	}
+	mode, interval := withDefaults(context.Request.PostFormValue(symbols.Mode), interval)
 
-	responseObject := testArguments(mw, context, ip, gateway, arpInterface)
+	scanName := context.Request.PostFormValue(symbols.ScanName)
+	responseObject, _ := testArguments(mw, context, ip, scanName, gateway, arpInterface, mode, interval)
 
 	response, marshalError := json.Marshal(responseObject)
 	if marshalError != nil {
@@ -108,8 +165,14 @@ func handleARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool
 	}()
 	var configuration struct {
 		TargetIP      string
+		ScanName      string
 		Gateway       string
 		InterfaceName string
+		Mode          string
+		OneWay        bool
+		Interval      uint
+		DNSRules      []spoof.DNSRule
+		DNSScript     string
 	}
 	readError := connection.ReadJSON(&configuration)
 	if readError != nil {
@@ -117,7 +180,18 @@ func handleARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool
 		go mw.LogError(context.Request, readError)
 		return false
 	}
-	response := testArguments(mw, context, configuration.TargetIP, configuration.Gateway, configuration.InterfaceName)
+	configuration.Mode, configuration.Interval = withDefaults(configuration.Mode, configuration.Interval)
+	response, targets := testArguments(mw, context, configuration.TargetIP, configuration.ScanName, configuration.Gateway, configuration.InterfaceName, configuration.Mode, configuration.Interval)
+	// DNS spoofing answers the queries of the victims from the forwarder, so the session relays their traffic itself
+	var dnsSpoofer *spoof.DNSSpoofer
+	if response.Succeed && (len(configuration.DNSRules) > 0 || len(configuration.DNSScript) > 0) {
+		var dnsError error
+		dnsSpoofer, dnsError = spoof.NewDNSSpoofer(configuration.DNSRules, configuration.DNSScript)
+		if dnsError != nil {
+			response.Succeed = false
+			response.Message = "Invalid DNS spoofing configuration: " + dnsError.Error()
+		}
+	}
 	writeError := connection.WriteJSON(response)
 	if writeError != nil {
 		go mw.LogARPSpoofStarted(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway, false)
@@ -128,10 +202,19 @@ func handleARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool
 		return false
 	}
 
-	engine, newEngineError := spoof.NewEngine(configuration.TargetIP, configuration.Gateway, configuration.InterfaceName)
+	engine, unresolved, newEngineError := spoof.NewEngine(targets, configuration.Gateway, configuration.InterfaceName, configuration.Mode, configuration.OneWay)
 	if newEngineError != nil {
 		go mw.LogARPSpoofStarted(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway, false)
 		go mw.LogError(context.Request, newEngineError)
+		writeError = connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type:    symbols.ErrorResponse,
+				Payload: newEngineError.Error(),
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
 		return false
 	}
 	defer func() {
@@ -140,25 +223,166 @@ func handleARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool
 			go mw.LogError(context.Request, closeError)
 		}
 	}()
-
-	stopChannel := make(chan bool, 1)
-	go func() {
-		var action struct {
-			Action string
+	var (
+		start          time.Time
+		stopReason     string
+		spoofedAnswers []*objects.DNSSpoofedAnswer
+		poisoned       = map[string]struct{}{}
+	)
+	// recordAnswers keeps the answers forged since the last call and sends them to the client, the errors of the
+	// resolver script are only logged since their queries were forwarded
+	recordAnswers := func() {
+		if dnsSpoofer == nil {
+			return
+		}
+		answers, resolverErrors := dnsSpoofer.TakeAnswers()
+		for _, resolverError := range resolverErrors {
+			go mw.LogDNSResolverFailed(context.Request, context.User.Username, resolverError)
 		}
-		err := connection.ReadJSON(&action)
-		if err != nil {
-			go mw.LogError(context.Request, err)
-			stopChannel <- true
+		for _, answer := range answers {
+			answer := answer
+			spoofedAnswers = append(spoofedAnswers, &answer)
+			go mw.LogDNSAnswerSpoofed(context.Request, context.User.Username, answer.Client, answer.Name, answer.Address)
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.DNSAnswerResponse,
+					Payload: answer,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
+		}
+	}
+	// The session is stored once the caches are restored and the forwarder stopped, with the answers of its last burst
+	defer func() {
+		if start.IsZero() {
+			// The spoof never started
 			return
 		}
-		switch action.Action {
-		case "STOP":
-			stopChannel <- true
+		engine.StopForwarding()
+		recordAnswers()
+		var poisonedTargets []string
+		for target := range poisoned {
+			poisonedTargets = append(poisonedTargets, target)
+		}
+		sort.Strings(poisonedTargets)
+		mw.SaveARPSpoof(context.Request, context.User.Username, configuration.InterfaceName, strings.Join(poisonedTargets, ", "), configuration.Gateway, "", engine.PacketsSent(), stopReason, spoofedAnswers, start, time.Now())
+	}()
+	// Whatever the way the spoof finishes, the caches of the victims are restored before closing the engine
+	defer func() {
+		restoreResult := succeedResponse{
+			Succeed: true,
+			Message: "ARP caches of " + strconv.Itoa(len(engine.Targets())) + " targets and " + configuration.Gateway + " restored",
+		}
+		restoreError := engine.Restore()
+		go mw.LogARPSpoofRestored(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway, restoreError == nil)
+		if restoreError != nil {
+			go mw.LogError(context.Request, restoreError)
+			restoreResult.Succeed = false
+			restoreResult.Message = "Failed to restore ARP caches: " + restoreError.Error()
+		}
+		restoreWriteError := connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type:    symbols.RestoreResponse,
+				Payload: restoreResult,
+			},
+		)
+		if restoreWriteError != nil {
+			go mw.LogError(context.Request, restoreWriteError)
+		}
+	}()
+
+	var (
+		dnsAnswered   chan struct{}
+		forwardErrors chan error
+	)
+	if dnsSpoofer != nil {
+		engine.SetDNSSpoofer(dnsSpoofer)
+		forwardError := engine.StartForwarding()
+		if forwardError != nil {
+			go mw.LogError(context.Request, forwardError)
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.ErrorResponse,
+					Payload: forwardError.Error(),
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
+			return false
+		}
+		dnsAnswered = dnsSpoofer.Answered
+		forwardErrors = engine.ForwardErrors
+	}
+
+	// Spoofs have no name of their own, they are named after the scan they take the targets from or their targets
+	sessionName := configuration.ScanName
+	if len(sessionName) == 0 {
+		sessionName = configuration.TargetIP
+	}
+	task, registered := mw.RegisterTask(context.Request, tasks.ARPSpoof, context.User.Username, configuration.InterfaceName, sessionName, strings.Join(engine.Targets(), ", ")+" <-> "+configuration.Gateway)
+	if !registered {
+		go mw.LogARPSpoofStarted(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway, false)
+		return false
+	}
+	defer mw.RemoveTask(context.Request, task)
+
+	initialTargets := targetsResponse{
+		Succeed: true,
+		Message: "Poisoning " + strconv.Itoa(len(engine.Targets())) + " targets",
+		Targets: engine.Targets(),
+	}
+	if len(unresolved) > 0 {
+		var skipped []string
+		for _, ip := range unresolved {
+			skipped = append(skipped, ip.String())
+		}
+		initialTargets.Message += ", no answer from " + strings.Join(skipped, ", ")
+	}
+	writeError = connection.WriteJSON(
+		tools.ServerWSResponse{
+			Type:    symbols.TargetsResponse,
+			Payload: initialTargets,
+		},
+	)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	for _, target := range engine.Targets() {
+		poisoned[target] = struct{}{}
+	}
+
+	stopChannel := make(chan string, 1)
+	actionsChannel := make(chan targetAction, 16)
+	go func() {
+		for {
+			var action targetAction
+			err := connection.ReadJSON(&action)
+			if err != nil {
+				go mw.LogError(context.Request, err)
+				stopChannel <- connectionLost
+				return
+			}
+			switch action.Action {
+			case symbols.StopSignal:
+				stopChannel <- stoppedByUser
+				return
+			case symbols.AddTargetSignal, symbols.RemoveTargetSignal:
+				select {
+				case actionsChannel <- action:
+				default:
+				}
+			}
 		}
 	}()
 
-	tick := time.Tick(time.Second)
+	tick := time.Tick(time.Duration(configuration.Interval) * time.Millisecond)
+
+	var lastPacketsSent uint64
+	start = time.Now()
 
 	go mw.LogARPSpoofStarted(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway, true)
 	defer mw.LogARPSpoofStopped(context.Request, context.User.Username, configuration.TargetIP, configuration.Gateway)
@@ -169,14 +393,98 @@ func handleARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool
 			poisonError := engine.Poison()
 			if poisonError != nil {
 				go mw.LogError(context.Request, poisonError)
+				stopReason = poisonError.Error()
 				return false
 			}
-		case <-stopChannel:
+			task.AddPackets(engine.PacketsSent() - lastPacketsSent)
+			lastPacketsSent = engine.PacketsSent()
+		case stopReason = <-stopChannel:
+			return false
+		case <-dnsAnswered:
+			recordAnswers()
+		case forwardError := <-forwardErrors:
+			go mw.LogError(context.Request, forwardError)
+			stopReason = forwardError.Error()
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.ErrorResponse,
+					Payload: forwardError.Error(),
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
+			return false
+		case action := <-actionsChannel:
+			var actionError error
+			target := net.ParseIP(action.Target).String()
+			if action.Action == symbols.AddTargetSignal {
+				actionError = engine.AddTarget(action.Target)
+				if actionError == nil {
+					poisoned[target] = struct{}{}
+				}
+			} else {
+				actionError = engine.RemoveTarget(target)
+			}
+			go mw.LogARPSpoofTargetUpdate(context.Request, context.User.Username, action.Action, action.Target, actionError == nil)
+			update := targetsResponse{
+				Succeed: actionError == nil,
+				Message: "Targets updated",
+				Targets: engine.Targets(),
+			}
+			if actionError != nil {
+				update.Message = actionError.Error()
+			}
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.TargetsResponse,
+					Payload: update,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
+		case reason := <-task.Killed:
+			stopReason = "Killed by an administrator: " + reason
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
 			return false
 		}
 	}
 }
 
+func listSpoofs(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, userARPSpoofs := mw.ListUserARPSpoofs(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/arp/spoof-list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("ARP spoof list").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Spoofs []*objects.ARPSpoofSession
+		}{
+			Spoofs: userARPSpoofs,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("ARP spoofs", context.NavigationBar, body.String())
+	return false
+}
+
 func ARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool {
 	action := context.Request.FormValue(actions.Action)
 	switch action {
@@ -184,8 +492,10 @@ func ARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool {
 		return testARPSpoofArguments(mw, context)
 	case actions.Spoof:
 		return handleARPSpoof(mw, context)
+	case actions.List:
+		return listSpoofs(mw, context)
 	}
-	succeed, _, _, _, arpSpoofPermissions, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
+	succeed, _, _, _, arpSpoofPermissions, _, _, _, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
 	if getPermissionsError != nil {
 		go mw.LogError(context.Request, getPermissionsError)
 		context.Redirect = symbols.UserARP
@@ -216,6 +526,8 @@ func ARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool {
 			}
 		}
 	}
+	// Saved scans can seed the list of targets
+	_, scans := mw.ListUserARPScans(context.Request, context.User.Username)
 	rawMenu, _ := mw.Templates.ReadFile("templates/user/arp/spoof.html")
 	var menu bytes.Buffer
 	_ = template.Must(template.New("ARP spoof").Parse(string(rawMenu))).Execute(&menu,
@@ -224,8 +536,10 @@ func ARPSpoof(mw *middleware.Middleware, context *middleware.Context) bool {
 				Name    string
 				Address string
 			}
+			Scans []*objects.ARPScanSession
 		}{
 			ARPSpoofInterfaces: targetInterfaces,
+			Scans:              scans,
 		},
 	)
 	context.Body = base.NewPage("ARP Spoof", context.NavigationBar, menu.String())
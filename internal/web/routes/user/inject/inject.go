@@ -0,0 +1,333 @@
+package inject
+
+import (
+	"bytes"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/inject"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
+	"strconv"
+	"time"
+)
+
+var (
+	upgradeInjectSession = websocket.Upgrader{
+		ReadBufferSize:    0, /* No Limit */
+		WriteBufferSize:   0, /* No Limit */
+		EnableCompression: true,
+		Subprotocols:      []string{"InjectSession"},
+	}
+)
+
+const (
+	scriptFinished = "Script finished"
+	stoppedByUser  = "Stopped by user"
+	connectionLost = "Connection lost"
+)
+
+type succeedResponse struct {
+	Succeed bool
+	Message string
+}
+
+type injectConfiguration struct {
+	InterfaceName string
+	Script        string
+	Rate          uint
+	MaxFrames     uint
+}
+
+// checkInjectArguments validates the session settings, on failure the message to show the user is returned
+func checkInjectArguments(mw *middleware.Middleware, context *middleware.Context, configuration *injectConfiguration) string {
+	if configuration.Rate == 0 {
+		configuration.Rate = inject.DefaultRate
+	}
+	if configuration.MaxFrames == 0 {
+		configuration.MaxFrames = inject.DefaultMaxFrames
+	}
+	if len(configuration.Script) == 0 || tools.CheckFilledWithWhiteSpace.MatchString(configuration.Script) {
+		return "No injection script provided"
+	}
+	if configuration.Rate > inject.MaximumRate {
+		return "Rate must be between 1 and " + strconv.Itoa(inject.MaximumRate) + " frames per second"
+	}
+	if configuration.MaxFrames > inject.MaximumFrames {
+		return "Frame limit must be between 1 and " + strconv.Itoa(inject.MaximumFrames)
+	}
+	succeed, _, _, _, _, injectInterfaces, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		return "Something goes wrong"
+	}
+	if !succeed {
+		return "Failed to query user interfaces"
+	}
+	if _, found := injectInterfaces[configuration.InterfaceName]; !found {
+		return "No inject permissions for selected interface"
+	}
+	connectedInterfaces := mw.ListNetInterfaces(context.Request)
+	if connectedInterfaces == nil {
+		return "Failed to list connected interfaces"
+	}
+	if _, found := connectedInterfaces[configuration.InterfaceName]; !found {
+		return "User has permissions but the interface is not connected to the machine"
+	}
+	return ""
+}
+
+func handleInjection(mw *middleware.Middleware, context *middleware.Context) bool {
+	connection, upgradeError := upgradeInjectSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
+	if upgradeError != nil {
+		go mw.LogError(context.Request, upgradeError)
+		context.Redirect = symbols.UserInject
+		return false
+	}
+	context.WriteBody = false
+	defer func() {
+		closeError := connection.Close()
+		if closeError != nil {
+			go mw.LogError(context.Request, closeError)
+		}
+	}()
+
+	var configuration injectConfiguration
+	readError := connection.ReadJSON(&configuration)
+	if readError != nil {
+		go mw.LogError(context.Request, readError)
+		return false
+	}
+	response := succeedResponse{
+		Succeed: true,
+		Message: "Everything ok!",
+	}
+	var engine *inject.Engine
+	if message := checkInjectArguments(mw, context, &configuration); len(message) > 0 {
+		response.Succeed = false
+		response.Message = message
+	} else {
+		var newEngineError error
+		engine, newEngineError = inject.NewEngine(configuration.InterfaceName, configuration.Rate, configuration.MaxFrames)
+		if newEngineError != nil {
+			go mw.LogError(context.Request, newEngineError)
+			response.Succeed = false
+			response.Message = newEngineError.Error()
+		}
+	}
+	if !response.Succeed {
+		go mw.LogInjectionStarted(context.Request, context.User.Username, configuration.InterfaceName, false)
+		writeError := connection.WriteJSON(response)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer engine.Close()
+
+	task, registered := mw.RegisterTask(context.Request, tasks.Inject, context.User.Username, configuration.InterfaceName, "", "")
+	if !registered {
+		go mw.LogInjectionStarted(context.Request, context.User.Username, configuration.InterfaceName, false)
+		writeError := connection.WriteJSON(
+			succeedResponse{
+				Succeed: false,
+				Message: "Something goes wrong",
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer mw.RemoveTask(context.Request, task)
+
+	writeError := connection.WriteJSON(response)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	go mw.LogInjectionStarted(context.Request, context.User.Username, configuration.InterfaceName, true)
+
+	stopChannel := make(chan string, 1)
+	go func() {
+		var action struct {
+			Action string
+		}
+		err := connection.ReadJSON(&action)
+		if err != nil {
+			go mw.LogError(context.Request, err)
+			stopChannel <- connectionLost
+			return
+		}
+		if action.Action == symbols.StopSignal {
+			stopChannel <- stoppedByUser
+		}
+	}()
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- engine.Run(configuration.Script)
+	}()
+
+	start := time.Now()
+	var (
+		frames     []*objects.InjectedFrame
+		stopReason string
+		online     = true
+	)
+	// Every frame sent ends in the audit record of the session, even when the client is gone
+	record := func(frame objects.InjectedFrame) {
+		frames = append(frames, &frame)
+		task.AddPackets(1)
+		go mw.LogFrameInjected(context.Request, context.User.Username, configuration.InterfaceName, frame.Summary)
+		if !online {
+			return
+		}
+		writeError = connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type:    symbols.InjectedFrameResponse,
+				Payload: frame,
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			online = false
+		}
+	}
+	var runError error
+mainLoop:
+	for {
+		select {
+		case frame := <-engine.Frames:
+			record(frame)
+		case stopReason = <-stopChannel:
+			online = stopReason != connectionLost
+			engine.Stop()
+		case reason := <-task.Killed:
+			stopReason = reason
+			engine.Stop()
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+				online = false
+			}
+		case runError = <-finished:
+			break mainLoop
+		}
+	}
+	for len(engine.Frames) > 0 {
+		record(<-engine.Frames)
+	}
+	finish := time.Now()
+
+	result := succeedResponse{
+		Succeed: true,
+		Message: scriptFinished,
+	}
+	if len(stopReason) > 0 {
+		result.Message = stopReason
+	} else if runError != nil {
+		stopReason = runError.Error()
+		result.Succeed = false
+		result.Message = stopReason
+	} else {
+		stopReason = scriptFinished
+	}
+	go mw.LogInjectionStopped(context.Request, context.User.Username, configuration.InterfaceName, engine.FramesSent())
+	mw.SaveInjection(context.Request, context.User.Username, configuration.InterfaceName, configuration.Script, engine.FramesSent(), stopReason, frames, start, finish)
+	if online {
+		writeError = connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type:    symbols.FinishedResponse,
+				Payload: result,
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+	}
+	return false
+}
+
+func listInjections(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, injections := mw.ListUserInjections(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/inject/inject-list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Injection list").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Injections []*objects.InjectionSession
+		}{
+			Injections: injections,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Injections", context.NavigationBar, body.String())
+	return false
+}
+
+func Inject(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.FormValue(actions.Action) {
+	case actions.Start:
+		return handleInjection(mw, context)
+	case actions.List:
+		return listInjections(mw, context)
+	}
+	succeed, _, _, _, _, injectPermissions, _, _, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getPermissionsError != nil {
+		go mw.LogError(context.Request, getPermissionsError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	connectedInterface := mw.ListNetInterfaces(context.Request)
+	var targetInterfaces []objects.InterfaceInformation
+	for _, permission := range injectPermissions {
+		i, found := connectedInterface[permission.Interface]
+		if found {
+			for _, address := range i.Addresses {
+				targetInterfaces = append(targetInterfaces,
+					objects.InterfaceInformation{
+						Name:    i.Name,
+						Address: address.IP.String(),
+					},
+				)
+			}
+		}
+	}
+	rawMenu, _ := mw.Templates.ReadFile("templates/user/inject/inject.html")
+	var menu bytes.Buffer
+	_ = template.Must(template.New("Inject").Parse(string(rawMenu))).Execute(&menu,
+		struct {
+			InjectInterfaces []objects.InterfaceInformation
+			MaximumRate      int
+			MaximumFrames    int
+		}{
+			InjectInterfaces: targetInterfaces,
+			MaximumRate:      inject.MaximumRate,
+			MaximumFrames:    inject.MaximumFrames,
+		},
+	)
+	context.Body = base.NewPage("Inject", context.NavigationBar, menu.String())
+	return false
+}
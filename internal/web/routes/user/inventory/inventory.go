@@ -0,0 +1,78 @@
+package inventory
+
+import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
+	"strconv"
+)
+
+func viewHost(mw *middleware.Middleware, context *middleware.Context) bool {
+	hostId, parseError := strconv.ParseUint(context.Request.PostFormValue(symbols.HostId), 10, 64)
+	if parseError != nil {
+		context.Redirect = symbols.UserInventory
+		return false
+	}
+	succeed, host := mw.UserGetInventoryHost(context.Request, context.User.Username, uint(hostId))
+	if !succeed {
+		context.Redirect = symbols.UserInventory
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/inventory/view-host.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Inventory host").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Host *objects.InventoryHost
+		}{
+			Host: host,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Inventory host", context.NavigationBar, body.String())
+	return false
+}
+
+func listHosts(mw *middleware.Middleware, context *middleware.Context) bool {
+	search := context.Request.FormValue(symbols.Search)
+	succeed, hosts := mw.ListUserInventory(context.Request, context.User.Username, search)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/inventory/list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Inventory").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Search string
+			Hosts  []*objects.InventoryHost
+		}{
+			Search: search,
+			Hosts:  hosts,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Inventory", context.NavigationBar, body.String())
+	return false
+}
+
+func Inventory(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.FormValue(actions.Action) {
+	case actions.View:
+		return viewHost(mw, context)
+	}
+	return listHosts(mw, context)
+}
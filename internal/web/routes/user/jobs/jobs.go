@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"bytes"
+	arp_scanner "github.com/shoriwe/CAPitan/internal/arp-scanner"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type jobEntry struct {
+	Job     *objects.Job
+	NextRun time.Time
+	Running bool
+}
+
+// parseUint reads an optional number of the form, empty means zero
+func parseUint(context *middleware.Context, name string) (uint, bool) {
+	value := strings.TrimSpace(context.Request.PostFormValue(name))
+	if len(value) == 0 {
+		return 0, true
+	}
+	number, parseError := strconv.ParseUint(value, 10, 32)
+	return uint(number), parseError == nil
+}
+
+// parseJob validates the job of the form against the permissions of the user
+func parseJob(mw *middleware.Middleware, context *middleware.Context) (*objects.Job, string) {
+	job := &objects.Job{
+		Name:        strings.TrimSpace(context.Request.PostFormValue(symbols.JobName)),
+		Kind:        context.Request.PostFormValue(symbols.Kind),
+		Interface:   context.Request.PostFormValue(symbols.Interface),
+		Schedule:    strings.TrimSpace(context.Request.PostFormValue(symbols.Schedule)),
+		Script:      context.Request.PostFormValue(symbols.Script),
+		Description: strings.TrimSpace(context.Request.PostFormValue(symbols.Description)),
+		Promiscuous: len(context.Request.PostFormValue(symbols.Promiscuous)) > 0,
+		Targets:     context.Request.PostFormValue(symbols.Targets),
+		Exclude:     context.Request.PostFormValue(symbols.Exclude),
+		Method:      context.Request.PostFormValue(symbols.Method),
+		Alerts:      len(context.Request.PostFormValue(symbols.Alerts)) > 0,
+		Created:     time.Now(),
+	}
+	if len(job.Name) == 0 {
+		return nil, "no job name provided"
+	}
+	var validNumber bool
+	if job.Interval, validNumber = parseUint(context, symbols.Interval); !validNumber {
+		return nil, "the interval must be a number of seconds"
+	}
+	if job.Duration, validNumber = parseUint(context, symbols.Duration); !validNumber {
+		return nil, "the duration must be a number of seconds"
+	}
+	if job.MaxPackets, validNumber = parseUint(context, symbols.MaxPackets); !validNumber {
+		return nil, "the packets limit must be a number"
+	}
+	if len(job.Schedule) > 0 {
+		_, parseError := scheduler.ParseSchedule(job.Schedule)
+		if parseError != nil {
+			return nil, parseError.Error()
+		}
+		job.Interval = 0
+	} else if job.Interval == 0 {
+		return nil, "a schedule or an interval is needed"
+	}
+	if len(job.Script) > 0 && tools.CheckFilledWithWhiteSpace.MatchString(job.Script) {
+		job.Script = ""
+	}
+	succeed, _, captureInterfaces, arpScanInterfaces, _, _, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		return nil, "Something goes wrong"
+	}
+	if !succeed {
+		return nil, "Something goes wrong"
+	}
+	switch job.Kind {
+	case objects.ARPScanJob:
+		if _, found := arpScanInterfaces[job.Interface]; !found {
+			return nil, "no ARP scan permission on the interface"
+		}
+		if job.Method == arp_scanner.PassiveMethod && job.Duration == 0 {
+			return nil, "passive scans need a duration"
+		}
+		job.Promiscuous, job.MaxPackets, job.Description = false, 0, ""
+	case objects.CaptureJob:
+		if _, found := captureInterfaces[job.Interface]; !found {
+			return nil, "no capture permission on the interface"
+		}
+		if job.Duration == 0 && job.MaxPackets == 0 {
+			return nil, "captures need a duration or a packets limit"
+		}
+		if len(job.Description) == 0 {
+			job.Description = "Scheduled by the job " + job.Name
+		}
+		job.Targets, job.Exclude, job.Method, job.Alerts = "", "", "", false
+	default:
+		return nil, "unknown job kind"
+	}
+	return job, ""
+}
+
+func newJob(mw *middleware.Middleware, context *middleware.Context) bool {
+	if context.Request.Method != http.MethodPost {
+		context.Redirect = symbols.UserJobs
+		return false
+	}
+	job, errorMessage := parseJob(mw, context)
+	if job == nil {
+		return listJobs(mw, context, errorMessage)
+	}
+	if !mw.UserSaveJob(context.Request, context.User.Username, job) {
+		return listJobs(mw, context, "Job name already taken")
+	}
+	context.Redirect = symbols.UserJobs
+	return false
+}
+
+func deleteJob(mw *middleware.Middleware, context *middleware.Context) bool {
+	context.Redirect = symbols.UserJobs
+	jobId, parseError := strconv.ParseUint(context.Request.PostFormValue(symbols.JobId), 10, 64)
+	if parseError != nil {
+		return false
+	}
+	mw.UserDeleteJob(context.Request, context.User.Username, uint(jobId))
+	return false
+}
+
+func viewJob(mw *middleware.Middleware, context *middleware.Context) bool {
+	jobId, parseError := strconv.ParseUint(context.Request.PostFormValue(symbols.JobId), 10, 64)
+	if parseError != nil {
+		context.Redirect = symbols.UserJobs
+		return false
+	}
+	succeed, job := mw.UserGetJob(context.Request, context.User.Username, uint(jobId))
+	if !succeed {
+		context.Redirect = symbols.UserJobs
+		return false
+	}
+	succeed, runs := mw.ListJobRuns(context.Request, context.User.Username, job.Id)
+	if !succeed {
+		context.Redirect = symbols.UserJobs
+		return false
+	}
+	entry := jobEntry{Job: job}
+	if mw.Scheduler != nil {
+		entry.NextRun, _ = mw.Scheduler.NextRun(job.Id)
+		entry.Running = mw.Scheduler.Running(job.Id)
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/jobs/view-job.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Job").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Entry jobEntry
+			Runs  []*objects.JobRun
+		}{
+			Entry: entry,
+			Runs:  runs,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Job", context.NavigationBar, body.String())
+	return false
+}
+
+func listJobs(mw *middleware.Middleware, context *middleware.Context, errorMessage string) bool {
+	succeed, jobs := mw.ListUserJobs(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	succeed, _, captureInterfaces, arpScanInterfaces, _, _, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	// Interfaces the user can scan or capture on, the job kind decides which permission is needed
+	interfacesSet := map[string]struct{}{}
+	for interfaceName := range captureInterfaces {
+		interfacesSet[interfaceName] = struct{}{}
+	}
+	for interfaceName := range arpScanInterfaces {
+		interfacesSet[interfaceName] = struct{}{}
+	}
+	var interfaces []string
+	for interfaceName := range interfacesSet {
+		interfaces = append(interfaces, interfaceName)
+	}
+	sort.Strings(interfaces)
+	var entries []jobEntry
+	for _, job := range jobs {
+		entry := jobEntry{Job: job}
+		if mw.Scheduler != nil {
+			entry.NextRun, _ = mw.Scheduler.NextRun(job.Id)
+			entry.Running = mw.Scheduler.Running(job.Id)
+		}
+		entries = append(entries, entry)
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/jobs/list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Jobs").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Message    string
+			Jobs       []jobEntry
+			Interfaces []string
+			Methods    []string
+		}{
+			Message:    errorMessage,
+			Jobs:       entries,
+			Interfaces: interfaces,
+			Methods:    arp_scanner.Methods(),
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Jobs", context.NavigationBar, body.String())
+	return false
+}
+
+func Jobs(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.FormValue(actions.Action) {
+	case actions.New:
+		return newJob(mw, context)
+	case actions.View:
+		return viewJob(mw, context)
+	case actions.Delete:
+		return deleteJob(mw, context)
+	}
+	return listJobs(mw, context, "")
+}
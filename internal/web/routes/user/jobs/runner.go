@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/arp/scan"
+	"github.com/shoriwe/CAPitan/internal/web/routes/user/packet"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"net/http"
+	"time"
+)
+
+// schedulerRequest is the request the jobs are logged with, they run without a client
+func schedulerRequest() *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, symbols.UserJobs, nil)
+	request.RemoteAddr = "scheduler"
+	return request
+}
+
+// sessionName names the session stored by a run after the time it started
+func sessionName(job *objects.Job, started time.Time) string {
+	return job.Name + " " + started.Format("2006-01-02 15:04:05")
+}
+
+// checkPermission confirms the owner of the job is still enabled and allowed to use its interface
+func checkPermission(mw *middleware.Middleware, username string, job *objects.Job) error {
+	succeed, user, captureInterfaces, arpScanInterfaces, _, _, _, _, getError := mw.GetUserInterfacePermissions(username)
+	if getError != nil {
+		return getError
+	}
+	if !succeed || user == nil || !user.IsEnabled {
+		return errors.New("the user is disabled")
+	}
+	var permitted bool
+	switch job.Kind {
+	case objects.ARPScanJob:
+		_, permitted = arpScanInterfaces[job.Interface]
+	case objects.CaptureJob:
+		_, permitted = captureInterfaces[job.Interface]
+	}
+	if !permitted {
+		return fmt.Errorf("the user has no %s permission on %s", job.Kind, job.Interface)
+	}
+	return nil
+}
+
+// execute runs the session of the job under its name, registered as a task so administrators can kill it
+func execute(mw *middleware.Middleware, request *http.Request, username string, job *objects.Job, name string) (uint64, string, error) {
+	permissionError := checkPermission(mw, username, job)
+	if permissionError != nil {
+		return 0, "", permissionError
+	}
+	switch job.Kind {
+	case objects.ARPScanJob:
+		if !mw.ReserveUserARPScanName(request, username, name) {
+			return 0, "", errors.New("scan name already taken")
+		}
+		defer mw.RemoveReservedARPScanName(request, username, name)
+		task, registered := mw.RegisterTask(request, tasks.ARPScan, username, job.Interface, name, job.Targets)
+		if !registered {
+			return 0, "", errors.New("failed to register the task")
+		}
+		defer mw.RemoveTask(request, task)
+		return scan.RunScanJob(mw, request, username, job, name, task)
+	case objects.CaptureJob:
+		if !mw.ReserveUserCaptureName(request, username, name) {
+			return 0, "", errors.New("capture name already taken")
+		}
+		defer mw.RemoveReservedCaptureName(request, username, name)
+		task, registered := mw.RegisterTask(request, tasks.Capture, username, job.Interface, name, "")
+		if !registered {
+			return 0, "", errors.New("failed to register the task")
+		}
+		defer mw.RemoveTask(request, task)
+		return packet.RunCaptureJob(mw, request, username, job, name, task)
+	}
+	return 0, "", fmt.Errorf("unknown job kind %s", job.Kind)
+}
+
+// runJob runs the job and stores the result in its history
+func runJob(mw *middleware.Middleware, view *objects.JobAdminView) {
+	if view.User == nil {
+		return
+	}
+	request := schedulerRequest()
+	run := &objects.JobRun{
+		JobId:   view.Job.Id,
+		Started: time.Now(),
+	}
+	run.Session = sessionName(view.Job, run.Started)
+	var runError error
+	run.Packets, run.Message, runError = execute(mw, request, view.User.Username, view.Job, run.Session)
+	run.Finished = time.Now()
+	run.Succeed = runError == nil
+	if runError != nil {
+		go mw.LogError(request, runError)
+		run.Message = runError.Error()
+	}
+	go mw.LogJobFinished(request, view.User.Username, view.Job.Name, run.Session, run.Message, run.Succeed)
+	mw.SaveJobRun(request, view.User.Username, run)
+}
+
+// NewScheduler returns the scheduler of the jobs of every user
+func NewScheduler(mw *middleware.Middleware) *scheduler.Scheduler {
+	request := schedulerRequest()
+	return scheduler.NewScheduler(
+		func() []*objects.JobAdminView {
+			return mw.ScheduledJobs(request)
+		},
+		func(view *objects.JobAdminView) {
+			runJob(mw, view)
+		},
+	)
+}
@@ -62,10 +62,20 @@ func Captures(mw *middleware.Middleware, context *middleware.Context) bool {
 		return importCapture(mw, context)
 	case actions.Start:
 		return startInterfaceBasedCapture(mw, context)
+	case actions.Attach:
+		return attachCapture(mw, context)
+	case actions.Stop:
+		return stopRunningCapture(mw, context)
 	case actions.View:
 		return viewCapture(mw, context)
 	case actions.Download:
 		return downloadCapture(mw, context)
+	case actions.DownloadHosts:
+		return downloadCaptureHosts(mw, context)
+	case actions.Replay:
+		return replayMenu(mw, context)
+	case actions.StartReplay:
+		return handleReplay(mw, context)
 	}
 	return listCaptures(mw, context)
 }
@@ -0,0 +1,166 @@
+package packet
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"github.com/google/gopacket"
+	"github.com/shoriwe/CAPitan/internal/capture"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+)
+
+// collector keeps the packets, streams and graphs of a capture as the engine delivers them. Live captures publish
+// every packet and stream and the graphs that changed, the key of the graph updates is their target and the one of
+// the packets and streams is empty
+type collector struct {
+	engine          *capture.Engine
+	publish         func(key string, response tools.ServerWSResponse)
+	topology        *objects.Topology
+	hostPacketCount *objects.Counter
+	layer4Count     *objects.Counter
+	streamTypeCount *objects.Counter
+	packets         []gopacket.Packet
+	streams         []capture.Data
+	hashedStreams   map[[16]byte]struct{}
+	// Bytes that will be stored in the database besides the pcap
+	storedBytes uint64
+	// Graphs changed since the last flush
+	updatedTopology        bool
+	updatedHostPacketCount bool
+	updatedLayer4Count     bool
+	updatedStreamTypeCount bool
+}
+
+// newCollector prepares the collector of the engine, publish can be nil when nobody watches the capture
+func newCollector(engine *capture.Engine, topology *objects.Topology, publish func(key string, response tools.ServerWSResponse)) *collector {
+	if publish == nil {
+		publish = func(string, tools.ServerWSResponse) {}
+	}
+	return &collector{
+		engine:          engine,
+		publish:         publish,
+		topology:        topology,
+		hostPacketCount: objects.NewCounter(),
+		layer4Count:     objects.NewCounter(),
+		streamTypeCount: objects.NewCounter(),
+		hashedStreams:   map[[16]byte]struct{}{},
+	}
+}
+
+func (collector *collector) addPacket(packet gopacket.Packet) error {
+	encodedPacket, marshalError := json.Marshal(capture.TransformPacketToMap(packet))
+	if marshalError != nil {
+		return marshalError
+	}
+	collector.storedBytes += uint64(len(encodedPacket))
+	collector.publish("",
+		tools.ServerWSResponse{
+			Type:    symbols.PacketResponse,
+			Payload: json.RawMessage(encodedPacket),
+		},
+	)
+	flow := packet.NetworkLayer().NetworkFlow()
+	if collector.topology.AddEdge(flow.Src().String(), flow.Dst().String()) {
+		collector.updatedTopology = true
+	}
+	if labelVendors(collector.topology, packet) {
+		collector.updatedTopology = true
+	}
+	collector.hostPacketCount.Count(flow.Src().String())
+	collector.layer4Count.Count(packet.TransportLayer().LayerType().String())
+	collector.updatedHostPacketCount = true
+	collector.updatedLayer4Count = true
+	collector.packets = append(collector.packets, packet)
+	return nil
+}
+
+// addStream counts the stream in its type, streams with the same contents are only stored once
+func (collector *collector) addStream(data capture.Data) {
+	collector.streamTypeCount.Count(data.Type)
+	collector.updatedStreamTypeCount = true
+	hash := md5.Sum(data.Content)
+	if _, found := collector.hashedStreams[hash]; found {
+		return
+	}
+	collector.hashedStreams[hash] = struct{}{}
+	collector.storedBytes += uint64(len(data.Content))
+	collector.streams = append(collector.streams, data)
+	collector.publish("",
+		tools.ServerWSResponse{
+			Type:    symbols.StreamResponse,
+			Payload: data,
+		},
+	)
+}
+
+// collect takes up to a thousand of the packets and streams already queued by the engine without waiting for more.
+// It returns how many were taken and false when the engine closed its channels
+func (collector *collector) collect() (int, bool, error) {
+	for read := 0; read < 1000; read++ {
+		select {
+		case packet, isOpen := <-collector.engine.Packets:
+			if !isOpen {
+				return read, false, nil
+			}
+			if packet == nil {
+				continue
+			}
+			addError := collector.addPacket(packet)
+			if addError != nil {
+				return read, true, addError
+			}
+		case data, isOpen := <-collector.engine.TCPStreams:
+			if !isOpen {
+				return read, false, nil
+			}
+			collector.addStream(data)
+		default:
+			return read, true, nil
+		}
+	}
+	return 1000, true, nil
+}
+
+// flush labels the hosts with the fingerprints of the engine and publishes the graphs that changed
+func (collector *collector) flush() {
+	if labelFingerprints(collector.topology, collector.engine) {
+		collector.updatedTopology = true
+	}
+	if collector.updatedTopology {
+		collector.publishGraph(symbols.UpdateTopologyGraph, collector.topology.Options())
+	}
+	if collector.updatedHostPacketCount {
+		collector.publishGraph(symbols.UpdateHostCountGraph, collector.hostPacketCount.Options())
+	}
+	if collector.updatedLayer4Count {
+		collector.publishGraph(symbols.UpdateLayer4Graph, collector.layer4Count.Options())
+	}
+	if collector.updatedStreamTypeCount {
+		collector.publishGraph(symbols.UpdateStreamCountGraph, collector.streamTypeCount.Options())
+	}
+	collector.updatedTopology = false
+	collector.updatedHostPacketCount = false
+	collector.updatedLayer4Count = false
+	collector.updatedStreamTypeCount = false
+}
+
+func (collector *collector) publishGraph(target string, options interface{}) {
+	collector.publish(target,
+		tools.ServerWSResponse{
+			Type: symbols.UpdateGraphsResponse,
+			Payload: struct {
+				Target  string
+				Options interface{}
+			}{
+				Target:  target,
+				Options: options,
+			},
+		},
+	)
+}
+
+// capturedBytes is what the capture would take from the storage quota if it was saved now
+func (collector *collector) capturedBytes() uint64 {
+	return collector.engine.DumpedBytes() + collector.storedBytes
+}
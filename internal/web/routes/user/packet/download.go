@@ -1,6 +1,7 @@
 package packet
 
 import (
+	"encoding/json"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 )
@@ -21,3 +22,32 @@ func downloadCapture(mw *middleware.Middleware, context *middleware.Context) boo
 	context.WriteBody = false
 	return false
 }
+
+// downloadCaptureHosts exports the hosts of the capture with the vendors of their MACs and the guesses of their systems
+func downloadCaptureHosts(mw *middleware.Middleware, context *middleware.Context) bool {
+	captureName := context.Request.PostFormValue(symbols.CaptureName)
+	succeed, captureSession, _, _ := mw.UserGetCapture(context.Request, context.User.Username, captureName)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	hosts, loaded := mw.CaptureHosts(context.Request, captureSession)
+	if !loaded {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	export, marshalError := json.Marshal(hosts)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	context.ResponseWriter.Header().Add("Content-Disposition", "attachment; filename=\"capture-hosts.json\"")
+	_, writeError := context.ResponseWriter.Write(export)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	context.WriteBody = false
+	return false
+}
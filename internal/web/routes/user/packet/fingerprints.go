@@ -0,0 +1,16 @@
+package packet
+
+import (
+	"github.com/shoriwe/CAPitan/internal/capture"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+)
+
+// labelFingerprints attaches the guesses of the systems of the hosts made by the engine to the topology, true is
+// returned when a guess changes
+func labelFingerprints(topology *objects.Topology, engine *capture.Engine) bool {
+	updated := false
+	for host, guesses := range engine.Fingerprints() {
+		updated = topology.SetFingerprints(host, guesses) || updated
+	}
+	return updated
+}
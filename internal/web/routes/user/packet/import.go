@@ -1,8 +1,7 @@
 package packet
 
 import (
-	"crypto/md5"
-	"github.com/google/gopacket"
+	"bytes"
 	"github.com/shoriwe/CAPitan/internal/capture"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
 	"github.com/shoriwe/CAPitan/internal/web/base"
@@ -10,24 +9,54 @@ import (
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 func importCapture(mw *middleware.Middleware, context *middleware.Context) bool {
 	switch context.Request.Method {
 	case http.MethodGet:
-		templateContents, _ := mw.Templates.ReadFile("templates/user/packet/import-capture.html")
-		context.Body = base.NewPage("Import", context.NavigationBar, string(templateContents))
-		return false
+		return renderImportPage(mw, context, "")
 	case http.MethodPost:
 		return handleImportCapture(mw, context)
 	}
 	return http405.MethodNotAllowed(mw, context)
 }
 
+// renderImportPage shows the import form with the reason the last import failed
+func renderImportPage(mw *middleware.Middleware, context *middleware.Context, errorMessage string) bool {
+	templateContents, _ := mw.Templates.ReadFile("templates/user/packet/import-capture.html")
+	var body bytes.Buffer
+	executeError := template.Must(template.New("Import").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Message string
+		}{
+			Message: errorMessage,
+		},
+	)
+	if executeError != nil {
+		go mw.LogError(context.Request, executeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.Redirect = ""
+	context.Body = base.NewPage("Import", context.NavigationBar, body.String())
+	return false
+}
+
+// quotaExceededMessage explains which limit of the storage quota the import does not fit in
+func quotaExceededMessage(usage *objects.StorageUsage) string {
+	if usage.Quota.MaxCaptures > 0 && usage.Captures >= usage.Quota.MaxCaptures {
+		return "Captures limit of " + usage.CapturesLimitString() + " reached"
+	}
+	return "Storage quota of " + usage.LimitString() + " exceeded, " + usage.UsedString() + " already used"
+}
+
 func handleImportCapture(mw *middleware.Middleware, context *middleware.Context) bool {
 	parseError := context.Request.ParseMultipartForm(1024 * 1024 * 1024 * 500)
 	context.Redirect = symbols.UserPacketCaptures + "?action=" + actions.Import
@@ -47,7 +76,7 @@ func handleImportCapture(mw *middleware.Middleware, context *middleware.Context)
 		go mw.LogError(context.Request, tempCreationError)
 		return false
 	}
-	_, copyError := io.Copy(file, mimeFile)
+	fileSize, copyError := io.Copy(file, mimeFile)
 	if copyError != nil {
 		go mw.LogError(context.Request, copyError)
 		return false
@@ -74,15 +103,27 @@ func handleImportCapture(mw *middleware.Middleware, context *middleware.Context)
 		return false
 	}
 
+	bpfFilter := strings.TrimSpace(context.Request.PostFormValue(symbols.BPFFilter))
+
 	if !mw.ReserveUserCaptureName(context.Request, context.User.Username, captureName) {
 		return false
 	}
 	defer mw.RemoveReservedCaptureName(context.Request, context.User.Username, captureName)
 
-	engine, creationError := capture.NewEngineWithFile(file)
+	succeed, usage := mw.UserGetStorageUsage(context.Request, context.User.Username)
+	if !succeed {
+		return renderImportPage(mw, context, "Something goes wrong")
+	}
+	if !usage.CanCreateCapture() || usage.Exceeded(uint64(fileSize)) {
+		go mw.LogStorageQuotaExceeded(context.Request, context.User.Username, captureName)
+		return renderImportPage(mw, context, quotaExceededMessage(usage))
+	}
+
+	// Pcap compiles the filter for the link type of the file
+	engine, creationError := capture.NewEngineWithFile(file, bpfFilter)
 	if creationError != nil {
 		go mw.LogError(context.Request, creationError)
-		return false
+		return renderImportPage(mw, context, creationError.Error())
 	}
 	defer engine.Close()
 
@@ -101,21 +142,7 @@ func handleImportCapture(mw *middleware.Middleware, context *middleware.Context)
 
 	tick := time.Tick(time.Second)
 
-	// Temporary storage of streams and packets
-	var (
-		packets []gopacket.Packet
-		streams []capture.Data
-	)
-
-	hashedStreams := map[[16]byte]struct{}{}
-
-	// Graphs data
-	var (
-		topology        = objects.NewTopology()
-		hostPacketCount = objects.NewCounter()
-		layer4Count     = objects.NewCounter()
-		streamTypeCount = objects.NewCounter()
-	)
+	captured := newCollector(engine, objects.NewTopology(), nil)
 
 masterLoop:
 	for {
@@ -131,35 +158,22 @@ masterLoop:
 				break masterLoop
 			}
 		case <-tick:
-			for i := 0; i < 1000; i++ {
-				select {
-				case packet, isOpen := <-engine.Packets:
-					if isOpen {
-						if packet != nil {
-							topology.AddEdge(packet.NetworkLayer().NetworkFlow().Src().String(), packet.NetworkLayer().NetworkFlow().Dst().String())
-							hostPacketCount.Count(packet.NetworkLayer().NetworkFlow().Src().String())
-							layer4Count.Count(packet.TransportLayer().LayerType().String())
-							packets = append(packets, packet)
-						}
-					} else {
-						break masterLoop
-					}
-					break
-				case data, isOpen := <-engine.TCPStreams:
-					if isOpen {
-						streamTypeCount.Count(data.Type)
-						if _, found := hashedStreams[md5.Sum(data.Content)]; !found {
-							streams = append(streams, data)
-						}
-					} else {
-						break masterLoop
-					}
-				default:
-					break masterLoop
-				}
+			// The file was read entirely once the engine has nothing queued
+			read, isOpen, collectError := captured.collect()
+			if collectError != nil {
+				go mw.LogError(context.Request, collectError)
+				return false
+			}
+			if !isOpen || read == 0 {
+				break masterLoop
 			}
 		}
 	}
+	captured.flush()
+	if usage.Exceeded(captured.capturedBytes()) {
+		go mw.LogStorageQuotaExceeded(context.Request, context.User.Username, captureName)
+		return renderImportPage(mw, context, quotaExceededMessage(usage))
+	}
 	context.Redirect = symbols.UserPacketCaptures
 	mw.SaveImportCapture(
 		context.Request,
@@ -167,12 +181,13 @@ masterLoop:
 		captureName,
 		description,
 		script,
-		topology.Options(),
-		hostPacketCount.Options(),
-		layer4Count.Options(),
-		streamTypeCount.Options(),
-		packets,
-		streams,
+		bpfFilter,
+		captured.topology.Options(),
+		captured.hostPacketCount.Options(),
+		captured.layer4Count.Options(),
+		captured.streamTypeCount.Options(),
+		captured.packets,
+		captured.streams,
 		engine.DumpPcap(),
 	)
 	return false
@@ -0,0 +1,102 @@
+package packet
+
+import (
+	"errors"
+	"github.com/shoriwe/CAPitan/internal/spoof"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// interceptConfiguration turns a live capture into a man in the middle session, the targets are ARP spoofed
+// on the capture interface and their traffic is forwarded in userspace
+type interceptConfiguration struct {
+	Targets  string
+	ScanName string
+	Gateway  string
+	Mode     string
+	OneWay   bool
+	Interval uint
+	// Optional DNS spoofing of the queries sent by the targets
+	DNSRules  []spoof.DNSRule
+	DNSScript string
+}
+
+type interceptTargets struct {
+	Succeed bool
+	Message string
+	Targets []string
+}
+
+// checkInterceptArguments validates the spoof settings of the intercept and expands its targets,
+// on failure the message to show the user is returned
+func checkInterceptArguments(mw *middleware.Middleware, context *middleware.Context, interfaceName string, intercept *interceptConfiguration) ([]net.IP, string) {
+	if len(intercept.Mode) == 0 {
+		intercept.Mode = spoof.ReplyMode
+	}
+	if intercept.Interval == 0 {
+		intercept.Interval = spoof.DefaultInterval
+	}
+	succeed, _, _, _, arpSpoofInterfaces, _, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		return nil, "Something goes wrong"
+	}
+	if !succeed {
+		return nil, "Failed to query user interfaces"
+	}
+	if _, found := arpSpoofInterfaces[interfaceName]; !found {
+		return nil, "No ARP spoof permissions for selected interface"
+	}
+	if net.ParseIP(intercept.Gateway) == nil {
+		return nil, "Invalid Gateway IP provided"
+	}
+	if intercept.Mode != spoof.ReplyMode && intercept.Mode != spoof.RequestMode {
+		return nil, "Invalid poisoning mode provided"
+	}
+	if intercept.Interval < spoof.MinimumInterval || intercept.Interval > spoof.MaximumInterval {
+		return nil, "Interval must be between " + strconv.Itoa(spoof.MinimumInterval) + " and " + strconv.Itoa(spoof.MaximumInterval) + " milliseconds"
+	}
+	return mw.CollectSpoofTargets(context.Request, context.User.Username, intercept.Targets, intercept.ScanName)
+}
+
+// startIntercept poisons the targets and starts relaying their traffic, the returned message lists the poisoned
+// targets and the ones that did not answer. The DNS spoofer is nil when no DNS spoofing was configured
+func startIntercept(interfaceName string, intercept *interceptConfiguration, targets []net.IP) (*spoof.Engine, *spoof.DNSSpoofer, interceptTargets, error) {
+	var dnsSpoofer *spoof.DNSSpoofer
+	if len(intercept.DNSRules) > 0 || len(intercept.DNSScript) > 0 {
+		var dnsError error
+		dnsSpoofer, dnsError = spoof.NewDNSSpoofer(intercept.DNSRules, intercept.DNSScript)
+		if dnsError != nil {
+			return nil, nil, interceptTargets{}, errors.New("Invalid DNS spoofing configuration: " + dnsError.Error())
+		}
+	}
+	engine, unresolved, newEngineError := spoof.NewEngine(targets, intercept.Gateway, interfaceName, intercept.Mode, intercept.OneWay)
+	if newEngineError != nil {
+		return nil, nil, interceptTargets{}, newEngineError
+	}
+	if dnsSpoofer != nil {
+		engine.SetDNSSpoofer(dnsSpoofer)
+	}
+	forwardError := engine.StartForwarding()
+	if forwardError != nil {
+		_ = engine.Close()
+		return nil, nil, interceptTargets{}, forwardError
+	}
+	result := interceptTargets{
+		Succeed: true,
+		Message: "Intercepting " + strconv.Itoa(len(engine.Targets())) + " targets",
+		Targets: engine.Targets(),
+	}
+	if len(unresolved) > 0 {
+		var skipped []string
+		for _, ip := range unresolved {
+			skipped = append(skipped, ip.String())
+		}
+		sort.Strings(skipped)
+		result.Message += ", no answer from " + strings.Join(skipped, ", ")
+	}
+	return engine, dnsSpoofer, result, nil
+}
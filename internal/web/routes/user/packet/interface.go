@@ -2,18 +2,22 @@ package packet
 
 import (
 	"bytes"
-	"crypto/md5"
-	"github.com/google/gopacket"
 	"github.com/gorilla/websocket"
 	"github.com/shoriwe/CAPitan/internal/capture"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/live"
+	"github.com/shoriwe/CAPitan/internal/spoof"
+	"github.com/shoriwe/CAPitan/internal/tasks"
 	"github.com/shoriwe/CAPitan/internal/tools"
 	"github.com/shoriwe/CAPitan/internal/web/base"
 	"github.com/shoriwe/CAPitan/internal/web/http405"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"html/template"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,354 +28,433 @@ var upgradeCaptureSession = websocket.Upgrader{
 	Subprotocols:      []string{"PacketCaptureSession"},
 }
 
-func startInterfaceBasedCapture(mw *middleware.Middleware, context *middleware.Context) bool {
-	connection, upgradeError := upgradeCaptureSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
-	if upgradeError != nil {
-		go mw.LogError(context.Request, upgradeError)
-		context.Redirect = symbols.UserPacketCaptures
-		return false
-	}
-	context.WriteBody = false
-	defer func() {
-		closeError := connection.Close()
-		if closeError != nil {
-			go mw.LogError(context.Request, closeError)
-		}
-	}()
+type captureConfiguration struct {
+	Promiscuous   bool
+	Script        string
+	BPFFilter     string
+	Description   string
+	CaptureName   string
+	InterfaceName string
+	Intercept     *interceptConfiguration
+}
+
+// interfaceCapture is a capture of an interface running on the server, optionally on top of an intercept of its
+// targets. Its events are published to the live capture the clients attach to, so it keeps running without them
+type interfaceCapture struct {
+	mw               *middleware.Middleware
+	remoteAddr       string
+	username         string
+	configuration    captureConfiguration
+	usage            *objects.StorageUsage
+	engine           *capture.Engine
+	liveCapture      *live.Capture
+	task             *tasks.Task
+	spoofEngine      *spoof.Engine
+	dnsSpoofer       *spoof.DNSSpoofer
+	initialTargets   interceptTargets
+	interceptStopped bool
+	spoofedAnswers   []*objects.DNSSpoofedAnswer
+}
 
-	var configuration struct {
-		Promiscuous   bool
-		Script        string
-		Description   string
-		CaptureName   string
-		InterfaceName string
+// request returns the request the events of the capture are logged with, it only carries the address of the client
+// that started it since the one of the handler can not be used once the handler returned
+func (session *interfaceCapture) request() *http.Request {
+	request, _ := http.NewRequest(http.MethodGet, symbols.UserPacketCaptures, nil)
+	request.RemoteAddr = session.remoteAddr
+	return request
+}
+
+// stopIntercept restores the caches of the victims before closing the spoof, whatever the way the intercept finishes
+func (session *interfaceCapture) stopIntercept() {
+	if session.spoofEngine == nil || session.interceptStopped {
+		return
 	}
-	readError := connection.ReadJSON(&configuration)
-	if readError != nil {
-		go mw.LogError(context.Request, readError)
-		return false
+	session.interceptStopped = true
+	restoreResult := interceptTargets{
+		Succeed: true,
+		Message: "ARP caches of " + strconv.Itoa(len(session.spoofEngine.Targets())) + " targets and " + session.configuration.Intercept.Gateway + " restored",
+		Targets: session.spoofEngine.Targets(),
 	}
-	// Check configuration
-	isValid, errorMessage := checkInterfaceCaptureInputArguments(mw, context, configuration.CaptureName, configuration.InterfaceName, configuration.Description, configuration.Script)
-	if !isValid {
-		writeError := connection.WriteJSON(struct {
-			Succeed bool
-			Message string
-		}{
-			Succeed: false,
-			Message: errorMessage,
-		})
-		if writeError != nil {
-			go mw.LogError(context.Request, writeError)
-			return false
-		}
-		return false
+	restoreError := session.spoofEngine.Restore()
+	session.spoofEngine.StopForwarding()
+	go session.mw.LogARPSpoofRestored(session.request(), session.username, session.configuration.Intercept.Targets, session.configuration.Intercept.Gateway, restoreError == nil)
+	if restoreError != nil {
+		go session.mw.LogError(session.request(), restoreError)
+		restoreResult.Succeed = false
+		restoreResult.Message = "Failed to restore ARP caches: " + restoreError.Error()
 	}
-	if !mw.ReserveUserCaptureName(context.Request, context.User.Username, configuration.CaptureName) {
-		writeError := connection.WriteJSON(
-			struct {
-				Succeed bool
-				Message string
-			}{
-				Succeed: false,
-				Message: "Capture name already taken",
+	closeError := session.spoofEngine.Close()
+	if closeError != nil {
+		go session.mw.LogError(session.request(), closeError)
+	}
+	go session.mw.LogInterceptStopped(session.request(), session.username, session.configuration.CaptureName, session.spoofEngine.ForwardedPackets())
+	session.liveCapture.Publish(
+		tools.ServerWSResponse{
+			Type:    symbols.RestoreResponse,
+			Payload: restoreResult,
+		},
+	)
+}
+
+// recordAnswers keeps the DNS answers forged since the last call and publishes them to the viewers
+func (session *interfaceCapture) recordAnswers() {
+	if session.dnsSpoofer == nil {
+		return
+	}
+	answers, resolverErrors := session.dnsSpoofer.TakeAnswers()
+	for _, resolverError := range resolverErrors {
+		go session.mw.LogDNSResolverFailed(session.request(), session.username, resolverError)
+	}
+	for _, answer := range answers {
+		answer := answer
+		session.spoofedAnswers = append(session.spoofedAnswers, &answer)
+		go session.mw.LogDNSAnswerSpoofed(session.request(), session.username, answer.Client, answer.Name, answer.Address)
+		session.liveCapture.Publish(
+			tools.ServerWSResponse{
+				Type:    symbols.DNSAnswerResponse,
+				Payload: answer,
 			},
 		)
+	}
+}
 
-		if writeError != nil {
-			go mw.LogError(context.Request, writeError)
-			return false
-		}
-		return false
+// release frees everything the capture holds besides its task
+func (session *interfaceCapture) release() {
+	session.stopIntercept()
+	session.engine.Close()
+	session.mw.RemoveReservedCaptureName(session.request(), session.username, session.configuration.CaptureName)
+}
+
+// publishError notifies the viewers why the capture stopped without being stored
+func (session *interfaceCapture) publishError(err error) {
+	session.liveCapture.Publish(tools.ServerWSResponse{
+		Type:    symbols.ErrorResponse,
+		Payload: err.Error(),
+	})
+}
+
+// launchInterfaceCapture prepares the capture and starts it in the background with the client that started it already
+// attached, the message explains why it failed
+func launchInterfaceCapture(mw *middleware.Middleware, request *http.Request, username string, configuration captureConfiguration, interceptedTargets []net.IP) (*live.Capture, *live.Viewer, string) {
+	if !mw.ReserveUserCaptureName(request, username, configuration.CaptureName) {
+		return nil, nil, "Capture name already taken"
+	}
+
+	succeed, usage := mw.UserGetStorageUsage(request, username)
+	if !succeed || !usage.CanCreateCapture() {
+		mw.RemoveReservedCaptureName(request, username, configuration.CaptureName)
+		return nil, nil, "Storage quota exceeded"
 	}
-	defer mw.RemoveReservedCaptureName(context.Request, context.User.Username, configuration.CaptureName)
 
-	engine, creationError := capture.NewEngineWithInterface(configuration.InterfaceName)
+	engine, creationError := capture.NewEngineWithInterface(configuration.InterfaceName, configuration.BPFFilter)
 	if creationError != nil {
-		go mw.LogError(context.Request, creationError)
-		writeError := connection.WriteJSON(struct {
-			Succeed bool
-			Message string
-		}{
-			Succeed: false,
-			Message: creationError.Error(),
-		},
-		)
-		if writeError != nil {
-			go mw.LogError(context.Request, writeError)
-			return false
-		}
-		return false
+		go mw.LogError(request, creationError)
+		mw.RemoveReservedCaptureName(request, username, configuration.CaptureName)
+		return nil, nil, creationError.Error()
 	}
-	// Respond to the client if everything is ok
-	writeError := connection.WriteJSON(struct {
-		Succeed bool
-		Message string
-	}{
-		Succeed: true,
-		Message: "Everything ok!",
-	},
+	engine.Promiscuous = configuration.Promiscuous
+	session := &interfaceCapture{
+		mw:            mw,
+		remoteAddr:    request.RemoteAddr,
+		username:      username,
+		configuration: configuration,
+		usage:         usage,
+		engine:        engine,
+		liveCapture:   live.NewCapture(username, configuration.CaptureName, configuration.InterfaceName),
+	}
+	var (
+		taskKind    = tasks.Capture
+		taskTargets string
 	)
-	if writeError != nil {
-		go mw.LogError(context.Request, writeError)
-		return false
+	if configuration.Intercept != nil {
+		var interceptError error
+		session.spoofEngine, session.dnsSpoofer, session.initialTargets, interceptError = startIntercept(configuration.InterfaceName, configuration.Intercept, interceptedTargets)
+		if interceptError == nil {
+			// The copies of the frames relayed by the forwarder would show every packet twice
+			interceptError = engine.SetBPFFilter(session.spoofEngine.ForwardingFilter())
+			if interceptError != nil {
+				_ = session.spoofEngine.Close()
+			}
+		}
+		go mw.LogInterceptStarted(request, username, configuration.CaptureName, configuration.Intercept.Targets, configuration.Intercept.Gateway, interceptError == nil)
+		if interceptError != nil {
+			go mw.LogError(request, interceptError)
+			session.spoofEngine = nil
+			session.release()
+			return nil, nil, interceptError.Error()
+		}
+		taskKind = tasks.Intercept
+		taskTargets = strings.Join(session.initialTargets.Targets, ", ") + " <-> " + configuration.Intercept.Gateway
 	}
-	defer engine.Close()
-	engine.Promiscuous = configuration.Promiscuous
 
 	if len(configuration.Script) > 0 {
 		initError := engine.InitScript(configuration.Script)
 		if initError != nil {
-			go mw.LogError(context.Request, initError)
-			return false
+			go mw.LogError(request, initError)
+			session.release()
+			return nil, nil, initError.Error()
 		}
 	}
 
-	stopChannel := make(chan bool, 1)
-	go func() {
-		var action struct {
-			Action string
-		}
-		err := connection.ReadJSON(&action)
-		if err != nil {
-			go mw.LogError(context.Request, err)
-			engine.ErrorChannel <- err
-			stopChannel <- true
-			return
-		}
-		switch action.Action {
-		case symbols.StopSignal:
-			stopChannel <- true
-		}
-	}()
+	var registered bool
+	session.task, registered = mw.RegisterTask(request, taskKind, username, configuration.InterfaceName, configuration.CaptureName, taskTargets)
+	if !registered {
+		session.release()
+		return nil, nil, "Something goes wrong"
+	}
 
-	tick := time.Tick(time.Second)
+	viewer, _, _ := session.liveCapture.Attach(username)
+	if session.spoofEngine != nil {
+		session.liveCapture.PublishState(
+			symbols.TargetsResponse,
+			tools.ServerWSResponse{
+				Type:    symbols.TargetsResponse,
+				Payload: session.initialTargets,
+			},
+		)
+	}
+	mw.LiveCaptures.Add(session.liveCapture)
+	go session.run()
+	return session.liveCapture, viewer, ""
+}
+
+// run captures until the capture is stopped, then stores it
+func (session *interfaceCapture) run() {
+	mw, request, engine, task := session.mw, session.request(), session.engine, session.task
+	defer session.liveCapture.Finish()
+	defer mw.LiveCaptures.Remove(session.liveCapture)
+	defer session.release()
+	defer mw.RemoveTask(request, task)
 
-	// Temporary storage of streams and packets
 	var (
-		packets []gopacket.Packet
-		streams []capture.Data
+		dnsAnswered   chan struct{}
+		poisonTick    <-chan time.Time
+		forwardErrors chan error
 	)
+	if session.spoofEngine != nil {
+		poisonTick = time.Tick(time.Duration(session.configuration.Intercept.Interval) * time.Millisecond)
+		forwardErrors = session.spoofEngine.ForwardErrors
+		if session.dnsSpoofer != nil {
+			dnsAnswered = session.dnsSpoofer.Answered
+		}
+	}
 
-	hashedStreams := map[[16]byte]struct{}{}
+	tick := time.Tick(time.Second)
+
+	var quotaWarning bool
 
 	startError := engine.Start()
 	if startError != nil {
-		go mw.LogError(context.Request, startError)
-		return false
+		go mw.LogError(request, startError)
+		session.publishError(startError)
+		return
 	}
 
-	// Graphs data
-	var (
-		topology        = objects.NewTopology()
-		hostPacketCount = objects.NewCounter()
-		layer4Count     = objects.NewCounter()
-		streamTypeCount = objects.NewCounter()
-	)
+	captured := newCollector(engine, objects.NewTopology(), func(key string, response tools.ServerWSResponse) {
+		if len(key) == 0 {
+			session.liveCapture.Publish(response)
+		} else {
+			session.liveCapture.PublishState(key, response)
+		}
+	})
 
 	start := time.Now()
+	var (
+		stopReason   = "Capture finished"
+		captureSaved bool
+	)
+	if session.spoofEngine != nil {
+		// The spoof is only linked to the capture when the capture gets saved, it is stored once the forwarder
+		// stopped with the answers of its last burst
+		defer func() {
+			session.stopIntercept()
+			session.recordAnswers()
+			var linkedCapture string
+			if captureSaved {
+				linkedCapture = session.configuration.CaptureName
+			}
+			mw.SaveARPSpoof(request, session.username, session.configuration.InterfaceName, strings.Join(session.initialTargets.Targets, ", "), session.configuration.Intercept.Gateway, linkedCapture, session.spoofEngine.PacketsSent(), stopReason, session.spoofedAnswers, start, time.Now())
+		}()
+	}
 masterLoop:
 	for {
 		select {
 		case err, isOpen := <-engine.ErrorChannel:
 			if isOpen {
 				if err != nil {
-					writeError = connection.WriteJSON(tools.ServerWSResponse{
-						Type:    symbols.ErrorResponse,
-						Payload: err.Error(),
-					})
-					if writeError != nil {
-						go mw.LogError(context.Request, writeError)
-					}
-					return false
+					stopReason = err.Error()
+					session.publishError(err)
+					return
 				}
 			} else {
 				break masterLoop
 			}
-		case stop, isOpen := <-stopChannel:
-			if isOpen {
-				if stop {
-					break masterLoop
-				}
+		case reason := <-session.liveCapture.Stopped():
+			stopReason = reason
+			break masterLoop
+		case <-poisonTick:
+			poisonError := session.spoofEngine.Poison()
+			if poisonError != nil {
+				stopReason = poisonError.Error()
+				go mw.LogError(request, poisonError)
+				session.publishError(poisonError)
+				return
 			}
+		case <-dnsAnswered:
+			session.recordAnswers()
+		case forwardError := <-forwardErrors:
+			stopReason = forwardError.Error()
+			go mw.LogError(request, forwardError)
+			session.publishError(forwardError)
+			return
+		case reason := <-task.Killed:
+			stopReason = "Killed by an administrator: " + reason
+			session.liveCapture.Publish(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			break masterLoop
 		case <-tick:
-			updatedTopology := false
-			updatedPacketCountPerHost := false
-			updatedLayer4Count := false
-			updatedStreamTypeCount := false
-			for i := 0; i < 1000; i++ {
-				select {
-				case packet, isOpen := <-engine.Packets:
-					if isOpen {
-						if packet != nil {
-							//Send the packet to the client
-							writeError = connection.WriteJSON(
-								tools.ServerWSResponse{
-									Type:    symbols.PacketResponse,
-									Payload: capture.TransformPacketToMap(packet),
-								},
-							)
-							if writeError != nil {
-								go mw.LogError(context.Request, writeError)
-								return false
-							}
-
-							// Send the topology graph update
-							updatedPacketCountPerHost = true
-							updatedLayer4Count = true
-
-							if topology.AddEdge(packet.NetworkLayer().NetworkFlow().Src().String(), packet.NetworkLayer().NetworkFlow().Dst().String()) && !updatedTopology {
-								updatedTopology = true
-							}
-							hostPacketCount.Count(packet.NetworkLayer().NetworkFlow().Src().String())
-							layer4Count.Count(packet.TransportLayer().LayerType().String())
-
-							packets = append(packets, packet)
-						}
-					} else {
-						break masterLoop
-					}
-					break
-				case data, isOpen := <-engine.TCPStreams:
-					if isOpen {
-						updatedStreamTypeCount = true
-						streamTypeCount.Count(data.Type)
-
-						if _, found := hashedStreams[md5.Sum(data.Content)]; !found {
-							writeError = connection.WriteJSON(
-								tools.ServerWSResponse{
-									Type:    symbols.StreamResponse,
-									Payload: data,
-								},
-							)
-							if writeError != nil {
-								go mw.LogError(context.Request, writeError)
-								return false
-							}
-						}
-
-						streams = append(streams, data)
-					} else {
-						break masterLoop
-					}
-				default:
-					break
-				}
+			before := len(captured.packets)
+			_, isOpen, collectError := captured.collect()
+			task.AddPackets(uint64(len(captured.packets) - before))
+			if collectError != nil {
+				go mw.LogError(request, collectError)
+				session.publishError(collectError)
+				return
 			}
-			if updatedTopology {
-				// Update graphs
-				writeError = connection.WriteJSON(
-					tools.ServerWSResponse{
-						Type: symbols.UpdateGraphsResponse,
-						Payload: struct {
-							Target  string
-							Options interface{}
-						}{
-							Target:  symbols.UpdateTopologyGraph,
-							Options: topology.Options(),
-						},
-					},
-				)
-				if writeError != nil {
-					go mw.LogError(context.Request, writeError)
-					return false
-				}
-			}
-			if updatedPacketCountPerHost {
-				writeError = connection.WriteJSON(
-					tools.ServerWSResponse{
-						Type: symbols.UpdateGraphsResponse,
-						Payload: struct {
-							Target  string
-							Options interface{}
-						}{
-							Target:  symbols.UpdateHostCountGraph,
-							Options: hostPacketCount.Options(),
-						},
-					},
-				)
-				if writeError != nil {
-					go mw.LogError(context.Request, writeError)
-					return false
-				}
+			if !isOpen {
+				break masterLoop
 			}
-			if updatedLayer4Count {
-				writeError = connection.WriteJSON(
+			captured.flush()
+			// Check the storage quota of the user
+			capturedBytes := captured.capturedBytes()
+			if session.usage.Exceeded(capturedBytes) {
+				stopReason = "Storage quota exceeded"
+				go mw.LogStorageQuotaExceeded(request, session.username, session.configuration.CaptureName)
+				session.liveCapture.Publish(
 					tools.ServerWSResponse{
-						Type: symbols.UpdateGraphsResponse,
-						Payload: struct {
-							Target  string
-							Options interface{}
-						}{
-							Target:  symbols.UpdateLayer4Graph,
-							Options: layer4Count.Options(),
-						},
+						Type:    symbols.QuotaExceededResponse,
+						Payload: "Storage quota of " + session.usage.LimitString() + " exceeded, the capture was stopped and saved",
 					},
 				)
-				if writeError != nil {
-					go mw.LogError(context.Request, writeError)
-					return false
-				}
-			}
-			if updatedStreamTypeCount {
-				writeError = connection.WriteJSON(
+				break masterLoop
+			} else if !quotaWarning && session.usage.NearLimit(capturedBytes) {
+				quotaWarning = true
+				session.liveCapture.Publish(
 					tools.ServerWSResponse{
-						Type: symbols.UpdateGraphsResponse,
+						Type: symbols.QuotaWarningResponse,
 						Payload: struct {
-							Target  string
-							Options interface{}
+							Used  string
+							Limit string
 						}{
-							Target:  symbols.UpdateStreamCountGraph,
-							Options: streamTypeCount.Options(),
+							Used:  objects.FormatBytes(session.usage.Bytes + capturedBytes),
+							Limit: session.usage.LimitString(),
 						},
 					},
 				)
-				if writeError != nil {
-					go mw.LogError(context.Request, writeError)
-					return false
-				}
 			}
 		}
 	}
 
 	finish := time.Now()
 
-	// Send to the client that it is safe to close the connection
+	session.stopIntercept()
+	captured.flush()
 
-	writeError = connection.WriteJSON(struct {
+	captureSaved = mw.SaveInterfaceCapture(
+		request,
+		session.username,
+		session.configuration.CaptureName,
+		session.configuration.InterfaceName,
+		session.configuration.Description,
+		session.configuration.Script,
+		session.configuration.BPFFilter,
+		session.configuration.Promiscuous,
+		captured.topology.Options(),
+		captured.hostPacketCount.Options(),
+		captured.layer4Count.Options(),
+		captured.streamTypeCount.Options(),
+		captured.packets,
+		captured.streams,
+		engine.DumpPcap(),
+		start, finish,
+	)
+}
+
+func startInterfaceBasedCapture(mw *middleware.Middleware, context *middleware.Context) bool {
+	connection, upgradeError := upgradeCaptureSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
+	if upgradeError != nil {
+		go mw.LogError(context.Request, upgradeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.WriteBody = false
+	defer func() {
+		closeError := connection.Close()
+		if closeError != nil {
+			go mw.LogError(context.Request, closeError)
+		}
+	}()
+
+	var configuration captureConfiguration
+	readError := connection.ReadJSON(&configuration)
+	if readError != nil {
+		go mw.LogError(context.Request, readError)
+		return false
+	}
+	// Check configuration
+	isValid, errorMessage := checkInterfaceCaptureInputArguments(mw, context, configuration.CaptureName, configuration.InterfaceName, configuration.Description, configuration.Script)
+	var (
+		interceptedTargets []net.IP
+		liveCapture        *live.Capture
+		viewer             *live.Viewer
+	)
+	// Pcap compiles the filter for the link type of the interface once it is opened
+	configuration.BPFFilter = strings.TrimSpace(configuration.BPFFilter)
+	if isValid && configuration.Intercept != nil {
+		interceptedTargets, errorMessage = checkInterceptArguments(mw, context, configuration.InterfaceName, configuration.Intercept)
+		isValid = interceptedTargets != nil
+	}
+	if isValid {
+		liveCapture, viewer, errorMessage = launchInterfaceCapture(mw, context.Request, context.User.Username, configuration, interceptedTargets)
+	}
+	if liveCapture == nil {
+		writeError := connection.WriteJSON(struct {
+			Succeed bool
+			Message string
+		}{
+			Succeed: false,
+			Message: errorMessage,
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	// Respond to the client if everything is ok
+	writeError := connection.WriteJSON(struct {
 		Succeed bool
+		Message string
 	}{
 		Succeed: true,
-	})
+		Message: "Everything ok!",
+	},
+	)
 	if writeError != nil {
 		go mw.LogError(context.Request, writeError)
+		liveCapture.Detach(viewer)
 		return false
 	}
-
-	mw.SaveInterfaceCapture(
-		context.Request,
-		context.User.Username,
-		configuration.CaptureName,
-		configuration.InterfaceName,
-		configuration.Description,
-		configuration.Script,
-		configuration.Promiscuous,
-		topology.Options(),
-		hostPacketCount.Options(),
-		layer4Count.Options(),
-		streamTypeCount.Options(),
-		packets,
-		streams,
-		engine.DumpPcap(),
-		start, finish,
-	)
-	return false
+	return watchCapture(mw, context, connection, liveCapture, viewer, nil)
 }
 
 func newInterfaceCapture(mw *middleware.Middleware, context *middleware.Context) bool {
 	switch context.Request.Method {
 	case http.MethodGet:
-		succeed, _, captureInterfaces, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+		succeed, _, captureInterfaces, _, _, _, _, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
 		if getError != nil {
 			go mw.LogError(context.Request, getError)
 			return false
@@ -401,6 +484,9 @@ func newInterfaceCapture(mw *middleware.Middleware, context *middleware.Context)
 		_ = template.Must(template.New("New Capture").Parse(string(menuTemplate))).Execute(&menu,
 			struct {
 				CaptureInterfaces []objects.InterfaceInformation
+				Attach            string
+				Owner             string
+				ReadOnly          bool
 			}{
 				CaptureInterfaces: availableInterfaces,
 			},
@@ -0,0 +1,123 @@
+package packet
+
+import (
+	"errors"
+	"github.com/shoriwe/CAPitan/internal/capture"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"net/http"
+	"time"
+)
+
+// RunCaptureJob captures on the interface of a job without a client until the duration or the packets limit of the
+// job is reached, the storage quota of the user runs out or an administrator kills the task, then stores the
+// capture. It returns the packets captured and why the capture stopped
+func RunCaptureJob(mw *middleware.Middleware, request *http.Request, username string, job *objects.Job, captureName string, task *tasks.Task) (uint64, string, error) {
+	succeed, usage := mw.UserGetStorageUsage(request, username)
+	if !succeed || !usage.CanCreateCapture() {
+		return 0, "", errors.New("storage quota exceeded")
+	}
+
+	engine, creationError := capture.NewEngineWithInterface(job.Interface, "")
+	if creationError != nil {
+		return 0, "", creationError
+	}
+	defer engine.Close()
+	engine.Promiscuous = job.Promiscuous
+
+	if len(job.Script) > 0 {
+		initError := engine.InitScript(job.Script)
+		if initError != nil {
+			return 0, "", initError
+		}
+	}
+
+	var deadline <-chan time.Time
+	if job.Duration > 0 {
+		deadline = time.After(time.Duration(job.Duration) * time.Second)
+	}
+
+	captured := newCollector(engine, objects.NewTopology(), nil)
+
+	startError := engine.Start()
+	if startError != nil {
+		return 0, "", startError
+	}
+
+	tick := time.Tick(time.Second)
+	start := time.Now()
+	stopReason := "Capture finished"
+masterLoop:
+	for {
+		select {
+		case err, isOpen := <-engine.ErrorChannel:
+			if !isOpen {
+				break masterLoop
+			}
+			if err != nil {
+				return uint64(len(captured.packets)), "", err
+			}
+		case packet, isOpen := <-engine.Packets:
+			if !isOpen {
+				break masterLoop
+			}
+			if packet == nil {
+				continue
+			}
+			addError := captured.addPacket(packet)
+			if addError != nil {
+				return uint64(len(captured.packets)), "", addError
+			}
+			task.AddPackets(1)
+			if job.MaxPackets > 0 && uint(len(captured.packets)) >= job.MaxPackets {
+				stopReason = "Packets limit reached"
+				break masterLoop
+			}
+		case data, isOpen := <-engine.TCPStreams:
+			if !isOpen {
+				break masterLoop
+			}
+			captured.addStream(data)
+		case <-tick:
+			captured.flush()
+			if usage.Exceeded(captured.capturedBytes()) {
+				stopReason = "Storage quota exceeded"
+				go mw.LogStorageQuotaExceeded(request, username, captureName)
+				break masterLoop
+			}
+		case <-deadline:
+			stopReason = "Duration reached"
+			break masterLoop
+		case reason := <-task.Killed:
+			stopReason = "Killed by an administrator: " + reason
+			break masterLoop
+		}
+	}
+
+	finish := time.Now()
+	captured.flush()
+
+	saved := mw.SaveInterfaceCapture(
+		request,
+		username,
+		captureName,
+		job.Interface,
+		job.Description,
+		job.Script,
+		"",
+		job.Promiscuous,
+		captured.topology.Options(),
+		captured.hostPacketCount.Options(),
+		captured.layer4Count.Options(),
+		captured.streamTypeCount.Options(),
+		captured.packets,
+		captured.streams,
+		engine.DumpPcap(),
+		start, finish,
+	)
+	if !saved {
+		return uint64(len(captured.packets)), "", errors.New("failed to store the capture")
+	}
+	return uint64(len(captured.packets)), stopReason, nil
+}
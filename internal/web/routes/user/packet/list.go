@@ -3,6 +3,7 @@ package packet
 import (
 	"bytes"
 	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/live"
 	"github.com/shoriwe/CAPitan/internal/web/base"
 	"github.com/shoriwe/CAPitan/internal/web/middleware"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
@@ -20,8 +21,12 @@ func listCaptures(mw *middleware.Middleware, context *middleware.Context) bool {
 	err := template.Must(template.New("Packet").Parse(string(templateContents))).Execute(
 		&body,
 		struct {
+			Running  []*live.Capture
+			Shared   []*live.Capture
 			Captures []*objects.CaptureSession
 		}{
+			Running:  mw.LiveCaptures.List(context.User.Username),
+			Shared:   mw.ListSharedCaptures(context.Request, context.User.Username),
 			Captures: userCaptures,
 		},
 	)
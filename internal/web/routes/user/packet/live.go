@@ -0,0 +1,212 @@
+package packet
+
+import (
+	"bytes"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/live"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/http405"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"html/template"
+	"net/http"
+)
+
+// sharedEvents are the events the users watching the capture of somebody else receive
+var sharedEvents = map[string]struct{}{
+	symbols.PacketResponse:       {},
+	symbols.StreamResponse:       {},
+	symbols.UpdateGraphsResponse: {},
+}
+
+// notifyWatchers sends to the owner of the capture the users watching it, called every time a watcher comes or goes
+func notifyWatchers(liveCapture *live.Capture) {
+	liveCapture.Notify(
+		liveCapture.Owner,
+		tools.ServerWSResponse{
+			Type:    symbols.WatchersResponse,
+			Payload: liveCapture.Watchers(),
+		},
+	)
+}
+
+// watchCapture sends the backlog and then the live events of the capture to the client until the capture finishes
+// or the client goes away, closing the connection only detaches the client and the capture keeps running. Only the
+// owner can stop the capture, the rest of the users just watch its packets, streams and graphs
+func watchCapture(mw *middleware.Middleware, context *middleware.Context, connection *websocket.Conn, liveCapture *live.Capture, viewer *live.Viewer, backlog []tools.ServerWSResponse) bool {
+	readOnly := viewer.Username != liveCapture.Owner
+	if readOnly {
+		notifyWatchers(liveCapture)
+		defer notifyWatchers(liveCapture)
+	} else if len(liveCapture.Watchers()) > 0 {
+		notifyWatchers(liveCapture)
+	}
+	for _, event := range backlog {
+		if _, shared := sharedEvents[event.Type]; readOnly && !shared {
+			continue
+		}
+		writeError := connection.WriteJSON(event)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			liveCapture.Detach(viewer)
+			return false
+		}
+	}
+
+	go func() {
+		for {
+			var action struct {
+				Action string
+			}
+			err := connection.ReadJSON(&action)
+			if err != nil {
+				liveCapture.Detach(viewer)
+				return
+			}
+			switch action.Action {
+			case symbols.StopSignal:
+				if !readOnly {
+					mw.UserStopCapture(context.Request, context.User.Username, liveCapture.Name, "Stopped by user")
+				}
+			}
+		}
+	}()
+
+	for event := range viewer.Events {
+		if _, shared := sharedEvents[event.Type]; readOnly && !shared {
+			continue
+		}
+		writeError := connection.WriteJSON(event)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			liveCapture.Detach(viewer)
+			return false
+		}
+	}
+
+	select {
+	case <-liveCapture.Done:
+		// Send to the client that it is safe to close the connection
+		writeError := connection.WriteJSON(struct {
+			Succeed bool
+		}{
+			Succeed: true,
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+	default:
+		// Detached, the client can attach again later
+	}
+	return false
+}
+
+func attachCaptureWS(mw *middleware.Middleware, context *middleware.Context) bool {
+	connection, upgradeError := upgradeCaptureSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
+	if upgradeError != nil {
+		go mw.LogError(context.Request, upgradeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.WriteBody = false
+	defer func() {
+		closeError := connection.Close()
+		if closeError != nil {
+			go mw.LogError(context.Request, closeError)
+		}
+	}()
+
+	var request struct {
+		CaptureName string
+		Owner       string
+	}
+	readError := connection.ReadJSON(&request)
+	if readError != nil {
+		go mw.LogError(context.Request, readError)
+		return false
+	}
+	if len(request.Owner) == 0 {
+		request.Owner = context.User.Username
+	}
+	liveCapture, viewer, backlog, attached := mw.UserAttachCapture(context.Request, context.User.Username, request.Owner, request.CaptureName)
+	if !attached {
+		writeError := connection.WriteJSON(struct {
+			Succeed bool
+			Message string
+		}{
+			Succeed: false,
+			Message: "No running capture with that name",
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	writeError := connection.WriteJSON(struct {
+		Succeed bool
+		Message string
+	}{
+		Succeed: true,
+		Message: "Everything ok!",
+	})
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		liveCapture.Detach(viewer)
+		return false
+	}
+	return watchCapture(mw, context, connection, liveCapture, viewer, backlog)
+}
+
+func renderRunningCapture(mw *middleware.Middleware, context *middleware.Context) bool {
+	captureName := context.Request.PostFormValue(symbols.CaptureName)
+	owner := context.Request.PostFormValue(symbols.Owner)
+	if len(owner) == 0 {
+		owner = context.User.Username
+	}
+	if _, found := mw.LiveCaptures.Get(owner, captureName); !found {
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	menuTemplate, _ := mw.Templates.ReadFile("templates/user/packet/new-interface-capture.html")
+	var menu bytes.Buffer
+	executeError := template.Must(template.New("Attach").Parse(string(menuTemplate))).Execute(&menu,
+		struct {
+			CaptureInterfaces []objects.InterfaceInformation
+			Attach            string
+			Owner             string
+			ReadOnly          bool
+		}{
+			Attach:   captureName,
+			Owner:    owner,
+			ReadOnly: owner != context.User.Username,
+		},
+	)
+	if executeError != nil {
+		go mw.LogError(context.Request, executeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.Body = base.NewPage("Packet", context.NavigationBar, menu.String())
+	return false
+}
+
+func attachCapture(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.Method {
+	case http.MethodPost:
+		return renderRunningCapture(mw, context)
+	case http.MethodGet:
+		return attachCaptureWS(mw, context)
+	}
+	return http405.MethodNotAllowed(mw, context)
+}
+
+func stopRunningCapture(mw *middleware.Middleware, context *middleware.Context) bool {
+	if context.Request.Method != http.MethodPost {
+		return http405.MethodNotAllowed(mw, context)
+	}
+	mw.UserStopCapture(context.Request, context.User.Username, context.Request.PostFormValue(symbols.CaptureName), "Stopped by user")
+	context.Redirect = symbols.UserPacketCaptures
+	return false
+}
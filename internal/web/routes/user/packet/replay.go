@@ -0,0 +1,310 @@
+package packet
+
+import (
+	"bytes"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/replay"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"html/template"
+	"time"
+)
+
+var upgradeReplaySession = websocket.Upgrader{
+	ReadBufferSize:    0, /* No Limit */
+	WriteBufferSize:   0, /* No Limit */
+	EnableCompression: true,
+	Subprotocols:      []string{"ReplaySession"},
+}
+
+const (
+	replayFinished       = "Replay finished"
+	replayStoppedByUser  = "Stopped by user"
+	replayConnectionLost = "Connection lost"
+	progressInterval     = 500 * time.Millisecond
+)
+
+type replayConfiguration struct {
+	CaptureName   string
+	InterfaceName string
+	replay.Options
+}
+
+type replayResponse struct {
+	Succeed bool
+	Message string
+}
+
+type replayProgress struct {
+	Sent  uint64
+	Total uint64
+	Loop  uint64
+}
+
+// checkReplayArguments verifies the user can replay on the interface and returns the stored capture,
+// on failure the message to show the user is returned
+func checkReplayArguments(mw *middleware.Middleware, context *middleware.Context, configuration *replayConfiguration) (*objects.CaptureSession, string) {
+	if len(configuration.InterfaceName) == 0 || tools.CheckFilledWithWhiteSpace.MatchString(configuration.InterfaceName) {
+		return nil, "No interface provided"
+	}
+	succeed, _, _, _, _, _, replayInterfaces, _, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		return nil, "Something goes wrong"
+	}
+	if !succeed {
+		return nil, "Failed to query user interfaces"
+	}
+	if _, found := replayInterfaces[configuration.InterfaceName]; !found {
+		return nil, "No replay permissions for selected interface"
+	}
+	connectedInterfaces := mw.ListNetInterfaces(context.Request)
+	if connectedInterfaces == nil {
+		return nil, "Failed to list connected interfaces"
+	}
+	if _, found := connectedInterfaces[configuration.InterfaceName]; !found {
+		return nil, "User has permissions but the interface is not connected to the machine"
+	}
+	succeed, captureSession, _, _ := mw.UserGetCapture(context.Request, context.User.Username, configuration.CaptureName)
+	if !succeed {
+		return nil, "Capture not found"
+	}
+	return captureSession, ""
+}
+
+func handleReplay(mw *middleware.Middleware, context *middleware.Context) bool {
+	connection, upgradeError := upgradeReplaySession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
+	if upgradeError != nil {
+		go mw.LogError(context.Request, upgradeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.WriteBody = false
+	defer func() {
+		closeError := connection.Close()
+		if closeError != nil {
+			go mw.LogError(context.Request, closeError)
+		}
+	}()
+
+	var configuration replayConfiguration
+	readError := connection.ReadJSON(&configuration)
+	if readError != nil {
+		go mw.LogError(context.Request, readError)
+		return false
+	}
+	response := replayResponse{
+		Succeed: true,
+		Message: "Everything ok!",
+	}
+	var engine *replay.Engine
+	if captureSession, message := checkReplayArguments(mw, context, &configuration); len(message) > 0 {
+		response.Succeed = false
+		response.Message = message
+	} else {
+		var newEngineError error
+		engine, newEngineError = replay.NewEngine(configuration.InterfaceName, captureSession.Pcap, configuration.Options)
+		if newEngineError != nil {
+			go mw.LogError(context.Request, newEngineError)
+			response.Succeed = false
+			response.Message = newEngineError.Error()
+		}
+	}
+	if !response.Succeed {
+		go mw.LogReplayStarted(context.Request, context.User.Username, configuration.CaptureName, configuration.InterfaceName, false)
+		writeError := connection.WriteJSON(response)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer engine.Close()
+
+	task, registered := mw.RegisterTask(context.Request, tasks.Replay, context.User.Username, configuration.InterfaceName, configuration.CaptureName, "")
+	if !registered {
+		go mw.LogReplayStarted(context.Request, context.User.Username, configuration.CaptureName, configuration.InterfaceName, false)
+		writeError := connection.WriteJSON(
+			replayResponse{
+				Succeed: false,
+				Message: "Something goes wrong",
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer mw.RemoveTask(context.Request, task)
+
+	writeError := connection.WriteJSON(response)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	go mw.LogReplayStarted(context.Request, context.User.Username, configuration.CaptureName, configuration.InterfaceName, true)
+
+	stopChannel := make(chan string, 1)
+	go func() {
+		var action struct {
+			Action string
+		}
+		err := connection.ReadJSON(&action)
+		if err != nil {
+			go mw.LogError(context.Request, err)
+			stopChannel <- replayConnectionLost
+			return
+		}
+		if action.Action == symbols.StopSignal {
+			stopChannel <- replayStoppedByUser
+		}
+	}()
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- engine.Run()
+	}()
+
+	var (
+		stopReason string
+		reported   uint64
+		runError   error
+		online     = true
+	)
+	sendProgress := func() {
+		sent := engine.PacketsSent()
+		task.AddPackets(sent - reported)
+		reported = sent
+		if !online {
+			return
+		}
+		writeError = connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type: symbols.ProgressResponse,
+				Payload: replayProgress{
+					Sent:  sent,
+					Total: engine.Total(),
+					Loop:  engine.Loop(),
+				},
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			online = false
+		}
+	}
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+mainLoop:
+	for {
+		select {
+		case <-ticker.C:
+			sendProgress()
+		case stopReason = <-stopChannel:
+			online = stopReason != replayConnectionLost
+			engine.Stop()
+		case reason := <-task.Killed:
+			stopReason = reason
+			engine.Stop()
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+				online = false
+			}
+		case runError = <-finished:
+			break mainLoop
+		}
+	}
+	sendProgress()
+	go mw.LogReplayStopped(context.Request, context.User.Username, configuration.CaptureName, configuration.InterfaceName, engine.PacketsSent())
+
+	result := replayResponse{
+		Succeed: true,
+		Message: replayFinished,
+	}
+	if len(stopReason) > 0 {
+		result.Message = stopReason
+	} else if runError != nil {
+		result.Succeed = false
+		result.Message = runError.Error()
+	}
+	if online {
+		writeError = connection.WriteJSON(
+			tools.ServerWSResponse{
+				Type:    symbols.FinishedResponse,
+				Payload: result,
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+	}
+	return false
+}
+
+// replayMenu renders the replay settings of a stored capture
+func replayMenu(mw *middleware.Middleware, context *middleware.Context) bool {
+	captureName := context.Request.PostFormValue(symbols.CaptureName)
+	succeed, _, _, _ := mw.UserGetCapture(context.Request, context.User.Username, captureName)
+	if !succeed {
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	succeed, _, _, _, _, _, replayPermissions, _, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getPermissionsError != nil {
+		go mw.LogError(context.Request, getPermissionsError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	connectedInterface := mw.ListNetInterfaces(context.Request)
+	var targetInterfaces []objects.InterfaceInformation
+	for _, permission := range replayPermissions {
+		i, found := connectedInterface[permission.Interface]
+		if found {
+			for _, address := range i.Addresses {
+				targetInterfaces = append(targetInterfaces,
+					objects.InterfaceInformation{
+						Name:    i.Name,
+						Address: address.IP.String(),
+					},
+				)
+			}
+		}
+	}
+	rawMenu, _ := mw.Templates.ReadFile("templates/user/packet/replay-capture.html")
+	var menu bytes.Buffer
+	executeError := template.Must(template.New("Replay").Parse(string(rawMenu))).Execute(&menu,
+		struct {
+			CaptureName      string
+			ReplayInterfaces []objects.InterfaceInformation
+			MaximumSpeed     int
+			MaximumRate      int
+			MaximumLoops     int
+		}{
+			CaptureName:      captureName,
+			ReplayInterfaces: targetInterfaces,
+			MaximumSpeed:     replay.MaximumSpeed,
+			MaximumRate:      replay.MaximumRate,
+			MaximumLoops:     replay.MaximumLoops,
+		},
+	)
+	if executeError != nil {
+		go mw.LogError(context.Request, executeError)
+		context.Redirect = symbols.UserPacketCaptures
+		return false
+	}
+	context.Body = base.NewPage("Replay", context.NavigationBar, menu.String())
+	return false
+}
@@ -0,0 +1,33 @@
+package packet
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	"github.com/shoriwe/CAPitan/internal/oui"
+	"net"
+)
+
+// isLocalAddress reports if the MAC of the frame belongs to the host, the traffic of other networks carries the
+// MAC of the router
+func isLocalAddress(ip net.IP) bool {
+	return ip != nil && (ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// labelVendors sets the vendors of the local hosts of the packet in the topology, true is returned when a label
+// changes
+func labelVendors(topology *objects.Topology, packet gopacket.Packet) bool {
+	ethernetLayer, isEthernet := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !isEthernet {
+		return false
+	}
+	flow := packet.NetworkLayer().NetworkFlow()
+	updated := false
+	if source := net.IP(flow.Src().Raw()); isLocalAddress(source) {
+		updated = topology.SetVendor(flow.Src().String(), oui.Label(ethernetLayer.SrcMAC)) || updated
+	}
+	if destination := net.IP(flow.Dst().Raw()); isLocalAddress(destination) {
+		updated = topology.SetVendor(flow.Dst().String(), oui.Label(ethernetLayer.DstMAC)) || updated
+	}
+	return updated
+}
@@ -37,11 +37,13 @@ func renderOldCapture(mw *middleware.Middleware, context *middleware.Context) bo
 			CaptureName    string
 			Description    string
 			Script         string
+			BPFFilter      string
 		}{
 			RawCaptureName: captureName,
 			CaptureName:    html.EscapeString(captureName),
 			Description:    html.EscapeString(captureSession.Description),
 			Script:         html.EscapeString(string(captureSession.FilterScript)),
+			BPFFilter:      captureSession.BPFFilter,
 		},
 	)
 	if executeError != nil {
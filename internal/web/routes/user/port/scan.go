@@ -0,0 +1,505 @@
+package port
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/data/objects"
+	port_scanner "github.com/shoriwe/CAPitan/internal/port-scanner"
+	"github.com/shoriwe/CAPitan/internal/tasks"
+	"github.com/shoriwe/CAPitan/internal/tools"
+	"github.com/shoriwe/CAPitan/internal/web/base"
+	"github.com/shoriwe/CAPitan/internal/web/middleware"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"html/template"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	upgradePortScanSession = websocket.Upgrader{
+		ReadBufferSize:    0, /* No Limit */
+		WriteBufferSize:   0, /* No Limit */
+		EnableCompression: true,
+		Subprotocols:      []string{"PortScanSession"},
+	}
+)
+
+type succeedResponse struct {
+	Succeed bool
+	Message string
+}
+
+type portScanConfiguration struct {
+	ScanName      string
+	InterfaceName string
+	Targets       string
+	Exclude       string
+	Ports         string
+	Mode          string
+	Rate          uint
+	Retries       uint
+	// Milliseconds waited for every answer
+	Timeout uint
+	Gateway string
+	// Name of an ARP scan of the user, its hosts are the targets
+	ARPScan string
+}
+
+// checkScanArguments validates the scan settings and replaces the targets with the hosts of the ARP scan when one
+// is selected, on failure the message to show the user is returned
+func checkScanArguments(mw *middleware.Middleware, context *middleware.Context, configuration *portScanConfiguration) string {
+	if len(configuration.ScanName) == 0 || tools.CheckFilledWithWhiteSpace.MatchString(configuration.ScanName) {
+		return "no scan name provided"
+	}
+	succeed, _, _, _, _, _, _, portScanInterfaces, getError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getError != nil {
+		go mw.LogError(context.Request, getError)
+		return "Something goes wrong"
+	}
+	if !succeed {
+		return "Failed to query user interfaces"
+	}
+	if _, found := portScanInterfaces[configuration.InterfaceName]; !found {
+		return "No port scan permissions for selected interface"
+	}
+	connectedInterfaces := mw.ListNetInterfaces(context.Request)
+	if connectedInterfaces == nil {
+		return "Failed to list connected interfaces"
+	}
+	if _, found := connectedInterfaces[configuration.InterfaceName]; !found {
+		return "User has permissions but the interface is not connected to the machine"
+	}
+	if len(configuration.ARPScan) == 0 {
+		return ""
+	}
+	if len(configuration.Targets) > 0 && !tools.CheckFilledWithWhiteSpace.MatchString(configuration.Targets) {
+		return "provide either a targets list or an ARP scan"
+	}
+	found, arpScan := mw.UserGetARPScan(context.Request, context.User.Username, configuration.ARPScan)
+	if !found {
+		return "ARP scan not found"
+	}
+	hosts, loaded := mw.ScanHosts(context.Request, arpScan)
+	if !loaded {
+		return "Something goes wrong"
+	}
+	if len(hosts) == 0 {
+		return "the ARP scan found no hosts"
+	}
+	targets := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		targets = append(targets, host.IP)
+	}
+	configuration.Targets = strings.Join(targets, ", ")
+	return ""
+}
+
+// sortResults orders the ports by host and number
+func sortResults(results []objects.ScannedPort) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return bytes.Compare(net.ParseIP(results[i].Host).To16(), net.ParseIP(results[j].Host).To16()) < 0
+		}
+		return results[i].Port < results[j].Port
+	})
+}
+
+func handleNewScan(mw *middleware.Middleware, context *middleware.Context) bool {
+	connection, upgradeError := upgradePortScanSession.Upgrade(context.ResponseWriter, context.Request, context.ResponseWriter.Header())
+	if upgradeError != nil {
+		go mw.LogError(context.Request, upgradeError)
+		context.Redirect = symbols.UserPortScan
+		return false
+	}
+	context.WriteBody = false
+	defer func() {
+		closeError := connection.Close()
+		if closeError != nil {
+			go mw.LogError(context.Request, closeError)
+		}
+	}()
+
+	var configuration portScanConfiguration
+	readError := connection.ReadJSON(&configuration)
+	if readError != nil {
+		go mw.LogError(context.Request, readError)
+		return false
+	}
+	response := succeedResponse{
+		Succeed: true,
+		Message: "Everything ok!",
+	}
+	if message := checkScanArguments(mw, context, &configuration); len(message) > 0 {
+		response.Succeed = false
+		response.Message = message
+	} else if !mw.ReserveUserPortScanName(context.Request, context.User.Username, configuration.ScanName) {
+		response.Succeed = false
+		response.Message = "Scan name already taken"
+	}
+	if !response.Succeed {
+		writeError := connection.WriteJSON(response)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer mw.RemoveReservedPortScanName(context.Request, context.User.Username, configuration.ScanName)
+
+	engine, engineCreationError := port_scanner.NewEngine(
+		configuration.InterfaceName,
+		configuration.Targets,
+		configuration.Exclude,
+		configuration.Ports,
+		port_scanner.Options{
+			Mode:    configuration.Mode,
+			Rate:    configuration.Rate,
+			Retries: configuration.Retries,
+			Timeout: time.Duration(configuration.Timeout) * time.Millisecond,
+			Gateway: configuration.Gateway,
+		},
+	)
+	if engineCreationError != nil {
+		go mw.LogError(context.Request, engineCreationError)
+		writeError := connection.WriteJSON(
+			succeedResponse{
+				Succeed: false,
+				Message: engineCreationError.Error(),
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer engine.Close()
+
+	task, registered := mw.RegisterTask(context.Request, tasks.PortScan, context.User.Username, configuration.InterfaceName, configuration.ScanName, "")
+	if !registered {
+		writeError := connection.WriteJSON(
+			succeedResponse{
+				Succeed: false,
+				Message: "Something goes wrong",
+			},
+		)
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+		}
+		return false
+	}
+	defer mw.RemoveTask(context.Request, task)
+
+	writeError := connection.WriteJSON(response)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+
+	stopChannel := make(chan bool, 1)
+	go func() {
+		var action struct {
+			Action string
+		}
+		err := connection.ReadJSON(&action)
+		if err != nil {
+			go mw.LogError(context.Request, err)
+			stopChannel <- true
+			return
+		}
+		if action.Action == symbols.StopSignal {
+			stopChannel <- true
+		}
+	}()
+
+	var results []objects.ScannedPort
+
+	// flushResults sends to the client the open ports found since the last call, false means the connection is
+	// lost. Closed and filtered ports only count in the progress
+	flushResults := func() bool {
+		for {
+			select {
+			case result := <-engine.Results:
+				task.AddPackets(1)
+				if result.State != port_scanner.OpenState && result.State != port_scanner.OpenFilteredState {
+					continue
+				}
+				scannedPort := objects.ScannedPort{
+					Host:     result.Host,
+					Port:     result.Port,
+					Protocol: result.Protocol,
+					State:    result.State,
+				}
+				results = append(results, scannedPort)
+				writeError = connection.WriteJSON(tools.ServerWSResponse{
+					Type:    symbols.PortResponse,
+					Payload: scannedPort,
+				})
+				if writeError != nil {
+					go mw.LogError(context.Request, writeError)
+					return false
+				}
+			default:
+				return true
+			}
+		}
+	}
+
+	sendProgress := func(responseType string) bool {
+		writeError = connection.WriteJSON(tools.ServerWSResponse{
+			Type:    responseType,
+			Payload: engine.Progress(),
+		})
+		if writeError != nil {
+			go mw.LogError(context.Request, writeError)
+			return false
+		}
+		return true
+	}
+
+	tick := time.Tick(500 * time.Millisecond)
+
+	engine.Start()
+
+	start := time.Now()
+mainLoop:
+	for {
+		select {
+		case <-stopChannel:
+			break mainLoop
+		case reason := <-task.Killed:
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.KilledResponse,
+					Payload: reason,
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+				return false
+			}
+			break mainLoop
+		case engineError := <-engine.ErrorChannel:
+			go mw.LogError(context.Request, engineError)
+			writeError = connection.WriteJSON(
+				tools.ServerWSResponse{
+					Type:    symbols.ErrorResponse,
+					Payload: engineError.Error(),
+				},
+			)
+			if writeError != nil {
+				go mw.LogError(context.Request, writeError)
+			}
+			return false
+		case <-engine.Done:
+			// Every result is already queued, send the remaining ports and the final counters
+			if !flushResults() || !sendProgress(symbols.FinishedResponse) {
+				return false
+			}
+			break mainLoop
+		case <-tick:
+			if !flushResults() || !sendProgress(symbols.ProgressResponse) {
+				return false
+			}
+		}
+	}
+	engine.Close()
+	if !flushResults() {
+		return false
+	}
+	finish := time.Now()
+
+	// Send to the client that it is safe to close the connection
+	writeError = connection.WriteJSON(
+		struct {
+			Succeed bool
+		}{
+			Succeed: true,
+		},
+	)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+
+	sortResults(results)
+	mw.SavePortScan(
+		context.Request,
+		context.User.Username,
+		configuration.ScanName,
+		configuration.InterfaceName,
+		configuration.Targets,
+		configuration.Exclude,
+		configuration.Ports,
+		configuration.Mode,
+		results,
+		start, finish,
+	)
+	return false
+}
+
+func renderController(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, _, _, _, _, _, _, portScanPermissions, getPermissionsError := mw.GetUserInterfacePermissions(context.User.Username)
+	if getPermissionsError != nil {
+		go mw.LogError(context.Request, getPermissionsError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	connectedInterface := mw.ListNetInterfaces(context.Request)
+	var targetInterfaces []objects.InterfaceInformation
+	for _, permission := range portScanPermissions {
+		i, found := connectedInterface[permission.Interface]
+		if found {
+			for _, address := range i.Addresses {
+				targetInterfaces = append(targetInterfaces,
+					objects.InterfaceInformation{
+						Name:    i.Name,
+						Address: address.IP.String(),
+					},
+				)
+			}
+		}
+	}
+	// The ARP scans of the user can seed the targets
+	_, arpScans := mw.ListUserARPScans(context.Request, context.User.Username)
+	var arpScanNames []string
+	for _, arpScan := range arpScans {
+		arpScanNames = append(arpScanNames, arpScan.Name)
+	}
+	sort.Strings(arpScanNames)
+
+	renderTemplate, _ := mw.Templates.ReadFile("templates/user/port/scan.html")
+	var output bytes.Buffer
+	templateExecutionError := template.Must(template.New("Port scan").Parse(string(renderTemplate))).Execute(
+		&output,
+		struct {
+			PortScanInterfaces []objects.InterfaceInformation
+			ARPScans           []string
+			SelectedARPScan    string
+			MaximumRate        int
+			MaximumRetries     int
+			MaximumTimeout     int64
+			Modes              []string
+		}{
+			PortScanInterfaces: targetInterfaces,
+			ARPScans:           arpScanNames,
+			SelectedARPScan:    context.Request.FormValue(symbols.ARPScan),
+			MaximumRate:        port_scanner.MaximumRate,
+			MaximumRetries:     port_scanner.MaximumRetries,
+			MaximumTimeout:     port_scanner.MaximumTimeout.Milliseconds(),
+			Modes:              port_scanner.Modes(),
+		},
+	)
+	if templateExecutionError != nil {
+		go mw.LogError(context.Request, templateExecutionError)
+		return false
+	}
+	context.Body = base.NewPage("New port scan", context.NavigationBar, output.String())
+	return false
+}
+
+func viewScan(mw *middleware.Middleware, context *middleware.Context) bool {
+	scanName := context.Request.PostFormValue(symbols.ScanName)
+	succeed, scanSession := mw.UserGetPortScan(context.Request, context.User.Username, scanName)
+	if !succeed {
+		context.Redirect = symbols.UserPortScan
+		return false
+	}
+	results, loaded := mw.PortScanResults(context.Request, scanSession)
+	if !loaded {
+		context.Redirect = symbols.UserPortScan
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/port/view-scan.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Port scan view").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Scan    *objects.PortScanSession
+			Results []objects.ScannedPort
+		}{
+			Scan:    scanSession,
+			Results: results,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("View port scan", context.NavigationBar, body.String())
+	return false
+}
+
+func listScans(mw *middleware.Middleware, context *middleware.Context) bool {
+	succeed, portScans := mw.ListUserPortScans(context.Request, context.User.Username)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	templateContents, _ := mw.Templates.ReadFile("templates/user/port/scan-list.html")
+	var body bytes.Buffer
+	err := template.Must(template.New("Port scan list").Parse(string(templateContents))).Execute(
+		&body,
+		struct {
+			Scans []*objects.PortScanSession
+		}{
+			Scans: portScans,
+		},
+	)
+	if err != nil {
+		context.Redirect = symbols.Dashboard
+		go mw.LogError(context.Request, err)
+		return false
+	}
+	context.Body = base.NewPage("Port scans", context.NavigationBar, body.String())
+	return false
+}
+
+func downloadScan(mw *middleware.Middleware, context *middleware.Context) bool {
+	scanName := context.Request.PostFormValue(symbols.ScanName)
+	succeed, scanSession := mw.UserGetPortScan(context.Request, context.User.Username, scanName)
+	if !succeed {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	results, loaded := mw.PortScanResults(context.Request, scanSession)
+	if !loaded {
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	export, marshalError := json.Marshal(results)
+	if marshalError != nil {
+		go mw.LogError(context.Request, marshalError)
+		context.Redirect = symbols.Dashboard
+		return false
+	}
+	context.ResponseWriter.Header().Add("Content-Disposition", "attachment; filename=\"port-scan.json\"")
+	_, writeError := context.ResponseWriter.Write(export)
+	if writeError != nil {
+		go mw.LogError(context.Request, writeError)
+		return false
+	}
+	context.WriteBody = false
+	return false
+}
+
+func PortScan(mw *middleware.Middleware, context *middleware.Context) bool {
+	switch context.Request.FormValue(actions.Action) {
+	case actions.New:
+		return handleNewScan(mw, context)
+	case actions.View:
+		return viewScan(mw, context)
+	case actions.List:
+		return listScans(mw, context)
+	case actions.Download:
+		return downloadScan(mw, context)
+	}
+	return renderController(mw, context)
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long the open requests have to finish once the server is asked to stop
+const shutdownTimeout = 10 * time.Second
+
+// Serve runs the jobs of the scheduler and listens on the address until the process is interrupted or terminated,
+// then it stops accepting connections and the scheduler stops starting jobs
+func Serve(address string, handler http.Handler, jobScheduler *scheduler.Scheduler) error {
+	server := &http.Server{
+		Addr:    address,
+		Handler: handler,
+	}
+	jobScheduler.Start()
+	defer jobScheduler.Stop()
+	serveErrors := make(chan error, 1)
+	go func() {
+		serveErrors <- server.ListenAndServe()
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	select {
+	case serveError := <-serveErrors:
+		return serveError
+	case <-signals:
+	}
+	shutdownContext, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	shutdownError := server.Shutdown(shutdownContext)
+	if serveError := <-serveErrors; !errors.Is(serveError, http.ErrServerClosed) {
+		return serveError
+	}
+	return shutdownError
+}
@@ -0,0 +1,85 @@
+function newSeparator() {
+    const separator = document.createElement("span");
+    separator.style.width = "1vw";
+    return separator;
+}
+
+function newText(className, text, width) {
+    const result = document.createElement("h3");
+    result.classList.add(className);
+    result.innerText = text;
+    result.style.width = width;
+    return result;
+}
+
+function killTask(id) {
+    const reason = document.getElementById("kill-reason").value;
+    const formBody = [
+        "task=" + encodeURIComponent(id),
+        "reason=" + encodeURIComponent(reason)
+    ];
+    fetch(
+        "/admin/tasks?action=kill",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(_ => {
+        refreshTasks();
+    });
+}
+
+function renderTask(task) {
+    const entry = document.createElement("div");
+    entry.classList.add("list-entry");
+
+    let description = task.Name;
+    if (task.Targets !== "") {
+        description = task.Targets;
+    }
+
+    const killButton = document.createElement("button");
+    killButton.classList.add("red-button");
+    killButton.innerText = "Kill";
+    killButton.onclick = function () {
+        killTask(task.Id);
+    }
+
+    entry.append(newText("purple-text", task.Kind, "12%"));
+    entry.append(newSeparator());
+    entry.append(newText("green-text", task.Username, "12%"));
+    entry.append(newSeparator());
+    entry.append(newText("black-text", task.Interface, "12%"));
+    entry.append(newSeparator());
+    entry.append(newText("blue-text", description, "24%"));
+    entry.append(newSeparator());
+    entry.append(newText("black-text", task.Duration, "12%"));
+    entry.append(newSeparator());
+    entry.append(newText("black-text", task.Packets + " packets", "12%"));
+    entry.append(newSeparator());
+    entry.append(killButton);
+    return entry;
+}
+
+function refreshTasks() {
+    fetch("/admin/tasks?action=list").then(response => {
+        return response.json();
+    }).then(tasks => {
+        const tasksList = document.getElementById("tasks-list");
+        tasksList.innerHTML = "";
+        if (tasks === null || tasks.length === 0) {
+            document.getElementById("no-tasks").style.display = "block";
+            return;
+        }
+        document.getElementById("no-tasks").style.display = "none";
+        for (const task of tasks) {
+            tasksList.append(renderTask(task));
+        }
+    });
+}
+
+refreshTasks();
+setInterval(refreshTasks, 2000);
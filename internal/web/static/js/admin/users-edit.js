@@ -235,4 +235,136 @@ function deleteARPSpoofInterface(id) {
             reloadPage("arp-spoof-permissions");
         }
     );
+}
+
+function addInjectInterface(id) {
+    const i = id.replace("inject-interface-", "");
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=add-inject-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("inject-permissions");
+        }
+    );
+}
+
+function deleteInjectInterface(id) {
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    const i = id.replace("inject-interface-to-delete-", "");
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=delete-inject-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("inject-permissions");
+        }
+    );
+}
+
+function addReplayInterface(id) {
+    const i = id.replace("replay-interface-", "");
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=add-replay-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("replay-permissions");
+        }
+    );
+}
+
+function deleteReplayInterface(id) {
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    const i = id.replace("replay-interface-to-delete-", "");
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=delete-replay-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("replay-permissions");
+        }
+    );
+}
+
+function addPortScanInterface(id) {
+    const i = id.replace("port-scan-interface-", "");
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=add-port-scan-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("port-scan-permissions");
+        }
+    );
+}
+
+function deletePortScanInterface(id) {
+    const username = document.getElementById("resubmit-username").value;
+    const formBody = [];
+    const i = id.replace("port-scan-interface-to-delete-", "");
+    formBody.push("username=" + encodeURIComponent(username));
+    formBody.push("interface=" + encodeURIComponent(i));
+    fetch(
+        "/admin/user?action=delete-port-scan-interface",
+        {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/x-www-form-urlencoded'
+            },
+            body: formBody.join("&")
+        }
+    ).then(
+        _ => {
+            reloadPage("port-scan-permissions");
+        }
+    );
 }
\ No newline at end of file
@@ -19,27 +19,68 @@ function stopScan() {
     }
 }
 
+const hostEntries = {};
+
+function addText(entry, className, text) {
+    const span = document.createElement("span");
+    span.style.width = "1%";
+    const element = document.createElement("h3");
+    element.classList.add(className);
+    element.innerText = text;
+    if (entry.children.length > 0) {
+        entry.append(span);
+    }
+    entry.append(element);
+}
+
+// Hosts seen again by the passive method replace their previous entry
 function addHost(hostInformation) {
     const results = document.getElementById("results");
     const entry = document.createElement("div");
     entry.classList.add("list-entry");
-    const ip = document.createElement("h3")
-    ip.classList.add("blue-text");
-    ip.innerText = hostInformation.IP;
-    const mac = document.createElement("h3");
-    mac.classList.add("black-text");
-    mac.innerText = hostInformation.MAC;
-    const span = document.createElement("span");
-    span.style.width = "1%";
-    entry.append(ip);
-    entry.append(span);
-    entry.append(mac);
-    results.append(entry);
+    addText(entry, "blue-text", hostInformation.IP);
+    addText(entry, "black-text", hostInformation.MAC);
+    if (hostInformation.Vendor) {
+        addText(entry, "green-text", hostInformation.Vendor);
+    } else if (hostInformation.LocallyAdministered) {
+        addText(entry, "red-text", "Locally administered");
+    }
+    for (const hostname of hostInformation.Hostnames || []) {
+        addText(entry, "purple-text", hostname);
+    }
+    if (hostInformation.Sources && hostInformation.Sources.length > 0) {
+        addText(entry, "green-text", hostInformation.Sources.join(", "));
+    }
+    const previous = hostEntries[hostInformation.IP];
+    if (previous !== undefined) {
+        previous.replaceWith(entry);
+    } else {
+        results.append(entry);
+    }
+    hostEntries[hostInformation.IP] = entry;
+    if (document.getElementById("method").value === "passive") {
+        document.getElementById("progress").innerText = Object.keys(hostEntries).length + " hosts seen";
+    }
+}
+
+function updateProgress(progress) {
+    const total = progress.Total > 0 ? progress.Total : "?";
+    document.getElementById("progress").innerText = progress.Answered + " answered, " + progress.Sent + "/" + total + " requested";
 }
 
 async function startScan() {
     const scanName = document.getElementById("scan-name").value;
     const script = document.getElementById("hosts-script").value;
+    const targets = document.getElementById("targets").value;
+    const exclude = document.getElementById("exclude").value;
+    const rate = parseInt(document.getElementById("rate").value, 10) || 100;
+    const retries = parseInt(document.getElementById("retries").value, 10) || 0;
+    const timeout = parseInt(document.getElementById("timeout").value, 10) || 1000;
+    const method = document.getElementById("method").value;
+    const ports = document.getElementById("ports").value.split(/[\s,]+/).filter(port => port.length > 0).map(port => parseInt(port, 10));
+    const gateway = document.getElementById("gateway").value;
+    const resolveNames = document.getElementById("resolve-names").checked;
+    const alerts = document.getElementById("alerts").checked;
     const errorMessage = document.getElementById("error-message");
     const setupMenu = document.getElementById("setup-menu");
     const resultsMenu = document.getElementById("results-menu");
@@ -53,6 +94,16 @@ async function startScan() {
                 ScanName: scanName,
                 InterfaceName: selectedInterface,
                 Script: script,
+                Targets: targets,
+                Exclude: exclude,
+                Rate: rate,
+                Retries: retries,
+                Timeout: timeout,
+                Method: method,
+                Ports: ports,
+                Gateway: gateway,
+                ResolveNames: resolveNames,
+                Alerts: alerts,
             }
         );
         connection.send(configuration);
@@ -74,9 +125,33 @@ async function startScan() {
                         case "stop":
                             // TODO: Implement me
                             break;
+                        case "killed":
+                            errorMessage.style.display = "block";
+                            errorMessage.innerText = data.Payload;
+                            // The scan is saved by the server, wait for it
+                            connection.onmessage = function (message) {
+                                const data = JSON.parse(message.data);
+                                if (data.Succeed) {
+                                    connection.close(1000);
+                                }
+                            };
+                            break;
                         case "host":
                             addHost(data.Payload);
                             break;
+                        case "progress":
+                            updateProgress(data.Payload);
+                            break;
+                        case "finished":
+                            updateProgress(data.Payload);
+                            // The scan is saved by the server, wait for it
+                            connection.onmessage = function (message) {
+                                const data = JSON.parse(message.data);
+                                if (data.Succeed) {
+                                    document.location.href = "/arp/scan?action=list";
+                                }
+                            };
+                            break;
                     }
                 }
             } else {
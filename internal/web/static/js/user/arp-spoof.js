@@ -1,16 +1,127 @@
 let selectedInterface = undefined;
+let selectedScan = "";
 let connection = undefined;
+let dnsAnswers = 0;
+
+function selectScan(id) {
+    selectedScan = id;
+    document.getElementById("scan").textContent = id === "" ? "No ARP scan" : id;
+}
+
+function addTarget() {
+    const target = document.getElementById("new-target").value;
+    connection.send(JSON.stringify({Action: "ADD", Target: target}));
+    document.getElementById("new-target").value = "";
+}
+
+function removeTarget(target) {
+    connection.send(JSON.stringify({Action: "REMOVE", Target: target}));
+}
+
+function renderTargets(update) {
+    const message = document.getElementById("targets-message");
+    message.innerText = update.Message;
+    message.className = update.Succeed ? "black-text" : "red-text";
+    const targets = document.getElementById("targets");
+    targets.innerHTML = "";
+    for (const target of update.Targets) {
+        const entry = document.createElement("div");
+        entry.className = "list-entry";
+        const ip = document.createElement("h3");
+        ip.className = "purple-text";
+        ip.innerText = target;
+        const remove = document.createElement("button");
+        remove.className = "red-button";
+        remove.innerText = "Remove";
+        remove.onclick = function () {
+            removeTarget(target);
+        };
+        entry.appendChild(ip);
+        entry.appendChild(document.createElement("span")).style.width = "1vw";
+        entry.appendChild(remove);
+        targets.appendChild(entry);
+    }
+}
+
+// dnsRules parses one rule per line, the kind is optional and defaults to exact
+function dnsRules() {
+    const rules = [];
+    for (const line of document.getElementById("dns-rules").value.split("\n")) {
+        const fields = line.trim().split(/\s+/).filter(field => field.length > 0);
+        if (fields.length === 2) {
+            rules.push({Kind: "exact", Pattern: fields[0], Address: fields[1]});
+        } else if (fields.length === 3) {
+            rules.push({Kind: fields[0], Pattern: fields[1], Address: fields[2]});
+        }
+    }
+    return rules;
+}
+
+function renderDNSAnswer(answer) {
+    dnsAnswers++;
+    const message = document.getElementById("dns-answers-message");
+    message.innerText = "Spoofed DNS answers: " + dnsAnswers;
+    message.style.display = "block";
+    const entry = document.createElement("div");
+    entry.className = "list-entry";
+    const description = document.createElement("h3");
+    description.className = "black-text";
+    description.innerText = answer.Client + " " + answer.Type + " " + answer.Name + " -> " + answer.Address;
+    entry.appendChild(description);
+    const list = document.getElementById("dns-answers");
+    list.insertBefore(entry, list.firstChild);
+}
 
 function selectARPSpoofInterface(id) {
     selectedInterface = id;
     document.getElementById("spoof").textContent = id;
 }
 
-function testInput(ip, gateway, arpInterface) {
+function toggleOneWay() {
+    const oneWay = document.getElementById("one-way");
+    oneWay.checked = !oneWay.checked;
+    const oneWayCheckbox = document.getElementById("one-way-checkbox");
+    if (oneWay.checked) {
+        oneWayCheckbox.classList.add("unchecked-checkbox");
+        oneWayCheckbox.classList.remove("checked-checkbox");
+        oneWayCheckbox.textContent = "One-way";
+    } else {
+        oneWayCheckbox.classList.remove("unchecked-checkbox");
+        oneWayCheckbox.classList.add("checked-checkbox");
+        oneWayCheckbox.textContent = "Two-way";
+    }
+}
+
+function toggleRequestMode() {
+    const requestMode = document.getElementById("request-mode");
+    requestMode.checked = !requestMode.checked;
+    const requestModeCheckbox = document.getElementById("request-mode-checkbox");
+    if (requestMode.checked) {
+        requestModeCheckbox.classList.add("unchecked-checkbox");
+        requestModeCheckbox.classList.remove("checked-checkbox");
+        requestModeCheckbox.textContent = "Gratuitous requests";
+    } else {
+        requestModeCheckbox.classList.remove("unchecked-checkbox");
+        requestModeCheckbox.classList.add("checked-checkbox");
+        requestModeCheckbox.textContent = "Replies";
+    }
+}
+
+function selectedMode() {
+    if (document.getElementById("request-mode").checked) {
+        return "request";
+    }
+    return "reply";
+}
+
+function testInput(ip, gateway, arpInterface, interval) {
     const formBody = [
         "ip=" + encodeURIComponent(ip),
         "interface=" + encodeURIComponent(arpInterface),
-        "gateway=" + encodeURIComponent(gateway)
+        "gateway=" + encodeURIComponent(gateway),
+        "scan-name=" + encodeURIComponent(selectedScan),
+        "mode=" + encodeURIComponent(selectedMode()),
+        "interval=" + encodeURIComponent(interval)
     ];
     return fetch(
         "/arp/spoof?action=test",
@@ -26,13 +137,20 @@ function testInput(ip, gateway, arpInterface) {
     });
 }
 
+function showSpoofMessage(message) {
+    document.getElementById("spoof-message").innerText = message;
+    document.getElementById("spoof-message").style.display = "block";
+}
+
 function closeConnection() {
-    connection.send(JSON.stringify({Action: "STOP"}))
-    connection.close(1000);
-    document.location.href = "/arp/spoof"
+    // The server answers with the result of the ARP cache restoration before closing
+    connection.send(JSON.stringify({Action: "STOP"}));
+    document.getElementById("stop-button").style.display = "none";
+    document.getElementById("add-target-container").style.display = "none";
+    showSpoofMessage("Restoring ARP caches...");
 }
 
-async function setupConnection(ip, gateway) {
+async function setupConnection(ip, gateway, interval) {
     const target = "ws://" + document.location.host + "/arp/spoof?action=spoof";
     connection = new WebSocket(target, "ARPSpoofSession");
 
@@ -40,8 +158,14 @@ async function setupConnection(ip, gateway) {
         const configuration = JSON.stringify(
             {
                 TargetIP: ip,
+                ScanName: selectedScan,
                 Gateway: gateway,
                 InterfaceName: selectedInterface,
+                Mode: selectedMode(),
+                OneWay: document.getElementById("one-way").checked,
+                Interval: interval,
+                DNSRules: dnsRules(),
+                DNSScript: document.getElementById("dns-script").value,
             }
         );
         connection.send(configuration);
@@ -51,9 +175,32 @@ async function setupConnection(ip, gateway) {
             if (data.Succeed) {
                 document.getElementById("setup-container").style.display = "none";
                 document.getElementById("spoof-container").style.display = "block";
-                document.getElementById("spoofed-ip").innerText = ip;
                 document.getElementById("spoofed-gateway").innerText = gateway;
                 document.getElementById("spoofed-interface").innerText = selectedInterface;
+                connection.onmessage = function (message) {
+                    const data = JSON.parse(message.data);
+                    switch (data.Type) {
+                        case "targets":
+                            renderTargets(data.Payload);
+                            break;
+                        case "dns-answer":
+                            renderDNSAnswer(data.Payload);
+                            break;
+                        case "killed":
+                        case "error":
+                            document.getElementById("stop-button").style.display = "none";
+                            showSpoofMessage(data.Payload);
+                            break;
+                        case "restore":
+                            document.getElementById("stop-button").style.display = "none";
+                            document.getElementById("add-target-container").style.display = "none";
+                            document.getElementById("restore-message").innerText = data.Payload.Message;
+                            document.getElementById("restore-message").className = data.Payload.Succeed ? "green-text" : "red-text";
+                            document.getElementById("restore-message").style.display = "block";
+                            connection.close(1000);
+                            break;
+                    }
+                };
             } else {
                 document.getElementById("error-message-container").style.display = "block";
                 document.getElementById("error-message-container").innerText = data.Message;
@@ -66,12 +213,13 @@ async function setupConnection(ip, gateway) {
 async function startSpoof() {
     const ip = document.getElementById("ip").value;
     const gateway = document.getElementById("gateway").value;
+    const interval = parseInt(document.getElementById("interval").value, 10) || 1000;
 
-    const result = await testInput(ip, gateway, selectedInterface);
+    const result = await testInput(ip, gateway, selectedInterface, interval);
     if (!result.Succeed) {
         document.getElementById("error-message").innerText = result.Message;
         document.getElementById("error-message-container").style.display = "block";
         return;
     }
-    await setupConnection(ip, gateway);
+    await setupConnection(ip, gateway, interval);
 }
\ No newline at end of file
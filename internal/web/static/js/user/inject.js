@@ -0,0 +1,88 @@
+let selectedInterface = undefined;
+let connection = undefined;
+let framesSent = 0;
+
+function selectInjectInterface(id) {
+    selectedInterface = id;
+    document.getElementById("inject").textContent = id;
+}
+
+function showErrorMessage(message) {
+    const errorMessage = document.getElementById("error-message");
+    errorMessage.innerText = message;
+    errorMessage.style.display = "block";
+}
+
+function stopInjection() {
+    connection.send(JSON.stringify({Action: "STOP"}));
+    document.getElementById("stop-button").style.display = "none";
+}
+
+function addFrame(frame) {
+    framesSent++;
+    document.getElementById("frames-sent").innerText = framesSent + " frames";
+    const entry = document.createElement("div");
+    entry.className = "list-entry";
+    const time = document.createElement("h3");
+    time.className = "blue-text";
+    time.innerText = new Date(frame.Time).toLocaleTimeString();
+    const summary = document.createElement("h3");
+    summary.className = "black-text";
+    summary.innerText = frame.Summary + " (" + frame.Length + " bytes)";
+    entry.appendChild(time);
+    entry.appendChild(document.createElement("span")).style.width = "1vw";
+    entry.appendChild(summary);
+    const frames = document.getElementById("frames");
+    frames.insertBefore(entry, frames.firstChild);
+}
+
+function startInjection() {
+    const script = document.getElementById("inject-script").value;
+    const rate = parseInt(document.getElementById("rate").value, 10) || 10;
+    const maxFrames = parseInt(document.getElementById("max-frames").value, 10) || 1000;
+
+    const target = "ws://" + document.location.host + "/inject?action=start";
+    connection = new WebSocket(target, "InjectSession");
+
+    connection.onopen = function (_) {
+        connection.send(JSON.stringify(
+            {
+                InterfaceName: selectedInterface,
+                Script: script,
+                Rate: rate,
+                MaxFrames: maxFrames,
+            }
+        ));
+        connection.onmessage = function (message) {
+            const data = JSON.parse(message.data);
+            if (!data.Succeed) {
+                showErrorMessage(data.Message);
+                connection.close(1000);
+                return;
+            }
+            document.getElementById("error-message").style.display = "none";
+            document.getElementById("setup-menu").style.display = "none";
+            document.getElementById("results-menu").style.display = "block";
+            document.getElementById("active-interface").innerText = selectedInterface;
+            connection.onmessage = function (message) {
+                const data = JSON.parse(message.data);
+                switch (data.Type) {
+                    case "frame":
+                        addFrame(data.Payload);
+                        break;
+                    case "killed":
+                        showErrorMessage(data.Payload);
+                        break;
+                    case "finished":
+                        document.getElementById("stop-button").style.display = "none";
+                        const finished = document.getElementById("finished-message");
+                        finished.innerText = data.Payload.Message;
+                        finished.className = data.Payload.Succeed ? "green-text" : "red-text";
+                        finished.style.display = "block";
+                        connection.close(1000);
+                        break;
+                }
+            };
+        };
+    };
+}
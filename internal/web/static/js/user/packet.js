@@ -28,6 +28,56 @@ function togglePromiscuous() {
     }
 }
 
+function toggleIntercept() {
+    const intercept = document.getElementById("intercept");
+    intercept.checked = !intercept.checked;
+    const interceptCheckbox = document.getElementById("intercept-checkbox");
+    if (intercept.checked) {
+        interceptCheckbox.classList.remove("unchecked-checkbox");
+        interceptCheckbox.classList.add("checked-checkbox");
+        interceptCheckbox.textContent = "Intercept";
+        document.getElementById("intercept-container").style.display = "block";
+    } else {
+        interceptCheckbox.classList.add("unchecked-checkbox");
+        interceptCheckbox.classList.remove("checked-checkbox");
+        interceptCheckbox.textContent = "Capture only";
+        document.getElementById("intercept-container").style.display = "none";
+    }
+}
+
+function toggleInterceptOneWay() {
+    const oneWay = document.getElementById("intercept-one-way");
+    oneWay.checked = !oneWay.checked;
+    const oneWayCheckbox = document.getElementById("intercept-one-way-checkbox");
+    if (oneWay.checked) {
+        oneWayCheckbox.classList.add("unchecked-checkbox");
+        oneWayCheckbox.classList.remove("checked-checkbox");
+        oneWayCheckbox.textContent = "One way";
+    } else {
+        oneWayCheckbox.classList.remove("unchecked-checkbox");
+        oneWayCheckbox.classList.add("checked-checkbox");
+        oneWayCheckbox.textContent = "Two way";
+    }
+}
+
+function interceptConfiguration() {
+    if (!document.getElementById("intercept").checked) {
+        return null;
+    }
+    return {
+        Targets: document.getElementById("intercept-targets").value,
+        Gateway: document.getElementById("intercept-gateway").value,
+        OneWay: document.getElementById("intercept-one-way").checked,
+        Interval: parseInt(document.getElementById("intercept-interval").value) || 0
+    };
+}
+
+function showInterceptMessage(payload) {
+    const interceptMessage = document.getElementById("intercept-message");
+    interceptMessage.innerText = payload.Message;
+    interceptMessage.style.display = "block";
+}
+
 async function testNewCaptureInformation() {
     const captureName = document.getElementById("capture-name");
     const description = document.getElementById("description");
@@ -207,7 +257,17 @@ async function updateTopology(data) {
             top: 'top',
             left: 'left',
         },
-        tooltip: {},
+        tooltip: {
+            // Hosts with fingerprints list the guesses of their system and the traffic they were made from
+            formatter: function (params) {
+                if (params.dataType !== 'node' || !params.data.fingerprints) {
+                    return params.name;
+                }
+                return params.name + params.data.fingerprints.map(function (guess) {
+                    return "<br>" + guess.Method + ": " + guess.Label;
+                }).join("");
+            }
+        },
         series: [
             {
                 name: 'Network Topology',
@@ -225,7 +285,17 @@ async function updateTopology(data) {
                 label: {
                     show: true,
                     position: 'right',
-                    formatter: '{b}'
+                    // Local hosts are labeled with the vendor of their MAC and hosts with the guess of their system
+                    formatter: function (params) {
+                        let label = params.name;
+                        if (params.data.vendor) {
+                            label += "\n" + params.data.vendor;
+                        }
+                        if (params.data.os) {
+                            label += "\n" + params.data.os;
+                        }
+                        return label;
+                    }
                 },
                 labelLayout: {
                     hideOverlap: true
@@ -381,6 +451,61 @@ async function updateGraphs(data) {
     }
 }
 
+function handleCaptureEvent(message) {
+    const quotaMessage = document.getElementById("quota-message");
+    const updateData = JSON.parse(message.data);
+    switch (updateData.Type) {
+        case "packet":
+            loadPacket(updateData.Payload);
+            break;
+        case "stream":
+            loadStream(updateData.Payload);
+            break;
+        case "update-graphs":
+            updateGraphs(updateData.Payload);
+            break;
+        case "targets":
+        case "restore":
+            showInterceptMessage(updateData.Payload);
+            break;
+        case "watchers":
+            showWatchers(updateData.Payload);
+            break;
+        case "quota-warning":
+            quotaMessage.innerText = `Storage quota almost reached: ${updateData.Payload.Used} of ${updateData.Payload.Limit}`;
+            quotaMessage.style.display = "block";
+            break;
+        case "quota-exceeded":
+        case "killed":
+            quotaMessage.innerText = updateData.Payload;
+            quotaMessage.style.display = "block";
+            // The server stops the capture by itself, wait for it to be saved
+            connection.onmessage = function (message) {
+                const data = JSON.parse(message.data);
+                if (data.Succeed) {
+                    connection.close(1000);
+                }
+            };
+            break;
+    }
+}
+
+function showWatchers(watchers) {
+    const watchersMessage = document.getElementById("watchers-message");
+    if (watchers.length === 0) {
+        watchersMessage.style.display = "none";
+        return;
+    }
+    watchersMessage.innerText = "Watching: " + watchers.join(", ");
+    watchersMessage.style.display = "block";
+}
+
+function showCaptureSession(captureName) {
+    document.getElementById("new-config-container").style.display = "none";
+    document.getElementById("capture-session-container").style.display = "block"
+    document.getElementById("title").innerText = captureName;
+}
+
 async function newCapture() {
     const errorMessage = document.getElementById("error-message");
 
@@ -394,6 +519,7 @@ async function newCapture() {
         const captureName = document.getElementById("capture-name");
         const description = document.getElementById("description");
         const filterScript = document.getElementById("filter-script");
+        const bpfFilter = document.getElementById("bpf-filter");
         const promiscuousCheckbox = document.getElementById("promiscuous");
         const configuration = JSON.stringify(
             {
@@ -401,7 +527,9 @@ async function newCapture() {
                 CaptureName: captureName.value,
                 Description: description.value,
                 Script: filterScript.value,
-                Promiscuous: promiscuousCheckbox.checked
+                BPFFilter: bpfFilter.value,
+                Promiscuous: promiscuousCheckbox.checked,
+                Intercept: interceptConfiguration()
             }
         );
         connection.send(configuration);
@@ -409,24 +537,37 @@ async function newCapture() {
         connection.onmessage = function (message) {
             const data = JSON.parse(message.data);
             if (data.Succeed) {
-                document.getElementById("new-config-container").style.display = "none";
-                document.getElementById("capture-session-container").style.display = "block"
-                document.getElementById("title").innerText = captureName.value;
-
-                connection.onmessage = function (message) {
-                    const updateData = JSON.parse(message.data);
-                    switch (updateData.Type) {
-                        case "packet":
-                            loadPacket(updateData.Payload);
-                            break;
-                        case "stream":
-                            loadStream(updateData.Payload);
-                            break;
-                        case "update-graphs":
-                            updateGraphs(updateData.Payload);
-                            break;
-                    }
-                };
+                showCaptureSession(captureName.value);
+                connection.onmessage = handleCaptureEvent;
+            } else {
+                errorMessage.innerHTML = data.Message;
+                errorMessage.style.display = "block";
+            }
+        };
+    }
+}
+
+// attachCapture receives what a running capture already captured and then its live updates, the captures of other
+// users are only watched
+async function attachCapture(captureName, owner) {
+    const errorMessage = document.getElementById("error-message");
+
+    const target = "ws://" + document.location.host + "/packet?action=attach";
+    connection = new WebSocket(target, "PacketCaptureSession");
+
+    connection.onopen = function (_) {
+        connection.send(
+            JSON.stringify({
+                CaptureName: captureName,
+                Owner: owner
+            })
+        );
+
+        connection.onmessage = function (message) {
+            const data = JSON.parse(message.data);
+            if (data.Succeed) {
+                showCaptureSession(captureName);
+                connection.onmessage = handleCaptureEvent;
             } else {
                 errorMessage.innerHTML = data.Message;
                 errorMessage.style.display = "block";
@@ -477,6 +618,13 @@ function stopCapture() {
     // Wait for the response of the server to properly close the connection
     connection.onmessage = function (message) {
         const data = JSON.parse(message.data);
+        if (data.Type !== undefined) {
+            // Intercepts restore the caches of their targets before the capture is saved
+            if (data.Type === "restore") {
+                showInterceptMessage(data.Payload);
+            }
+            return;
+        }
         if (data.Succeed) {
             connection.close(1000);
         }
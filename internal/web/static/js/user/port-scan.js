@@ -0,0 +1,124 @@
+let selectedInterface = undefined;
+let connection = undefined;
+
+function selectPortScanInterface(id) {
+    selectedInterface = id;
+    document.getElementById("scan").textContent = id;
+}
+
+function stopScan() {
+    const message = {
+        Action: "STOP"
+    }
+    connection.send(JSON.stringify(message));
+    connection.onmessage = function (event) {
+        const data = JSON.parse(event.data)
+        if (data.Succeed) {
+            document.location.href = "/port/scan?action=list";
+        }
+    }
+}
+
+function addText(entry, className, text) {
+    const span = document.createElement("span");
+    span.style.width = "1%";
+    const element = document.createElement("h3");
+    element.classList.add(className);
+    element.innerText = text;
+    if (entry.children.length > 0) {
+        entry.append(span);
+    }
+    entry.append(element);
+}
+
+function addPort(port) {
+    const entry = document.createElement("div");
+    entry.classList.add("list-entry");
+    addText(entry, "blue-text", port.Host);
+    addText(entry, "black-text", port.Port + "/" + port.Protocol);
+    addText(entry, port.State === "open" ? "green-text" : "purple-text", port.State);
+    document.getElementById("results").append(entry);
+}
+
+function updateProgress(progress) {
+    document.getElementById("progress").innerText = progress.Open + " open, " + progress.Probed + "/" + progress.Total + " ports probed";
+}
+
+function startScan() {
+    const scanName = document.getElementById("scan-name").value;
+    const errorMessage = document.getElementById("error-message");
+    const setupMenu = document.getElementById("setup-menu");
+    const resultsMenu = document.getElementById("results-menu");
+
+    const target = "ws://" + document.location.host + "/port/scan?action=new";
+    connection = new WebSocket(target, "PortScanSession");
+
+    connection.onopen = function (_) {
+        connection.send(JSON.stringify(
+            {
+                ScanName: scanName,
+                InterfaceName: selectedInterface,
+                Targets: document.getElementById("targets").value,
+                Exclude: document.getElementById("exclude").value,
+                Ports: document.getElementById("ports").value,
+                Mode: document.getElementById("mode").value,
+                Rate: parseInt(document.getElementById("rate").value, 10) || 100,
+                Retries: parseInt(document.getElementById("retries").value, 10) || 0,
+                Timeout: parseInt(document.getElementById("timeout").value, 10) || 1000,
+                Gateway: document.getElementById("gateway").value,
+                ARPScan: document.getElementById("arp-scan").value,
+            }
+        ));
+
+        connection.onmessage = function (message) {
+            const data = JSON.parse(message.data);
+            if (!data.Succeed) {
+                errorMessage.style.display = "block";
+                errorMessage.innerText = data.Message;
+                connection.close(1000);
+                return;
+            }
+            errorMessage.style.display = "none";
+            setupMenu.style.display = "none";
+            resultsMenu.style.display = "block";
+            document.getElementById("active-scan-name").innerText = scanName;
+            connection.onmessage = function (message) {
+                const data = JSON.parse(message.data);
+                switch (data.Type) {
+                    case "error":
+                        errorMessage.style.display = "block";
+                        errorMessage.innerText = data.Payload;
+                        connection.close(1000);
+                        break;
+                    case "killed":
+                        errorMessage.style.display = "block";
+                        errorMessage.innerText = data.Payload;
+                        // The scan is saved by the server, wait for it
+                        connection.onmessage = function (message) {
+                            const data = JSON.parse(message.data);
+                            if (data.Succeed) {
+                                connection.close(1000);
+                            }
+                        };
+                        break;
+                    case "port":
+                        addPort(data.Payload);
+                        break;
+                    case "progress":
+                        updateProgress(data.Payload);
+                        break;
+                    case "finished":
+                        updateProgress(data.Payload);
+                        // The scan is saved by the server, wait for it
+                        connection.onmessage = function (message) {
+                            const data = JSON.parse(message.data);
+                            if (data.Succeed) {
+                                document.location.href = "/port/scan?action=list";
+                            }
+                        };
+                        break;
+                }
+            };
+        };
+    };
+}
@@ -0,0 +1,88 @@
+let selectedInterface = undefined;
+let connection = undefined;
+
+function selectReplayInterface(id) {
+    selectedInterface = id;
+    document.getElementById("replay").textContent = id;
+}
+
+function showErrorMessage(message) {
+    const errorMessage = document.getElementById("error-message");
+    errorMessage.innerText = message;
+    errorMessage.style.display = "block";
+}
+
+function stopReplay() {
+    connection.send(JSON.stringify({Action: "STOP"}));
+    document.getElementById("stop-button").style.display = "none";
+}
+
+function rewrites(id) {
+    const result = [];
+    for (const line of document.getElementById(id).value.split("\n")) {
+        const fields = line.trim().split(/\s+/);
+        if (fields.length === 2) {
+            result.push({From: fields[0], To: fields[1]});
+        }
+    }
+    return result;
+}
+
+function updateProgress(progress) {
+    document.getElementById("progress").innerText = progress.Sent + "/" + progress.Total + " packets, loop " + progress.Loop;
+}
+
+function startReplay() {
+    const captureName = document.getElementById("capture-name").innerText;
+    const speed = parseFloat(document.getElementById("speed").value) || 1;
+    const rate = parseInt(document.getElementById("rate").value, 10) || 0;
+    const loops = parseInt(document.getElementById("loops").value, 10) || 1;
+
+    const target = "ws://" + document.location.host + "/packet?action=start-replay";
+    connection = new WebSocket(target, "ReplaySession");
+
+    connection.onopen = function (_) {
+        connection.send(JSON.stringify(
+            {
+                CaptureName: captureName,
+                InterfaceName: selectedInterface,
+                Speed: speed,
+                Rate: rate,
+                Loops: loops,
+                MACRewrites: rewrites("mac-rewrites"),
+                IPRewrites: rewrites("ip-rewrites"),
+            }
+        ));
+        connection.onmessage = function (message) {
+            const data = JSON.parse(message.data);
+            if (!data.Succeed) {
+                showErrorMessage(data.Message);
+                connection.close(1000);
+                return;
+            }
+            document.getElementById("error-message").style.display = "none";
+            document.getElementById("setup-menu").style.display = "none";
+            document.getElementById("results-menu").style.display = "block";
+            document.getElementById("active-interface").innerText = selectedInterface;
+            connection.onmessage = function (message) {
+                const data = JSON.parse(message.data);
+                switch (data.Type) {
+                    case "progress":
+                        updateProgress(data.Payload);
+                        break;
+                    case "killed":
+                        showErrorMessage(data.Payload);
+                        break;
+                    case "finished":
+                        document.getElementById("stop-button").style.display = "none";
+                        const finished = document.getElementById("finished-message");
+                        finished.innerText = data.Payload.Message;
+                        finished.className = data.Payload.Succeed ? "green-text" : "red-text";
+                        finished.style.display = "block";
+                        connection.close(1000);
+                        break;
+                }
+            };
+        };
+    };
+}
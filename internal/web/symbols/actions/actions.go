@@ -11,6 +11,7 @@ const (
 	Start                   = "start"
 	View                    = "view"
 	Download                = "download"
+	DownloadHosts           = "download-hosts"
 	Spoof                   = "spoof"
 	List                    = "list"
 	AddCaptureInterface     = "add-capture-interface"
@@ -19,6 +20,22 @@ const (
 	DeleteARPScanInterface  = "delete-arp-scan-interface"
 	AddARPSpoofInterface    = "add-arp-spoof-interface"
 	DeleteARPSpoofInterface = "delete-arp-spoof-interface"
+	AddInjectInterface      = "add-inject-interface"
+	DeleteInjectInterface   = "delete-inject-interface"
+	AddReplayInterface      = "add-replay-interface"
+	DeleteReplayInterface   = "delete-replay-interface"
+	AddPortScanInterface    = "add-port-scan-interface"
+	DeletePortScanInterface = "delete-port-scan-interface"
+	Replay                  = "replay"
+	StartReplay             = "start-replay"
 	UpdateStatus            = "update-status"
 	UpdatePassword          = "update-password"
+	SetUserQuota            = "set-user-quota"
+	DeleteUserQuota         = "delete-user-quota"
+	SetRoleQuota            = "set-role-quota"
+	Kill                    = "kill"
+	Compare                 = "compare"
+	Delete                  = "delete"
+	Attach                  = "attach"
+	Stop                    = "stop"
 )
@@ -30,4 +30,40 @@ const (
 	StreamResponse         = "stream"
 	HostResponse           = "host"
 	StopSignal             = "STOP"
+	AddTargetSignal        = "ADD"
+	RemoveTargetSignal     = "REMOVE"
+	TargetsResponse        = "targets"
+	QuotaWarningResponse   = "quota-warning"
+	QuotaExceededResponse  = "quota-exceeded"
+	Role                   = "role"
+	MaxMegabytes           = "max-megabytes"
+	MaxCaptures            = "max-captures"
+	Task                   = "task"
+	Reason                 = "reason"
+	KilledResponse         = "killed"
+	RestoreResponse        = "restore"
+	Mode                   = "mode"
+	Interval               = "interval"
+	DNSAnswerResponse      = "dns-answer"
+	InjectedFrameResponse  = "frame"
+	FinishedResponse       = "finished"
+	ProgressResponse       = "progress"
+	PortResponse           = "port"
+	ARPScan                = "arp-scan"
+	Search                 = "search"
+	HostId                 = "host-id"
+	JobId                  = "job-id"
+	JobName                = "job-name"
+	Kind                   = "kind"
+	Schedule               = "schedule"
+	Duration               = "duration"
+	MaxPackets             = "max-packets"
+	Targets                = "targets"
+	Exclude                = "exclude"
+	Method                 = "method"
+	Promiscuous            = "promiscuous"
+	Alerts                 = "alerts"
+	Owner                  = "owner"
+	BPFFilter              = "bpf-filter"
+	WatchersResponse       = "watchers"
 )
@@ -14,9 +14,18 @@ const (
 	AdminPanel             = "/admin"
 	AdminEditUsers         = "/admin/user"
 	AdminARPScans          = "/admin/arp"
+	AdminARPSpoofs         = "/admin/arp/spoof"
+	AdminInjections        = "/admin/inject"
 	AdminPacketCaptures    = "/admin/captures"
+	AdminStorageQuotas     = "/admin/quotas"
+	AdminActiveTasks       = "/admin/tasks"
 	UserPacketCaptures     = "/packet"
 	UserARP                = "/arp"
 	UserARPSpoof           = "/arp/spoof"
 	UserARPScan            = "/arp/scan"
+	UserInject             = "/inject"
+	UserPortScan           = "/port/scan"
+	UserInventory          = "/inventory"
+	UserAlerts             = "/alerts"
+	UserJobs               = "/jobs"
 )
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// finishScan collects the hosts of the scan and waits until the server closes the connection, once it does the
+// scan is stored
+func finishScan(t *testing.T, connection *websocket.Conn) map[string]string {
+	hosts, _ := collectHosts(t, connection)
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		if _, _, readError := connection.ReadMessage(); readError != nil {
+			break
+		}
+	}
+	_ = connection.Close()
+	return hosts
+}
+
+func TestCompareARPScans(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	for _, scan := range [][2]string{{"Monday", "192.0.2.10-11"}, {"Tuesday", "192.0.2.11-12"}} {
+		connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+			"ScanName": scan[0],
+			"Targets":  scan[1],
+		})
+		if connection == nil {
+			t.Fatal(message)
+		}
+		finishScan(t, connection)
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.UserARPScan+"?action="+actions.Compare, strings.NewReader(url.Values{symbols.Old: []string{"Monday"}, symbols.New: []string{"Tuesday"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	page := string(body)
+	newHosts := page[strings.Index(page, `id="new-hosts"`):strings.Index(page, `id="vanished-hosts"`)]
+	vanishedHosts := page[strings.Index(page, `id="vanished-hosts"`):strings.Index(page, `id="changed-hosts"`)]
+	if !strings.Contains(newHosts, "192.0.2.12") || strings.Contains(newHosts, "192.0.2.11") || strings.Contains(newHosts, "192.0.2.10") {
+		t.Fatal(newHosts)
+	}
+	if !strings.Contains(vanishedHosts, "192.0.2.10") || strings.Contains(vanishedHosts, "192.0.2.11") {
+		t.Fatal(vanishedHosts)
+	}
+	if !strings.Contains(page, "0 changed") {
+		t.Fatal(page)
+	}
+	// Unknown scans go back to the list
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.UserARPScan+"?action="+actions.Compare, strings.NewReader(url.Values{symbols.Old: []string{"Monday"}, symbols.New: []string{"Sunday"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.Header.Get("Location") != symbols.UserARPScan+"?action="+actions.List {
+		t.Fatal(response.Header.Get("Location"))
+	}
+}
+
+func TestARPScanNewDeviceAlerts(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	// The first scan is the baseline, with an empty inventory nothing is raised
+	for _, scan := range [][2]string{{"Baseline", "192.0.2.10-11"}, {"Daily", "192.0.2.11-12"}} {
+		connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+			"ScanName": scan[0],
+			"Targets":  scan[1],
+			"Alerts":   true,
+		})
+		if connection == nil {
+			t.Fatal(message)
+		}
+		finishScan(t, connection)
+		cookies := loginAsAdmin(t, server.URL, client)
+		page := getPage(t, client, cookies, server.URL+symbols.UserAlerts)
+		if scan[0] == "Baseline" && strings.Contains(page, "New device") {
+			t.Fatal(page)
+		}
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	page := getPage(t, client, cookies, server.URL+symbols.UserAlerts)
+	if strings.Count(page, "New device") != 1 || !strings.Contains(page, "192.0.2.12") || !strings.Contains(page, "02:00:c0:00:02:0c") || !strings.Contains(page, "Daily") {
+		t.Fatal(page)
+	}
+	if strings.Contains(page, "192.0.2.11") || strings.Contains(page, "MAC changed") {
+		t.Fatal(page)
+	}
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"encoding/json"
 	"github.com/gorilla/websocket"
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
@@ -8,7 +9,9 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestNewARPScanWithValidScript(t *testing.T) {
@@ -414,3 +417,567 @@ LoadHostGenerator(Targets())
 		t.Fatal(closeError)
 	}
 }
+
+func TestNewARPScanIPv6(t *testing.T) {
+	requireTestInterface(t)
+	const ipv6Script = `
+class Targets
+	def Initialize()
+		self.current = 1
+	end
+
+	def Next()
+		host = "2001:db8::" + self.current.ToString()
+		self.current += 1
+		return host
+	end
+
+	def HasNext()
+		return self.current < 4
+	end
+
+	def Iter()
+		return self
+	end
+end
+
+LoadHostGenerator(Targets())
+`
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp scan interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPScan, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new neighbor solicitation sweep
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPScan + "?action=" + actions.New
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			ScanName      string
+			InterfaceName string
+			Script        string
+		}{
+			ScanName:      "IPv6",
+			InterfaceName: interfaceName,
+			Script:        ipv6Script,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed || status.Message != "Everything ok!" {
+		t.Fatal(status.Message)
+	}
+	// Every solicited host answers with its advertisement
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	found := map[string]string{}
+	for len(found) < 3 {
+		var event struct {
+			Type    string
+			Payload struct {
+				IP  string
+				MAC string
+			}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.HostResponse {
+			found[event.Payload.IP] = event.Payload.MAC
+		}
+	}
+	if found["2001:db8::2"] != "02:00:00:00:00:02" {
+		t.Fatal(found)
+	}
+}
+
+// startTargetsScan logs in as admin and starts a scan with the configuration on the first ethernet interface,
+// returning the websocket once the scan is running or the message of the failure
+func startTargetsScan(t *testing.T, server string, client *http.Client, configuration map[string]interface{}) (*websocket.Conn, string) {
+	response, requestError := client.PostForm(
+		server+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	request, _ := http.NewRequest(http.MethodGet, server+symbols.UserARPScan, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserARPScan+"?action="+actions.New, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	configuration["InterfaceName"] = interfaceName
+	writeError := connection.WriteJSON(configuration)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		_ = connection.Close()
+		return nil, status.Message
+	}
+	return connection, ""
+}
+
+type scanProgress struct {
+	Sent     uint64
+	Answered uint64
+	Total    uint64
+}
+
+// collectHosts reads the hosts found by the scan until it finishes on its own, returning them with the final
+// counters of the scan
+func collectHosts(t *testing.T, connection *websocket.Conn) (map[string]string, scanProgress) {
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	found := map[string]string{}
+	for {
+		var event struct {
+			Type    string
+			Payload json.RawMessage
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		switch event.Type {
+		case symbols.HostResponse:
+			var host struct {
+				IP  string
+				MAC string
+			}
+			_ = json.Unmarshal(event.Payload, &host)
+			found[host.IP] = host.MAC
+		case symbols.FinishedResponse:
+			var progress scanProgress
+			_ = json.Unmarshal(event.Payload, &progress)
+			// The scan is saved before the connection is closed
+			var status struct {
+				Succeed bool
+			}
+			readError = connection.ReadJSON(&status)
+			if readError != nil || !status.Succeed {
+				t.Fatal(readError, status)
+			}
+			return found, progress
+		}
+	}
+}
+
+func TestNewARPScanWithTargets(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Targets",
+		"Targets":  "192.0.2.10-12, 192.0.2.20/31",
+		"Exclude":  "192.0.2.11",
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	found, progress := collectHosts(t, connection)
+	if len(found) != 4 || progress.Sent != 4 || progress.Answered != 4 || progress.Total != 4 {
+		t.Fatal(found, progress)
+	}
+	for _, host := range []string{"192.0.2.10", "192.0.2.12", "192.0.2.20", "192.0.2.21"} {
+		if _, ok := found[host]; !ok {
+			t.Fatal(found)
+		}
+	}
+}
+
+func TestNewARPScanInterfaceSubnet(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Subnet",
+		"Exclude":  "192.0.2.0/28, 192.0.2.24-255",
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	found, _ := collectHosts(t, connection)
+	// Only 192.0.2.16 to 192.0.2.23 are left of the 192.0.2.0/24 network of the interface
+	if len(found) != 8 {
+		t.Fatal(found)
+	}
+	for host := 16; host < 24; host++ {
+		if _, ok := found["192.0.2."+strconv.Itoa(host)]; !ok {
+			t.Fatal(found)
+		}
+	}
+}
+
+func TestNewARPScanWithInvalidTargets(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	for _, testCase := range []struct {
+		targets string
+		exclude string
+		retries uint
+		method  string
+		gateway string
+		message string
+	}{
+		{"192.0.2.300", "", 0, "", "", "invalid host \"192.0.2.300\""},
+		{"192.0.2.20-10", "", 0, "", "", "invalid range \"192.0.2.20-10\""},
+		{"10.0.0.0/8", "", 0, "", "", "targets expand to more than 65536 hosts"},
+		{"192.0.2.1", "192.0.2.0/33", 0, "", "", "exclusions: invalid CIDR \"192.0.2.0/33\""},
+		{"192.0.2.1", "", 11, "", "", "retries must be between 0 and 10"},
+		{"192.0.2.1", "", 0, "smoke-signals", "", "unknown discovery method \"smoke-signals\""},
+		{"192.0.2.1", "", 0, "icmp-echo", "2001:db8::1", "invalid gateway IP provided, it must be an IPv4 address"},
+	} {
+		connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+			"ScanName": "Invalid",
+			"Targets":  testCase.targets,
+			"Exclude":  testCase.exclude,
+			"Retries":  testCase.retries,
+			"Method":   testCase.method,
+			"Gateway":  testCase.gateway,
+		})
+		if connection != nil {
+			_ = connection.Close()
+			t.Fatal(testCase)
+		}
+		if message != testCase.message {
+			t.Fatal(message)
+		}
+	}
+}
+
+func TestNewARPScanTimeout(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	// The interface address never answers, so it is asked again and then given up
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Timeout",
+		"Targets":  "192.0.2.2, 192.0.2.5",
+		"Retries":  1,
+		"Timeout":  200,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	start := time.Now()
+	found, progress := collectHosts(t, connection)
+	if _, ok := found["192.0.2.5"]; !ok || len(found) != 1 {
+		t.Fatal(found)
+	}
+	if progress.Sent != 2 || progress.Answered != 1 || progress.Total != 2 {
+		t.Fatal(progress)
+	}
+	// Two attempts of 200 milliseconds each
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 5*time.Second {
+		t.Fatal(elapsed)
+	}
+}
+
+func TestNewRoutedScan(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	for _, method := range []string{"icmp-echo", "icmp-timestamp", "tcp-syn", "tcp-ack", "udp"} {
+		// The probes go through the gateway, the hosts of the documentation network never answer
+		connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+			"ScanName": "Routed " + method,
+			"Targets":  "198.51.100.1-3",
+			"Method":   method,
+			"Ports":    []uint{22, 80},
+			"Gateway":  "192.0.2.1",
+			"Timeout":  100,
+		})
+		if connection == nil {
+			t.Fatal(method, message)
+		}
+		found, progress := collectHosts(t, connection)
+		_ = connection.Close()
+		if len(found) != 0 || progress.Sent != 3 || progress.Answered != 0 || progress.Total != 3 {
+			t.Fatal(method, found, progress)
+		}
+	}
+}
+
+func TestNewRoutedScanWithoutGateway(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	// The interface address never answers the ARP request of the gateway
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "No gateway",
+		"Targets":  "198.51.100.1",
+		"Method":   "icmp-echo",
+		"Gateway":  "192.0.2.2",
+		"Timeout":  100,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload interface{}
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.ErrorResponse {
+			if event.Payload != "gateway 192.0.2.2 did not answer" {
+				t.Fatal(event.Payload)
+			}
+			return
+		}
+	}
+}
+
+func TestNewPassiveScan(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Passive",
+		"Script":   "LoadHostGenerator((\"192.0.2.1\",))",
+		"Method":   "passive",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("passive scan accepted a script")
+	}
+	if message != "the passive method only listens, host generator scripts are not supported" {
+		t.Fatal(message)
+	}
+	connection, message = startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Passive",
+		"Targets":  "192.0.2.0/24",
+		"Method":   "passive",
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	// The passive method never finishes on its own
+	time.Sleep(time.Second)
+	writeError := connection.WriteJSON(map[string]string{"Action": symbols.StopSignal})
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Succeed bool
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.ProgressResponse || event.Type == symbols.FinishedResponse {
+			t.Fatal(event.Type)
+		}
+		if event.Succeed {
+			break
+		}
+	}
+	// The session is stored like the active scans
+	connection, message = startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Passive",
+		"Method":   "passive",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("scan name reused")
+	}
+	if message != "Scan name already taken" {
+		t.Fatal(message)
+	}
+}
+
+func TestNewARPScanResolveNames(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName":     "Names",
+		"Targets":      "192.0.2.10-11",
+		"Timeout":      200,
+		"ResolveNames": true,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	// The hosts do not answer the lookups, the scan finishes once they time out
+	found, progress := collectHosts(t, connection)
+	if len(found) != 2 || progress.Answered != 2 {
+		t.Fatal(found, progress)
+	}
+}
+
+func TestNewARPScanVendors(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	connection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Vendors",
+		"Targets":  "192.0.2.10",
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload struct {
+				IP                  string
+				Vendor              string
+				LocallyAdministered bool
+			}
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type != symbols.HostResponse {
+			continue
+		}
+		// The MACs of the lab network have the locally administered bit set
+		if event.Payload.IP != "192.0.2.10" || event.Payload.Vendor != "" || !event.Payload.LocallyAdministered {
+			t.Fatal(event.Payload)
+		}
+		break
+	}
+	collectHosts(t, connection)
+}
@@ -0,0 +1,259 @@
+package test
+
+import (
+	"bytes"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/shoriwe/CAPitan/internal/capture"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// viewCaptureDetails returns the page of a stored capture
+func viewCaptureDetails(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, captureName string) string {
+	response := postForm(t, client, cookies, server+symbols.UserPacketCaptures+"?action="+actions.View, url.Values{symbols.CaptureName: {captureName}})
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	return string(body)
+}
+
+// importCapture posts the pcap to the import form, returning the response of the server
+func importCapture(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, captureName, bpfFilter string, pcap []byte) *http.Response {
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	_ = formWriter.WriteField(symbols.CaptureName, captureName)
+	_ = formWriter.WriteField(symbols.Description, "Imported")
+	_ = formWriter.WriteField(symbols.BPFFilter, bpfFilter)
+	fileWriter, _ := formWriter.CreateFormFile(symbols.File, "capture.pcap")
+	_, _ = fileWriter.Write(pcap)
+	_ = formWriter.Close()
+	request, _ := http.NewRequest(http.MethodPost, server+symbols.UserPacketCaptures+"?action="+actions.Import, &form)
+	request.Header.Set("Content-Type", formWriter.FormDataContentType())
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	return response
+}
+
+// mixedCapture returns a pcap of the link type with two TCP and three UDP packets, every one of them with a payload
+func mixedCapture(t *testing.T, linkType layers.LinkType) []byte {
+	var output bytes.Buffer
+	writer := pcapgo.NewWriter(&output)
+	writeError := writer.WriteFileHeader(65536, linkType)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	for index, protocol := range []layers.IPProtocol{layers.IPProtocolTCP, layers.IPProtocolUDP, layers.IPProtocolTCP, layers.IPProtocolUDP, layers.IPProtocolUDP} {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: protocol,
+			SrcIP:    net.IPv4(192, 0, 2, 10).To4(),
+			DstIP:    net.IPv4(192, 0, 2, 20).To4(),
+		}
+		var transport gopacket.SerializableLayer
+		if protocol == layers.IPProtocolTCP {
+			tcp := &layers.TCP{SrcPort: layers.TCPPort(40000 + index), DstPort: 80, PSH: true, ACK: true, Window: 1024}
+			_ = tcp.SetNetworkLayerForChecksum(ip)
+			transport = tcp
+		} else {
+			udp := &layers.UDP{SrcPort: layers.UDPPort(40000 + index), DstPort: 9999}
+			_ = udp.SetNetworkLayerForChecksum(ip)
+			transport = udp
+		}
+		serializable := []gopacket.SerializableLayer{ip, transport, gopacket.Payload("payload")}
+		if linkType == layers.LinkTypeEthernet {
+			serializable = append([]gopacket.SerializableLayer{&layers.Ethernet{SrcMAC: hostMAC(10), DstMAC: hostMAC(20), EthernetType: layers.EthernetTypeIPv4}}, serializable...)
+		}
+		buffer := gopacket.NewSerializeBuffer()
+		serializeError := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, serializable...)
+		if serializeError != nil {
+			t.Fatal(serializeError)
+		}
+		writeError = writer.WritePacket(
+			gopacket.CaptureInfo{
+				Timestamp:     time.Now(),
+				CaptureLength: len(buffer.Bytes()),
+				Length:        len(buffer.Bytes()),
+			},
+			buffer.Bytes(),
+		)
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+	}
+	return output.Bytes()
+}
+
+// openCaptureFile writes the pcap to a temporary file and opens it for reading
+func openCaptureFile(t *testing.T, pcap []byte) *os.File {
+	file, createError := os.CreateTemp("", "*.pcap")
+	if createError != nil {
+		t.Fatal(createError)
+	}
+	t.Cleanup(func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	})
+	_, writeError := file.Write(pcap)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	_, seekError := file.Seek(0, io.SeekStart)
+	if seekError != nil {
+		t.Fatal(seekError)
+	}
+	return file
+}
+
+func TestCaptureBPFFilter(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+
+	// Imported captures
+	response := importCapture(t, server.URL, client, cookies, "Filtered import", " tcp ", fingerprintCapture(t))
+	if response.Header.Get("Location") != symbols.UserPacketCaptures {
+		t.Fatal(response.Header.Get("Location"))
+	}
+	if page := viewCaptureDetails(t, server.URL, client, cookies, "Filtered import"); !strings.Contains(page, `id="bpf-filter">tcp</code>`) {
+		t.Fatal(page)
+	}
+
+	// Interface captures
+	interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures+"?action="+actions.New)))
+	for captureName, filter := range map[string]string{
+		"Filtered capture":   "udp port 53",
+		"Unfiltered capture": "   ",
+	} {
+		connection, succeed, message := dialCapture(t, server.URL, cookies, actions.Start, map[string]interface{}{
+			"Description":   "Kernel side filter",
+			"CaptureName":   captureName,
+			"InterfaceName": interfaceName,
+			"BPFFilter":     filter,
+		})
+		if !succeed {
+			t.Fatal(message)
+		}
+		writeError := connection.WriteJSON(map[string]string{"Action": symbols.StopSignal})
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+		_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for {
+			var event map[string]interface{}
+			readError := connection.ReadJSON(&event)
+			if readError != nil {
+				t.Fatal(readError)
+			}
+			if _, isEvent := event["Type"]; !isEvent {
+				break
+			}
+		}
+		_ = connection.Close()
+	}
+	if page := viewCaptureDetails(t, server.URL, client, cookies, "Filtered capture"); !strings.Contains(page, `id="bpf-filter">udp port 53</code>`) {
+		t.Fatal(page)
+	}
+	if page := viewCaptureDetails(t, server.URL, client, cookies, "Unfiltered capture"); !strings.Contains(page, `id="bpf-filter">None</code>`) {
+		t.Fatal(page)
+	}
+}
+
+func TestCombineBPFFilters(t *testing.T) {
+	if filter := capture.CombineBPFFilters("tcp port 80", "", "not host 192.0.2.1"); filter != "(tcp port 80) and (not host 192.0.2.1)" {
+		t.Fatal(filter)
+	}
+	if filter := capture.CombineBPFFilters("", "arp"); filter != "(arp)" {
+		t.Fatal(filter)
+	}
+	if filter := capture.CombineBPFFilters(); len(filter) != 0 {
+		t.Fatal(filter)
+	}
+}
+
+func TestImportInvalidBPFFilter(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	// The page tells why the import failed and nothing is stored
+	response := importCapture(t, server.URL, client, cookies, "Broken filter", "tcp port", fingerprintCapture(t))
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(body), `id="error-message">invalid BPF filter: `) {
+		t.Fatal(response.StatusCode, string(body))
+	}
+	if page := getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures); strings.Contains(page, `value="Broken filter"`) {
+		t.Fatal(page)
+	}
+}
+
+func TestBPFFilterImportedFile(t *testing.T) {
+	engine, creationError := capture.NewEngineWithFile(openCaptureFile(t, mixedCapture(t, layers.LinkTypeEthernet)), "udp")
+	if creationError != nil {
+		t.Fatal(creationError)
+	}
+	defer engine.Close()
+	startError := engine.Start()
+	if startError != nil {
+		t.Fatal(startError)
+	}
+	// Only the UDP packets reach the engine
+	var udpPackets int
+	timeout := time.After(5 * time.Second)
+	for udpPackets < 3 {
+		select {
+		case packet := <-engine.Packets:
+			if packet.Layer(layers.LayerTypeUDP) == nil {
+				t.Fatal(packet)
+			}
+			udpPackets++
+		case <-engine.TCPStreams:
+			t.Fatal("TCP stream of a filtered packet")
+		case <-timeout:
+			t.Fatal(udpPackets)
+		}
+	}
+	select {
+	case packet := <-engine.Packets:
+		t.Fatal(packet)
+	case <-time.After(2 * time.Second):
+	}
+
+	// Filters are compiled for the link type of the file, raw IP packets have no ethernet header to match
+	_, creationError = capture.NewEngineWithFile(openCaptureFile(t, mixedCapture(t, layers.LinkTypeRaw)), "ether host 00:1b:21:00:00:0a")
+	if creationError == nil || !strings.HasPrefix(creationError.Error(), "invalid BPF filter: ") {
+		t.Fatal(creationError)
+	}
+	engine, creationError = capture.NewEngineWithFile(openCaptureFile(t, mixedCapture(t, layers.LinkTypeRaw)), "udp")
+	if creationError != nil {
+		t.Fatal(creationError)
+	}
+	engine.Close()
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialCapture opens a capture websocket of the action sending the request, returning the answer of the server
+func dialCapture(t *testing.T, server string, cookies []*http.Cookie, action string, request interface{}) (*websocket.Conn, bool, string) {
+	header := http.Header{}
+	for _, cookie := range cookies {
+		header.Add("Cookie", cookie.String())
+	}
+	hostUrl, _ := url.Parse(server)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserPacketCaptures+"?action="+action, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError := connection.WriteJSON(request)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError := connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	return connection, status.Succeed, status.Message
+}
+
+func TestDetachedCapture(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures+"?action="+actions.New)))
+	connection, succeed, message := dialCapture(t, server.URL, cookies, actions.Start, map[string]interface{}{
+		"Description":   "Survives its client",
+		"CaptureName":   "Detached",
+		"InterfaceName": interfaceName,
+	})
+	if !succeed {
+		t.Fatal(message)
+	}
+	// Closing the connection only detaches the client
+	_ = connection.Close()
+	time.Sleep(time.Second)
+	page := getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures)
+	if !strings.Contains(page, `id="running-captures"`) || !strings.Contains(page, "Detached") {
+		t.Fatal(page)
+	}
+	_, succeed, message = dialCapture(t, server.URL, cookies, actions.Attach, map[string]string{"CaptureName": "Missing"})
+	if succeed || message != "No running capture with that name" {
+		t.Fatal(message)
+	}
+	// Clients can attach and detach as many times as they want
+	connection, succeed, message = dialCapture(t, server.URL, cookies, actions.Attach, map[string]string{"CaptureName": "Detached"})
+	if !succeed {
+		t.Fatal(message)
+	}
+	_ = connection.Close()
+	connection, succeed, message = dialCapture(t, server.URL, cookies, actions.Attach, map[string]string{"CaptureName": "Detached"})
+	if !succeed {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	// Stopping from the list notifies the attached clients once the capture is stored
+	response := postForm(t, client, cookies, server.URL+symbols.UserPacketCaptures+"?action="+actions.Stop, url.Values{symbols.CaptureName: []string{"Detached"}})
+	if response.Header.Get("Location") != symbols.UserPacketCaptures {
+		t.Fatal(response.Header)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event map[string]interface{}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if _, isEvent := event["Type"]; isEvent {
+			continue
+		}
+		if event["Succeed"] != true {
+			t.Fatal(event)
+		}
+		break
+	}
+	page = getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures)
+	if strings.Contains(page, `id="running-captures"`) || !strings.Contains(page, `value="Detached"`) {
+		t.Fatal(page)
+	}
+	_, succeed, _ = dialCapture(t, server.URL, cookies, actions.Attach, map[string]string{"CaptureName": "Detached"})
+	if succeed {
+		t.Fatal("attached to a finished capture")
+	}
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hostMAC(last byte) net.HardwareAddr {
+	return net.HardwareAddr{0x00, 0x1b, 0x21, 0x00, 0x00, last}
+}
+
+// fingerprintCapture returns a pcap with the traffic of a Linux and a Windows host opening connections, an Android
+// phone requesting an address with DHCP, an iPhone browsing and a router with a stack of its own
+func fingerprintCapture(t *testing.T) []byte {
+	var output bytes.Buffer
+	writer := pcapgo.NewWriter(&output)
+	writeError := writer.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	timestamp := time.Now()
+	write := func(serializable ...gopacket.SerializableLayer) {
+		buffer := gopacket.NewSerializeBuffer()
+		serializeError := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, serializable...)
+		if serializeError != nil {
+			t.Fatal(serializeError)
+		}
+		timestamp = timestamp.Add(time.Millisecond)
+		writeError = writer.WritePacket(
+			gopacket.CaptureInfo{
+				Timestamp:     timestamp,
+				CaptureLength: len(buffer.Bytes()),
+				Length:        len(buffer.Bytes()),
+			},
+			buffer.Bytes(),
+		)
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+	}
+	writeTCP := func(last byte, ttl uint8, tcp *layers.TCP, payload []byte) {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      ttl,
+			Protocol: layers.IPProtocolTCP,
+			SrcIP:    net.IPv4(192, 0, 2, last).To4(),
+			DstIP:    net.IPv4(192, 0, 2, 1).To4(),
+		}
+		tcp.SrcPort, tcp.DstPort = 40000, 80
+		_ = tcp.SetNetworkLayerForChecksum(ip)
+		write(
+			&layers.Ethernet{SrcMAC: hostMAC(last), DstMAC: hostMAC(1), EthernetType: layers.EthernetTypeIPv4},
+			ip,
+			tcp,
+			gopacket.Payload(payload),
+		)
+	}
+	mss := layers.TCPOption{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}}
+	nop := layers.TCPOption{OptionType: layers.TCPOptionKindNop, OptionLength: 1}
+	sack := layers.TCPOption{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2}
+	windowScale := layers.TCPOption{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{7}}
+	timestamps := layers.TCPOption{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: make([]byte, 8)}
+	// Linux
+	writeTCP(10, 64, &layers.TCP{SYN: true, Window: 64240, Options: []layers.TCPOption{mss, sack, timestamps, nop, windowScale}}, nil)
+	// Windows, one router away
+	writeTCP(11, 127, &layers.TCP{SYN: true, Window: 64240, Options: []layers.TCPOption{mss, nop, windowScale, nop, nop, sack}}, nil)
+	// Answers do not fingerprint the host
+	writeTCP(15, 64, &layers.TCP{SYN: true, ACK: true, Window: 64240, Options: []layers.TCPOption{mss, nop, windowScale, nop, nop, sack}}, nil)
+	// iPhone
+	writeTCP(13, 64, &layers.TCP{PSH: true, ACK: true, Window: 2048}, []byte("GET / HTTP/1.1\r\nHost: example.com\r\nUser-Agent: Mozilla/5.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X) AppleWebKit/605.1.15\r\n\r\n"))
+	// Router with unknown options
+	writeTCP(14, 254, &layers.TCP{SYN: true, Window: 1024, Options: []layers.TCPOption{mss, nop, nop, sack}}, nil)
+	// Android phone without an address, the guess is attached once the server assigns it
+	writeDHCP := func(operation layers.DHCPOp, source, destination net.IP, options layers.DHCPOptions, assigned net.IP) {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    source.To4(),
+			DstIP:    destination.To4(),
+		}
+		udp := &layers.UDP{SrcPort: 68, DstPort: 67}
+		sourceMAC := hostMAC(12)
+		if operation == layers.DHCPOpReply {
+			udp.SrcPort, udp.DstPort = 67, 68
+			sourceMAC = hostMAC(1)
+		}
+		_ = udp.SetNetworkLayerForChecksum(ip)
+		write(
+			&layers.Ethernet{SrcMAC: sourceMAC, DstMAC: layers.EthernetBroadcast, EthernetType: layers.EthernetTypeIPv4},
+			ip,
+			udp,
+			&layers.DHCPv4{
+				Operation:    operation,
+				HardwareType: layers.LinkTypeEthernet,
+				HardwareLen:  6,
+				Xid:          1,
+				ClientIP:     net.IPv4zero.To4(),
+				YourClientIP: assigned.To4(),
+				NextServerIP: net.IPv4zero.To4(),
+				RelayAgentIP: net.IPv4zero.To4(),
+				ClientHWAddr: hostMAC(12),
+				Options:      options,
+			},
+		)
+	}
+	writeDHCP(layers.DHCPOpRequest, net.IPv4zero, net.IPv4bcast,
+		layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeDiscover)}),
+			layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 3, 6, 15, 26, 28, 51, 58, 59, 43}),
+			layers.NewDHCPOption(layers.DHCPOptEnd, nil),
+		},
+		net.IPv4zero,
+	)
+	writeDHCP(layers.DHCPOpReply, net.IPv4(192, 0, 2, 1), net.IPv4bcast,
+		layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeAck)}),
+			layers.NewDHCPOption(layers.DHCPOptEnd, nil),
+		},
+		net.IPv4(192, 0, 2, 12),
+	)
+	return output.Bytes()
+}
+
+func TestFingerprintImportedCapture(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	_ = formWriter.WriteField(symbols.CaptureName, "fingerprints")
+	_ = formWriter.WriteField(symbols.Description, "Capture to fingerprint")
+	fileWriter, _ := formWriter.CreateFormFile(symbols.File, "capture.pcap")
+	_, _ = fileWriter.Write(fingerprintCapture(t))
+	_ = formWriter.Close()
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.UserPacketCaptures+"?action="+actions.Import, &form)
+	request.Header.Set("Content-Type", formWriter.FormDataContentType())
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.Header.Get("Location") != symbols.UserPacketCaptures {
+		t.Fatal(response.Header.Get("Location"))
+	}
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.UserPacketCaptures+"?action="+actions.DownloadHosts, strings.NewReader(url.Values{symbols.CaptureName: []string{"fingerprints"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var hosts []struct {
+		Host         string
+		OS           string
+		Fingerprints []struct {
+			Method   string
+			Label    string
+			Evidence string
+		}
+	}
+	decodeError := json.NewDecoder(response.Body).Decode(&hosts)
+	if decodeError != nil {
+		t.Fatal(decodeError)
+	}
+	systems := map[string]string{}
+	for _, host := range hosts {
+		systems[host.Host] = host.OS
+		if host.Host == "192.0.2.11" && (len(host.Fingerprints) != 1 || host.Fingerprints[0].Evidence != "ttl 128, window 64240, mss 1460, options M,N,W,N,N,S") {
+			t.Fatal(host)
+		}
+	}
+	for host, system := range map[string]string{
+		"192.0.2.10": "Linux or Android",
+		"192.0.2.11": "Windows",
+		"192.0.2.12": "Android",
+		"192.0.2.13": "iOS (iPhone)",
+		"192.0.2.14": "Network device",
+		"192.0.2.15": "",
+	} {
+		if systems[host] != system {
+			t.Fatal(host, systems)
+		}
+	}
+}
@@ -3,14 +3,43 @@ package test
 import (
 	"github.com/shoriwe/CAPitan/internal/data/memory"
 	"github.com/shoriwe/CAPitan/internal/logs"
+	"github.com/shoriwe/CAPitan/internal/scheduler"
 	"github.com/shoriwe/CAPitan/internal/web"
 	"net/http/httptest"
 	"os"
+	"testing"
 )
 
-func NewTestServer() *httptest.Server {
+// TestInterface names the interface of the lab network the tests expecting hosts to answer run on. The interface has
+// 192.0.2.2/24 and 2001:db8::2/64, every other address of both networks answers ARP requests and neighbor
+// solicitations with a MAC ending in its last byte and 192.0.2.1 routes to the rest of the hosts the tests probe
+const TestInterface = "CAPITAN_TEST_INTERFACE"
+
+// requireTestInterface skips the test when no lab network is configured
+func requireTestInterface(t *testing.T) {
+	if len(os.Getenv(TestInterface)) == 0 {
+		t.Skip(TestInterface + " is not set, the test needs hosts answering on a lab network")
+	}
+}
+
+// TestServer is a server with its jobs scheduler running, closing it stops the scheduler
+type TestServer struct {
+	*httptest.Server
+	scheduler *scheduler.Scheduler
+}
+
+func (server *TestServer) Close() {
+	server.scheduler.Stop()
+	server.Server.Close()
+}
+
+func NewTestServer() *TestServer {
 	database := memory.NewInMemoryDB()
 	logger := logs.NewLogger(os.Stderr)
-	handler := web.NewServerMux(database, logger)
-	return httptest.NewServer(handler)
+	handler, jobScheduler := web.NewServerMux(database, logger)
+	jobScheduler.Start()
+	return &TestServer{
+		Server:    httptest.NewServer(handler),
+		scheduler: jobScheduler,
+	}
 }
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type injectionEvent struct {
+	Type    string
+	Payload struct {
+		Succeed bool
+		Message string
+		Summary string
+		Length  int
+	}
+}
+
+// startInjection logs in as admin and runs the script on the first ethernet interface, returning the events
+// received until the session finished and the session cookies
+func startInjection(t *testing.T, server string, client *http.Client, script string) ([]injectionEvent, []*http.Cookie) {
+	response, requestError := client.PostForm(
+		server+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get inject interface
+	request, _ := http.NewRequest(http.MethodGet, server+symbols.UserInject, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserInject+"?action="+actions.Start, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			InterfaceName string
+			Script        string
+			Rate          uint
+		}{
+			InterfaceName: interfaceName,
+			Script:        script,
+			Rate:          100,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var events []injectionEvent
+	for {
+		var event injectionEvent
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		events = append(events, event)
+		if event.Type == symbols.FinishedResponse {
+			return events, cookies
+		}
+	}
+}
+
+func TestInjectFrames(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	events, cookies := startInjection(t, server.URL, client, `Inject((Ethernet(None, None), ARP(1, None, None, None, "192.0.2.1")))
+Inject((Ethernet(None, "02:00:c0:00:02:01"), IPv4(None, "192.0.2.1"), UDP(5353, 53), DNS(1, "example.com", "A")))
+Inject((Ethernet(None, "02:00:c0:00:02:01"), IPv4(None, "192.0.2.1"), TCP(40000, 80, "S", 1, 0), Payload("hello")))`)
+	if len(events) != 4 {
+		t.Fatal(events)
+	}
+	for index, prefix := range []string{"Ethernet/ARP ", "Ethernet/IPv4/UDP/DNS ", "Ethernet/IPv4/TCP/Payload "} {
+		if events[index].Type != symbols.InjectedFrameResponse || !strings.HasPrefix(events[index].Payload.Summary, prefix) {
+			t.Fatal(events[index])
+		}
+	}
+	if !events[3].Payload.Succeed {
+		t.Fatal(events[3].Payload.Message)
+	}
+	// The session is kept with the number of frames sent
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserInject+"?action="+actions.List, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !strings.Contains(string(body), "3 frames") {
+		t.Fatal(string(body))
+	}
+}
+
+func TestInjectInvalidFrame(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	events, _ := startInjection(t, server.URL, client, `Inject((IPv4(None, "192.0.2.1"), UDP(5353, 53)))`)
+	if len(events) != 1 || events[0].Payload.Succeed || !strings.Contains(events[0].Payload.Message, "Ethernet") {
+		t.Fatal(events)
+	}
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"bytes"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var inventoryEntry = regexp.MustCompile(`<h3 class="purple-text">([^<]+)</h3>(?s:.*?)name="host-id" readonly style="display: none;"\s+type="text"\s+value="(\d+)"`)
+
+// inventoryHosts lists the hosts of the inventory matching the search, indexed by IP to their ids
+func inventoryHosts(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, search string) map[string]string {
+	body := getPage(t, client, cookies, server+symbols.UserInventory+"?"+url.Values{symbols.Search: []string{search}}.Encode())
+	hosts := map[string]string{}
+	for _, match := range inventoryEntry.FindAllStringSubmatch(body, -1) {
+		hosts[match[1]] = match[2]
+	}
+	return hosts
+}
+
+func TestInventoryMergesScansAndCaptures(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	// The ARP scan finds the MACs of the hosts
+	arpConnection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Inventory hosts",
+		"Targets":  "192.0.2.10-11",
+	})
+	if arpConnection == nil {
+		t.Fatal(message)
+	}
+	collectHosts(t, arpConnection)
+	_ = arpConnection.Close()
+	cookies := loginAsAdmin(t, server.URL, client)
+	hosts := inventoryHosts(t, server.URL, client, cookies, "")
+	if len(hosts) != 2 || len(hosts["192.0.2.10"]) == 0 || len(hosts["192.0.2.11"]) == 0 {
+		t.Fatal(hosts)
+	}
+	// The port scan adds the open ports of the hosts
+	listener, listenError := net.Listen("tcp", "127.0.0.1:0")
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+	defer listener.Close()
+	openPort := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName":      "Inventory ports",
+		"InterfaceName": "lo",
+		"Mode":          "connect",
+		"Targets":       "127.0.0.1",
+		"Ports":         openPort,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	collectPorts(t, connection)
+	_ = connection.Close()
+	// The capture fingerprints the host found by the ARP scan, the remote hosts without guesses are left out
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	_ = formWriter.WriteField(symbols.CaptureName, "Inventory capture")
+	_ = formWriter.WriteField(symbols.Description, "Capture of the inventory")
+	fileWriter, _ := formWriter.CreateFormFile(symbols.File, "capture.pcap")
+	_, _ = fileWriter.Write(fingerprintCapture(t))
+	_ = formWriter.Close()
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.UserPacketCaptures+"?action="+actions.Import, &form)
+	request.Header.Set("Content-Type", formWriter.FormDataContentType())
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	_, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	hosts = inventoryHosts(t, server.URL, client, cookies, "")
+	if _, found := hosts["192.0.2.1"]; found || len(hosts) != 6 {
+		t.Fatal(hosts)
+	}
+	if found := inventoryHosts(t, server.URL, client, cookies, "linux"); len(found) != 1 || found["192.0.2.10"] != hosts["192.0.2.10"] {
+		t.Fatal(found)
+	}
+	if found := inventoryHosts(t, server.URL, client, cookies, openPort+"/tcp"); len(found) != 1 || len(found["127.0.0.1"]) == 0 {
+		t.Fatal(found)
+	}
+	if found := inventoryHosts(t, server.URL, client, cookies, "02:00:c0:00:02:0b"); len(found) != 1 || len(found["192.0.2.11"]) == 0 {
+		t.Fatal(found)
+	}
+	// The detail page lists what every session learned of the host
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.UserInventory+"?action="+actions.View, strings.NewReader(url.Values{symbols.HostId: []string{hosts["192.0.2.10"]}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(response.Body)
+	for _, expected := range []string{"02:00:c0:00:02:0a", "Linux or Android", "Inventory hosts", "Inventory capture"} {
+		if !strings.Contains(body.String(), expected) {
+			t.Fatal(expected, body.String())
+		}
+	}
+	// Unknown hosts go back to the inventory
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.UserInventory+"?action="+actions.View, strings.NewReader(url.Values{symbols.HostId: []string{"1000"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.Header.Get("Location") != symbols.UserInventory {
+		t.Fatal(response.Header.Get("Location"))
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"github.com/shoriwe/CAPitan/internal/scheduler"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jobEntry = regexp.MustCompile(`<h3 class="purple-text">([^<]+)</h3>(?s:.*?)name="job-id" readonly style="display: none;"\s+type="text"\s+value="(\d+)"`)
+
+func postForm(t *testing.T, client *http.Client, cookies []*http.Cookie, target string, values url.Values) *http.Response {
+	request, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	return response
+}
+
+// listJobs returns the ids of the jobs of the user indexed by name
+func listJobs(t *testing.T, server string, client *http.Client, cookies []*http.Cookie) map[string]string {
+	jobs := map[string]string{}
+	for _, match := range jobEntry.FindAllStringSubmatch(getPage(t, client, cookies, server+symbols.UserJobs), -1) {
+		jobs[match[1]] = match[2]
+	}
+	return jobs
+}
+
+// waitJobRun waits until the history of the job has a finished run
+func waitJobRun(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, jobId string) string {
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		response := postForm(t, client, cookies, server+symbols.UserJobs+"?action="+actions.View, url.Values{symbols.JobId: []string{jobId}})
+		body, _ := io.ReadAll(response.Body)
+		history := string(body)
+		if strings.Contains(history, "Succeed</h3>") || strings.Contains(history, "Failed</h3>") {
+			return history
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatal("the job never ran")
+	return ""
+}
+
+func TestScheduledJobs(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server.URL+symbols.UserARPScan)))
+	for _, job := range []url.Values{
+		{
+			symbols.JobName:   []string{"Hourly scan"},
+			symbols.Kind:      []string{"arp-scan"},
+			symbols.Interface: []string{interfaceName},
+			symbols.Interval:  []string{"3600"},
+			symbols.Targets:   []string{"192.0.2.10-11"},
+		},
+		{
+			symbols.JobName:   []string{"Nightly capture"},
+			symbols.Kind:      []string{"capture"},
+			symbols.Interface: []string{interfaceName},
+			symbols.Interval:  []string{"3600"},
+			symbols.Duration:  []string{"1"},
+		},
+		{
+			symbols.JobName:   []string{"Broken capture"},
+			symbols.Kind:      []string{"capture"},
+			symbols.Interface: []string{interfaceName},
+			symbols.Interval:  []string{"3600"},
+			symbols.Duration:  []string{"1"},
+			symbols.Script:    []string{"def ("},
+		},
+	} {
+		response := postForm(t, client, cookies, server.URL+symbols.UserJobs+"?action="+actions.New, job)
+		if response.Header.Get("Location") != symbols.UserJobs {
+			body, _ := io.ReadAll(response.Body)
+			t.Fatal(job.Get(symbols.JobName), string(body))
+		}
+	}
+	jobs := listJobs(t, server.URL, client, cookies)
+	if len(jobs) != 3 {
+		t.Fatal(jobs)
+	}
+	// Jobs with an interval run once they are scheduled and store normal sessions
+	history := waitJobRun(t, server.URL, client, cookies, jobs["Hourly scan"])
+	if !strings.Contains(history, "Succeed</h3>") || !strings.Contains(history, "Scan finished") {
+		t.Fatal(history)
+	}
+	if page := getPage(t, client, cookies, server.URL+symbols.UserARPScan+"?action="+actions.List); !strings.Contains(page, "Hourly scan ") {
+		t.Fatal(page)
+	}
+	history = waitJobRun(t, server.URL, client, cookies, jobs["Nightly capture"])
+	if !strings.Contains(history, "Succeed</h3>") || !strings.Contains(history, "Duration reached") {
+		t.Fatal(history)
+	}
+	if page := getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures); !strings.Contains(page, "Nightly capture ") {
+		t.Fatal(page)
+	}
+	// Failures are kept in the history
+	history = waitJobRun(t, server.URL, client, cookies, jobs["Broken capture"])
+	if !strings.Contains(history, "Failed</h3>") {
+		t.Fatal(history)
+	}
+	// Deleted jobs are not listed anymore
+	postForm(t, client, cookies, server.URL+symbols.UserJobs+"?action="+actions.Delete, url.Values{symbols.JobId: []string{jobs["Broken capture"]}})
+	if jobs = listJobs(t, server.URL, client, cookies); len(jobs) != 2 || len(jobs["Broken capture"]) > 0 {
+		t.Fatal(jobs)
+	}
+}
+
+func TestScheduledJobsWithInvalidArguments(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server.URL+symbols.UserARPScan)))
+	for expected, job := range map[string]url.Values{
+		"a schedule or an interval is needed": {
+			symbols.JobName:   []string{"No schedule"},
+			symbols.Kind:      []string{"arp-scan"},
+			symbols.Interface: []string{interfaceName},
+		},
+		"the minute field must be between 0 and 59": {
+			symbols.JobName:   []string{"Bad schedule"},
+			symbols.Kind:      []string{"arp-scan"},
+			symbols.Interface: []string{interfaceName},
+			symbols.Schedule:  []string{"61 * * * *"},
+		},
+		"captures need a duration or a packets limit": {
+			symbols.JobName:   []string{"Endless capture"},
+			symbols.Kind:      []string{"capture"},
+			symbols.Interface: []string{interfaceName},
+			symbols.Interval:  []string{"60"},
+		},
+		"no ARP scan permission on the interface": {
+			symbols.JobName:   []string{"Unknown interface"},
+			symbols.Kind:      []string{"arp-scan"},
+			symbols.Interface: []string{"missing0"},
+			symbols.Interval:  []string{"60"},
+		},
+	} {
+		response := postForm(t, client, cookies, server.URL+symbols.UserJobs+"?action="+actions.New, job)
+		body, _ := io.ReadAll(response.Body)
+		if !strings.Contains(string(body), expected) {
+			t.Fatal(expected, string(body))
+		}
+	}
+	if jobs := listJobs(t, server.URL, client, cookies); len(jobs) != 0 {
+		t.Fatal(jobs)
+	}
+}
+
+func TestJobScheduleNext(t *testing.T) {
+	schedule, parseError := scheduler.ParseSchedule("30 3 * * 1-5")
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	// Saturday
+	after := time.Date(2022, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if next := schedule.Next(after); !next.Equal(time.Date(2022, time.January, 3, 3, 30, 0, 0, time.UTC)) {
+		t.Fatal(next)
+	}
+	schedule, parseError = scheduler.ParseSchedule("*/15 * 1 * 0")
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	// Either the first day of the month or a Sunday
+	if next := schedule.Next(after); !next.Equal(time.Date(2022, time.January, 1, 12, 15, 0, 0, time.UTC)) {
+		t.Fatal(next)
+	}
+	if next := schedule.Next(time.Date(2022, time.January, 1, 23, 50, 0, 0, time.UTC)); !next.Equal(time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal(next)
+	}
+	if _, parseError = scheduler.ParseSchedule("* * *"); parseError == nil {
+		t.Fatal("expecting an error")
+	}
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"github.com/shoriwe/CAPitan/internal/oui"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestOUIVendor(t *testing.T) {
+	mac, _ := net.ParseMAC("00:00:0c:12:34:56")
+	if vendor := oui.Vendor(mac); vendor != "Cisco Systems, Inc" {
+		t.Fatal(vendor)
+	}
+	vendor, locallyAdministered := oui.Lookup("da:a1:19:00:00:01")
+	if vendor != "" || !locallyAdministered {
+		t.Fatal(vendor, locallyAdministered)
+	}
+	if label := oui.Label(net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}); label != "" {
+		t.Fatal(label)
+	}
+}
+
+func TestOUILoad(t *testing.T) {
+	const ouiText = `OUI/MA-L                                                    Organization
+company_id                                                  Organization
+                                                            Address
+
+FC-FF-AA   (hex)		Example Networks
+FCFFAA     (base 16)		Example Networks
+				Example street
+`
+	count, loadError := oui.Load(strings.NewReader(ouiText))
+	if loadError != nil || count != 1 {
+		t.Fatal(count, loadError)
+	}
+	if vendor, _ := oui.Lookup("fc:ff:aa:00:00:01"); vendor != "Example Networks" {
+		t.Fatal(vendor)
+	}
+	const ouiCSV = `Registry,Assignment,Organization Name,Organization Address
+MA-L,FCFFAB,"Example Devices, Inc",Example street
+MA-M,FCFFAC1,Example Modules,Example street
+`
+	count, loadError = oui.Load(strings.NewReader(ouiCSV))
+	if loadError != nil || count != 1 {
+		t.Fatal(count, loadError)
+	}
+	if vendor, _ := oui.Lookup("fc-ff-ab-00-00-01"); vendor != "Example Devices, Inc" {
+		t.Fatal(vendor)
+	}
+	_, loadError = oui.Load(strings.NewReader("not a registry"))
+	if loadError == nil {
+		t.Fatal("invalid registry loaded")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -927,3 +928,168 @@ func TestNewCaptureWithNonPermittedInterface(t *testing.T) {
 		t.Fatal(closeError)
 	}
 }
+
+func TestInterceptSession(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// The interface needs both capture and spoof permissions
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new intercept
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserPacketCaptures + "?action=" + actions.Start
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	type intercept struct {
+		Targets string
+		Gateway string
+	}
+	writeError := connection.WriteJSON(
+		struct {
+			Promiscuous   bool
+			Description   string
+			CaptureName   string
+			InterfaceName string
+			Intercept     intercept
+		}{
+			Promiscuous:   true,
+			Description:   "My description",
+			CaptureName:   "Intercepted",
+			InterfaceName: interfaceName,
+			Intercept: intercept{
+				Targets: "192.168.1.10",
+				Gateway: "192.168.1.1",
+			},
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed || status.Message != "Everything ok!" {
+		t.Fatal(status.Message)
+	}
+	type event struct {
+		Type    string
+		Succeed bool
+		Payload struct {
+			Succeed bool
+			Message string
+			Targets []string
+		}
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var targets event
+	readError = connection.ReadJSON(&targets)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if targets.Type != symbols.TargetsResponse || len(targets.Payload.Targets) != 1 || targets.Payload.Targets[0] != "192.168.1.10" {
+		t.Fatal(targets)
+	}
+	// Stop it, the caches are restored before the capture is saved
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+		}{
+			Action: "STOP",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	restored := false
+	for {
+		var update event
+		readError = connection.ReadJSON(&update)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if update.Type == symbols.RestoreResponse {
+			if !update.Payload.Succeed {
+				t.Fatal(update.Payload.Message)
+			}
+			restored = true
+		} else if len(update.Type) == 0 && update.Succeed {
+			break
+		}
+	}
+	if !restored {
+		t.Fatal("caches not restored")
+	}
+	// The spoof appears in the history linked to the capture
+	var page string
+	for try := 0; try < 50; try++ {
+		request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof+"?action="+actions.List, nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		response, requestError = client.Do(request)
+		if requestError != nil {
+			t.Fatal(requestError)
+		}
+		body, readError = io.ReadAll(response.Body)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		page = string(body)
+		if strings.Contains(page, "Intercepted") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !strings.Contains(page, "Intercepted") || !strings.Contains(page, "192.168.1.10") {
+		t.Fatal(page)
+	}
+}
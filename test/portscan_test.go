@@ -0,0 +1,418 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loginAsAdmin returns the session cookies of the admin user
+func loginAsAdmin(t *testing.T, server string, client *http.Client) []*http.Cookie {
+	response, requestError := client.PostForm(
+		server+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	return response.Cookies()
+}
+
+func getPage(t *testing.T, client *http.Client, cookies []*http.Cookie, target string) string {
+	request, _ := http.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	return string(body)
+}
+
+// startPortScan starts a port scan with the configuration, returning the websocket once the scan is running or
+// the message of the failure. Without InterfaceName the first ethernet interface is used
+func startPortScan(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, configuration map[string]interface{}) (*websocket.Conn, string) {
+	if _, found := configuration["InterfaceName"]; !found {
+		interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server+symbols.UserPortScan)))
+		if len(interfaceName) == 0 {
+			t.Fatal("no ethernet interface with port scan permissions")
+		}
+		configuration["InterfaceName"] = interfaceName
+	}
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserPortScan+"?action="+actions.New, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError := connection.WriteJSON(configuration)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError := connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		_ = connection.Close()
+		return nil, status.Message
+	}
+	return connection, ""
+}
+
+type portScanProgress struct {
+	Probed uint64
+	Open   uint64
+	Total  uint64
+}
+
+// collectPorts reads the ports reported until the scan finishes on its own, indexed by host, port and protocol
+func collectPorts(t *testing.T, connection *websocket.Conn) (map[string]string, portScanProgress) {
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	found := map[string]string{}
+	for {
+		var event struct {
+			Type    string
+			Payload json.RawMessage
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		switch event.Type {
+		case symbols.PortResponse:
+			var port struct {
+				Host     string
+				Port     uint16
+				Protocol string
+				State    string
+			}
+			_ = json.Unmarshal(event.Payload, &port)
+			found[net.JoinHostPort(port.Host, strconv.Itoa(int(port.Port)))+"/"+port.Protocol] = port.State
+		case symbols.ErrorResponse:
+			t.Fatal(string(event.Payload))
+		case symbols.FinishedResponse:
+			var progress portScanProgress
+			_ = json.Unmarshal(event.Payload, &progress)
+			// The scan is saved before the connection is closed
+			var status struct {
+				Succeed bool
+			}
+			readError = connection.ReadJSON(&status)
+			if readError != nil || !status.Succeed {
+				t.Fatal(readError, status)
+			}
+			return found, progress
+		}
+	}
+}
+
+// closedPort returns a port of the protocol nobody listens on
+func closedPort(t *testing.T, network string) int {
+	if network == "udp" {
+		listener, listenError := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if listenError != nil {
+			t.Fatal(listenError)
+		}
+		defer listener.Close()
+		return listener.LocalAddr().(*net.UDPAddr).Port
+	}
+	listener, listenError := net.Listen("tcp", "127.0.0.1:0")
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestNewPortScanConnect(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	listener, listenError := net.Listen("tcp", "127.0.0.1:0")
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+	defer listener.Close()
+	openPort := listener.Addr().(*net.TCPAddr).Port
+	closed := closedPort(t, "tcp")
+	cookies := loginAsAdmin(t, server.URL, client)
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName":      "Connect",
+		"InterfaceName": "lo",
+		"Mode":          "connect",
+		"Targets":       "127.0.0.1",
+		"Ports":         strconv.Itoa(openPort) + ", " + strconv.Itoa(closed),
+		"Timeout":       500,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	found, progress := collectPorts(t, connection)
+	openKey := "127.0.0.1:" + strconv.Itoa(openPort) + "/tcp"
+	// Closed ports only count in the progress
+	if len(found) != 1 || found[openKey] != "open" || progress.Probed != 2 || progress.Open != 1 || progress.Total != 2 {
+		t.Fatal(found, progress)
+	}
+	if body := getPage(t, client, cookies, server.URL+symbols.UserPortScan+"?action="+actions.List); !strings.Contains(body, "Connect") {
+		t.Fatal(body)
+	}
+	// The name of a saved scan can not be used again
+	connection, message = startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName":      "Connect",
+		"InterfaceName": "lo",
+		"Targets":       "127.0.0.1",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("scan name reused")
+	}
+	if message != "Scan name already taken" {
+		t.Fatal(message)
+	}
+}
+
+func TestNewPortScanUDP(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	listener, listenError := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+	defer listener.Close()
+	go func() {
+		buffer := make([]byte, 1500)
+		for {
+			_, address, readError := listener.ReadFromUDP(buffer)
+			if readError != nil {
+				return
+			}
+			_, _ = listener.WriteToUDP([]byte("answer"), address)
+		}
+	}()
+	openPort := listener.LocalAddr().(*net.UDPAddr).Port
+	closed := closedPort(t, "udp")
+	cookies := loginAsAdmin(t, server.URL, client)
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName":      "UDP",
+		"InterfaceName": "lo",
+		"Mode":          "udp",
+		"Targets":       "127.0.0.1",
+		"Ports":         strconv.Itoa(openPort) + " " + strconv.Itoa(closed),
+		"Timeout":       500,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	found, progress := collectPorts(t, connection)
+	if len(found) != 1 || found["127.0.0.1:"+strconv.Itoa(openPort)+"/udp"] != "open" || progress.Probed != 2 || progress.Open != 1 {
+		t.Fatal(found, progress)
+	}
+}
+
+func TestNewPortScanSYN(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName": "SYN",
+		"Mode":     "syn",
+		"Targets":  "192.0.2.10-11",
+		"Ports":    "22, 80-81",
+		"Retries":  0,
+		"Timeout":  100,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	// The hosts of the lab network answer ARP but not TCP, every port is filtered
+	found, progress := collectPorts(t, connection)
+	if len(found) != 0 || progress.Probed != 6 || progress.Total != 6 || progress.Open != 0 {
+		t.Fatal(found, progress)
+	}
+}
+
+func TestNewPortScanFromARPScan(t *testing.T) {
+	requireTestInterface(t)
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	arpConnection, message := startTargetsScan(t, server.URL, client, map[string]interface{}{
+		"ScanName": "Hosts",
+		"Targets":  "192.0.2.10-12",
+	})
+	if arpConnection == nil {
+		t.Fatal(message)
+	}
+	collectHosts(t, arpConnection)
+	_ = arpConnection.Close()
+	cookies := loginAsAdmin(t, server.URL, client)
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName": "Seeded",
+		"Mode":     "syn",
+		"Targets":  "192.0.2.20",
+		"ARPScan":  "Hosts",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("targets and ARP scan accepted")
+	}
+	if message != "provide either a targets list or an ARP scan" {
+		t.Fatal(message)
+	}
+	connection, message = startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName": "Seeded",
+		"Mode":     "syn",
+		"ARPScan":  "Missing",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("unknown ARP scan accepted")
+	}
+	if message != "ARP scan not found" {
+		t.Fatal(message)
+	}
+	connection, message = startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName": "Seeded",
+		"Mode":     "syn",
+		"ARPScan":  "Hosts",
+		"Ports":    "443",
+		"Retries":  0,
+		"Timeout":  100,
+	})
+	if connection == nil {
+		t.Fatal(message)
+	}
+	defer connection.Close()
+	_, progress := collectPorts(t, connection)
+	if progress.Total != 3 || progress.Probed != 3 {
+		t.Fatal(progress)
+	}
+	// The hosts of the ARP scan are kept as the targets of the port scan
+	form := url.Values{symbols.ScanName: []string{"Seeded"}}
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.UserPortScan+"?action="+actions.View, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "192.0.2.10, 192.0.2.11, 192.0.2.12") {
+		t.Fatal(string(body))
+	}
+}
+
+func TestNewPortScanWithInvalidArguments(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	for _, testCase := range []struct {
+		configuration map[string]interface{}
+		message       string
+	}{
+		{map[string]interface{}{"Targets": "192.0.2.10"}, "no scan name provided"},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "192.0.2.10", "Ports": "0"}, "invalid port \"0\", ports must be between 1 and 65535"},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "192.0.2.10", "Ports": "80-20"}, "invalid port range \"80-20\""},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "192.0.2.10", "Mode": "xmas"}, "unknown scan mode \"xmas\""},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "192.0.2.0/24", "Ports": "1-65535"}, "the targets and ports expand to more than 1048576 probes"},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "2001:db8::2", "Mode": "syn"}, "only IPv4 hosts can be scanned in SYN mode, use connect for IPv6 hosts"},
+		{map[string]interface{}{"ScanName": "Invalid", "Targets": "198.51.100.1", "Mode": "syn"}, "198.51.100.1 is outside the subnet of the interface, provide the gateway to reach it"},
+		{map[string]interface{}{"ScanName": "Invalid", "InterfaceName": "missing", "Targets": "192.0.2.10"}, "No port scan permissions for selected interface"},
+	} {
+		connection, message := startPortScan(t, server.URL, client, cookies, testCase.configuration)
+		if connection != nil {
+			_ = connection.Close()
+			t.Fatal(testCase.configuration)
+		}
+		if message != testCase.message {
+			t.Fatal(message)
+		}
+	}
+	// Without the permission the interface can not be scanned
+	form := url.Values{
+		symbols.Username:  []string{"admin"},
+		symbols.Interface: []string{"lo"},
+	}
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.AdminEditUsers+"?action="+actions.DeletePortScanInterface, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var deleted struct {
+		Succeed bool
+	}
+	decodeError := json.NewDecoder(response.Body).Decode(&deleted)
+	if decodeError != nil || !deleted.Succeed {
+		t.Fatal(decodeError, deleted)
+	}
+	connection, message := startPortScan(t, server.URL, client, cookies, map[string]interface{}{
+		"ScanName":      "Denied",
+		"InterfaceName": "lo",
+		"Targets":       "127.0.0.1",
+	})
+	if connection != nil {
+		_ = connection.Close()
+		t.Fatal("scan without permission accepted")
+	}
+	if message != "No port scan permissions for selected interface" {
+		t.Fatal(message)
+	}
+}
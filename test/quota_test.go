@@ -0,0 +1,348 @@
+package test
+
+import (
+	"bytes"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetUserStorageQuota(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	cookies := response.Cookies()
+	// Set the quota
+	data := url.Values{
+		symbols.Username:     []string{"admin"},
+		symbols.MaxMegabytes: []string{"10"},
+		symbols.MaxCaptures:  []string{"5"},
+	}
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.AdminStorageQuotas+"?action="+actions.SetUserQuota, strings.NewReader(data.Encode()))
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Check it is shown in the settings page
+	request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.Settings, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !regexp.MustCompile("0 B of 10.0 MiB").Match(body) || !regexp.MustCompile("0 of 5 captures").Match(body) {
+		t.Fatal(string(body))
+	}
+	// Check it is listed in the admin page
+	request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.AdminStorageQuotas, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError = io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !regexp.MustCompile("Custom").Match(body) {
+		t.Fatal(string(body))
+	}
+}
+
+func TestNewCaptureExceedingQuota(t *testing.T) {
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Only allow one capture
+	data := url.Values{
+		symbols.Username:     []string{"admin"},
+		symbols.MaxMegabytes: []string{"0"},
+		symbols.MaxCaptures:  []string{"1"},
+	}
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.AdminStorageQuotas+"?action="+actions.SetUserQuota, strings.NewReader(data.Encode()))
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	// Get capture interface
+	request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.UserPacketCaptures+"?action="+actions.New, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatal(response)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceEntries := regexp.MustCompile("<a id=\".+\" onclick=\"selectCaptureInterface\\(this\\.id\\)\"").FindAll(body, -1)
+	if len(interfaceEntries) == 0 {
+		t.Fatal(string(body))
+	}
+	preInterfaceName := regexp.MustCompile("id\\=\\S+").Find(interfaceEntries[0])
+	if len(preInterfaceName) <= 4 {
+		t.Fatal(string(body))
+	}
+	interfaceName := string(preInterfaceName[4 : len(preInterfaceName)-1])
+	// Start a new capture
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserPacketCaptures + "?action=" + actions.Start
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError := connection.WriteJSON(
+		struct {
+			Promiscuous   bool
+			Script        string
+			Description   string
+			CaptureName   string
+			InterfaceName string
+		}{
+			Promiscuous:   true,
+			Script:        ``,
+			Description:   "My description",
+			CaptureName:   "First",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed || status.Message != "Everything ok!" {
+		t.Fatal(status.Message)
+	}
+	writeError = connection.WriteJSON(struct {
+		Action string
+	}{
+		Action: "STOP",
+	})
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	time.Sleep(2 * time.Second)
+	closeError := connection.Close()
+	if closeError != nil {
+		t.Fatal(closeError)
+	}
+	// The second capture should be rejected
+	connection, _, dialError = websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError = connection.WriteJSON(
+		struct {
+			Promiscuous   bool
+			Script        string
+			Description   string
+			CaptureName   string
+			InterfaceName string
+		}{
+			Promiscuous:   true,
+			Script:        ``,
+			Description:   "My description",
+			CaptureName:   "Second",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if status.Succeed || status.Message != "Storage quota exceeded" {
+		t.Fatal(status.Message)
+	}
+}
+
+// importSmallCapture posts a pcap with a single UDP packet to the import form, returning the response of the server
+func importSmallCapture(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, captureName string) *http.Response {
+	var pcap bytes.Buffer
+	pcapWriter := pcapgo.NewWriter(&pcap)
+	writeError := pcapWriter.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IPv4(192, 0, 2, 10).To4(), DstIP: net.IPv4(192, 0, 2, 20).To4()}
+	udp := &layers.UDP{SrcPort: 40000, DstPort: 53}
+	_ = udp.SetNetworkLayerForChecksum(ip)
+	packet := gopacket.NewSerializeBuffer()
+	_ = gopacket.SerializeLayers(packet, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0x1b, 0x21, 0, 0, 0x0a}, DstMAC: net.HardwareAddr{0, 0x1b, 0x21, 0, 0, 0x14}, EthernetType: layers.EthernetTypeIPv4},
+		ip, udp, gopacket.Payload("payload"),
+	)
+	writeError = pcapWriter.WritePacket(gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(packet.Bytes()), Length: len(packet.Bytes())}, packet.Bytes())
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	_ = formWriter.WriteField(symbols.CaptureName, captureName)
+	_ = formWriter.WriteField(symbols.Description, "Imported")
+	fileWriter, _ := formWriter.CreateFormFile(symbols.File, "capture.pcap")
+	_, _ = fileWriter.Write(pcap.Bytes())
+	_ = formWriter.Close()
+	request, _ := http.NewRequest(http.MethodPost, server+symbols.UserPacketCaptures+"?action="+actions.Import, &form)
+	request.Header.Set("Content-Type", formWriter.FormDataContentType())
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError := client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	return response
+}
+
+func TestImportCaptureExceedingQuota(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	cookies := response.Cookies()
+	// Only allow one capture
+	data := url.Values{
+		symbols.Username:     []string{"admin"},
+		symbols.MaxMegabytes: []string{"0"},
+		symbols.MaxCaptures:  []string{"1"},
+	}
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.AdminStorageQuotas+"?action="+actions.SetUserQuota, strings.NewReader(data.Encode()))
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	response = importSmallCapture(t, server.URL, client, cookies, "First")
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.Settings, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !strings.Contains(string(body), "1 of 1 captures") {
+		t.Fatal(string(body))
+	}
+	// The second import is rejected in the page
+	response = importSmallCapture(t, server.URL, client, cookies, "Second")
+	body, readError = io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(body), `id="error-message">Captures limit of 1 reached`) {
+		t.Fatal(response.StatusCode, string(body))
+	}
+}
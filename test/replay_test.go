@@ -0,0 +1,252 @@
+package test
+
+import (
+	"bytes"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// udpCapture returns a pcap with count UDP packets sent 10 milliseconds apart
+func udpCapture(t *testing.T, count int) []byte {
+	var output bytes.Buffer
+	writer := pcapgo.NewWriter(&output)
+	writeError := writer.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	start := time.Now()
+	for index := 0; index < count; index++ {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: layers.IPProtocolUDP,
+			SrcIP:    net.IPv4(192, 0, 2, 10).To4(),
+			DstIP:    net.IPv4(192, 0, 2, 20).To4(),
+		}
+		udp := &layers.UDP{
+			SrcPort: 40000,
+			DstPort: 9999,
+		}
+		_ = udp.SetNetworkLayerForChecksum(ip)
+		buffer := gopacket.NewSerializeBuffer()
+		serializeError := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+			&layers.Ethernet{
+				SrcMAC:       net.HardwareAddr{0x02, 0x00, 0xc0, 0x00, 0x02, 0x0a},
+				DstMAC:       net.HardwareAddr{0x02, 0x00, 0xc0, 0x00, 0x02, 0x14},
+				EthernetType: layers.EthernetTypeIPv4,
+			},
+			ip,
+			udp,
+			gopacket.Payload("replay"),
+		)
+		if serializeError != nil {
+			t.Fatal(serializeError)
+		}
+		writeError = writer.WritePacket(
+			gopacket.CaptureInfo{
+				Timestamp:     start.Add(time.Duration(index) * 10 * time.Millisecond),
+				CaptureLength: len(buffer.Bytes()),
+				Length:        len(buffer.Bytes()),
+			},
+			buffer.Bytes(),
+		)
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+	}
+	return output.Bytes()
+}
+
+func TestReplayCapture(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Import the capture to replay
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	_ = formWriter.WriteField(symbols.CaptureName, "replayed")
+	_ = formWriter.WriteField(symbols.Description, "Capture to replay")
+	fileWriter, _ := formWriter.CreateFormFile(symbols.File, "capture.pcap")
+	_, _ = fileWriter.Write(udpCapture(t, 3))
+	_ = formWriter.Close()
+	request, _ := http.NewRequest(http.MethodPost, server.URL+symbols.UserPacketCaptures+"?action="+actions.Import, &form)
+	request.Header.Set("Content-Type", formWriter.FormDataContentType())
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.Header.Get("Location") != symbols.UserPacketCaptures {
+		t.Fatal(response.Header.Get("Location"))
+	}
+	// Get replay interface
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.UserPacketCaptures+"?action="+actions.Replay, strings.NewReader(url.Values{symbols.CaptureName: []string{"replayed"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserPacketCaptures+"?action="+actions.StartReplay, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		map[string]interface{}{
+			"CaptureName":   "replayed",
+			"InterfaceName": interfaceName,
+			"Speed":         2,
+			"Loops":         2,
+			"IPRewrites":    []map[string]string{{"From": "192.0.2.20", "To": "192.0.2.30"}},
+			"MACRewrites":   []map[string]string{{"From": "02:00:c0:00:02:14", "To": "02:00:c0:00:02:1e"}},
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var lastProgress struct {
+		Sent  uint64
+		Total uint64
+		Loop  uint64
+	}
+	for {
+		var event struct {
+			Type    string
+			Payload struct {
+				Succeed bool
+				Message string
+				Sent    uint64
+				Total   uint64
+				Loop    uint64
+			}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		switch event.Type {
+		case symbols.ProgressResponse:
+			lastProgress.Sent, lastProgress.Total, lastProgress.Loop = event.Payload.Sent, event.Payload.Total, event.Payload.Loop
+		case symbols.FinishedResponse:
+			if !event.Payload.Succeed {
+				t.Fatal(event.Payload.Message)
+			}
+			if lastProgress.Sent != 6 || lastProgress.Total != 6 || lastProgress.Loop != 2 {
+				t.Fatal(lastProgress)
+			}
+			return
+		}
+	}
+}
+
+func TestReplayMissingCapture(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var cookiesHeader string
+	for _, cookie := range response.Cookies() {
+		cookiesHeader += cookie.String()
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	connection, _, dialError := websocket.DefaultDialer.Dial("ws://"+hostUrl.Host+symbols.UserPacketCaptures+"?action="+actions.StartReplay, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		map[string]interface{}{
+			"CaptureName":   "missing",
+			"InterfaceName": "lo",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError := connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if status.Succeed || status.Message != "Capture not found" {
+		t.Fatal(status)
+	}
+}
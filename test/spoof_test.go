@@ -5,13 +5,128 @@ import (
 	"github.com/shoriwe/CAPitan/internal/web/symbols"
 	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidInput(t *testing.T) {
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	location, err := response.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != symbols.Dashboard {
+		t.Fatal(location.Path)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatal(response)
+	}
+	//// Get any interface able to send ARP frames
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new arp spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+		}{
+			TargetIP:      "198.51.100.10",
+			Gateway:       "198.51.100.1",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	// Check if everything is ok
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed || status.Message != "Everything ok!" {
+		t.Fatal(status.Message)
+	}
+	// Nothing owns the documentation addresses, so the session ends without poisoning once the gateway does not
+	// answer
+	_ = connection.SetReadDeadline(time.Now().Add(30 * time.Second))
+	var event struct {
+		Type    string
+		Payload string
+	}
+	readError = connection.ReadJSON(&event)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if event.Type != symbols.ErrorResponse || event.Payload != "could not resolve the MAC address of 198.51.100.1" {
+		t.Fatal(event)
+	}
+	// Finish it
+	closeError := connection.Close()
+	if closeError != nil {
+		t.Fatal(closeError)
+	}
+}
+
+func TestInvalidTargetIP(t *testing.T) {
 	// Login
 	server := NewTestServer()
 	defer server.Close()
@@ -86,7 +201,7 @@ func TestValidInput(t *testing.T) {
 			Gateway       string
 			InterfaceName string
 		}{
-			TargetIP:      "192.168.1.1",
+			TargetIP:      "192.168.1.aaaa",
 			Gateway:       "192.168.1.1",
 			InterfaceName: interfaceName,
 		},
@@ -103,7 +218,7 @@ func TestValidInput(t *testing.T) {
 	if readError != nil {
 		t.Fatal(readError)
 	}
-	if !status.Succeed || status.Message != "Everything ok!" {
+	if status.Succeed || status.Message != "Invalid IP provided" {
 		t.Fatal(status.Message)
 	}
 	// Finish it
@@ -113,7 +228,7 @@ func TestValidInput(t *testing.T) {
 	}
 }
 
-func TestInvalidTargetIP(t *testing.T) {
+func TestInvalidGateway(t *testing.T) {
 	// Login
 	server := NewTestServer()
 	defer server.Close()
@@ -188,9 +303,113 @@ func TestInvalidTargetIP(t *testing.T) {
 			Gateway       string
 			InterfaceName string
 		}{
-			TargetIP:      "192.168.1.aaaa",
+			TargetIP:      "192.168.1.1",
+			Gateway:       "invalid",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	// Check if everything is ok
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if status.Succeed || status.Message != "Invalid Gateway IP provided" {
+		t.Fatal(status.Message)
+	}
+	// Finish it
+	closeError := connection.Close()
+	if closeError != nil {
+		t.Fatal(closeError)
+	}
+}
+
+func TestInvalidMode(t *testing.T) {
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	location, err := response.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != symbols.Dashboard {
+		t.Fatal(location.Path)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatal(response)
+	}
+	//// Get any interface
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceEntries := regexp.MustCompile("<a id=\".+\" onclick=\"selectARPSpoofInterface\\(this\\.id\\)\"").FindAll(body, -1)
+	if len(interfaceEntries) == 0 {
+		t.Fatal(string(body))
+	}
+	preInterfaceName := regexp.MustCompile("id\\=\\S+").Find(interfaceEntries[0])
+	if len(preInterfaceName) <= 4 {
+		t.Fatal(string(body))
+	}
+	interfaceName := string(preInterfaceName[4 : len(preInterfaceName)-1])
+	// Start a new arp spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+			Mode          string
+		}{
+			TargetIP:      "192.168.1.1",
 			Gateway:       "192.168.1.1",
 			InterfaceName: interfaceName,
+			Mode:          "invalid",
 		},
 	)
 	if writeError != nil {
@@ -205,7 +424,7 @@ func TestInvalidTargetIP(t *testing.T) {
 	if readError != nil {
 		t.Fatal(readError)
 	}
-	if status.Succeed || status.Message != "Invalid IP provided" {
+	if status.Succeed || status.Message != "Invalid poisoning mode provided" {
 		t.Fatal(status.Message)
 	}
 	// Finish it
@@ -215,7 +434,7 @@ func TestInvalidTargetIP(t *testing.T) {
 	}
 }
 
-func TestInvalidGateway(t *testing.T) {
+func TestInvalidInterval(t *testing.T) {
 	// Login
 	server := NewTestServer()
 	defer server.Close()
@@ -289,10 +508,12 @@ func TestInvalidGateway(t *testing.T) {
 			TargetIP      string
 			Gateway       string
 			InterfaceName string
+			Interval      uint
 		}{
 			TargetIP:      "192.168.1.1",
-			Gateway:       "invalid",
+			Gateway:       "192.168.1.1",
 			InterfaceName: interfaceName,
+			Interval:      10,
 		},
 	)
 	if writeError != nil {
@@ -307,7 +528,7 @@ func TestInvalidGateway(t *testing.T) {
 	if readError != nil {
 		t.Fatal(readError)
 	}
-	if status.Succeed || status.Message != "Invalid Gateway IP provided" {
+	if status.Succeed || status.Message != "Interval must be between 100 and 60000 milliseconds" {
 		t.Fatal(status.Message)
 	}
 	// Finish it
@@ -418,3 +639,670 @@ func TestInvalidInterface(t *testing.T) {
 		t.Fatal(closeError)
 	}
 }
+
+// findEthernetInterface returns the interface of the lab network when configured, otherwise the first interface of the
+// menu able to send ARP and NDP frames, loopback interfaces have no hardware address
+func findEthernetInterface(body []byte) string {
+	interfaceEntries := regexp.MustCompile("<a id=\"(.+)\" onclick=\"select\\w+Interface\\(this\\.id\\)\"").FindAllSubmatch(body, -1)
+	if testInterface := os.Getenv(TestInterface); len(testInterface) > 0 {
+		for _, entry := range interfaceEntries {
+			if string(entry[1]) == testInterface {
+				return testInterface
+			}
+		}
+	}
+	for _, entry := range interfaceEntries {
+		i, findError := net.InterfaceByName(string(entry[1]))
+		if findError == nil && len(i.HardwareAddr) > 0 {
+			return i.Name
+		}
+	}
+	return ""
+}
+
+func TestARPSpoofSessionHistory(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new arp spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+		}{
+			TargetIP:      "192.168.1.10",
+			Gateway:       "192.168.1.1",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	// Stop it
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+		}{
+			Action: "STOP",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	// The session should appear in the user history and in the admin list
+	for _, target := range []string{symbols.UserARPSpoof + "?action=" + actions.List, symbols.AdminARPSpoofs} {
+		var page string
+		for try := 0; try < 50; try++ {
+			request, _ = http.NewRequest(http.MethodGet, server.URL+target, nil)
+			for _, cookie := range cookies {
+				request.AddCookie(cookie)
+			}
+			response, requestError = client.Do(request)
+			if requestError != nil {
+				t.Fatal(requestError)
+			}
+			body, readError = io.ReadAll(response.Body)
+			if readError != nil {
+				t.Fatal(readError)
+			}
+			page = string(body)
+			if strings.Contains(page, "192.168.1.10") {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if !strings.Contains(page, "192.168.1.10") || !strings.Contains(page, "Stopped by user") {
+			t.Fatal(page)
+		}
+	}
+}
+
+func TestARPSpoofRestoresCaches(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new arp spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+		}{
+			TargetIP:      "192.168.1.10",
+			Gateway:       "192.168.1.1",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	// Stop it
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+		}{
+			Action: "STOP",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	// The server restores the caches of both victims before closing
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload struct {
+				Succeed bool
+				Message string
+			}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.RestoreResponse {
+			if !event.Payload.Succeed {
+				t.Fatal(event.Payload.Message)
+			}
+			break
+		}
+	}
+}
+
+func TestMultiTargetARPSpoof(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new arp spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+		}{
+			TargetIP:      "192.168.1.8/30, 192.168.1.9",
+			Gateway:       "192.168.1.1",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	readTargets := func() []string {
+		var event struct {
+			Type    string
+			Payload struct {
+				Succeed bool
+				Message string
+				Targets []string
+			}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type != symbols.TargetsResponse || !event.Payload.Succeed {
+			t.Fatal(event)
+		}
+		return event.Payload.Targets
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	// Both hosts of the network are poisoned, without duplicates
+	targets := readTargets()
+	if strings.Join(targets, ",") != "192.168.1.10,192.168.1.9" {
+		t.Fatal(targets)
+	}
+	// Add a new target while the session runs
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+			Target string
+		}{
+			Action: symbols.AddTargetSignal,
+			Target: "192.168.1.20",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	targets = readTargets()
+	if strings.Join(targets, ",") != "192.168.1.10,192.168.1.20,192.168.1.9" {
+		t.Fatal(targets)
+	}
+	// Remove one of the initial ones
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+			Target string
+		}{
+			Action: symbols.RemoveTargetSignal,
+			Target: "192.168.1.9",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	targets = readTargets()
+	if strings.Join(targets, ",") != "192.168.1.10,192.168.1.20" {
+		t.Fatal(targets)
+	}
+}
+
+func TestIPv6NeighborSpoof(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	// Start a new neighbor discovery spoof
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			TargetIP      string
+			Gateway       string
+			InterfaceName string
+		}{
+			TargetIP:      "2001:db8::10, 2001:db8::20",
+			Gateway:       "2001:db8::1",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var event struct {
+		Type    string
+		Payload struct {
+			Succeed bool
+			Message string
+			Targets []string
+		}
+	}
+	readError = connection.ReadJSON(&event)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if event.Type != symbols.TargetsResponse || strings.Join(event.Payload.Targets, ",") != "2001:db8::10,2001:db8::20" {
+		t.Fatal(event)
+	}
+	// IPv4 targets can not be mixed with an IPv6 gateway
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+			Target string
+		}{
+			Action: symbols.AddTargetSignal,
+			Target: "192.168.1.20",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	readError = connection.ReadJSON(&event)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if event.Type != symbols.TargetsResponse || event.Payload.Succeed {
+		t.Fatal(event)
+	}
+}
+
+func TestARPSpoofWithDNSRules(t *testing.T) {
+	requireTestInterface(t)
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get arp spoof interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserARPSpoof, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceName := findEthernetInterface(body)
+	if len(interfaceName) == 0 {
+		t.Fatal(string(body))
+	}
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserARPSpoof + "?action=" + actions.Spoof
+	type dnsRule struct {
+		Kind    string
+		Pattern string
+		Address string
+	}
+	type configuration struct {
+		TargetIP      string
+		Gateway       string
+		InterfaceName string
+		DNSRules      []dnsRule
+		DNSScript     string
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	// Invalid rules and scripts are refused before spoofing
+	for _, invalid := range []configuration{
+		{DNSRules: []dnsRule{{Kind: "exact", Pattern: "example.com", Address: "not an address"}}},
+		{DNSRules: []dnsRule{{Kind: "unknown", Pattern: "example.com", Address: "10.0.0.1"}}},
+		{DNSRules: []dnsRule{{Kind: "regex", Pattern: "(", Address: "10.0.0.1"}}},
+		{DNSScript: "1 +"},
+		{DNSScript: "a = 1"},
+	} {
+		invalid.TargetIP = "192.168.1.10"
+		invalid.Gateway = "192.168.1.1"
+		invalid.InterfaceName = interfaceName
+		connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+		if dialError != nil {
+			t.Fatal(dialError)
+		}
+		writeError := connection.WriteJSON(invalid)
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+		readError = connection.ReadJSON(&status)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		_ = connection.Close()
+		if status.Succeed {
+			t.Fatal(invalid)
+		}
+	}
+	// Valid rules and resolver start the spoof with the forwarder
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		configuration{
+			TargetIP:      "192.168.1.10",
+			Gateway:       "192.168.1.1",
+			InterfaceName: interfaceName,
+			DNSRules: []dnsRule{
+				{Kind: "exact", Pattern: "example.com", Address: "10.0.0.1"},
+				{Kind: "wildcard", Pattern: "*.example.org", Address: "10.0.0.2"},
+			},
+			DNSScript: `def resolve(name, type)
+    if name == "example.net"
+        return "10.0.0.3"
+    end
+    return None
+end
+LoadDNSResolver(resolve)`,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	writeError = connection.WriteJSON(
+		struct {
+			Action string
+		}{
+			Action: "STOP",
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload interface{}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.ErrorResponse {
+			t.Fatal(event.Payload)
+		}
+		if event.Type == symbols.RestoreResponse {
+			break
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKillActiveCapture(t *testing.T) {
+	// Login
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, requestError := client.PostForm(
+		server.URL+symbols.Login,
+		url.Values{
+			"username": []string{"admin"},
+			"password": []string{"admin"},
+		},
+	)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	// Prepare cookies
+	cookies := response.Cookies()
+	var cookiesHeader string
+	for _, cookie := range cookies {
+		cookiesHeader += cookie.String()
+	}
+	// Get capture interface
+	request, _ := http.NewRequest(http.MethodGet, server.URL+symbols.UserPacketCaptures+"?action="+actions.New, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	body, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	interfaceEntries := regexp.MustCompile("<a id=\".+\" onclick=\"selectCaptureInterface\\(this\\.id\\)\"").FindAll(body, -1)
+	if len(interfaceEntries) == 0 {
+		t.Fatal(string(body))
+	}
+	preInterfaceName := regexp.MustCompile("id\\=\\S+").Find(interfaceEntries[0])
+	if len(preInterfaceName) <= 4 {
+		t.Fatal(string(body))
+	}
+	interfaceName := string(preInterfaceName[4 : len(preInterfaceName)-1])
+	// Start a new capture
+	header := http.Header{}
+	header.Set("Cookie", cookiesHeader)
+	hostUrl, _ := url.Parse(server.URL)
+	websocketURL := "ws://" + hostUrl.Host + symbols.UserPacketCaptures + "?action=" + actions.Start
+	connection, _, dialError := websocket.DefaultDialer.Dial(websocketURL, header)
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+	defer connection.Close()
+	writeError := connection.WriteJSON(
+		struct {
+			Promiscuous   bool
+			Script        string
+			Description   string
+			CaptureName   string
+			InterfaceName string
+		}{
+			Promiscuous:   true,
+			Script:        ``,
+			Description:   "My description",
+			CaptureName:   "Test",
+			InterfaceName: interfaceName,
+		},
+	)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	var status struct {
+		Succeed bool
+		Message string
+	}
+	readError = connection.ReadJSON(&status)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if !status.Succeed {
+		t.Fatal(status.Message)
+	}
+	// List the active tasks
+	request, _ = http.NewRequest(http.MethodGet, server.URL+symbols.AdminActiveTasks+"?action="+actions.List, nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var activeTasks []struct {
+		Id       string
+		Kind     string
+		Username string
+		Name     string
+	}
+	decodeError := json.NewDecoder(response.Body).Decode(&activeTasks)
+	if decodeError != nil {
+		t.Fatal(decodeError)
+	}
+	if len(activeTasks) != 1 || activeTasks[0].Name != "Test" || activeTasks[0].Username != "admin" {
+		t.Fatal(activeTasks)
+	}
+	// Kill it
+	data := url.Values{
+		symbols.Task:   []string{activeTasks[0].Id},
+		symbols.Reason: []string{"Maintenance"},
+	}
+	request, _ = http.NewRequest(http.MethodPost, server.URL+symbols.AdminActiveTasks+"?action="+actions.Kill, strings.NewReader(data.Encode()))
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, requestError = client.Do(request)
+	if requestError != nil {
+		t.Fatal(requestError)
+	}
+	var killResponse struct {
+		Succeed bool
+	}
+	decodeError = json.NewDecoder(response.Body).Decode(&killResponse)
+	if decodeError != nil {
+		t.Fatal(decodeError)
+	}
+	if !killResponse.Succeed {
+		t.Fatal("failed to kill the capture")
+	}
+	// The owner should be notified
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload interface{}
+		}
+		readError = connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.KilledResponse {
+			if event.Payload != "Maintenance" {
+				t.Fatal(event.Payload)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/shoriwe/CAPitan/internal/web/symbols"
+	"github.com/shoriwe/CAPitan/internal/web/symbols/actions"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createUser creates an enabled user allowed to capture on the interfaces and returns its session cookies
+func createUser(t *testing.T, server string, client *http.Client, cookies []*http.Cookie, username string, captureInterfaces ...string) []*http.Cookie {
+	postForm(t, client, cookies, server+symbols.AdminEditUsers+"?action="+actions.New, url.Values{symbols.Username: {username}})
+	postForm(t, client, cookies, server+symbols.AdminEditUsers+"?action="+actions.UpdatePassword, url.Values{symbols.Username: {username}, symbols.Password: {"password"}})
+	postForm(t, client, cookies, server+symbols.AdminEditUsers+"?action="+actions.UpdateStatus, url.Values{symbols.Username: {username}, symbols.IsAdmin: {""}, symbols.IsEnabled: {"on"}})
+	for _, interfaceName := range captureInterfaces {
+		postForm(t, client, cookies, server+symbols.AdminEditUsers+"?action="+actions.AddCaptureInterface, url.Values{symbols.Username: {username}, symbols.Interface: {interfaceName}})
+	}
+	response := postForm(t, client, nil, server+symbols.Login, url.Values{"username": {username}, "password": {"password"}})
+	if response.StatusCode != http.StatusFound {
+		t.Fatal(response)
+	}
+	return response.Cookies()
+}
+
+// readWatchers returns the next users watching the capture the owner is notified about
+func readWatchers(t *testing.T, connection *websocket.Conn) []string {
+	_ = connection.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Type    string
+			Payload []string
+		}
+		readError := connection.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if event.Type == symbols.WatchersResponse {
+			return event.Payload
+		}
+	}
+}
+
+func TestWatchRunningCapture(t *testing.T) {
+	server := NewTestServer()
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cookies := loginAsAdmin(t, server.URL, client)
+	interfaceName := findEthernetInterface([]byte(getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures+"?action="+actions.New)))
+	watcherCookies := createUser(t, server.URL, client, cookies, "watcher", interfaceName)
+	outsiderCookies := createUser(t, server.URL, client, cookies, "outsider")
+
+	owner, succeed, message := dialCapture(t, server.URL, cookies, actions.Start, map[string]interface{}{
+		"Description":   "Watched by the team",
+		"CaptureName":   "Shared",
+		"InterfaceName": interfaceName,
+	})
+	if !succeed {
+		t.Fatal(message)
+	}
+	defer owner.Close()
+
+	// Only the users allowed to capture on the interface see the capture
+	if page := getPage(t, client, watcherCookies, server.URL+symbols.UserPacketCaptures); !strings.Contains(page, `id="shared-captures"`) || !strings.Contains(page, "Shared") {
+		t.Fatal(page)
+	}
+	if page := getPage(t, client, outsiderCookies, server.URL+symbols.UserPacketCaptures); strings.Contains(page, `id="shared-captures"`) {
+		t.Fatal(page)
+	}
+	_, succeed, _ = dialCapture(t, server.URL, outsiderCookies, actions.Attach, map[string]string{"CaptureName": "Shared", "Owner": "admin"})
+	if succeed {
+		t.Fatal("attached without permission")
+	}
+	// A user with the same capture name does not see the one of the owner
+	_, succeed, _ = dialCapture(t, server.URL, watcherCookies, actions.Attach, map[string]string{"CaptureName": "Shared"})
+	if succeed {
+		t.Fatal("attached to the capture of another user as its owner")
+	}
+
+	watcher, succeed, message := dialCapture(t, server.URL, watcherCookies, actions.Attach, map[string]string{"CaptureName": "Shared", "Owner": "admin"})
+	if !succeed {
+		t.Fatal(message)
+	}
+	if watchers := readWatchers(t, owner); !reflect.DeepEqual(watchers, []string{"watcher"}) {
+		t.Fatal(watchers)
+	}
+	// Watchers can not stop the capture
+	writeError := watcher.WriteJSON(map[string]string{"Action": symbols.StopSignal})
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	time.Sleep(time.Second)
+	if page := getPage(t, client, cookies, server.URL+symbols.UserPacketCaptures); !strings.Contains(page, `id="running-captures"`) {
+		t.Fatal(page)
+	}
+	_ = watcher.Close()
+	if watchers := readWatchers(t, owner); len(watchers) != 0 {
+		t.Fatal(watchers)
+	}
+
+	// Once the owner stops it every watcher is told it was stored
+	watcher, succeed, message = dialCapture(t, server.URL, watcherCookies, actions.Attach, map[string]string{"CaptureName": "Shared", "Owner": "admin"})
+	if !succeed {
+		t.Fatal(message)
+	}
+	defer watcher.Close()
+	readWatchers(t, owner)
+	writeError = owner.WriteJSON(map[string]string{"Action": symbols.StopSignal})
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+	_ = watcher.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event map[string]interface{}
+		readError := watcher.ReadJSON(&event)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if eventType, isEvent := event["Type"]; isEvent {
+			if eventType == symbols.WatchersResponse {
+				t.Fatal("watchers notified to a watcher")
+			}
+			continue
+		}
+		if event["Succeed"] != true {
+			t.Fatal(event)
+		}
+		break
+	}
+}